@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// AmountInputMode is how an integration expresses amounts in create requests.
+type AmountInputMode string
+
+const (
+	// AmountInputMinorUnits expects a JSON integer in the currency's minor units, e.g. 10000 for 100.00 THB.
+	AmountInputMinorUnits AmountInputMode = "minor_units"
+	// AmountInputDecimalString expects a JSON string in major units, e.g. "100.00", parsed with the currency
+	// exponent.
+	AmountInputDecimalString AmountInputMode = "decimal_string"
+)
+
+// AmountInput is the amount of a create request, kept undecoded until the currency, and with it the input mode
+// and exponent, is known.
+type AmountInput struct {
+	raw json.RawMessage
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *AmountInput) UnmarshalJSON(data []byte) error {
+	a.raw = append(a.raw[:0], data...)
+	return nil
+}
+
+// MinorUnits returns the amount in minor units of currency, rejecting a format that does not match mode.
+// A missing amount is 0, left to the caller's range checks.
+func (a AmountInput) MinorUnits(mode AmountInputMode, currency string) (int64, error) {
+	raw := bytes.TrimSpace(a.raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	isString := raw[0] == '"'
+	switch mode {
+	case AmountInputDecimalString:
+		var value string
+		if !isString || json.Unmarshal(raw, &value) != nil {
+			return 0, fmt.Errorf("amount must be a decimal string in major units, e.g. \"100.00\"")
+		}
+		money, err := ParseDecimalAmount(value, currency)
+		if err != nil {
+			return 0, err
+		}
+		return money.Amount, nil
+	default:
+		var amount int64
+		if isString || json.Unmarshal(raw, &amount) != nil {
+			return 0, fmt.Errorf("amount must be an integer in minor units")
+		}
+		return amount, nil
+	}
+}
+
+// parseAmountInputModes parses AMOUNT_INPUT_MODES, per-currency overrides of the input mode such as
+// "THB=decimal_string,JPY=minor_units".
+func parseAmountInputModes(spec string) (map[string]AmountInputMode, error) {
+	modes := make(map[string]AmountInputMode)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		currency, rawMode, ok := strings.Cut(entry, "=")
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		mode := AmountInputMode(strings.TrimSpace(rawMode))
+		if !ok || currency == "" || !validAmountInputMode(mode) {
+			return nil, fmt.Errorf("invalid AMOUNT_INPUT_MODES entry %q: want CURRENCY=minor_units|decimal_string", entry)
+		}
+		modes[currency] = mode
+	}
+	return modes, nil
+}
+
+func validAmountInputMode(mode AmountInputMode) bool {
+	return mode == AmountInputMinorUnits || mode == AmountInputDecimalString
+}
+
+// amountInputMode returns the input mode configured for currency, defaulting to minor units.
+func (r *APIRouter) amountInputMode(currency string) AmountInputMode {
+	// Validate has already rejected malformed AMOUNT_INPUT_MODES.
+	modes, _ := parseAmountInputModes(r.config.AmountInputModes)
+	if mode, ok := modes[strings.ToUpper(currency)]; ok {
+		return mode
+	}
+	if r.config.AmountInputMode != "" {
+		return r.config.AmountInputMode
+	}
+	return AmountInputMinorUnits
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmountInputModes(t *testing.T) {
+	newApp := func(config Config) *fiber.App {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, config)
+		return app
+	}
+
+	t.Run("Minor Units Mode", func(t *testing.T) {
+		app := newApp(Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":10050,"currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, int64(10050), payment.Amount)
+	})
+
+	t.Run("Decimal String Mode Uses Currency Exponent", func(t *testing.T) {
+		app := newApp(Config{AmountInputMode: AmountInputDecimalString})
+
+		resp, payment := postPayment(t, app, `{"amount":"100.50","currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, int64(10050), payment.Amount)
+
+		resp, payment = postPayment(t, app, `{"amount":"1500","currency":"JPY"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, int64(1500), payment.Amount)
+
+		resp, _ = postPayment(t, app, `{"amount":"1500.5","currency":"JPY"}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Per Currency Override", func(t *testing.T) {
+		app := newApp(Config{AmountInputModes: "thb=decimal_string"})
+
+		resp, payment := postPayment(t, app, `{"amount":"100.00","currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, int64(10000), payment.Amount)
+
+		resp, payment = postPayment(t, app, `{"amount":10000,"currency":"USD"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, int64(10000), payment.Amount)
+	})
+
+	t.Run("Mismatched Format Rejected", func(t *testing.T) {
+		resp, _ := postPayment(t, newApp(Config{}), `{"amount":"100.00","currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+		resp, _ = postPayment(t, newApp(Config{AmountInputMode: AmountInputDecimalString}), `{"amount":10000,"currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	})
+}
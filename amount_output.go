@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AmountSerialization is how amount fields are written in JSON responses.
+type AmountSerialization string
+
+const (
+	// AmountsAsIntegers writes amounts as JSON numbers in minor units, e.g. 1050; it is the default.
+	AmountsAsIntegers AmountSerialization = "integer"
+	// AmountsAsStrings writes amounts as strings of minor units, e.g. "1050", for clients such as JavaScript
+	// that lose precision on integers above 2^53.
+	AmountsAsStrings AmountSerialization = "string"
+)
+
+// acceptAmountsParam is the Accept media type parameter that selects the serialization per request, e.g.
+// "Accept: application/json; amounts=string".
+const acceptAmountsParam = "amounts"
+
+// validAmountSerialization reports whether s is a known serialization.
+func validAmountSerialization(s AmountSerialization) bool {
+	return s == AmountsAsIntegers || s == AmountsAsStrings
+}
+
+// isAmountField reports whether a JSON object key holds an amount in minor units: "amount" itself, as on every
+// Money value, or a name starting with "amount_" such as "amount_refunded".
+func isAmountField(key string) bool {
+	return key == "amount" || strings.HasPrefix(key, "amount_")
+}
+
+// requestedAmountSerialization returns the serialization asked for by an amounts= parameter in the Accept
+// header, or fallback when there is none.
+func requestedAmountSerialization(accept string, fallback AmountSerialization) AmountSerialization {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		for _, param := range params[1:] {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), acceptAmountsParam) {
+				continue
+			}
+			requested := AmountSerialization(strings.ToLower(strings.Trim(strings.TrimSpace(value), `"`)))
+			if validAmountSerialization(requested) {
+				return requested
+			}
+		}
+	}
+	return fallback
+}
+
+// NewAmountSerializationMiddleware rewrites integer amount fields of JSON responses as strings when the
+// configured or requested serialization is AmountsAsStrings. Handlers keep encoding amounts as integers, and
+// responses stored for idempotent replay stay in that form, so a replay is rewritten for the replaying client.
+func NewAmountSerializationMiddleware(defaultSerialization AmountSerialization) fiber.Handler {
+	if !validAmountSerialization(defaultSerialization) {
+		defaultSerialization = AmountsAsIntegers
+	}
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		c.Vary(fiber.HeaderAccept)
+		if requestedAmountSerialization(c.Get(fiber.HeaderAccept), defaultSerialization) != AmountsAsStrings {
+			return nil
+		}
+		contentType := string(c.Response().Header.ContentType())
+		if !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) && !strings.HasPrefix(contentType, MIMEApplicationProblemJSON) {
+			return nil
+		}
+		body, err := stringifyAmounts(c.Response().Body())
+		if err != nil {
+			// Not JSON after all; leave the body as the handler wrote it.
+			return nil
+		}
+		c.Response().SetBody(body)
+		return nil
+	}
+}
+
+// jsonFrame tracks the container being written by stringifyAmounts.
+type jsonFrame struct {
+	object    bool
+	count     int
+	expectKey bool
+	key       string
+}
+
+// stringifyAmounts re-encodes a JSON document with the integer values of amount fields quoted, keeping the
+// order of keys and every other value unchanged.
+func stringifyAmounts(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var out bytes.Buffer
+	var stack []*jsonFrame
+
+	// beginValue writes the separator before a value and reports the key it belongs to, if any.
+	beginValue := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			top.expectKey = true
+			top.count++
+			return top.key
+		}
+		if top.count > 0 {
+			out.WriteByte(',')
+		}
+		top.count++
+		return ""
+	}
+
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(stack) > 0 {
+			if top := stack[len(stack)-1]; top.object && top.expectKey {
+				if key, ok := token.(string); ok {
+					if top.count > 0 {
+						out.WriteByte(',')
+					}
+					encoded, _ := json.Marshal(key)
+					out.Write(encoded)
+					out.WriteByte(':')
+					top.key, top.expectKey = key, false
+					continue
+				}
+			}
+		}
+
+		switch value := token.(type) {
+		case json.Delim:
+			switch value {
+			case '{', '[':
+				beginValue()
+				out.WriteByte(byte(value))
+				stack = append(stack, &jsonFrame{object: value == '{', expectKey: value == '{'})
+			default:
+				stack = stack[:len(stack)-1]
+				out.WriteByte(byte(value))
+			}
+		case json.Number:
+			key := beginValue()
+			if isAmountField(key) && !strings.ContainsAny(value.String(), ".eE") {
+				out.WriteByte('"')
+				out.WriteString(value.String())
+				out.WriteByte('"')
+			} else {
+				out.WriteString(value.String())
+			}
+		default:
+			beginValue()
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(encoded)
+		}
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringifyAmounts(t *testing.T) {
+	t.Run("Amount Fields Quoted In Order", func(t *testing.T) {
+		body := `{"id":"pay_1","amount":9007199254740993,"amount_refunded":0,"count":2,` +
+			`"totals":[{"amount":1050,"currency":"THB"}],"rate":1.5,"ok":true,"note":null}`
+		out, err := stringifyAmounts([]byte(body))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":"pay_1","amount":"9007199254740993","amount_refunded":"0","count":2,`+
+			`"totals":[{"amount":"1050","currency":"THB"}],"rate":1.5,"ok":true,"note":null}`, string(out))
+	})
+
+	t.Run("Strings Reencoded Unchanged", func(t *testing.T) {
+		out, err := stringifyAmounts([]byte(`["a\"b",{"amount":"already"},[]]`))
+		assert.NoError(t, err)
+		assert.Equal(t, `["a\"b",{"amount":"already"},[]]`, string(out))
+	})
+
+	t.Run("Invalid JSON Rejected", func(t *testing.T) {
+		_, err := stringifyAmounts([]byte(`OK`))
+		assert.Error(t, err)
+	})
+}
+
+func TestAmountSerialization(t *testing.T) {
+	create := func(t *testing.T, config Config, accept string) string {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, config)
+		req := httptest.NewRequest(http.MethodPost, "/payments",
+			strings.NewReader(`{"amount":1050,"currency":"THB","token":"tok_visa"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			req.Header.Set(fiber.HeaderAccept, accept)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	t.Run("Integer By Default", func(t *testing.T) {
+		body := create(t, Config{}, "")
+		assert.Contains(t, body, `"amount":1050,`)
+		assert.Contains(t, body, `"amount_refunded":0`)
+	})
+
+	t.Run("String Via Accept Parameter", func(t *testing.T) {
+		body := create(t, Config{}, "application/json; amounts=string")
+		assert.Contains(t, body, `"amount":"1050",`)
+		assert.Contains(t, body, `"amount_refunded":"0"`)
+	})
+
+	t.Run("String Via Config", func(t *testing.T) {
+		body := create(t, Config{AmountSerialization: AmountsAsStrings}, "")
+		assert.Contains(t, body, `"amount":"1050",`)
+	})
+
+	t.Run("Accept Overrides Config", func(t *testing.T) {
+		body := create(t, Config{AmountSerialization: AmountsAsStrings}, `application/json;amounts="integer"`)
+		assert.Contains(t, body, `"amount":1050,`)
+	})
+}
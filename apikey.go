@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// localsAPIKey is the fiber.Locals key holding the APIKey a request authenticated with.
+const localsAPIKey = "api_key"
+
+// API key modes accepted in API_KEYS.
+const (
+	apiKeyModeLive = "live"
+	apiKeyModeTest = "test"
+)
+
+// APIKey is a credential a partner integration calls the API with. Test keys run the same code paths against
+// the sandbox gateway, and what they create is flagged as test data.
+type APIKey struct {
+	Key      string
+	TestMode bool
+	// MerchantID is set for keys a merchant issued itself through /merchants/:id/api-keys.
+	MerchantID string
+}
+
+// parseAPIKeys parses API_KEYS, a comma-separated list of "key=live" or "key=test" pairs.
+func parseAPIKeys(spec string) ([]APIKey, error) {
+	var keys []APIKey
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, mode, ok := strings.Cut(entry, "=")
+		key, mode = strings.TrimSpace(key), strings.ToLower(strings.TrimSpace(mode))
+		if !ok || key == "" || (mode != apiKeyModeLive && mode != apiKeyModeTest) {
+			return nil, fmt.Errorf("invalid API_KEYS entry: want key=live or key=test")
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("invalid API_KEYS: a key is listed twice")
+		}
+		seen[key] = true
+		keys = append(keys, APIKey{Key: key, TestMode: mode == apiKeyModeTest})
+	}
+	return keys, nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header, or "" when there is none.
+func bearerToken(c *fiber.Ctx) string {
+	scheme, token, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// NewAPIKeyMiddleware resolves the API key sent as a bearer token, one of the configured keys or a merchant's
+// unexpired key, and stores it for handlers. A request with an unknown or expired key is rejected with 401; one
+// without a key is treated as live traffic. A key NewAPIKeyResolver already resolved is not looked up again.
+func NewAPIKeyMiddleware(keys []APIKey, merchantKeys MerchantAPIKeyStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals(localsAPIKey).(APIKey); ok {
+			return c.Next()
+		}
+		token := bearerToken(c)
+		if token == "" {
+			return c.Next()
+		}
+		key, err := resolveAPIKey(c, token, keys, merchantKeys)
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return respondError(c, ErrCodeInvalidAPIKey, "unknown API key")
+		}
+		if err != nil {
+			return respondError(c, ErrCodeInternal, "failed to look up API key")
+		}
+		c.Locals(localsAPIKey, key)
+		return c.Next()
+	}
+}
+
+// NewAPIKeyResolver stores the API key sent as a bearer token like NewAPIKeyMiddleware, but never rejects: it
+// runs in the server-wide chain ahead of rate limiting, and a request whose key does not resolve is limited by
+// its client IP and left for NewAPIKeyMiddleware to reject.
+func NewAPIKeyResolver(keys []APIKey, merchantKeys MerchantAPIKeyStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token := bearerToken(c); token != "" {
+			if key, err := resolveAPIKey(c, token, keys, merchantKeys); err == nil {
+				c.Locals(localsAPIKey, key)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// resolveAPIKey returns the configured or merchant API key token stands for, or ErrAPIKeyNotFound when it is
+// unknown or expired.
+func resolveAPIKey(c *fiber.Ctx, token string, keys []APIKey, merchantKeys MerchantAPIKeyStore) (APIKey, error) {
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key.Key)) == 1 {
+			return key, nil
+		}
+	}
+	merchantKey, err := merchantKeys.GetByHash(c.UserContext(), hashAPIKey(token))
+	if err != nil {
+		return APIKey{}, err
+	}
+	if !merchantKey.activeAt(time.Now()) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return APIKey{
+		Key:        utils.CopyString(token),
+		TestMode:   merchantKey.TestMode,
+		MerchantID: merchantKey.MerchantID,
+	}, nil
+}
+
+// requestTestMode reports whether the request authenticated with a test key.
+func requestTestMode(c *fiber.Ctx) bool {
+	key, ok := c.Locals(localsAPIKey).(APIKey)
+	return ok && key.TestMode
+}
+
+// requestMerchantID returns the merchant whose own API key authenticated the request, or "".
+func requestMerchantID(c *fiber.Ctx) string {
+	key, _ := c.Locals(localsAPIKey).(APIKey)
+	return key.MerchantID
+}
+
+// gatewayFor returns the gateway serving live or test payments. Test payments always go to the sandbox.
+func (r *APIRouter) gatewayFor(testMode bool) PaymentGateway {
+	if testMode {
+		return r.testGateway
+	}
+	return r.gateway
+}
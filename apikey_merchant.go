@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+// ErrAPIKeyNotFound is returned by a MerchantAPIKeyStore when no key matches.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// merchantAPIKeyPrefixLength is how much of a key is kept in plaintext so merchants can tell their keys apart.
+const merchantAPIKeyPrefixLength = 12
+
+// MerchantAPIKey is an API key a merchant created for itself. Only a SHA-256 hash of the key is stored: the
+// plaintext is returned once, when the key is created, and cannot be retrieved afterwards.
+type MerchantAPIKey struct {
+	ID         string
+	MerchantID string
+	Prefix     string
+	Hash       string
+	TestMode   bool
+	CreatedAt  time.Time
+	// ExpiresAt is set when the key is revoked. The key keeps working until then, so the merchant can switch
+	// its integration to a new key without downtime.
+	ExpiresAt *time.Time
+}
+
+// activeAt reports whether the key authenticates requests at now.
+func (k MerchantAPIKey) activeAt(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// MerchantAPIKeyStore persists merchant API keys by hash.
+type MerchantAPIKeyStore interface {
+	Create(ctx context.Context, key MerchantAPIKey) error
+	// GetByHash returns the key with the given hash, revoked or not, or ErrAPIKeyNotFound.
+	GetByHash(ctx context.Context, hash string) (MerchantAPIKey, error)
+	ListByMerchant(ctx context.Context, merchantID string) ([]MerchantAPIKey, error)
+	// Revoke makes the merchant's key stop working at expiresAt, or earlier if it was already revoked with an
+	// earlier expiry. It returns ErrAPIKeyNotFound when the merchant has no key with that ID.
+	Revoke(ctx context.Context, merchantID, id string, expiresAt time.Time) (MerchantAPIKey, error)
+}
+
+// MemoryMerchantAPIKeyStore is a MerchantAPIKeyStore that keeps keys in memory.
+type MemoryMerchantAPIKeyStore struct {
+	keys memoryCollection[MerchantAPIKey]
+}
+
+// NewMemoryMerchantAPIKeyStore creates an empty MemoryMerchantAPIKeyStore.
+func NewMemoryMerchantAPIKeyStore() *MemoryMerchantAPIKeyStore {
+	return &MemoryMerchantAPIKeyStore{}
+}
+
+// Create implements MerchantAPIKeyStore.
+func (s *MemoryMerchantAPIKeyStore) Create(_ context.Context, key MerchantAPIKey) error {
+	s.keys.add(key)
+	return nil
+}
+
+// GetByHash implements MerchantAPIKeyStore.
+func (s *MemoryMerchantAPIKeyStore) GetByHash(_ context.Context, hash string) (MerchantAPIKey, error) {
+	keys := s.keys.filter(func(key MerchantAPIKey) bool { return key.Hash == hash })
+	if len(keys) == 0 {
+		return MerchantAPIKey{}, ErrAPIKeyNotFound
+	}
+	return keys[0], nil
+}
+
+// ListByMerchant implements MerchantAPIKeyStore, returning keys in creation order.
+func (s *MemoryMerchantAPIKeyStore) ListByMerchant(_ context.Context, merchantID string) ([]MerchantAPIKey, error) {
+	return s.keys.filter(func(key MerchantAPIKey) bool { return key.MerchantID == merchantID }), nil
+}
+
+// Revoke implements MerchantAPIKeyStore.
+func (s *MemoryMerchantAPIKeyStore) Revoke(_ context.Context, merchantID, id string, expiresAt time.Time) (MerchantAPIKey, error) {
+	var revoked MerchantAPIKey
+	found := s.keys.update(func(key MerchantAPIKey) bool {
+		return key.ID == id && key.MerchantID == merchantID
+	}, func(key MerchantAPIKey) MerchantAPIKey {
+		if key.ExpiresAt == nil || expiresAt.Before(*key.ExpiresAt) {
+			key.ExpiresAt = &expiresAt
+		}
+		revoked = key
+		return key
+	})
+	if !found {
+		return MerchantAPIKey{}, ErrAPIKeyNotFound
+	}
+	return revoked, nil
+}
+
+// hashAPIKey returns the hex SHA-256 of an API key. Keys are long random strings, so an unsalted fast hash is
+// enough to make a leaked table useless while still allowing lookup by hash.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newMerchantAPIKeySecret generates a key such as "sk_live_<48 hex characters>".
+func newMerchantAPIKeySecret(testMode bool) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	mode := apiKeyModeLive
+	if testMode {
+		mode = apiKeyModeTest
+	}
+	return "sk_" + mode + "_" + hex.EncodeToString(buf), nil
+}
+
+// canManageMerchant reports whether the request may manage the merchant's resources: operators with the admin
+// token always can, and merchants can with one of their own API keys.
+func (r *APIRouter) canManageMerchant(c *fiber.Ctx, merchantID string) bool {
+	if r.isAdmin(c) {
+		return true
+	}
+	own := requestMerchantID(c)
+	return own != "" && own == merchantID
+}
+
+// createMerchantAPIKeyRequest is the body accepted by POST /merchants/:id/api-keys.
+type createMerchantAPIKeyRequest struct {
+	// Mode is "live" (the default) or "test".
+	Mode string `json:"mode"`
+}
+
+// MerchantAPIKeyResponse is the JSON representation of a merchant API key. Key is only set in the response
+// that created it.
+type MerchantAPIKeyResponse struct {
+	ID         string     `json:"id"`
+	MerchantID string     `json:"merchant_id"`
+	Key        string     `json:"key,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Mode       string     `json:"mode"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+func newMerchantAPIKeyResponse(key MerchantAPIKey) MerchantAPIKeyResponse {
+	mode := apiKeyModeLive
+	if key.TestMode {
+		mode = apiKeyModeTest
+	}
+	return MerchantAPIKeyResponse{
+		ID:         key.ID,
+		MerchantID: key.MerchantID,
+		Prefix:     key.Prefix,
+		Mode:       mode,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+	}
+}
+
+// createMerchantAPIKey issues a new API key for the merchant and returns it in plaintext, the only time it is
+// shown. A merchant's test key may only issue test keys, so that it cannot be escalated to live access.
+func (r *APIRouter) createMerchantAPIKey(c *fiber.Ctx) error {
+	merchantID := c.Params("id")
+	if !r.canManageMerchant(c, merchantID) {
+		return respondError(c, ErrCodeForbidden, "API keys can only be managed with one of the merchant's keys or the admin token")
+	}
+	var req createMerchantAPIKeyRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+		}
+	}
+	if req.Mode == "" {
+		req.Mode = apiKeyModeLive
+	}
+	if req.Mode != apiKeyModeLive && req.Mode != apiKeyModeTest {
+		return respondError(c, ErrCodeValidationFailed, "mode must be live or test")
+	}
+
+	testMode := req.Mode == apiKeyModeTest
+	if !testMode && requestTestMode(c) && !r.isAdmin(c) {
+		return respondError(c, ErrCodeForbidden, "a test API key can only create test keys")
+	}
+	secret, err := newMerchantAPIKeySecret(testMode)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to generate API key")
+	}
+	key := MerchantAPIKey{
+		ID:         uuid.New().String(),
+		MerchantID: utils.CopyString(merchantID),
+		Prefix:     secret[:merchantAPIKeyPrefixLength],
+		Hash:       hashAPIKey(secret),
+		TestMode:   testMode,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := r.merchantKeys.Create(c.UserContext(), key); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to save API key")
+	}
+
+	response := newMerchantAPIKeyResponse(key)
+	response.Key = secret
+	r.setLocation(c, "merchants", key.MerchantID, "api-keys", key.ID)
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// listMerchantAPIKeys lists the merchant's keys, without their secrets.
+func (r *APIRouter) listMerchantAPIKeys(c *fiber.Ctx) error {
+	merchantID := c.Params("id")
+	if !r.canManageMerchant(c, merchantID) {
+		return respondError(c, ErrCodeForbidden, "API keys can only be managed with one of the merchant's keys or the admin token")
+	}
+	keys, err := r.merchantKeys.ListByMerchant(c.UserContext(), merchantID)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to list API keys")
+	}
+	response := make([]MerchantAPIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, newMerchantAPIKeyResponse(key))
+	}
+	return c.JSON(fiber.Map{"api_keys": response})
+}
+
+// revokeMerchantAPIKey revokes a key after the configured grace period, during which it still works.
+func (r *APIRouter) revokeMerchantAPIKey(c *fiber.Ctx) error {
+	merchantID := c.Params("id")
+	if !r.canManageMerchant(c, merchantID) {
+		return respondError(c, ErrCodeForbidden, "API keys can only be managed with one of the merchant's keys or the admin token")
+	}
+	expiresAt := time.Now().UTC().Add(r.config.APIKeyRevocationGrace)
+	key, err := r.merchantKeys.Revoke(c.UserContext(), merchantID, c.Params("key_id"), expiresAt)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return respondError(c, ErrCodeNotFound, "API key not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to revoke API key")
+	}
+	return c.JSON(newMerchantAPIKeyResponse(key))
+}
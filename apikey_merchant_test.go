@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func merchantKeyRequest(t *testing.T, app *fiber.App, method, path, body string, headers map[string]string) (*http.Response, MerchantAPIKeyResponse) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var key MerchantAPIKeyResponse
+	_ = json.NewDecoder(resp.Body).Decode(&key)
+	return resp, key
+}
+
+func TestMerchantAPIKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	admin := map[string]string{HeaderAdminToken: "admin-secret"}
+	bearer := func(key string) map[string]string {
+		return map[string]string{fiber.HeaderAuthorization: "Bearer " + key}
+	}
+	newApp := func(grace time.Duration) (*fiber.App, *MemoryMerchantAPIKeyStore) {
+		keys := NewMemoryMerchantAPIKeyStore()
+		app := fiber.New()
+		(&APIRouter{merchantKeys: keys}).SetupRoutes(app, Config{AdminToken: "admin-secret", APIKeyRevocationGrace: grace})
+		return app, keys
+	}
+	listKeys := func(t *testing.T, app *fiber.App, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/merchants/m_1/api-keys", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+key)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("Creates Key Shown Once And Stored Hashed", func(t *testing.T) {
+		app, keys := newApp(time.Hour)
+
+		resp, created := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", admin)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.True(t, strings.HasPrefix(created.Key, "sk_live_"))
+		assert.Equal(t, created.Key[:merchantAPIKeyPrefixLength], created.Prefix)
+		assert.Equal(t, "/merchants/m_1/api-keys/"+created.ID, resp.Header.Get(fiber.HeaderLocation))
+
+		stored, _ := keys.ListByMerchant(ctx, "m_1")
+		if assert.Len(t, stored, 1) {
+			assert.Equal(t, hashAPIKey(created.Key), stored[0].Hash)
+			assert.NotContains(t, stored[0].Hash, created.Key)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/merchants/m_1/api-keys", nil)
+		req.Header.Set(HeaderAdminToken, "admin-secret")
+		listResp, err := app.Test(req)
+		assert.NoError(t, err)
+		var listed struct {
+			APIKeys []MerchantAPIKeyResponse `json:"api_keys"`
+		}
+		assert.NoError(t, json.NewDecoder(listResp.Body).Decode(&listed))
+		if assert.Len(t, listed.APIKeys, 1) {
+			assert.Equal(t, created.ID, listed.APIKeys[0].ID)
+			assert.Empty(t, listed.APIKeys[0].Key)
+		}
+	})
+
+	t.Run("Merchant Rotates With Its Own Key", func(t *testing.T) {
+		app, _ := newApp(time.Hour)
+		_, first := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", admin)
+
+		resp, second := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", bearer(first.Key))
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.NotEqual(t, first.Key, second.Key)
+
+		resp, _ = merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_2/api-keys", "", bearer(first.Key))
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		resp, _ = merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", nil)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Both Keys Work During Grace Period", func(t *testing.T) {
+		app, _ := newApp(time.Hour)
+		_, oldKey := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", admin)
+		_, newKey := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", bearer(oldKey.Key))
+
+		resp, revoked := merchantKeyRequest(t, app, http.MethodDelete, "/merchants/m_1/api-keys/"+oldKey.ID, "", bearer(newKey.Key))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		if assert.NotNil(t, revoked.ExpiresAt) {
+			assert.WithinDuration(t, time.Now().Add(time.Hour), *revoked.ExpiresAt, time.Minute)
+		}
+
+		assert.Equal(t, http.StatusOK, listKeys(t, app, oldKey.Key))
+		assert.Equal(t, http.StatusOK, listKeys(t, app, newKey.Key))
+	})
+
+	t.Run("Old Key Fails Once Revocation Takes Effect", func(t *testing.T) {
+		app, keys := newApp(0)
+		_, oldKey := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", admin)
+		_, newKey := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", admin)
+
+		resp, _ := merchantKeyRequest(t, app, http.MethodDelete, "/merchants/m_1/api-keys/"+oldKey.ID, "", admin)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, listKeys(t, app, oldKey.Key))
+		assert.Equal(t, http.StatusOK, listKeys(t, app, newKey.Key))
+
+		// A grace period that has run out behaves the same.
+		_, keyInGrace := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", "", admin)
+		_, err := keys.Revoke(ctx, "m_1", keyInGrace.ID, time.Now().Add(-time.Second))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, listKeys(t, app, keyInGrace.Key))
+	})
+
+	t.Run("Revoking Again Does Not Extend Grace", func(t *testing.T) {
+		keys := NewMemoryMerchantAPIKeyStore()
+		assert.NoError(t, keys.Create(ctx, MerchantAPIKey{ID: "key_1", MerchantID: "m_1", Hash: hashAPIKey("sk_live_x")}))
+		soon := time.Now().Add(time.Minute)
+		_, err := keys.Revoke(ctx, "m_1", "key_1", soon)
+		assert.NoError(t, err)
+		revoked, err := keys.Revoke(ctx, "m_1", "key_1", soon.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, soon, *revoked.ExpiresAt)
+
+		_, err = keys.Revoke(ctx, "m_2", "key_1", soon)
+		assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	})
+
+	t.Run("Test Mode Key Creates Test Payments", func(t *testing.T) {
+		app, _ := newApp(time.Hour)
+		_, key := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", `{"mode":"test"}`, admin)
+		assert.Equal(t, apiKeyModeTest, key.Mode)
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB"}`, bearer(key.Key))
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.True(t, payment.TestMode)
+
+		resp, _ = merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", `{"mode":"staging"}`, admin)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Test Key Cannot Create Live Keys", func(t *testing.T) {
+		app, keys := newApp(time.Hour)
+		_, testKey := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", `{"mode":"test"}`, admin)
+
+		for _, body := range []string{`{"mode":"live"}`, ""} {
+			resp, created := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", body, bearer(testKey.Key))
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, body)
+			assert.Empty(t, created.Key)
+		}
+		stored, _ := keys.ListByMerchant(ctx, "m_1")
+		assert.Len(t, stored, 1)
+
+		resp, created := merchantKeyRequest(t, app, http.MethodPost, "/merchants/m_1/api-keys", `{"mode":"test"}`, bearer(testKey.Key))
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, apiKeyModeTest, created.Mode)
+	})
+
+	t.Run("Unknown Key Not Found", func(t *testing.T) {
+		app, _ := newApp(time.Hour)
+		resp, _ := merchantKeyRequest(t, app, http.MethodDelete, "/merchants/m_1/api-keys/nope", "", admin)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := parseAPIKeys("sk_live_1=live, sk_test_1=TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, []APIKey{{Key: "sk_live_1"}, {Key: "sk_test_1", TestMode: true}}, keys)
+
+	for _, spec := range []string{"sk_1", "sk_1=staging", "=test", "sk_1=live,sk_1=test"} {
+		_, err := parseAPIKeys(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestTestModeAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	newApp := func() (*fiber.App, *MemoryPaymentStore, *SandboxGateway, *SandboxGateway) {
+		store := NewMemoryPaymentStore()
+		live := NewSandboxGateway("live")
+		sandbox := NewSandboxGateway("sandbox")
+		app := fiber.New()
+		(&APIRouter{store: store, gateway: live, testGateway: sandbox}).SetupRoutes(app, Config{APIKeys: "sk_live_1=live,sk_test_1=test"})
+		return app, store, live, sandbox
+	}
+	withKey := func(key string) map[string]string {
+		return map[string]string{fiber.HeaderAuthorization: "Bearer " + key}
+	}
+	list := func(t *testing.T, app *fiber.App, key string) []PaymentResponse {
+		req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+key)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var page struct {
+			Data []PaymentResponse `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		return page.Data
+	}
+	body := `{"amount":1000,"currency":"THB","token":"tok_visa"}`
+
+	t.Run("Test Key Payment Flagged And Sent To Sandbox", func(t *testing.T) {
+		app, store, live, sandbox := newApp()
+		resp, payment := postPayment(t, app, body, withKey("sk_test_1"))
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.True(t, payment.TestMode)
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 0, live.Processed(GatewayOpAuthorize))
+		stored, _ := store.Get(ctx, payment.ID)
+		assert.True(t, stored.TestMode)
+	})
+
+	t.Run("Live Key Payment Not Flagged", func(t *testing.T) {
+		app, _, live, sandbox := newApp()
+		_, payment := postPayment(t, app, body, withKey("sk_live_1"))
+		assert.False(t, payment.TestMode)
+		assert.Equal(t, 1, live.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 0, sandbox.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Unknown Key Rejected", func(t *testing.T) {
+		app, _, _, _ := newApp()
+		resp, _ := postPayment(t, app, body, withKey("sk_nope"))
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Listings Keep Test And Live Apart", func(t *testing.T) {
+		app, _, _, _ := newApp()
+		_, livePayment := postPayment(t, app, body, withKey("sk_live_1"))
+		_, testPayment := postPayment(t, app, body, withKey("sk_test_1"))
+
+		liveList := list(t, app, "sk_live_1")
+		if assert.Len(t, liveList, 1) {
+			assert.Equal(t, livePayment.ID, liveList[0].ID)
+		}
+		testList := list(t, app, "sk_test_1")
+		if assert.Len(t, testList, 1) {
+			assert.Equal(t, testPayment.ID, testList[0].ID)
+		}
+	})
+
+	t.Run("Lookups Keep Test And Live Apart", func(t *testing.T) {
+		app, _, _, _ := newApp()
+		_, livePayment := postPayment(t, app, body, withKey("sk_live_1"))
+		_, testPayment := postPayment(t, app, body, withKey("sk_test_1"))
+
+		assert.Equal(t, http.StatusOK, getPaymentStatus(t, app, livePayment.ID, "sk_live_1"))
+		assert.Equal(t, http.StatusNotFound, getPaymentStatus(t, app, testPayment.ID, "sk_live_1"))
+		assert.Equal(t, http.StatusOK, getPaymentStatus(t, app, testPayment.ID, "sk_test_1"))
+		assert.Equal(t, http.StatusNotFound, getPaymentStatus(t, app, livePayment.ID, "sk_test_1"))
+	})
+
+	t.Run("Test Key Cannot Capture Or Refund Live Payments", func(t *testing.T) {
+		app, _, live, sandbox := newApp()
+		_, payment := postPayment(t, app, body, withKey("sk_live_1"))
+
+		assert.Equal(t, http.StatusNotFound, postWithKey(t, app, "/payments/"+payment.ID+"/capture", "", "sk_test_1"))
+		assert.Equal(t, 0, live.Processed(GatewayOpCapture))
+		assert.Equal(t, 0, sandbox.Processed(GatewayOpCapture))
+
+		assert.Equal(t, http.StatusOK, postWithKey(t, app, "/payments/"+payment.ID+"/capture", "", "sk_live_1"))
+		assert.Equal(t, http.StatusNotFound, postWithKey(t, app, "/payments/"+payment.ID+"/refunds", `{"amount":100}`, "sk_test_1"))
+		assert.Equal(t, 0, live.Processed(GatewayOpRefund))
+		assert.Equal(t, http.StatusNotFound, getPaymentStatus(t, app, payment.ID+"/timeline", "sk_test_1"))
+	})
+
+	t.Run("Merchant Keys See Only Their Payments", func(t *testing.T) {
+		merchantKeys := NewMemoryMerchantAPIKeyStore()
+		for merchantID, key := range map[string]string{"m_1": "sk_live_m1", "m_2": "sk_live_m2"} {
+			assert.NoError(t, merchantKeys.Create(ctx, MerchantAPIKey{
+				ID: "key_" + merchantID, MerchantID: merchantID, Hash: hashAPIKey(key), CreatedAt: time.Now(),
+			}))
+		}
+		app := fiber.New()
+		(&APIRouter{merchantKeys: merchantKeys}).SetupRoutes(app, Config{})
+		_, payment := postPayment(t, app, body, withKey("sk_live_m1"))
+
+		assert.Equal(t, http.StatusOK, getPaymentStatus(t, app, payment.ID, "sk_live_m1"))
+		assert.Equal(t, http.StatusNotFound, getPaymentStatus(t, app, payment.ID, "sk_live_m2"))
+		assert.Len(t, list(t, app, "sk_live_m1"), 1)
+		assert.Empty(t, list(t, app, "sk_live_m2"))
+
+		assert.Equal(t, http.StatusNotFound, postWithKey(t, app, "/payments/"+payment.ID+"/capture", "", "sk_live_m2"))
+		assert.Equal(t, http.StatusNotFound, getPaymentStatus(t, app, payment.ID+"/payouts", "sk_live_m2"))
+		assert.Equal(t, http.StatusOK, postWithKey(t, app, "/payments/"+payment.ID+"/capture", "", "sk_live_m1"))
+	})
+
+	t.Run("Test Payment Excluded From Live Report", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		capturedAt := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+		assert.NoError(t, store.Save(ctx, Payment{ID: "pay_live", Amount: 1000, Currency: "THB", Status: PaymentStatusCaptured, CapturedAt: &capturedAt}))
+		assert.NoError(t, store.Save(ctx, Payment{ID: "pay_test", Amount: 5000, Currency: "THB", Status: PaymentStatusCaptured, CapturedAt: &capturedAt, TestMode: true}))
+
+		report, err := BuildSettlementReport(ctx, store, "2026-10-14", time.UTC)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Count)
+		assert.Equal(t, []Money{NewMoney(1000, "THB")}, report.Totals)
+	})
+
+	t.Run("Test Capture Not Booked In Ledger", func(t *testing.T) {
+		ledger := NewMemoryLedger()
+		router := &APIRouter{ledger: ledger}
+		router.ensureDependencies(Config{})
+		assert.NoError(t, router.postCapture(ctx, Payment{ID: "pay_test", Amount: 1000, Currency: "THB", TestMode: true}))
+		entries, _ := ledger.ListByPayment(ctx, "pay_test")
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Idempotency Keys Scoped By Mode", func(t *testing.T) {
+		app, _, live, sandbox := newApp()
+		withKeyAndIdempotency := func(key string) map[string]string {
+			headers := withKey(key)
+			headers[HeaderIdempotencyKey] = "order-1"
+			return headers
+		}
+
+		_, testFirst := postPayment(t, app, body, withKeyAndIdempotency("sk_test_1"))
+		resp, liveFirst := postPayment(t, app, `{"amount":2000,"currency":"THB","token":"tok_visa"}`, withKeyAndIdempotency("sk_live_1"))
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(HeaderIdempotentReplayed))
+		assert.NotEqual(t, testFirst.ID, liveFirst.ID)
+		assert.False(t, liveFirst.TestMode)
+
+		resp, testSecond := postPayment(t, app, body, withKeyAndIdempotency("sk_test_1"))
+		assert.Equal(t, "true", resp.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, testFirst.ID, testSecond.ID)
+		resp, liveSecond := postPayment(t, app, `{"amount":2000,"currency":"THB","token":"tok_visa"}`, withKeyAndIdempotency("sk_live_1"))
+		assert.Equal(t, "true", resp.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, liveFirst.ID, liveSecond.ID)
+
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 1, live.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Idempotency Keys Scoped By Merchant", func(t *testing.T) {
+		merchantKeys := NewMemoryMerchantAPIKeyStore()
+		for merchantID, key := range map[string]string{"m_1": "sk_live_1", "m_2": "sk_live_2"} {
+			assert.NoError(t, merchantKeys.Create(ctx, MerchantAPIKey{
+				ID: "key_" + merchantID, MerchantID: merchantID, Hash: hashAPIKey(key), CreatedAt: time.Now(),
+			}))
+		}
+		live := NewSandboxGateway("live")
+		app := fiber.New()
+		(&APIRouter{gateway: live, merchantKeys: merchantKeys}).SetupRoutes(app, Config{})
+		withKeyAndIdempotency := func(key string) map[string]string {
+			headers := withKey(key)
+			headers[HeaderIdempotencyKey] = "order-1"
+			return headers
+		}
+
+		_, first := postPayment(t, app, body, withKeyAndIdempotency("sk_live_1"))
+		resp, other := postPayment(t, app, body, withKeyAndIdempotency("sk_live_2"))
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(HeaderIdempotentReplayed), "another merchant's response is never replayed")
+		assert.NotEqual(t, first.ID, other.ID)
+		assert.Equal(t, "m_2", other.MerchantID)
+
+		resp, replayed := postPayment(t, app, body, withKeyAndIdempotency("sk_live_1"))
+		assert.Equal(t, "true", resp.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, first.ID, replayed.ID)
+		assert.Equal(t, 2, live.Processed(GatewayOpAuthorize), "nor is its authorization at the gateway")
+	})
+}
+
+func getPaymentStatus(t *testing.T, app *fiber.App, paymentID, key string) int {
+	req := httptest.NewRequest(http.MethodGet, "/payments/"+paymentID, nil)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+key)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp.StatusCode
+}
+
+func postWithKey(t *testing.T, app *fiber.App, path, body, key string) int {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+key)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp.StatusCode
+}
+
+func TestBearerToken(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString(bearerToken(c)) })
+	for header, want := range map[string]string{"Bearer sk_1": "sk_1", "bearer  sk_2 ": "sk_2", "Basic abc": "", "": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(fiber.HeaderAuthorization, header)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		got, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, want, string(got), header)
+	}
+}
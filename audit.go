@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HeaderAdminToken carries the operator token that authorizes admin-only overrides.
+const HeaderAdminToken = "X-Admin-Token"
+
+// AuditEntry records an operator action that bypassed or changed a business rule.
+type AuditEntry struct {
+	ID           string
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Reason       string
+	Details      map[string]string
+	OccurredAt   time.Time
+}
+
+// AuditLog records audit entries. Entries are append-only.
+type AuditLog interface {
+	Record(ctx context.Context, entry AuditEntry) error
+	List(ctx context.Context) ([]AuditEntry, error)
+}
+
+// MemoryAuditLog is an AuditLog that keeps entries in memory.
+type MemoryAuditLog struct {
+	entries memoryCollection[AuditEntry]
+}
+
+// NewMemoryAuditLog creates an empty MemoryAuditLog.
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{}
+}
+
+// Record implements AuditLog.
+func (l *MemoryAuditLog) Record(_ context.Context, entry AuditEntry) error {
+	l.entries.add(entry)
+	return nil
+}
+
+// List implements AuditLog, returning entries in the order they were recorded.
+func (l *MemoryAuditLog) List(_ context.Context) ([]AuditEntry, error) {
+	return l.entries.filter(func(AuditEntry) bool { return true }), nil
+}
+
+// isAdmin reports whether the request carries the configured admin token. No request is an admin when the
+// token is not configured.
+func (r *APIRouter) isAdmin(c *fiber.Ctx) bool {
+	if r.config.AdminToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Get(HeaderAdminToken)), []byte(r.config.AdminToken)) == 1
+}
+
+// requireAdmin is route middleware that rejects requests without the admin token.
+func (r *APIRouter) requireAdmin(c *fiber.Ctx) error {
+	if !r.isAdmin(c) {
+		return respondError(c, ErrCodeForbidden, "admin token required")
+	}
+	return c.Next()
+}
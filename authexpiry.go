@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultAuthorizationValidity is how long an authorization is held when its gateway has no configured validity.
+const defaultAuthorizationValidity = 7 * 24 * time.Hour
+
+// parseAuthorizationValidity parses AUTHORIZATION_VALIDITY, per-gateway authorization lifetimes such as
+// "kbank=168h,scb=720h", keyed by gateway name.
+func parseAuthorizationValidity(spec string) (map[string]time.Duration, error) {
+	validity := make(map[string]time.Duration)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		gateway, rawValidity, ok := strings.Cut(entry, "=")
+		gateway = strings.TrimSpace(gateway)
+		duration, err := time.ParseDuration(strings.TrimSpace(rawValidity))
+		if !ok || gateway == "" || err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid AUTHORIZATION_VALIDITY entry %q: want gateway=positive duration", entry)
+		}
+		validity[gateway] = duration
+	}
+	return validity, nil
+}
+
+// authorizationValidity is how long the gateway holds an authorization before the issuer releases the funds.
+func (r *APIRouter) authorizationValidity(gateway PaymentGateway) time.Duration {
+	// Validate has already rejected malformed AUTHORIZATION_VALIDITY.
+	validity, _ := parseAuthorizationValidity(r.config.AuthorizationValidity)
+	if duration, ok := validity[gateway.Name()]; ok {
+		return duration
+	}
+	if r.config.DefaultAuthorizationValidity > 0 {
+		return r.config.DefaultAuthorizationValidity
+	}
+	return defaultAuthorizationValidity
+}
+
+// expiredAuthorizations lists the authorized payments whose authorization is past its expected expiry.
+func (r *APIRouter) expiredAuthorizations(ctx context.Context) ([]Payment, error) {
+	payments, err := r.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	var expired []Payment
+	for _, payment := range payments {
+		if payment.Status == PaymentStatusAuthorized && payment.AuthorizationExpiresAt != nil && now.After(*payment.AuthorizationExpiresAt) {
+			expired = append(expired, payment)
+		}
+	}
+	return expired, nil
+}
+
+// voidExpiredAuthorization voids an authorization the gateway no longer honors and marks the payment
+// expired, so that it is not captured against funds the issuer has already released. A payment whose capture
+// or refund is in progress is left for the next run, and one captured since it was listed is skipped.
+func (r *APIRouter) voidExpiredAuthorization(ctx context.Context, payment Payment) error {
+	op, acquired := r.operationLocks.acquire(payment.ID, "void expired authorization")
+	if !acquired {
+		return nil
+	}
+	defer r.operationLocks.release(payment.ID, op, nil)
+	payment, err := r.store.Get(ctx, payment.ID)
+	if err != nil {
+		return err
+	}
+	if payment.Status != PaymentStatusAuthorized {
+		return nil
+	}
+
+	err = r.gatewayFor(payment.TestMode).Void(ctx, VoidRequest{
+		PaymentID:        payment.ID,
+		IdempotencyKey:   GatewayIdempotencyKey(payment.ID, GatewayOpVoid, ""),
+		GatewayReference: payment.GatewayReference,
+		Method:           payment.Method,
+		Currency:         payment.Currency,
+	})
+	if err != nil {
+		return err
+	}
+	before := payment
+	payment.Status = PaymentStatusExpired
+	payment.UpdatedAt = time.Now().UTC()
+	if err := r.updatePayment(ctx, before, &payment); err != nil {
+		return err
+	}
+	r.recordEvent(ctx, payment.ID, EventPaymentExpired)
+	return nil
+}
+
+// NewAuthorizationExpiryWorker creates the worker that voids expired authorizations every interval.
+func NewAuthorizationExpiryWorker(r *APIRouter, interval time.Duration) *Worker[Payment] {
+	return NewWorker("authorization-expiry", interval, r.expiredAuthorizations, r.voidExpiredAuthorization)
+}
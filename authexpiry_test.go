@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAuthorizationValidity(t *testing.T) {
+	validity, err := parseAuthorizationValidity(" kbank=168h , scb=720h ")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{"kbank": 7 * 24 * time.Hour, "scb": 30 * 24 * time.Hour}, validity)
+
+	for _, spec := range []string{"kbank", "kbank=7d", "=168h", "kbank=0s"} {
+		_, err := parseAuthorizationValidity(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestAuthorizationExpiry(t *testing.T) {
+	ctx := context.Background()
+	config := Config{APIKeys: "sk_live_1=live,sk_test_1=test", AuthorizationValidity: "kbank=168h,sandbox=720h"}
+	newRouter := func() (*APIRouter, *MemoryPaymentStore, *SandboxGateway, *SandboxGateway) {
+		store := NewMemoryPaymentStore()
+		live := NewSandboxGateway("kbank")
+		sandbox := NewSandboxGateway("sandbox")
+		return &APIRouter{store: store, gateway: live, testGateway: sandbox}, store, live, sandbox
+	}
+	body := `{"amount":1000,"currency":"THB","token":"tok_visa"}`
+
+	t.Run("Expiry Follows Gateway Validity", func(t *testing.T) {
+		router, store, _, _ := newRouter()
+		app := fiber.New()
+		router.SetupRoutes(app, config)
+
+		_, live := postPayment(t, app, body, map[string]string{fiber.HeaderAuthorization: "Bearer sk_live_1"})
+		_, test := postPayment(t, app, body, map[string]string{fiber.HeaderAuthorization: "Bearer sk_test_1"})
+		if assert.NotNil(t, live.AuthorizationExpiresAt) && assert.NotNil(t, test.AuthorizationExpiresAt) {
+			assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), *live.AuthorizationExpiresAt, time.Minute)
+			assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), *test.AuthorizationExpiresAt, time.Minute)
+		}
+		stored, _ := store.Get(ctx, live.ID)
+		assert.Equal(t, live.AuthorizationExpiresAt, stored.AuthorizationExpiresAt)
+	})
+
+	t.Run("Default Validity For Unlisted Gateway", func(t *testing.T) {
+		router, _, live, _ := newRouter()
+		router.ensureDependencies(Config{DefaultAuthorizationValidity: 48 * time.Hour})
+		assert.Equal(t, 48*time.Hour, router.authorizationValidity(live))
+
+		router.ensureDependencies(Config{})
+		assert.Equal(t, defaultAuthorizationValidity, router.authorizationValidity(live))
+	})
+
+	t.Run("Void Job Respects Each Expiry", func(t *testing.T) {
+		router, store, live, sandbox := newRouter()
+		events := NewMemoryEventStore()
+		router.events = events
+		router.ensureDependencies(config)
+
+		// Both payments were authorized eight days ago: past kbank's seven days, within the sandbox's thirty.
+		authorizedAt := time.Now().UTC().Add(-8 * 24 * time.Hour)
+		seed := func(id string, testMode bool, gateway PaymentGateway) {
+			expiresAt := authorizedAt.Add(router.authorizationValidity(gateway))
+			assert.NoError(t, store.Save(ctx, Payment{
+				ID: id, Amount: 1000, Currency: "THB", Method: "card", Status: PaymentStatusAuthorized, TestMode: testMode,
+				GatewayReference: "ref_" + id, CreatedAt: authorizedAt, UpdatedAt: authorizedAt, AuthorizationExpiresAt: &expiresAt,
+			}))
+		}
+		seed("pay_live", false, live)
+		seed("pay_test", true, sandbox)
+
+		expired, err := router.expiredAuthorizations(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, expired, 1) {
+			assert.Equal(t, "pay_live", expired[0].ID)
+			assert.NoError(t, router.voidExpiredAuthorization(ctx, expired[0]))
+		}
+
+		payment, _ := store.Get(ctx, "pay_live")
+		assert.Equal(t, PaymentStatusExpired, payment.Status)
+		assert.Equal(t, 1, live.Processed(GatewayOpVoid))
+		recorded, _ := events.ListByPayment(ctx, "pay_live")
+		assert.Equal(t, EventPaymentExpired, recorded[len(recorded)-1].Type)
+
+		payment, _ = store.Get(ctx, "pay_test")
+		assert.Equal(t, PaymentStatusAuthorized, payment.Status)
+		assert.Equal(t, 0, sandbox.Processed(GatewayOpVoid))
+
+		expired, _ = router.expiredAuthorizations(ctx)
+		assert.Empty(t, expired)
+	})
+}
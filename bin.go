@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// minBINLength is the shortest card prefix a lookup is attempted for.
+const minBINLength = 6
+
+// binLookupMetric counts payments by the card brand and issuing country resolved from their BIN.
+const binLookupMetric = "payment_card_bin_lookups_total"
+
+// BINInfo describes the card issuer resolved from a BIN.
+type BINInfo struct {
+	Brand   string `json:"brand"`
+	Country string `json:"country,omitempty"`
+}
+
+// BINRange maps card prefixes between Low and High inclusive, compared on their first Length digits, to an issuer.
+type BINRange struct {
+	Low    int
+	High   int
+	Length int
+	Info   BINInfo
+}
+
+// BINTable resolves BINs to issuer information from an in-memory range table. It is read-only after
+// construction and safe for concurrent use.
+type BINTable struct {
+	ranges []BINRange
+}
+
+// NewBINTable builds a BINTable; when ranges overlap the most specific one wins.
+func NewBINTable(ranges []BINRange) *BINTable {
+	sorted := append([]BINRange(nil), ranges...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Length != sorted[j].Length {
+			return sorted[i].Length > sorted[j].Length
+		}
+		return sorted[i].High-sorted[i].Low < sorted[j].High-sorted[j].Low
+	})
+	return &BINTable{ranges: sorted}
+}
+
+// DefaultBINTable resolves the card brand from the networks' published IIN ranges; it carries no issuer
+// countries, which come from a BIN_TABLE_FILE.
+func DefaultBINTable() *BINTable {
+	return NewBINTable([]BINRange{
+		{Low: 4, High: 4, Length: 1, Info: BINInfo{Brand: "visa"}},
+		{Low: 51, High: 55, Length: 2, Info: BINInfo{Brand: "mastercard"}},
+		{Low: 2221, High: 2720, Length: 4, Info: BINInfo{Brand: "mastercard"}},
+		{Low: 34, High: 34, Length: 2, Info: BINInfo{Brand: "amex"}},
+		{Low: 37, High: 37, Length: 2, Info: BINInfo{Brand: "amex"}},
+		{Low: 3528, High: 3589, Length: 4, Info: BINInfo{Brand: "jcb"}},
+		{Low: 62, High: 62, Length: 2, Info: BINInfo{Brand: "unionpay"}},
+	})
+}
+
+// LoadBINTable reads a CSV of "low,high,brand,country" rows, where low and high are card prefixes of equal
+// length, and adds them on top of the default brand ranges.
+func LoadBINTable(r io.Reader) (*BINTable, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	ranges := append([]BINRange(nil), DefaultBINTable().ranges...)
+	for i, row := range rows {
+		if len(row) != 4 {
+			return nil, fmt.Errorf("bin table line %d: expected 4 columns, got %d", i+1, len(row))
+		}
+		low, high := strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		lowValue, lowErr := strconv.Atoi(low)
+		highValue, highErr := strconv.Atoi(high)
+		if lowErr != nil || highErr != nil || len(low) != len(high) || lowValue > highValue {
+			return nil, fmt.Errorf("bin table line %d: invalid range %q-%q", i+1, low, high)
+		}
+		country := strings.ToUpper(strings.TrimSpace(row[3]))
+		if country != "" {
+			if err := ValidateCountryCode(country); err != nil {
+				return nil, fmt.Errorf("bin table line %d: %w", i+1, err)
+			}
+		}
+		ranges = append(ranges, BINRange{
+			Low:    lowValue,
+			High:   highValue,
+			Length: len(low),
+			Info:   BINInfo{Brand: strings.TrimSpace(row[2]), Country: country},
+		})
+	}
+	return NewBINTable(ranges), nil
+}
+
+// LoadBINTableFile loads a BIN table from path, see LoadBINTable.
+func LoadBINTableFile(path string) (*BINTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return LoadBINTable(file)
+}
+
+// Lookup resolves bin to issuer information. It reports false when the BIN is too short, malformed or not
+// covered by the table; callers must treat that as "unknown" rather than an error.
+func (t *BINTable) Lookup(bin string) (BINInfo, bool) {
+	if t == nil || len(bin) < minBINLength {
+		return BINInfo{}, false
+	}
+	for _, r := range bin {
+		if r < '0' || r > '9' {
+			return BINInfo{}, false
+		}
+	}
+	for _, candidate := range t.ranges {
+		if candidate.Length > len(bin) {
+			continue
+		}
+		prefix, _ := strconv.Atoi(bin[:candidate.Length])
+		if prefix >= candidate.Low && prefix <= candidate.High {
+			return candidate.Info, true
+		}
+	}
+	return BINInfo{}, false
+}
+
+// resolveBIN looks up the card's BIN and counts the result for analytics; an inconclusive lookup never fails
+// the payment.
+func (r *APIRouter) resolveBIN(bin string) BINInfo {
+	if bin == "" {
+		return BINInfo{}
+	}
+	info, ok := r.bins.Lookup(bin)
+	labels := Labels{"brand": "unknown", "country": "unknown"}
+	if ok {
+		labels["brand"] = info.Brand
+		if info.Country != "" {
+			labels["country"] = info.Country
+		}
+	}
+	r.metrics.Inc(binLookupMetric, labels)
+	return info
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBINTable(t *testing.T) {
+	table, err := LoadBINTable(strings.NewReader("411111,411199,visa,th\n"))
+	assert.NoError(t, err)
+
+	t.Run("Known BIN Resolves Country", func(t *testing.T) {
+		info, ok := table.Lookup("41111142")
+		assert.True(t, ok)
+		assert.Equal(t, BINInfo{Brand: "visa", Country: "TH"}, info)
+	})
+
+	t.Run("Falls Back To Brand Range", func(t *testing.T) {
+		info, ok := table.Lookup("555555")
+		assert.True(t, ok)
+		assert.Equal(t, BINInfo{Brand: "mastercard"}, info)
+	})
+
+	t.Run("Unknown BIN", func(t *testing.T) {
+		_, ok := table.Lookup("999999")
+		assert.False(t, ok)
+		_, ok = table.Lookup("4111")
+		assert.False(t, ok)
+		_, ok = table.Lookup("4111x1")
+		assert.False(t, ok)
+	})
+
+	t.Run("Rejects Malformed Rows", func(t *testing.T) {
+		_, err := LoadBINTable(strings.NewReader("4111,41119999,visa,TH\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestPaymentBINLookup(t *testing.T) {
+	newApp := func() (*fiber.App, *MetricsRegistry) {
+		table, _ := LoadBINTable(strings.NewReader("411111,411199,visa,TH\n"))
+		metrics := NewMetricsRegistry()
+		router := &APIRouter{bins: table, metrics: metrics}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{})
+		return app, metrics
+	}
+
+	t.Run("Known BIN", func(t *testing.T) {
+		app, metrics := newApp()
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","card_bin":"411111"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "visa", payment.CardBrand)
+		assert.Equal(t, "TH", payment.IssuerCountry)
+		assert.Equal(t, float64(1), metrics.CounterValue(binLookupMetric, Labels{"brand": "visa", "country": "TH"}))
+	})
+
+	t.Run("Unknown BIN Degrades Gracefully", func(t *testing.T) {
+		app, metrics := newApp()
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","card_bin":"999999"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, PaymentStatusAuthorized, payment.Status)
+		assert.Empty(t, payment.IssuerCountry)
+		assert.Equal(t, float64(1), metrics.CounterValue(binLookupMetric, Labels{"brand": "unknown", "country": "unknown"}))
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookBodyLimit(t *testing.T) {
+	newApp := func() *fiber.App {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{GatewayWebhookSecret: "whsec_gw", WebhookMaxBodyBytes: 1024})
+		return app
+	}
+	post := func(app *fiber.App, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/gateways/sandbox", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(HeaderWebhookSignature, SignWebhookPayload("whsec_gw", []byte(body), time.Now()))
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Oversized Webhook Rejected", func(t *testing.T) {
+		body := `{"type":"refund.succeeded","gateway_reference":"unknown","padding":"` + strings.Repeat("x", 2048) + `"}`
+		resp := post(newApp(), body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		assert.Equal(t, ErrCodePayloadTooLarge, decodeErrorCode(t, resp))
+	})
+
+	t.Run("Webhook Within Limit Processed", func(t *testing.T) {
+		resp := post(newApp(), `{"type":"refund.succeeded","gateway_reference":"unknown"}`)
+		// Past the size check and signature verification, the unknown refund is reported as not found.
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Default Limit Applies", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{})
+		body := `{"padding":"` + strings.Repeat("x", defaultWebhookMaxBodyBytes) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/gateways/sandbox", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+}
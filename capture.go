@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CaptureMode decides whether an approved authorization is captured right away or left for a capture call.
+type CaptureMode string
+
+const (
+	// CaptureAutomatic captures the payment immediately after it is authorized.
+	CaptureAutomatic CaptureMode = "automatic"
+	// CaptureManual leaves the payment authorized until POST /payments/:id/capture; it is the default.
+	CaptureManual CaptureMode = "manual"
+)
+
+// validCaptureMode reports whether mode is a known capture mode.
+func validCaptureMode(mode CaptureMode) bool {
+	return mode == CaptureAutomatic || mode == CaptureManual
+}
+
+// captureMode returns the requested capture mode, or the configured default when none was requested.
+func (r *APIRouter) captureMode(requested CaptureMode) CaptureMode {
+	if requested != "" {
+		return requested
+	}
+	if r.config.CaptureMode != "" {
+		return r.config.CaptureMode
+	}
+	return CaptureManual
+}
+
+// errPaymentNoLongerAuthorized is returned by capturePayment when a concurrent request captured or released the
+// payment first.
+var errPaymentNoLongerAuthorized = errors.New("payment is no longer authorized")
+
+// capturePayment settles an authorized payment's full amount at the gateway, stores the payment as captured and
+// books it in the ledger. The versioned save decides between concurrent captures, so only the one that stores
+// the payment books it.
+func (r *APIRouter) capturePayment(ctx context.Context, payment Payment, clientKey string) (Payment, error) {
+	result, err := r.gatewayFor(payment.TestMode).Capture(ctx, CaptureRequest{
+		PaymentID:        payment.ID,
+		IdempotencyKey:   GatewayIdempotencyKey(payment.ID, GatewayOpCapture, merchantClientKey(payment.MerchantID, clientKey)),
+		GatewayReference: payment.GatewayReference,
+		Amount:           payment.Money(),
+		Method:           payment.Method,
+	})
+	if err != nil {
+		return payment, err
+	}
+
+	captured, err := r.retryPaymentUpdate(ctx, payment, func(payment Payment) (Payment, error) {
+		if payment.Status != PaymentStatusAuthorized {
+			return payment, errPaymentNoLongerAuthorized
+		}
+		before := payment
+		now := time.Now().UTC()
+		payment.Status = PaymentStatusCaptured
+		payment.CapturedAt = &now
+		payment.EstimatedSettlementDate = r.estimateSettlementDate(payment)
+		payment.UpdatedAt = now
+		if result.GatewayReference != "" {
+			payment.GatewayReference = result.GatewayReference
+		}
+		// Keep the codes from the authorization unless the gateway only reports them now.
+		if result.ApprovalCode != "" {
+			payment.ApprovalCode = result.ApprovalCode
+		}
+		if result.NetworkTransactionID != "" {
+			payment.NetworkTransactionID = result.NetworkTransactionID
+		}
+		err := r.updatePayment(ctx, before, &payment)
+		return payment, err
+	})
+	if err != nil {
+		return payment, err
+	}
+	if err := r.postCapture(ctx, captured); err != nil {
+		return captured, err
+	}
+	r.recordEvent(ctx, captured.ID, EventPaymentCaptured)
+	return captured, nil
+}
+
+func (r *APIRouter) capturePaymentHandler(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	payment, err := r.loadVisiblePayment(c)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return respondError(c, ErrCodeNotFound, "payment not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	if payment.Status != PaymentStatusAuthorized {
+		return respondError(c, ErrCodeInvalidState, "only authorized payments can be captured")
+	}
+
+	payment, err = r.capturePayment(ctx, payment, c.Get(HeaderIdempotencyKey))
+	if errors.Is(err, ErrMethodUnavailable) {
+		return respondMethodUnavailable(c, "payment method "+payment.Method+" is temporarily unavailable", r.config.RetryAfterJitter)
+	}
+	var maintenance *MaintenanceError
+	if errors.As(err, &maintenance) {
+		return respondGatewayMaintenance(c, maintenance, r.config.RetryAfterJitter)
+	}
+	if errors.Is(err, errPaymentNoLongerAuthorized) {
+		return respondError(c, ErrCodeInvalidState, "payment was captured or released by another request")
+	}
+	if errors.Is(err, ErrPaymentVersionConflict) {
+		return respondError(c, ErrCodeConcurrentModification, "payment was changed by another request")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeGatewayError, "payment gateway error")
+	}
+	return c.JSON(newPaymentResponse(payment))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func postCaptureRequest(t *testing.T, app *fiber.App, paymentID string) (*http.Response, PaymentResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/payments/"+paymentID+"/capture", nil)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var payment PaymentResponse
+	_ = json.NewDecoder(resp.Body).Decode(&payment)
+	return resp, payment
+}
+
+// captureFailingGateway approves authorizations but cannot reach the processor for captures.
+type captureFailingGateway struct {
+	PaymentGateway
+}
+
+func (g captureFailingGateway) Capture(context.Context, CaptureRequest) (CaptureResult, error) {
+	return CaptureResult{}, ErrGatewayUnavailable
+}
+
+func TestCaptureMode(t *testing.T) {
+	ctx := context.Background()
+	newApp := func(config Config) (*fiber.App, PaymentStore, *SandboxGateway, *MemoryLedger) {
+		store := NewMemoryPaymentStore()
+		sandbox := NewSandboxGateway("sandbox")
+		ledger := NewMemoryLedger()
+		app := fiber.New()
+		(&APIRouter{store: store, gateway: sandbox, ledger: ledger}).SetupRoutes(app, config)
+		return app, store, sandbox, ledger
+	}
+
+	t.Run("Automatic Mode Ends Captured", func(t *testing.T) {
+		app, store, sandbox, ledger := newApp(Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":10000,"currency":"THB","capture_mode":"automatic"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, PaymentStatusCaptured, payment.Status)
+		assert.Equal(t, CaptureAutomatic, payment.CaptureMode)
+		assert.NotNil(t, payment.CapturedAt)
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpCapture))
+
+		stored, _ := store.Get(ctx, payment.ID)
+		assert.Equal(t, PaymentStatusCaptured, stored.Status)
+		transactions, _ := ledger.ListByPayment(ctx, payment.ID)
+		assert.Len(t, transactions, 1)
+	})
+
+	t.Run("Manual Mode Ends Authorized", func(t *testing.T) {
+		app, store, sandbox, _ := newApp(Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":10000,"currency":"THB","capture_mode":"manual"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, PaymentStatusAuthorized, payment.Status)
+		assert.Equal(t, CaptureManual, payment.CaptureMode)
+		assert.Equal(t, 0, sandbox.Processed(GatewayOpCapture))
+
+		resp, captured := postCaptureRequest(t, app, payment.ID)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, PaymentStatusCaptured, captured.Status)
+		stored, _ := store.Get(ctx, payment.ID)
+		assert.Equal(t, PaymentStatusCaptured, stored.Status)
+	})
+
+	t.Run("Configured Default Applies", func(t *testing.T) {
+		app, _, _, _ := newApp(Config{CaptureMode: CaptureAutomatic})
+		_, payment := postPayment(t, app, `{"amount":10000,"currency":"THB"}`, nil)
+		assert.Equal(t, PaymentStatusCaptured, payment.Status)
+
+		app, _, _, _ = newApp(Config{})
+		_, payment = postPayment(t, app, `{"amount":10000,"currency":"THB"}`, nil)
+		assert.Equal(t, PaymentStatusAuthorized, payment.Status)
+		assert.Equal(t, CaptureManual, payment.CaptureMode)
+	})
+
+	t.Run("Failed Capture Leaves Payment Authorized", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		app := fiber.New()
+		gateway := captureFailingGateway{NewSandboxGateway("sandbox")}
+		(&APIRouter{store: store, gateway: gateway}).SetupRoutes(app, Config{CaptureMode: CaptureAutomatic})
+
+		resp, _ := postPayment(t, app, `{"amount":10000,"currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusBadGateway, resp.StatusCode)
+		payments, _ := store.List(ctx)
+		assert.Len(t, payments, 1)
+		assert.Equal(t, PaymentStatusAuthorized, payments[0].Status)
+	})
+
+	t.Run("Invalid Mode", func(t *testing.T) {
+		app, _, _, _ := newApp(Config{})
+		resp, _ := postPayment(t, app, `{"amount":10000,"currency":"THB","capture_mode":"later"}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Capture Requires Authorized Payment", func(t *testing.T) {
+		app, store, _, _ := newApp(Config{})
+		seedCapturedPayment(t, store, "pay_1", 10000, "")
+
+		resp, _ := postCaptureRequest(t, app, "pay_1")
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+		resp, _ = postCaptureRequest(t, app, "pay_missing")
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+}
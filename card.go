@@ -0,0 +1,89 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CardDetails is everything about a card the API ever returns: enough to recognize it, never enough to use
+// it. Keeping responses to these fields keeps them out of PCI scope.
+type CardDetails struct {
+	Brand    string `json:"brand,omitempty"`
+	Last4    string `json:"last4,omitempty"`
+	ExpMonth int    `json:"exp_month,omitempty"`
+	ExpYear  int    `json:"exp_year,omitempty"`
+}
+
+// newCardDetails builds the card summary of a payment. Last4 is re-derived from the stored value so that a
+// full PAN stored there by mistake is still cut down to its last four digits.
+func newCardDetails(payment Payment) *CardDetails {
+	card := CardDetails{
+		Brand:    payment.CardBrand,
+		Last4:    lastDigits(payment.CardLast4, 4),
+		ExpMonth: payment.CardExpMonth,
+		ExpYear:  payment.CardExpYear,
+	}
+	if card == (CardDetails{}) {
+		return nil
+	}
+	return &card
+}
+
+// lastDigits returns the last n digits of s, ignoring any other characters.
+func lastDigits(s string, n int) string {
+	var digits []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			digits = append(digits, s[i])
+		}
+	}
+	if len(digits) > n {
+		digits = digits[len(digits)-n:]
+	}
+	return string(digits)
+}
+
+// panCandidate matches 13-19 digits, optionally grouped by single spaces or dashes as PANs are often written.
+var panCandidate = regexp.MustCompile(`\d(?:[ -]?\d){12,18}`)
+
+// maskPANs replaces every card number in s with asterisks followed by its last four digits. Only digit runs
+// that pass the Luhn check are masked, so order numbers and phone numbers are usually left alone.
+func maskPANs(s string) string {
+	return panCandidate.ReplaceAllStringFunc(s, func(match string) string {
+		digits := lastDigits(match, len(match))
+		if !luhnValid(digits) {
+			return match
+		}
+		return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	})
+}
+
+// maskPANsInMap returns a copy of m with card numbers masked in every value.
+func maskPANsInMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(m))
+	for k, v := range m {
+		masked[k] = maskPANs(v)
+	}
+	return masked
+}
+
+// luhnValid reports whether digits passes the Luhn checksum used by card numbers.
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
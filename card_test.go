@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskPANs(t *testing.T) {
+	t.Run("Masks Luhn Valid Card Numbers", func(t *testing.T) {
+		assert.Equal(t, "************4242", maskPANs("4242424242424242"))
+		assert.Equal(t, "card ************1111 on file", maskPANs("card 4111 1111 1111 1111 on file"))
+		assert.Equal(t, "***********0005", maskPANs("3782-822463-10005"))
+	})
+
+	t.Run("Leaves Other Numbers Alone", func(t *testing.T) {
+		assert.Equal(t, "order 1234567890123", maskPANs("order 1234567890123"))
+		assert.Equal(t, "4242", maskPANs("4242"))
+	})
+}
+
+func TestPaymentResponseCardFields(t *testing.T) {
+	t.Run("Card Limited To Brand Last4 And Expiry", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_visa","card_bin":"424242",`+
+			`"card_last4":"4242","card_exp_month":12,"card_exp_year":2030}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, &CardDetails{Brand: "visa", Last4: "4242", ExpMonth: 12, ExpYear: 2030}, payment.Card)
+	})
+
+	t.Run("Never Contains A Full PAN Even If Stored", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		now := time.Now().UTC()
+		pan := "4242424242424242"
+		_ = store.Save(context.Background(), Payment{
+			ID: "pay_1", Amount: 1000, Currency: "THB", Status: PaymentStatusAuthorized,
+			Reference: pan, CardLast4: pan, Metadata: map[string]string{"note": "paid with 4242 4242 4242 4242"},
+			CreatedAt: now, UpdatedAt: now,
+		})
+		app := fiber.New()
+		(&APIRouter{store: store}).SetupRoutes(app, Config{})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/payments", nil))
+		assert.NoError(t, err)
+		var body struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Len(t, body.Data, 1)
+		raw := string(body.Data[0])
+		assert.NotContains(t, raw, pan)
+		assert.NotContains(t, raw, "4242 4242 4242 4242")
+		assert.Contains(t, raw, `"last4":"4242"`)
+	})
+}
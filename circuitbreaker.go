@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CircuitState describes whether a circuit breaker lets calls through.
+type CircuitState string
+
+const (
+	// CircuitClosed lets every call through and counts consecutive failures.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen rejects calls until the cooldown has elapsed.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single trial call through to decide whether to close or re-open.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned when a call is rejected because its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreakerStatus is a point-in-time view of a circuit breaker for diagnostics.
+type CircuitBreakerStatus struct {
+	Name          string       `json:"name"`
+	State         CircuitState `json:"state"`
+	FailureCount  int          `json:"failure_count"`
+	LastChangedAt time.Time    `json:"last_state_change"`
+}
+
+// CircuitBreaker trips open after a number of consecutive failures and probes again after a cooldown.
+type CircuitBreaker struct {
+	mu            sync.Mutex
+	name          string
+	threshold     int
+	cooldown      time.Duration
+	state         CircuitState
+	failures      int
+	lastChangedAt time.Time
+	now           func() time.Time
+
+	// probing is set while the half-open trial call is in flight. A probe whose outcome is never recorded is
+	// given up on after a cooldown, so the breaker cannot stay half-open forever.
+	probing        bool
+	probeStartedAt time.Time
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker that opens after threshold consecutive failures.
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultBreakerFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	return &CircuitBreaker{
+		name:          name,
+		threshold:     threshold,
+		cooldown:      cooldown,
+		state:         CircuitClosed,
+		lastChangedAt: time.Now().UTC(),
+		now:           time.Now,
+	}
+}
+
+// Name returns the breaker's name.
+func (b *CircuitBreaker) Name() string {
+	return b.name
+}
+
+// Allow reports whether a call may proceed, moving an open breaker to half-open once the cooldown has elapsed.
+// A half-open breaker allows one call, the probe, until its outcome is recorded.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.ready() {
+		return false
+	}
+	if b.state == CircuitHalfOpen {
+		b.probing = true
+		b.probeStartedAt = b.now()
+	}
+	return true
+}
+
+// Ready reports whether Allow would let a call through, without claiming the half-open probe. Callers choosing
+// between several breakers use it so that looking at a half-open breaker does not use up its probe.
+func (b *CircuitBreaker) Ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ready()
+}
+
+func (b *CircuitBreaker) ready() bool {
+	if b.state == CircuitOpen && b.now().Sub(b.lastChangedAt) >= b.cooldown {
+		b.transition(CircuitHalfOpen)
+	}
+	switch b.state {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		return !b.probing || b.now().Sub(b.probeStartedAt) >= b.cooldown
+	default:
+		return false
+	}
+}
+
+// RecordSuccess resets the failure count and closes the breaker.
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != CircuitClosed {
+		b.transition(CircuitClosed)
+	}
+}
+
+// RecordFailure counts a failed call, opening the breaker at the threshold or when a half-open probe fails.
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= b.threshold) {
+		b.transition(CircuitOpen)
+	}
+}
+
+// Execute runs fn when the breaker allows it and records the outcome, returning ErrCircuitOpen otherwise.
+func (b *CircuitBreaker) Execute(fn func() error) error {
+	if !b.Allow() {
+		return ErrCircuitOpen
+	}
+	if err := fn(); err != nil {
+		b.RecordFailure()
+		return err
+	}
+	b.RecordSuccess()
+	return nil
+}
+
+// Status returns the breaker's current state for diagnostics.
+func (b *CircuitBreaker) Status() CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && b.now().Sub(b.lastChangedAt) >= b.cooldown {
+		b.transition(CircuitHalfOpen)
+	}
+	return CircuitBreakerStatus{
+		Name:          b.name,
+		State:         b.state,
+		FailureCount:  b.failures,
+		LastChangedAt: b.lastChangedAt,
+	}
+}
+
+func (b *CircuitBreaker) transition(state CircuitState) {
+	b.state = state
+	b.probing = false
+	b.lastChangedAt = b.now().UTC()
+}
+
+// CircuitBreakerRegistry keeps the named circuit breakers of the service so they can be inspected together.
+type CircuitBreakerRegistry struct {
+	mu       sync.RWMutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewCircuitBreakerRegistry creates an empty CircuitBreakerRegistry.
+func NewCircuitBreakerRegistry() *CircuitBreakerRegistry {
+	return &CircuitBreakerRegistry{breakers: make(map[string]*CircuitBreaker)}
+}
+
+// Register adds the breaker to the registry, replacing any breaker with the same name.
+func (r *CircuitBreakerRegistry) Register(breaker *CircuitBreaker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakers[breaker.Name()] = breaker
+}
+
+// Get returns the breaker registered under name, if any.
+func (r *CircuitBreakerRegistry) Get(name string) (*CircuitBreaker, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	breaker, ok := r.breakers[name]
+	return breaker, ok
+}
+
+// Statuses returns the status of every registered breaker, sorted by name.
+func (r *CircuitBreakerRegistry) Statuses() []CircuitBreakerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]CircuitBreakerStatus, 0, len(r.breakers))
+	for _, breaker := range r.breakers {
+		statuses = append(statuses, breaker.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (r *APIRouter) listCircuitBreakers(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"circuit_breakers": r.breakers.Statuses(),
+	})
+}
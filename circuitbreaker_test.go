@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Run("Opens After Threshold", func(t *testing.T) {
+		breaker := NewCircuitBreaker("gateway", 2, time.Minute)
+		failing := func() error { return errors.New("gateway down") }
+
+		assert.Error(t, breaker.Execute(failing))
+		assert.Equal(t, CircuitClosed, breaker.Status().State)
+		assert.Error(t, breaker.Execute(failing))
+		assert.Equal(t, CircuitOpen, breaker.Status().State)
+		assert.ErrorIs(t, breaker.Execute(func() error { return nil }), ErrCircuitOpen)
+	})
+
+	t.Run("Half-Open After Cooldown Then Closes", func(t *testing.T) {
+		now := time.Now()
+		breaker := NewCircuitBreaker("gateway", 1, time.Minute)
+		breaker.now = func() time.Time { return now }
+
+		breaker.RecordFailure()
+		assert.Equal(t, CircuitOpen, breaker.Status().State)
+
+		now = now.Add(2 * time.Minute)
+		assert.Equal(t, CircuitHalfOpen, breaker.Status().State)
+		assert.NoError(t, breaker.Execute(func() error { return nil }))
+		assert.Equal(t, CircuitClosed, breaker.Status().State)
+		assert.Equal(t, 0, breaker.Status().FailureCount)
+	})
+
+	t.Run("Half-Open Allows A Single Probe", func(t *testing.T) {
+		now := time.Now()
+		breaker := NewCircuitBreaker("gateway", 1, time.Minute)
+		breaker.now = func() time.Time { return now }
+		breaker.RecordFailure()
+		now = now.Add(2 * time.Minute)
+
+		assert.True(t, breaker.Ready())
+		assert.True(t, breaker.Ready(), "Ready does not claim the probe")
+		assert.True(t, breaker.Allow(), "the probe")
+		assert.False(t, breaker.Allow(), "no second call while the probe is in flight")
+		assert.False(t, breaker.Ready())
+
+		breaker.RecordFailure()
+		assert.Equal(t, CircuitOpen, breaker.Status().State, "a failed probe re-opens the breaker")
+		assert.False(t, breaker.Allow())
+
+		now = now.Add(2 * time.Minute)
+		assert.True(t, breaker.Allow())
+		now = now.Add(2 * time.Minute)
+		assert.True(t, breaker.Allow(), "a probe whose outcome was never recorded is given up on")
+	})
+}
+
+func TestCircuitBreakersEndpoint(t *testing.T) {
+	registry := NewCircuitBreakerRegistry()
+	tripped := NewCircuitBreaker("gateway.primary", 1, time.Minute)
+	registry.Register(tripped)
+	registry.Register(NewCircuitBreaker("gateway.secondary", 3, time.Minute))
+	tripped.RecordFailure()
+
+	app := fiber.New()
+	router := &APIRouter{breakers: registry}
+	router.SetupRoutes(app, Config{AdminToken: "admin-secret"})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/circuit-breakers", nil)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "admin token required")
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/circuit-breakers", nil)
+	req.Header.Set(HeaderAdminToken, "admin-secret")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		CircuitBreakers []CircuitBreakerStatus `json:"circuit_breakers"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Len(t, result.CircuitBreakers, 2)
+	assert.Equal(t, "gateway.primary", result.CircuitBreakers[0].Name)
+	assert.Equal(t, CircuitOpen, result.CircuitBreakers[0].State)
+	assert.Equal(t, 1, result.CircuitBreakers[0].FailureCount)
+	assert.False(t, result.CircuitBreakers[0].LastChangedAt.IsZero())
+	assert.Equal(t, CircuitClosed, result.CircuitBreakers[1].State)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultShedRetryAfter is the Retry-After hint sent with requests shed by the concurrency limiter.
+const defaultShedRetryAfter = time.Second
+
+// probePaths are exempt from load shedding so orchestrators keep seeing the instance as alive under load.
+var probePaths = map[string]bool{
+	"/health": true,
+	"/ready":  true,
+}
+
+// NewConcurrencyLimiter returns middleware that caps the number of requests in flight at once. Requests beyond
+// the cap are shed immediately with 503 and a Retry-After header instead of queueing; probe paths are exempt.
+func NewConcurrencyLimiter(maxInFlight int, retryAfter RetryAfter) fiber.Handler {
+	slots := make(chan struct{}, maxInFlight)
+
+	return func(c *fiber.Ctx) error {
+		if probePaths[c.Path()] {
+			return c.Next()
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			return c.Next()
+		default:
+			c.Set(fiber.HeaderRetryAfter, retryAfter.Header())
+			return respondError(c, ErrCodeServiceUnavailable, "server is overloaded, retry later")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	release := make(chan struct{})
+	var inFlight atomic.Int32
+
+	app := fiber.New()
+	app.Use(NewConcurrencyLimiter(2, RetryAfter{Base: 3 * time.Second}))
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		inFlight.Add(1)
+		<-release
+		return c.SendString("done")
+	})
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendString("OK")
+	})
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/slow", nil), 5000)
+			if err == nil {
+				statuses <- resp.StatusCode
+			}
+		}()
+	}
+	assert.Eventually(t, func() bool { return inFlight.Load() == 2 }, time.Second, 5*time.Millisecond)
+
+	t.Run("Excess Request Is Shed", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/slow", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "3", resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("Health Is Exempt", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/health", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	close(release)
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		assert.Equal(t, http.StatusOK, status)
+	}
+
+	t.Run("Capacity Is Released", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/slow", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Startup connection retry backoff: it starts at defaultConnectInitialBackoff and doubles per attempt up to
+// maxConnectBackoff, so a database that comes up a few seconds after the service is picked up quickly.
+const (
+	defaultConnectInitialBackoff = 250 * time.Millisecond
+	maxConnectBackoff            = 5 * time.Second
+)
+
+// checkPaymentDatabase connects to the payment store's database, retrying for DBConnectRetryBudget, and then waits
+// for ExpectedSchemaVersion when one is set. A store that is not a PaymentDatabase, like the in-memory store, has
+// nothing to check, and the checks are skipped with a log line.
+func checkPaymentDatabase(ctx context.Context, store PaymentStore, config Config, initialBackoff, poll time.Duration) error {
+	database, ok := store.(PaymentDatabase)
+	if !ok {
+		log.Printf("Payment store is not a database; skipping the startup connection and schema checks")
+		return nil
+	}
+	if err := ConnectWithRetry(ctx, "payment store", database.Ping, config.DBConnectRetryBudget, initialBackoff); err != nil {
+		return err
+	}
+	if config.ExpectedSchemaVersion > 0 {
+		return WaitForSchemaVersion(ctx, database.SchemaVersion, config.ExpectedSchemaVersion, config.SchemaWaitTimeout, poll, config.StrictStartupChecks)
+	}
+	return nil
+}
+
+// ConnectWithRetry calls connect until it succeeds, waiting with exponential backoff between attempts, and gives
+// up with the last error once budget has elapsed. A budget of 0 tries exactly once. It lets the service start
+// alongside its database in compose/k8s deploys instead of crash looping until the database is ready.
+func ConnectWithRetry(ctx context.Context, name string, connect func(context.Context) error, budget, initialBackoff time.Duration) error {
+	if initialBackoff <= 0 {
+		initialBackoff = defaultConnectInitialBackoff
+	}
+	deadline := time.Now().Add(budget)
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Connected to %s after %d attempts", name, attempt)
+			}
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("connecting to %s: giving up after %d attempts in %s: %w", name, attempt, budget, err)
+		}
+		wait := min(backoff, remaining)
+		log.Printf("Connecting to %s failed (attempt %d): %v; retrying in %s", name, attempt, err, wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
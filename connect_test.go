@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectWithRetry(t *testing.T) {
+	errNotReady := errors.New("connection refused")
+	failingFor := func(failures int) (func(context.Context) error, *int) {
+		attempts := 0
+		return func(context.Context) error {
+			attempts++
+			if attempts <= failures {
+				return errNotReady
+			}
+			return nil
+		}, &attempts
+	}
+
+	t.Run("Succeeds Once Database Is Available", func(t *testing.T) {
+		connect, attempts := failingFor(3)
+
+		err := ConnectWithRetry(context.Background(), "database", connect, time.Second, time.Millisecond)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, *attempts)
+	})
+
+	t.Run("Gives Up After Budget", func(t *testing.T) {
+		connect, attempts := failingFor(1000)
+
+		started := time.Now()
+		err := ConnectWithRetry(context.Background(), "database", connect, 20*time.Millisecond, time.Millisecond)
+		assert.ErrorIs(t, err, errNotReady)
+		assert.Greater(t, *attempts, 1)
+		assert.Less(t, time.Since(started), time.Second)
+	})
+
+	t.Run("Zero Budget Tries Once", func(t *testing.T) {
+		connect, attempts := failingFor(1)
+
+		err := ConnectWithRetry(context.Background(), "database", connect, 0, time.Millisecond)
+		assert.ErrorIs(t, err, errNotReady)
+		assert.Equal(t, 1, *attempts)
+	})
+}
+
+// flakyDatabaseStore is a PaymentDatabase that refuses connections failures times and then reports version.
+type flakyDatabaseStore struct {
+	*MemoryPaymentStore
+	failures int
+	version  int
+	pings    int
+}
+
+func (s *flakyDatabaseStore) Ping(context.Context) error {
+	s.pings++
+	if s.pings <= s.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (s *flakyDatabaseStore) SchemaVersion(context.Context) (int, error) {
+	return s.version, nil
+}
+
+func TestCheckPaymentDatabase(t *testing.T) {
+	ctx := context.Background()
+	config := Config{DBConnectRetryBudget: time.Second, ExpectedSchemaVersion: 3, StrictStartupChecks: true}
+
+	t.Run("Waits For The Database", func(t *testing.T) {
+		store := &flakyDatabaseStore{MemoryPaymentStore: NewMemoryPaymentStore(), failures: 2, version: 3}
+		assert.NoError(t, checkPaymentDatabase(ctx, store, config, time.Millisecond, time.Millisecond))
+		assert.Equal(t, 3, store.pings)
+	})
+
+	t.Run("Outdated Schema Blocks Startup", func(t *testing.T) {
+		store := &flakyDatabaseStore{MemoryPaymentStore: NewMemoryPaymentStore(), version: 2}
+		err := checkPaymentDatabase(ctx, store, config, time.Millisecond, time.Millisecond)
+		assert.ErrorIs(t, err, ErrSchemaOutdated)
+	})
+
+	t.Run("In-Memory Store Is Skipped", func(t *testing.T) {
+		assert.NoError(t, checkPaymentDatabase(ctx, NewMemoryPaymentStore(), config, time.Millisecond, time.Millisecond))
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"net/mail"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Customer is a merchant's customer, optionally linked to the merchant's own record by ExternalID.
+type Customer struct {
+	ID         string
+	MerchantID string
+	ExternalID string
+	Email      string
+	Name       string
+	Metadata   map[string]string
+	CreatedAt  time.Time
+}
+
+// CustomerStore persists customers. ExternalID is unique per merchant: Create returns the existing customer
+// instead of inserting a duplicate, atomically, so concurrent retries cannot both insert.
+type CustomerStore interface {
+	Create(ctx context.Context, customer Customer) (Customer, bool, error)
+	ListByMerchant(ctx context.Context, merchantID string) ([]Customer, error)
+}
+
+// MemoryCustomerStore is a CustomerStore that keeps customers in memory.
+type MemoryCustomerStore struct {
+	mu         sync.Mutex
+	customers  []Customer
+	byExternal map[string]int
+}
+
+// NewMemoryCustomerStore creates an empty MemoryCustomerStore.
+func NewMemoryCustomerStore() *MemoryCustomerStore {
+	return &MemoryCustomerStore{byExternal: make(map[string]int)}
+}
+
+// Create implements CustomerStore, reporting whether a new customer was inserted.
+func (s *MemoryCustomerStore) Create(_ context.Context, customer Customer) (Customer, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := customer.MerchantID + "|" + customer.ExternalID
+	if customer.ExternalID != "" {
+		if i, ok := s.byExternal[key]; ok {
+			return s.customers[i], false, nil
+		}
+		s.byExternal[key] = len(s.customers)
+	}
+	s.customers = append(s.customers, customer)
+	return customer, true, nil
+}
+
+// ListByMerchant implements CustomerStore.
+func (s *MemoryCustomerStore) ListByMerchant(_ context.Context, merchantID string) ([]Customer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var customers []Customer
+	for _, customer := range s.customers {
+		if customer.MerchantID == merchantID {
+			customers = append(customers, customer)
+		}
+	}
+	return customers, nil
+}
+
+// createCustomerRequest is the body accepted by POST /merchants/:id/customers.
+type createCustomerRequest struct {
+	ExternalID string `json:"external_id"`
+	Email      string `json:"email"`
+	Name       string `json:"name"`
+	// Metadata is bounded by the same limits as payment metadata.
+	Metadata map[string]string `json:"metadata"`
+}
+
+// CustomerResponse is the JSON representation of a customer returned by the API.
+type CustomerResponse struct {
+	ID         string            `json:"id"`
+	MerchantID string            `json:"merchant_id"`
+	ExternalID string            `json:"external_id,omitempty"`
+	Email      string            `json:"email,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+func newCustomerResponse(customer Customer) CustomerResponse {
+	return CustomerResponse{
+		ID:         customer.ID,
+		MerchantID: customer.MerchantID,
+		ExternalID: customer.ExternalID,
+		Email:      customer.Email,
+		Name:       customer.Name,
+		Metadata:   customer.Metadata,
+		CreatedAt:  customer.CreatedAt,
+	}
+}
+
+// createCustomer creates a customer. A repeated create with an external_id the merchant already used returns
+// the existing customer with 200 instead of a duplicate.
+func (r *APIRouter) createCustomer(c *fiber.Ctx) error {
+	merchantID := c.Params("id")
+	if !r.canManageMerchant(c, merchantID) {
+		return respondError(c, ErrCodeForbidden, "customers can only be managed with one of the merchant's keys or the admin token")
+	}
+	var req createCustomerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	if req.Email != "" {
+		if address, err := mail.ParseAddress(req.Email); err != nil || address.Address != req.Email {
+			return respondError(c, ErrCodeValidationFailed, "email must be a valid email address")
+		}
+	}
+	if err := newMetadataLimits(r.config).Validate(req.Metadata); err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+
+	customer, created, err := r.customers.Create(c.UserContext(), Customer{
+		ID:         uuid.NewString(),
+		MerchantID: merchantID,
+		ExternalID: req.ExternalID,
+		Email:      req.Email,
+		Name:       req.Name,
+		Metadata:   req.Metadata,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to create customer")
+	}
+	status := fiber.StatusOK
+	if created {
+		status = fiber.StatusCreated
+	}
+	r.setLocation(c, "merchants", customer.MerchantID, "customers", customer.ID)
+	return c.Status(status).JSON(newCustomerResponse(customer))
+}
+
+func (r *APIRouter) listCustomers(c *fiber.Ctx) error {
+	merchantID := c.Params("id")
+	if !r.canManageMerchant(c, merchantID) {
+		return respondError(c, ErrCodeForbidden, "customers can only be managed with one of the merchant's keys or the admin token")
+	}
+	page, err := r.parsePage(c)
+	if err != nil {
+		return respondError(c, ErrCodeInvalidRequest, err.Error())
+	}
+	customers, err := r.customers.ListByMerchant(c.UserContext(), merchantID)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to list customers")
+	}
+	responses := make([]CustomerResponse, 0, len(customers))
+	for _, customer := range customers {
+		responses = append(responses, newCustomerResponse(customer))
+	}
+	return respondPage(c, responses, page)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func postCustomer(t *testing.T, app *fiber.App, merchantID, body string) (*http.Response, CustomerResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/merchants/"+merchantID+"/customers", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderAdminToken, "admin-secret")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var customer CustomerResponse
+	_ = json.NewDecoder(resp.Body).Decode(&customer)
+	return resp, customer
+}
+
+func TestCreateCustomer(t *testing.T) {
+	newApp := func() *fiber.App {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{AdminToken: "admin-secret"})
+		return app
+	}
+
+	t.Run("New Customer", func(t *testing.T) {
+		resp, customer := postCustomer(t, newApp(), "m_1", `{"external_id":"crm-1","email":"a@example.com"}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.NotEmpty(t, customer.ID)
+		assert.Equal(t, "crm-1", customer.ExternalID)
+		assert.Equal(t, "m_1", customer.MerchantID)
+	})
+
+	t.Run("Repeated External ID Returns Existing", func(t *testing.T) {
+		app := newApp()
+		_, first := postCustomer(t, app, "m_1", `{"external_id":"crm-1"}`)
+		resp, second := postCustomer(t, app, "m_1", `{"external_id":"crm-1"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, first.ID, second.ID)
+	})
+
+	t.Run("Distinct External IDs", func(t *testing.T) {
+		app := newApp()
+		_, first := postCustomer(t, app, "m_1", `{"external_id":"crm-1"}`)
+		_, second := postCustomer(t, app, "m_1", `{"external_id":"crm-2"}`)
+		_, other := postCustomer(t, app, "m_2", `{"external_id":"crm-1"}`)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.NotEqual(t, first.ID, other.ID)
+	})
+
+	t.Run("Concurrent Creates Do Not Duplicate", func(t *testing.T) {
+		store := NewMemoryCustomerStore()
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, _ = store.Create(context.Background(), Customer{
+					ID: time.Now().String(), MerchantID: "m_1", ExternalID: "crm-1",
+				})
+			}()
+		}
+		wg.Wait()
+
+		customers, _ := store.ListByMerchant(context.Background(), "m_1")
+		assert.Len(t, customers, 1)
+	})
+
+	t.Run("Only The Merchant Or Admin Manages", func(t *testing.T) {
+		merchantKeys := NewMemoryMerchantAPIKeyStore()
+		for merchantID, key := range map[string]string{"m_1": "sk_live_m1", "m_2": "sk_live_m2"} {
+			assert.NoError(t, merchantKeys.Create(context.Background(), MerchantAPIKey{
+				ID: "key_" + merchantID, MerchantID: merchantID, Hash: hashAPIKey(key), CreatedAt: time.Now(),
+			}))
+		}
+		app := fiber.New()
+		(&APIRouter{merchantKeys: merchantKeys}).SetupRoutes(app, Config{AdminToken: "admin-secret"})
+		send := func(method, key string) int {
+			req := httptest.NewRequest(method, "/merchants/m_1/customers", strings.NewReader(`{"external_id":"crm-1"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if key != "" {
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+key)
+			}
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "sk_live_m2"), "another merchant's key")
+		assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "sk_live_m2"), "another merchant's key")
+		assert.Equal(t, http.StatusForbidden, send(http.MethodPost, ""))
+
+		assert.Equal(t, http.StatusCreated, send(http.MethodPost, "sk_live_m1"))
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "sk_live_m1"))
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HeaderDeduplicated is set to "true" on responses replayed for an identical request sent within the
+// deduplication window.
+const HeaderDeduplicated = "X-Deduplicated"
+
+// dedupKey identifies a request by its sender and content: the API key it authenticated with, or the client
+// IP when it sent none, together with a hash of the method, path and body.
+func dedupKey(c *fiber.Ctx) string {
+	sender := merchantKey(c)
+	if key, ok := c.Locals(localsAPIKey).(APIKey); ok {
+		sum := sha256.Sum256([]byte(key.Key))
+		sender = "api_key:" + hex.EncodeToString(sum[:8])
+	}
+	sum := sha256.Sum256(append([]byte(c.Method()+" "+c.Path()+"\n"), c.Body()...))
+	return "dedup " + sender + " " + hex.EncodeToString(sum[:])
+}
+
+// NewDedupMiddleware is a safety net for clients that send no Idempotency-Key: an identical request from the
+// same sender within window replays the first one's response, or is rejected with 409 while the first is still
+// processing. Requests carrying an Idempotency-Key are left to the idempotency middleware, and a zero window
+// turns deduplication off.
+func NewDedupMiddleware(store IdempotencyStore, window time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if window <= 0 || c.Get(HeaderIdempotencyKey) != "" {
+			return c.Next()
+		}
+		ctx := c.UserContext()
+		key := dedupKey(c)
+
+		record, found, err := lookupOrReserve(ctx, store, key)
+		if errors.Is(err, ErrIdempotencyInProgress) {
+			return respondError(c, ErrCodeDuplicateRequest, "an identical request is still being processed")
+		}
+		if err != nil {
+			return respondError(c, ErrCodeInternal, "failed to reserve deduplication key")
+		}
+		if found {
+			c.Set(HeaderDeduplicated, "true")
+			return replayResponse(c, record)
+		}
+
+		defer func() { _ = store.Release(ctx, key) }()
+		if err := c.Next(); err != nil {
+			return err
+		}
+		storeResponse(c, store, key, "", window)
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupMiddleware(t *testing.T) {
+	newApp := func(window time.Duration) (*fiber.App, *SandboxGateway) {
+		gateway := NewSandboxGateway("sandbox")
+		app := fiber.New()
+		(&APIRouter{gateway: gateway}).SetupRoutes(app, Config{DedupWindow: window, APIKeys: "sk_live_1=live,sk_live_2=live"})
+		return app, gateway
+	}
+	body := `{"amount":1000,"currency":"THB","token":"tok_visa"}`
+	withKey := func(key string) map[string]string {
+		return map[string]string{fiber.HeaderAuthorization: "Bearer " + key}
+	}
+
+	t.Run("Duplicate Body Within Window Deduplicated", func(t *testing.T) {
+		app, gateway := newApp(time.Minute)
+
+		resp, first := postPayment(t, app, body, withKey("sk_live_1"))
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(HeaderDeduplicated))
+
+		resp, second := postPayment(t, app, body, withKey("sk_live_1"))
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get(HeaderDeduplicated))
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, 1, gateway.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Different Body Proceeds", func(t *testing.T) {
+		app, gateway := newApp(time.Minute)
+
+		_, first := postPayment(t, app, body, withKey("sk_live_1"))
+		resp, second := postPayment(t, app, `{"amount":2000,"currency":"THB","token":"tok_visa"}`, withKey("sk_live_1"))
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(HeaderDeduplicated))
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.Equal(t, 2, gateway.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Other API Key Proceeds", func(t *testing.T) {
+		app, _ := newApp(time.Minute)
+
+		_, first := postPayment(t, app, body, withKey("sk_live_1"))
+		_, second := postPayment(t, app, body, withKey("sk_live_2"))
+		assert.NotEqual(t, first.ID, second.ID)
+	})
+
+	t.Run("Idempotency Key Bypasses Deduplication", func(t *testing.T) {
+		app, _ := newApp(time.Minute)
+
+		_, first := postPayment(t, app, body, map[string]string{HeaderIdempotencyKey: "order-1"})
+		_, second := postPayment(t, app, body, map[string]string{HeaderIdempotencyKey: "order-2"})
+		assert.NotEqual(t, first.ID, second.ID)
+	})
+
+	t.Run("Expires After Window", func(t *testing.T) {
+		app, _ := newApp(20 * time.Millisecond)
+
+		_, first := postPayment(t, app, body, nil)
+		time.Sleep(30 * time.Millisecond)
+		_, second := postPayment(t, app, body, nil)
+		assert.NotEqual(t, first.ID, second.ID)
+	})
+
+	t.Run("Disabled By Default", func(t *testing.T) {
+		app, _ := newApp(0)
+
+		_, first := postPayment(t, app, body, nil)
+		_, second := postPayment(t, app, body, nil)
+		assert.NotEqual(t, first.ID, second.ID)
+	})
+
+	t.Run("In Progress Duplicate Rejected", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		app := fiber.New()
+		app.Post("/payments", NewDedupMiddleware(NewMemoryIdempotencyStore(), time.Minute), func(c *fiber.Ctx) error {
+			close(started)
+			<-release
+			return c.SendStatus(fiber.StatusCreated)
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			resp, _ := postPayment(t, app, body, nil)
+			assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		}()
+		<-started
+		resp, _ := postPayment(t, app, body, nil)
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		close(release)
+		<-done
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultDescriptorMaxLength is the statement descriptor length accepted by the card networks.
+const defaultDescriptorMaxLength = 22
+
+// ErrDescriptorVariableMissing is returned in strict mode when a descriptor template references metadata
+// the payment does not have.
+var ErrDescriptorVariableMissing = errors.New("descriptor template references missing metadata")
+
+// ErrDescriptorNotASCII is returned when a descriptor for an ASCII-only gateway contains non-ASCII text and
+// DESCRIPTOR_NON_ASCII is set to reject it.
+var ErrDescriptorNotASCII = errors.New("descriptor contains non-ASCII text the gateway cannot display")
+
+// Handling of non-ASCII descriptor text for gateways that only accept ASCII.
+const (
+	// DescriptorTransliterate romanizes Thai and strips accents from Latin letters; it is the default.
+	DescriptorTransliterate = "transliterate"
+	// DescriptorReject fails the payment with 422 instead.
+	DescriptorReject = "reject"
+)
+
+// UTF8DescriptorSupporter is implemented by gateways that report whether statement descriptors may contain
+// UTF-8 text such as Thai. Gateways that do not implement it are treated as ASCII-only.
+type UTF8DescriptorSupporter interface {
+	SupportsUTF8Descriptors() bool
+}
+
+// DescriptorRules describes what a gateway accepts in a statement descriptor.
+type DescriptorRules struct {
+	// MaxLength is counted in characters; 0 uses the card network default.
+	MaxLength int
+	// UTF8 keeps letters and digits of any script; otherwise non-ASCII text is transliterated or rejected.
+	UTF8 bool
+	// RejectNonASCII rejects non-ASCII text for ASCII-only gateways instead of transliterating it.
+	RejectNonASCII bool
+}
+
+// DescriptorLengthLimiter is implemented by gateways whose statement descriptor limit differs from the
+// card network default.
+type DescriptorLengthLimiter interface {
+	DescriptorMaxLength() int
+}
+
+var (
+	descriptorPlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+	descriptorDisallowed  = regexp.MustCompile(`[^A-Za-z0-9 .,*#&/-]`)
+	descriptorUTF8        = regexp.MustCompile(`[^\p{L}\p{M}\p{N} .,*#&/-]`)
+	descriptorNonASCII    = regexp.MustCompile(`[^\x00-\x7F]`)
+	descriptorSpaces      = regexp.MustCompile(` {2,}`)
+)
+
+// RenderDescriptor fills a template such as "ORDER {order_id}" from payment metadata and reduces the result to
+// the ASCII characters and length gateways accept. Missing variables render as empty unless strict is set.
+func RenderDescriptor(template string, metadata map[string]string, strict bool, maxLength int) (string, error) {
+	return RenderDescriptorFor(template, metadata, strict, DescriptorRules{MaxLength: maxLength})
+}
+
+// RenderDescriptorFor renders a descriptor like RenderDescriptor for a gateway with the given rules.
+func RenderDescriptorFor(template string, metadata map[string]string, strict bool, rules DescriptorRules) (string, error) {
+	var missing []string
+	rendered := descriptorPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := metadata[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if strict && len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrDescriptorVariableMissing, strings.Join(missing, ", "))
+	}
+
+	if rules.UTF8 {
+		rendered = descriptorUTF8.ReplaceAllString(rendered, "")
+	} else {
+		if descriptorNonASCII.MatchString(descriptorUTF8.ReplaceAllString(rendered, "")) {
+			if rules.RejectNonASCII {
+				return "", ErrDescriptorNotASCII
+			}
+			rendered = TransliterateASCII(rendered)
+		}
+		rendered = descriptorDisallowed.ReplaceAllString(rendered, "")
+	}
+	rendered = strings.TrimSpace(descriptorSpaces.ReplaceAllString(rendered, " "))
+	maxLength := rules.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultDescriptorMaxLength
+	}
+	if runes := []rune(rendered); len(runes) > maxLength {
+		rendered = strings.TrimSpace(string(runes[:maxLength]))
+	}
+	return rendered, nil
+}
+
+// statementDescriptor renders the configured descriptor template for a payment going to gateway, or returns ""
+// when no template is configured.
+func (r *APIRouter) statementDescriptor(gateway PaymentGateway, metadata map[string]string) (string, error) {
+	if r.config.DescriptorTemplate == "" {
+		return "", nil
+	}
+	rules := DescriptorRules{MaxLength: defaultDescriptorMaxLength, RejectNonASCII: r.config.DescriptorNonASCII == DescriptorReject}
+	if limiter, ok := gatewayAs[DescriptorLengthLimiter](gateway); ok {
+		rules.MaxLength = limiter.DescriptorMaxLength()
+	}
+	if supporter, ok := gatewayAs[UTF8DescriptorSupporter](gateway); ok {
+		rules.UTF8 = supporter.SupportsUTF8Descriptors()
+	}
+	return RenderDescriptorFor(r.config.DescriptorTemplate, metadata, r.config.DescriptorTemplateStrict, rules)
+}
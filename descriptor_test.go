@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type descriptorRecordingGateway struct {
+	*SandboxGateway
+	maxLength   int
+	descriptors []string
+}
+
+func (g *descriptorRecordingGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	g.descriptors = append(g.descriptors, req.StatementDescriptor)
+	return g.SandboxGateway.Authorize(ctx, req)
+}
+
+func (g *descriptorRecordingGateway) DescriptorMaxLength() int {
+	return g.maxLength
+}
+
+func TestRenderDescriptor(t *testing.T) {
+	t.Run("Substitutes Metadata", func(t *testing.T) {
+		descriptor, err := RenderDescriptor("ORDER {order_id}", map[string]string{"order_id": "A123"}, true, 22)
+		assert.NoError(t, err)
+		assert.Equal(t, "ORDER A123", descriptor)
+	})
+
+	t.Run("Truncates And Sanitizes", func(t *testing.T) {
+		descriptor, err := RenderDescriptor("SHOP <{store}> {order_id}", map[string]string{
+			"store":    "Bangkok   Central",
+			"order_id": "ORD-0000000001",
+		}, true, 22)
+		assert.NoError(t, err)
+		assert.Equal(t, "SHOP Bangkok Central O", descriptor)
+		assert.LessOrEqual(t, len(descriptor), 22)
+	})
+
+	t.Run("Missing Variable", func(t *testing.T) {
+		_, err := RenderDescriptor("ORDER {order_id}", nil, true, 22)
+		assert.ErrorIs(t, err, ErrDescriptorVariableMissing)
+
+		descriptor, err := RenderDescriptor("ORDER {order_id}", nil, false, 22)
+		assert.NoError(t, err)
+		assert.Equal(t, "ORDER", descriptor)
+	})
+}
+
+func TestDescriptorCharset(t *testing.T) {
+	metadata := map[string]string{"shop": "ร้านกาแฟ"}
+
+	t.Run("Thai Preserved For UTF-8 Gateway", func(t *testing.T) {
+		descriptor, err := RenderDescriptorFor("{shop} #12", metadata, true, DescriptorRules{UTF8: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "ร้านกาแฟ #12", descriptor)
+	})
+
+	t.Run("UTF-8 Length Counts Characters", func(t *testing.T) {
+		descriptor, err := RenderDescriptorFor("{shop}", metadata, true, DescriptorRules{UTF8: true, MaxLength: 4})
+		assert.NoError(t, err)
+		assert.Equal(t, "ร้าน", descriptor)
+	})
+
+	t.Run("Thai Transliterated For ASCII Gateway", func(t *testing.T) {
+		descriptor, err := RenderDescriptorFor("{shop} #12", metadata, true, DescriptorRules{})
+		assert.NoError(t, err)
+		assert.Equal(t, "rankafae #12", descriptor)
+	})
+
+	t.Run("Thai Rejected For ASCII Gateway", func(t *testing.T) {
+		_, err := RenderDescriptorFor("{shop}", metadata, true, DescriptorRules{RejectNonASCII: true})
+		assert.ErrorIs(t, err, ErrDescriptorNotASCII)
+
+		descriptor, err := RenderDescriptorFor("SHOP <1>", nil, true, DescriptorRules{RejectNonASCII: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "SHOP 1", descriptor)
+	})
+
+	t.Run("Transliteration", func(t *testing.T) {
+		assert.Equal(t, "krungthep", TransliterateASCII("กรุงเทพ"))
+		assert.Equal(t, "ahan", TransliterateASCII("อาหาร"))
+		assert.Equal(t, "Cafe 2", TransliterateASCII("Café ๒"))
+	})
+}
+
+func TestPaymentStatementDescriptor(t *testing.T) {
+	newApp := func(config Config) (*fiber.App, *descriptorRecordingGateway) {
+		gateway := &descriptorRecordingGateway{SandboxGateway: NewSandboxGateway("sandbox"), maxLength: 10}
+		router := &APIRouter{gateway: gateway}
+		app := fiber.New()
+		router.SetupRoutes(app, config)
+		return app, gateway
+	}
+
+	t.Run("Sent To Gateway With Gateway Length Limit", func(t *testing.T) {
+		app, gateway := newApp(Config{DescriptorTemplate: "ORDER {order_id}"})
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","metadata":{"order_id":"12345678"}}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "ORDER 1234", payment.StatementDescriptor)
+		assert.Equal(t, []string{"ORDER 1234"}, gateway.descriptors)
+	})
+
+	t.Run("Follows Gateway Charset", func(t *testing.T) {
+		config := Config{DescriptorTemplate: "{shop}"}
+		body := `{"amount":1000,"currency":"THB","metadata":{"shop":"กาแฟ"}}`
+
+		app, gateway := newApp(config)
+		gateway.SetUTF8Descriptors(true)
+		_, payment := postPayment(t, app, body, nil)
+		assert.Equal(t, "กาแฟ", payment.StatementDescriptor)
+
+		app, gateway = newApp(config)
+		_, payment = postPayment(t, app, body, nil)
+		assert.Equal(t, "kafae", payment.StatementDescriptor)
+		assert.Equal(t, []string{"kafae"}, gateway.descriptors)
+
+		config.DescriptorNonASCII = DescriptorReject
+		app, gateway = newApp(config)
+		resp, _ := postPayment(t, app, body, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Empty(t, gateway.descriptors)
+	})
+
+	t.Run("Strict Template Rejects Missing Metadata", func(t *testing.T) {
+		app, gateway := newApp(Config{DescriptorTemplate: "ORDER {order_id}", DescriptorTemplateStrict: true})
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Empty(t, gateway.descriptors)
+	})
+}
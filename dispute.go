@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// DisputeStatus is the lifecycle state of a chargeback dispute.
+type DisputeStatus string
+
+const (
+	// DisputeStatusOpen is a dispute awaiting evidence or a decision.
+	DisputeStatusOpen DisputeStatus = "open"
+	// DisputeStatusWon is a dispute decided in the merchant's favour.
+	DisputeStatusWon DisputeStatus = "won"
+	// DisputeStatusLost is a dispute decided in the cardholder's favour.
+	DisputeStatusLost DisputeStatus = "lost"
+)
+
+// Dispute represents a chargeback raised by the cardholder against a payment.
+type Dispute struct {
+	ID        string
+	PaymentID string
+	Amount    int64
+	Currency  string
+	Reason    string
+	Status    DisputeStatus
+	OpenedAt  time.Time
+}
+
+// DisputeStore persists disputes.
+type DisputeStore interface {
+	Save(ctx context.Context, dispute Dispute) error
+	ListByPayment(ctx context.Context, paymentID string) ([]Dispute, error)
+}
+
+// MemoryDisputeStore is a DisputeStore that keeps disputes in memory.
+type MemoryDisputeStore struct {
+	disputes memoryCollection[Dispute]
+}
+
+// NewMemoryDisputeStore creates an empty MemoryDisputeStore.
+func NewMemoryDisputeStore() *MemoryDisputeStore {
+	return &MemoryDisputeStore{}
+}
+
+// Save implements DisputeStore, inserting or replacing the dispute.
+func (s *MemoryDisputeStore) Save(_ context.Context, dispute Dispute) error {
+	replaced := s.disputes.update(
+		func(d Dispute) bool { return d.ID == dispute.ID },
+		func(Dispute) Dispute { return dispute },
+	)
+	if !replaced {
+		s.disputes.add(dispute)
+	}
+	return nil
+}
+
+// ListByPayment implements DisputeStore.
+func (s *MemoryDisputeStore) ListByPayment(_ context.Context, paymentID string) ([]Dispute, error) {
+	return s.disputes.filter(func(d Dispute) bool { return d.PaymentID == paymentID }), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownKeyVersion is returned when a field was encrypted with a key version that is no longer configured.
+var ErrUnknownKeyVersion = errors.New("unknown encryption key version")
+
+// EncryptedField is a ciphertext stored together with the version of the key that produced it.
+// KeyVersion 0 means the value is stored in plaintext because no encryption key was configured.
+type EncryptedField struct {
+	KeyVersion int
+	Ciphertext []byte
+}
+
+// FieldEncryptor encrypts sensitive column values with AES-GCM, writing with the active key
+// and reading with whichever configured key version the value was written with.
+type FieldEncryptor struct {
+	aeads  map[int]cipher.AEAD
+	active int
+}
+
+// NewFieldEncryptor creates a FieldEncryptor from AES keys indexed by version; active selects the key used for writes.
+func NewFieldEncryptor(keys map[int][]byte, active int) (*FieldEncryptor, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("%w: active version %d", ErrUnknownKeyVersion, active)
+	}
+
+	aeads := make(map[int]cipher.AEAD, len(keys))
+	for version, key := range keys {
+		if version <= 0 {
+			return nil, fmt.Errorf("encryption key version must be positive, got %d", version)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key version %d: %w", version, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key version %d: %w", version, err)
+		}
+		aeads[version] = aead
+	}
+	return &FieldEncryptor{aeads: aeads, active: active}, nil
+}
+
+// NewFieldEncryptorFromConfig builds a FieldEncryptor from Config.EncryptionKeys, returning nil when no keys are set.
+// Keys are given as comma-separated "version:base64key" pairs; the highest version is used for writes.
+func NewFieldEncryptorFromConfig(config Config) (*FieldEncryptor, error) {
+	spec := strings.TrimSpace(config.EncryptionKeys)
+	if spec == "" {
+		return nil, nil
+	}
+
+	keys := make(map[int][]byte)
+	active := 0
+	for _, pair := range strings.Split(spec, ",") {
+		versionText, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid encryption key entry %q, expected version:base64key", pair)
+		}
+		version, err := strconv.Atoi(versionText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key version %q: %w", versionText, err)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key for version %d: %w", version, err)
+		}
+		keys[version] = key
+		if version > active {
+			active = version
+		}
+	}
+	return NewFieldEncryptor(keys, active)
+}
+
+// ActiveVersion returns the key version used for new writes.
+func (e *FieldEncryptor) ActiveVersion() int {
+	if e == nil {
+		return 0
+	}
+	return e.active
+}
+
+// Encrypt seals plaintext with the active key, binding it to associatedData (typically the row ID).
+// A nil FieldEncryptor stores the plaintext unchanged with KeyVersion 0.
+func (e *FieldEncryptor) Encrypt(plaintext, associatedData []byte) (EncryptedField, error) {
+	if e == nil {
+		return EncryptedField{Ciphertext: append([]byte(nil), plaintext...)}, nil
+	}
+
+	aead := e.aeads[e.active]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return EncryptedField{}, err
+	}
+	return EncryptedField{
+		KeyVersion: e.active,
+		Ciphertext: aead.Seal(nonce, nonce, plaintext, associatedData),
+	}, nil
+}
+
+// Decrypt opens a field written by Encrypt with any configured key version.
+func (e *FieldEncryptor) Decrypt(field EncryptedField, associatedData []byte) ([]byte, error) {
+	if field.KeyVersion == 0 {
+		return append([]byte(nil), field.Ciphertext...), nil
+	}
+	if e == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, field.KeyVersion)
+	}
+
+	aead, ok := e.aeads[field.KeyVersion]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, field.KeyVersion)
+	}
+	if len(field.Ciphertext) < aead.NonceSize() {
+		return nil, errors.New("encrypted field is truncated")
+	}
+	nonce, sealed := field.Ciphertext[:aead.NonceSize()], field.Ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, associatedData)
+}
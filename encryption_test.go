@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKey(fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, 32)
+}
+
+func TestFieldEncryptor(t *testing.T) {
+	t.Run("Round Trip", func(t *testing.T) {
+		encryptor, err := NewFieldEncryptor(map[int][]byte{1: testKey(1)}, 1)
+		assert.NoError(t, err)
+
+		field, err := encryptor.Encrypt([]byte("tok_visa_4242"), []byte("pay_1"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, field.KeyVersion)
+		assert.NotContains(t, string(field.Ciphertext), "tok_visa_4242")
+
+		plaintext, err := encryptor.Decrypt(field, []byte("pay_1"))
+		assert.NoError(t, err)
+		assert.Equal(t, "tok_visa_4242", string(plaintext))
+	})
+
+	t.Run("Bound To Row", func(t *testing.T) {
+		encryptor, _ := NewFieldEncryptor(map[int][]byte{1: testKey(1)}, 1)
+		field, _ := encryptor.Encrypt([]byte("secret"), []byte("pay_1"))
+
+		_, err := encryptor.Decrypt(field, []byte("pay_2"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown Version", func(t *testing.T) {
+		old, _ := NewFieldEncryptor(map[int][]byte{1: testKey(1)}, 1)
+		field, _ := old.Encrypt([]byte("secret"), nil)
+
+		rotated, _ := NewFieldEncryptor(map[int][]byte{2: testKey(2)}, 2)
+		_, err := rotated.Decrypt(field, nil)
+		assert.ErrorIs(t, err, ErrUnknownKeyVersion)
+	})
+
+	t.Run("From Config", func(t *testing.T) {
+		spec := "1:" + base64.StdEncoding.EncodeToString(testKey(1)) + ",2:" + base64.StdEncoding.EncodeToString(testKey(2))
+		encryptor, err := NewFieldEncryptorFromConfig(Config{EncryptionKeys: spec})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, encryptor.ActiveVersion())
+
+		disabled, err := NewFieldEncryptorFromConfig(Config{})
+		assert.NoError(t, err)
+		assert.Nil(t, disabled)
+
+		_, err = NewFieldEncryptorFromConfig(Config{EncryptionKeys: "1:not-base64!"})
+		assert.Error(t, err)
+	})
+}
+
+func TestMemoryPaymentStoreEncryption(t *testing.T) {
+	ctx := context.Background()
+	payment := Payment{
+		ID:        "pay_1",
+		Amount:    1000,
+		Currency:  "THB",
+		Status:    PaymentStatusPending,
+		CardToken: "tok_visa_4242",
+		Metadata:  map[string]string{"order_id": "ORD-1"},
+		CreatedAt: time.Now().UTC(),
+	}
+
+	t.Run("Encrypts At Rest And Decrypts On Read", func(t *testing.T) {
+		encryptor, _ := NewFieldEncryptor(map[int][]byte{1: testKey(1)}, 1)
+		store := NewMemoryPaymentStore()
+		store.SetEncryptor(encryptor)
+
+		assert.NoError(t, store.Save(ctx, payment))
+		row := store.rows["pay_1"]
+		assert.Equal(t, 1, row.cardToken.KeyVersion)
+		assert.NotContains(t, string(row.cardToken.Ciphertext), "tok_visa_4242")
+		assert.NotContains(t, string(row.metadata.Ciphertext), "ORD-1")
+
+		stored, err := store.Get(ctx, "pay_1")
+		assert.NoError(t, err)
+		expected := payment
+		expected.Version = 1
+		assert.Equal(t, expected, stored)
+	})
+
+	t.Run("Reads Row Written With Older Key Version", func(t *testing.T) {
+		v1, _ := NewFieldEncryptor(map[int][]byte{1: testKey(1)}, 1)
+		store := NewMemoryPaymentStore()
+		store.SetEncryptor(v1)
+		assert.NoError(t, store.Save(ctx, payment))
+
+		v2, _ := NewFieldEncryptor(map[int][]byte{1: testKey(1), 2: testKey(2)}, 2)
+		store.SetEncryptor(v2)
+
+		stored, err := store.Get(ctx, "pay_1")
+		assert.NoError(t, err)
+		assert.Equal(t, "tok_visa_4242", stored.CardToken)
+		assert.Equal(t, "ORD-1", stored.Metadata["order_id"])
+
+		assert.NoError(t, store.Save(ctx, stored))
+		assert.Equal(t, 2, store.rows["pay_1"].cardToken.KeyVersion)
+	})
+}
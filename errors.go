@@ -0,0 +1,229 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorCode is a stable, documented identifier returned in the "code" field of every error response.
+type ErrorCode string
+
+const (
+	// ErrCodeInvalidRequest is returned when the body or parameters cannot be parsed.
+	ErrCodeInvalidRequest ErrorCode = "invalid_request"
+	// ErrCodeIdempotencyKeyRequired is returned when the deployment requires an Idempotency-Key and none was sent.
+	ErrCodeIdempotencyKeyRequired ErrorCode = "idempotency_key_required"
+	// ErrCodeInvalidIdempotencyKey is returned when the Idempotency-Key header is too long or has disallowed characters.
+	ErrCodeInvalidIdempotencyKey ErrorCode = "invalid_idempotency_key"
+	// ErrCodeValidationFailed is returned when a well-formed request fails validation.
+	ErrCodeValidationFailed ErrorCode = "validation_failed"
+	// ErrCodeInvalidAmount is returned when an amount is missing, negative or out of range.
+	ErrCodeInvalidAmount ErrorCode = "invalid_amount"
+	// ErrCodeInvalidCurrency is returned when a currency is missing, unknown or does not match.
+	ErrCodeInvalidCurrency ErrorCode = "invalid_currency"
+	// ErrCodeInvalidCountry is returned when a country is not an ISO 3166-1 alpha-2 code.
+	ErrCodeInvalidCountry ErrorCode = "invalid_country"
+	// ErrCodeAmountExceedsRefundable is returned when a refund is larger than what is left to refund.
+	ErrCodeAmountExceedsRefundable ErrorCode = "amount_exceeds_refundable"
+	// ErrCodeIdempotencyConflict is returned when an Idempotency-Key is reused with a different request body.
+	ErrCodeIdempotencyConflict ErrorCode = "idempotency_conflict"
+	// ErrCodeRefundWindowExpired is returned when a refund is requested after the configured refund window.
+	ErrCodeRefundWindowExpired ErrorCode = "refund_window_expired"
+	// ErrCodeRefundLimitReached is returned when a payment already has the maximum number of refunds allowed.
+	ErrCodeRefundLimitReached ErrorCode = "refund_limit_reached"
+	// ErrCodeUnsupportedOperation is returned when the gateway does not support the requested operation.
+	ErrCodeUnsupportedOperation ErrorCode = "unsupported_operation"
+	// ErrCodePaymentDeclined is returned when the gateway declines an operation.
+	ErrCodePaymentDeclined ErrorCode = "payment_declined"
+	// ErrCodeInsufficientFunds is returned when the gateway declines an operation for lack of funds.
+	ErrCodeInsufficientFunds ErrorCode = "insufficient_funds"
+	// ErrCodeInvalidSignature is returned when a signed request's signature is missing, stale or wrong.
+	ErrCodeInvalidSignature ErrorCode = "invalid_signature"
+	// ErrCodeInvalidAPIKey is returned when the request's bearer token is not a known API key.
+	ErrCodeInvalidAPIKey ErrorCode = "invalid_api_key"
+	// ErrCodeForbidden is returned when the caller is not allowed to perform the operation.
+	ErrCodeForbidden ErrorCode = "forbidden"
+	// ErrCodeNotFound is returned when the requested resource does not exist.
+	ErrCodeNotFound ErrorCode = "resource_not_found"
+	// ErrCodeInvalidState is returned when the resource's current state does not allow the operation.
+	ErrCodeInvalidState ErrorCode = "invalid_state"
+	// ErrCodeIdempotencyInProgress is returned when a request with the same Idempotency-Key is still being processed.
+	ErrCodeIdempotencyInProgress ErrorCode = "idempotency_in_progress"
+	// ErrCodeDuplicateRequest is returned when an identical request without an Idempotency-Key is still being processed.
+	ErrCodeDuplicateRequest ErrorCode = "duplicate_request"
+	// ErrCodeConcurrentModification is returned when another request changed the resource while this one was updating it.
+	ErrCodeConcurrentModification ErrorCode = "concurrent_modification"
+	// ErrCodePaymentOperationInProgress is returned when another capture or refund of the payment, or cancellation of the
+	// payment intent, ran past the lock wait.
+	ErrCodePaymentOperationInProgress ErrorCode = "payment_operation_in_progress"
+	// ErrCodePayloadTooLarge is returned when a request body exceeds the size accepted by the route.
+	ErrCodePayloadTooLarge ErrorCode = "payload_too_large"
+	// ErrCodeGatewayError is returned when the payment gateway fails or cannot be reached.
+	ErrCodeGatewayError ErrorCode = "gateway_error"
+	// ErrCodeGatewayMaintenance is returned when the gateway is in a scheduled maintenance window.
+	ErrCodeGatewayMaintenance ErrorCode = "gateway_maintenance"
+	// ErrCodeRateLimited is returned when a client exceeds its request quota and should retry after the window resets.
+	ErrCodeRateLimited ErrorCode = "rate_limited"
+	// ErrCodeServiceUnavailable is returned when the service sheds load and the client should retry later.
+	ErrCodeServiceUnavailable ErrorCode = "service_unavailable"
+	// ErrCodeRequestTimeout is returned when a request did not complete within its configured timeout.
+	ErrCodeRequestTimeout ErrorCode = "request_timeout"
+	// ErrCodeInternal is returned for unexpected server-side failures.
+	ErrCodeInternal ErrorCode = "internal_error"
+)
+
+// ErrorCodeInfo documents an error code and the HTTP status it is returned with.
+type ErrorCodeInfo struct {
+	Code        ErrorCode `json:"code"`
+	Status      int       `json:"status"`
+	Description string    `json:"description"`
+}
+
+// errorCatalog is the registry of every error code the API returns, served at GET /errors.
+var errorCatalog = []ErrorCodeInfo{
+	{ErrCodeInvalidRequest, http.StatusBadRequest, "The request body or parameters could not be parsed."},
+	{ErrCodeIdempotencyKeyRequired, http.StatusBadRequest, "An Idempotency-Key header is required for this request."},
+	{ErrCodeInvalidIdempotencyKey, http.StatusBadRequest, "The Idempotency-Key header is too long or contains disallowed characters."},
+	{ErrCodeValidationFailed, http.StatusUnprocessableEntity, "The request is well-formed but failed validation."},
+	{ErrCodeInvalidAmount, http.StatusUnprocessableEntity, "The amount is missing, not positive or out of range."},
+	{ErrCodeInvalidCurrency, http.StatusUnprocessableEntity, "The currency is missing, unsupported or does not match."},
+	{ErrCodeInvalidCountry, http.StatusUnprocessableEntity, "The country is not an ISO 3166-1 alpha-2 code."},
+	{ErrCodeAmountExceedsRefundable, http.StatusUnprocessableEntity, "The refund amount exceeds what remains refundable."},
+	{ErrCodeIdempotencyConflict, http.StatusUnprocessableEntity, "The Idempotency-Key was already used with a different request."},
+	{ErrCodeRefundWindowExpired, http.StatusUnprocessableEntity, "The payment was captured too long ago to be refunded."},
+	{ErrCodeRefundLimitReached, http.StatusUnprocessableEntity, "The payment already has the maximum number of refunds allowed."},
+	{ErrCodeUnsupportedOperation, http.StatusUnprocessableEntity, "The payment gateway does not support this operation."},
+	{ErrCodePaymentDeclined, http.StatusPaymentRequired, "The payment gateway declined the operation."},
+	{ErrCodeInsufficientFunds, http.StatusPaymentRequired, "The payment gateway declined the operation for insufficient funds."},
+	{ErrCodeInvalidSignature, http.StatusUnauthorized, "The request signature is missing, outside the allowed time window or does not match."},
+	{ErrCodeInvalidAPIKey, http.StatusUnauthorized, "The bearer token is not a known API key."},
+	{ErrCodeForbidden, http.StatusForbidden, "The caller is not allowed to perform this operation."},
+	{ErrCodeNotFound, http.StatusNotFound, "The requested resource does not exist."},
+	{ErrCodeInvalidState, http.StatusConflict, "The resource's current state does not allow this operation."},
+	{ErrCodeIdempotencyInProgress, http.StatusConflict, "A request with the same Idempotency-Key is still in progress."},
+	{ErrCodeDuplicateRequest, http.StatusConflict, "An identical request from the same client is still in progress."},
+	{ErrCodeConcurrentModification, http.StatusConflict, "Another request changed the resource at the same time; fetch it again and retry."},
+	{ErrCodePaymentOperationInProgress, http.StatusConflict, "Another capture, refund or cancellation of the payment is still in progress; retry after it finishes."},
+	{ErrCodePayloadTooLarge, http.StatusRequestEntityTooLarge, "The request body is larger than this endpoint accepts."},
+	{ErrCodeGatewayError, http.StatusBadGateway, "The payment gateway failed or could not be reached."},
+	{ErrCodeGatewayMaintenance, http.StatusServiceUnavailable, "The payment gateway is down for scheduled maintenance; retry after the window ends."},
+	{ErrCodeRateLimited, http.StatusTooManyRequests, "The client exceeded its request quota; retry after the window resets."},
+	{ErrCodeServiceUnavailable, http.StatusServiceUnavailable, "The service is temporarily unable to handle the request; retry later."},
+	{ErrCodeRequestTimeout, http.StatusGatewayTimeout, "The request did not complete within its timeout; it may be retried with the same Idempotency-Key."},
+	{ErrCodeInternal, http.StatusInternalServerError, "An unexpected error occurred."},
+}
+
+// retryableErrorCodes are the client errors that say nothing about the request itself, only that it collided
+// with another one; the same request sent again later can succeed.
+var retryableErrorCodes = map[ErrorCode]bool{
+	ErrCodeIdempotencyInProgress:      true,
+	ErrCodeDuplicateRequest:           true,
+	ErrCodeConcurrentModification:     true,
+	ErrCodePaymentOperationInProgress: true,
+	ErrCodeRateLimited:                true,
+}
+
+// localsErrorCode holds the ErrorCode respondError answered with, so that middleware running after the handler
+// can tell why a request failed.
+const localsErrorCode = "error_code"
+
+// retryableResponse reports whether the response c carries is a server error or a retryable error code, which
+// must not be replayed to a client that retries.
+func retryableResponse(c *fiber.Ctx) bool {
+	if c.Response().StatusCode() >= fiber.StatusInternalServerError {
+		return true
+	}
+	code, _ := c.Locals(localsErrorCode).(ErrorCode)
+	return retryableErrorCodes[code]
+}
+
+var errorInfos = func() map[ErrorCode]ErrorCodeInfo {
+	infos := make(map[ErrorCode]ErrorCodeInfo, len(errorCatalog))
+	for _, info := range errorCatalog {
+		infos[info.Code] = info
+	}
+	return infos
+}()
+
+// errorStatus returns the HTTP status registered for code, or 500 for a code missing from the catalog.
+func errorStatus(code ErrorCode) int {
+	if info, ok := errorInfos[code]; ok {
+		return info.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// declineErrorCode maps a gateway decline reason to the registered error code.
+func declineErrorCode(reason string) ErrorCode {
+	if reason == "insufficient_funds" {
+		return ErrCodeInsufficientFunds
+	}
+	return ErrCodePaymentDeclined
+}
+
+// MIMEApplicationProblemJSON is the RFC 7807 media type clients can ask for errors in.
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// ProblemDetails is the RFC 7807 shape of an error, sent instead of the standard envelope to clients that
+// accept application/problem+json. Code carries our error code as an extension member.
+type ProblemDetails struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     ErrorCode `json:"code"`
+
+	TraceID   string `json:"trace_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// newProblemDetails maps an error code to its RFC 7807 form; the type points at the code's entry in the
+// GET /errors catalog.
+func newProblemDetails(code ErrorCode, message, instance string) ProblemDetails {
+	title := errorInfos[code].Description
+	if title == "" {
+		title = http.StatusText(errorStatus(code))
+	}
+	return ProblemDetails{
+		Type:     "/errors#" + string(code),
+		Title:    title,
+		Status:   errorStatus(code),
+		Detail:   message,
+		Instance: instance,
+		Code:     code,
+	}
+}
+
+// respondError writes the standard JSON error envelope with the HTTP status registered for code, or RFC 7807
+// problem details when the client prefers application/problem+json. Both carry the request's trace ID and
+// request ID when those middlewares are enabled, so a client can quote them and support can find the request's
+// span and access log line.
+func respondError(c *fiber.Ctx, code ErrorCode, message string) error {
+	c.Locals(localsErrorCode, code)
+	c.Vary(fiber.HeaderAccept)
+	traceID := SpanFromContext(c.UserContext()).TraceID()
+	requestID := RequestIDFromCtx(c)
+	if c.Accepts(fiber.MIMEApplicationJSON, MIMEApplicationProblemJSON) == MIMEApplicationProblemJSON {
+		problem := newProblemDetails(code, message, c.OriginalURL())
+		problem.TraceID = traceID
+		problem.RequestID = requestID
+		return c.Status(errorStatus(code)).JSON(problem, MIMEApplicationProblemJSON)
+	}
+	envelope := fiber.Map{
+		"error": message,
+		"code":  code,
+	}
+	if traceID != "" {
+		envelope["trace_id"] = traceID
+	}
+	if requestID != "" {
+		envelope["request_id"] = requestID
+	}
+	return c.Status(errorStatus(code)).JSON(envelope)
+}
+
+func listErrorCodes(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"errors": errorCatalog})
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeErrorCode(t *testing.T, resp *http.Response) ErrorCode {
+	var body struct {
+		Code ErrorCode `json:"code"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body.Code
+}
+
+func TestErrorCatalog(t *testing.T) {
+	t.Run("Codes Are Unique", func(t *testing.T) {
+		seen := make(map[ErrorCode]bool)
+		for _, info := range errorCatalog {
+			assert.False(t, seen[info.Code], "duplicate code %s", info.Code)
+			assert.NotEmpty(t, info.Description)
+			seen[info.Code] = true
+		}
+	})
+
+	t.Run("Catalog Endpoint Lists Codes", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/errors", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body struct {
+			Errors []ErrorCodeInfo `json:"errors"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Len(t, body.Errors, len(errorCatalog))
+		assert.Contains(t, body.Errors, ErrorCodeInfo{ErrCodeIdempotencyConflict, fiber.StatusUnprocessableEntity, "The Idempotency-Key was already used with a different request."})
+	})
+
+	t.Run("Unregistered Code Is Internal Error", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusInternalServerError, errorStatus("not_a_code"))
+	})
+}
+
+func TestErrorCodes(t *testing.T) {
+	newApp := func() *fiber.App {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{})
+		return app
+	}
+
+	t.Run("Codes In Envelope", func(t *testing.T) {
+		app := newApp()
+		postPayment(t, app, `{"amount":1000,"currency":"THB"}`, map[string]string{HeaderIdempotencyKey: "k1"})
+
+		cases := []struct {
+			name   string
+			method string
+			path   string
+			body   string
+			key    string
+			code   ErrorCode
+		}{
+			{"Malformed Body", http.MethodPost, "/payments", `{`, "", ErrCodeInvalidRequest},
+			{"Missing Currency", http.MethodPost, "/payments", `{"amount":1000}`, "", ErrCodeInvalidCurrency},
+			{"Non Positive Amount", http.MethodPost, "/payments", `{"amount":-1,"currency":"THB"}`, "", ErrCodeInvalidAmount},
+			{"Unknown Payment", http.MethodGet, "/payments/missing/timeline", "", "", ErrCodeNotFound},
+			{"Idempotency Conflict", http.MethodPost, "/payments", `{"amount":2000,"currency":"THB"}`, "k1", ErrCodeIdempotencyConflict},
+		}
+		for _, tc := range cases {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.key != "" {
+				req.Header.Set(HeaderIdempotencyKey, tc.key)
+			}
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, errorStatus(tc.code), resp.StatusCode, tc.name)
+			assert.Equal(t, tc.code, decodeErrorCode(t, resp), tc.name)
+		}
+	})
+
+	t.Run("Decline Reasons", func(t *testing.T) {
+		assert.Equal(t, ErrCodeInsufficientFunds, declineErrorCode("insufficient_funds"))
+		assert.Equal(t, ErrCodePaymentDeclined, declineErrorCode("card_declined"))
+	})
+}
+
+func TestProblemDetails(t *testing.T) {
+	app := fiber.New()
+	(&APIRouter{}).SetupRoutes(app, Config{})
+	post := func(accept string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/payments?source=test", strings.NewReader(`{"amount":0,"currency":"THB"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			req.Header.Set(fiber.HeaderAccept, accept)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Problem JSON When Requested", func(t *testing.T) {
+		resp := post(MIMEApplicationProblemJSON)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, MIMEApplicationProblemJSON, resp.Header.Get(fiber.HeaderContentType))
+
+		var problem ProblemDetails
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, ProblemDetails{
+			Type:     "/errors#invalid_amount",
+			Title:    "The amount is missing, not positive or out of range.",
+			Status:   fiber.StatusUnprocessableEntity,
+			Detail:   "amount must be a positive integer in minor units",
+			Instance: "/payments?source=test",
+			Code:     ErrCodeInvalidAmount,
+		}, problem)
+	})
+
+	t.Run("Standard Envelope By Default", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", fiber.MIMEApplicationJSON} {
+			resp := post(accept)
+			assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+			assert.Equal(t, fiber.MIMEApplicationJSON, resp.Header.Get(fiber.HeaderContentType))
+
+			var body map[string]string
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, map[string]string{"error": "amount must be a positive integer in minor units", "code": "invalid_amount"}, body)
+		}
+	})
+}
+
+// failingListStore fails every List, so listing payments answers 500.
+type failingListStore struct {
+	PaymentStore
+}
+
+func (s failingListStore) List(ctx context.Context) ([]Payment, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestErrorTraceID(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer func() { log.SetOutput(os.Stderr) }()
+	router := &APIRouter{store: failingListStore{NewMemoryPaymentStore()}}
+	get := func(t *testing.T, app *fiber.App, accept string) (*http.Response, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+		if accept != "" {
+			req.Header.Set(fiber.HeaderAccept, accept)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp, body
+	}
+
+	t.Run("Trace ID Matches The Logged Span", func(t *testing.T) {
+		buf.Reset()
+		app := fiber.New()
+		useMiddlewares(app, Config{MiddlewareRequestID: true, MiddlewareTracing: true})
+		router.SetupRoutes(app, Config{})
+
+		resp, body := get(t, app, "")
+		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+		traceID, _ := body["trace_id"].(string)
+		assert.Len(t, traceID, 32)
+		assert.Contains(t, buf.String(), "TRACE trace_id="+traceID+` span="GET /payments"`)
+		assert.Equal(t, resp.Header.Get(fiber.HeaderXRequestID), body["request_id"])
+	})
+
+	t.Run("Request ID Matches The Access Log When Tracing Is Off", func(t *testing.T) {
+		var accessLog bytes.Buffer
+		app := fiber.New()
+		app.Use(NewRequestIDMiddleware(), newAccessLogger(Config{}, &accessLog))
+		router.SetupRoutes(app, Config{})
+
+		resp, body := get(t, app, "")
+		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+		assert.NotContains(t, body, "trace_id")
+		requestID, _ := body["request_id"].(string)
+		assert.NotEmpty(t, requestID)
+		assert.Equal(t, resp.Header.Get(fiber.HeaderXRequestID), requestID)
+		assert.Contains(t, accessLog.String(), "| 500 |")
+		assert.Contains(t, accessLog.String(), "| "+requestID+" |")
+	})
+
+	t.Run("Problem Details Carry The IDs", func(t *testing.T) {
+		app := fiber.New()
+		useMiddlewares(app, Config{MiddlewareRequestID: true, MiddlewareTracing: true})
+		router.SetupRoutes(app, Config{})
+
+		resp, body := get(t, app, MIMEApplicationProblemJSON)
+		assert.Equal(t, MIMEApplicationProblemJSON, resp.Header.Get(fiber.HeaderContentType))
+		assert.NotEmpty(t, body["trace_id"])
+		assert.Equal(t, resp.Header.Get(fiber.HeaderXRequestID), body["request_id"])
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// EventType names something that happened to a payment.
+type EventType string
+
+const (
+	// EventPaymentCreated is recorded when a payment is created.
+	EventPaymentCreated EventType = "payment.created"
+	// EventPaymentAuthorized is recorded when a payment's funds are reserved.
+	EventPaymentAuthorized EventType = "payment.authorized"
+	// EventPaymentAuthorizationIncreased is recorded when an incremental authorization raises the held amount.
+	EventPaymentAuthorizationIncreased EventType = "payment.authorization_increased"
+	// EventPaymentCaptured is recorded when a payment's funds are settled.
+	EventPaymentCaptured EventType = "payment.captured"
+	// EventPaymentFailed is recorded when a payment is declined or errors.
+	EventPaymentFailed EventType = "payment.failed"
+	// EventPaymentCanceled is recorded when a payment is canceled before capture.
+	EventPaymentCanceled EventType = "payment.canceled"
+	// EventPaymentExpired is recorded when an asynchronous payment passes its deadline unpaid.
+	EventPaymentExpired EventType = "payment.expired"
+	// EventPaymentRefunded is recorded when a refund on the payment succeeds.
+	EventPaymentRefunded EventType = "payment.refunded"
+	// EventPaymentHeldForReview is recorded when the risk scorer holds a payment for manual review.
+	EventPaymentHeldForReview EventType = "payment.held_for_review"
+	// EventPaymentUpdated is recorded, next to any more specific event, when a payment's material fields change.
+	EventPaymentUpdated EventType = "payment.updated"
+)
+
+// knownEventTypes lists every event type, in the order of a payment's lifecycle.
+var knownEventTypes = []EventType{
+	EventPaymentCreated,
+	EventPaymentHeldForReview,
+	EventPaymentAuthorized,
+	EventPaymentAuthorizationIncreased,
+	EventPaymentCaptured,
+	EventPaymentRefunded,
+	EventPaymentFailed,
+	EventPaymentCanceled,
+	EventPaymentExpired,
+	EventPaymentUpdated,
+}
+
+// PaymentEvent records a state change of a payment.
+type PaymentEvent struct {
+	ID         string
+	PaymentID  string
+	Type       EventType
+	OccurredAt time.Time
+	// Changes lists the fields that changed, for payment.updated events.
+	Changes []string
+}
+
+// EventStore records payment events.
+type EventStore interface {
+	Append(ctx context.Context, event PaymentEvent) error
+	ListByPayment(ctx context.Context, paymentID string) ([]PaymentEvent, error)
+}
+
+// MemoryEventStore is an EventStore that keeps events in memory.
+type MemoryEventStore struct {
+	events memoryCollection[PaymentEvent]
+}
+
+// NewMemoryEventStore creates an empty MemoryEventStore.
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{}
+}
+
+// Append implements EventStore.
+func (s *MemoryEventStore) Append(_ context.Context, event PaymentEvent) error {
+	s.events.add(event)
+	return nil
+}
+
+// ListByPayment implements EventStore.
+func (s *MemoryEventStore) ListByPayment(_ context.Context, paymentID string) ([]PaymentEvent, error) {
+	return s.events.filter(func(e PaymentEvent) bool { return e.PaymentID == paymentID }), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// exportBatchSize is how many payments the export reads from the store per scan.
+const exportBatchSize = 100
+
+// contentTypeNDJSON is the media type of newline-delimited JSON.
+const contentTypeNDJSON = "application/x-ndjson"
+
+// exportPayments streams the payments matching the GET /payments filters as newline-delimited JSON, one
+// PaymentResponse per line. The store is read in batches with a cursor and each batch is flushed before the
+// next is read, so memory stays bounded however many payments match. Admin only.
+func (r *APIRouter) exportPayments(c *fiber.Ctx) error {
+	referenceNumber := utils.CopyString(c.Query("reference_number"))
+	testMode := requestTestMode(c)
+	matches := func(payment Payment) bool {
+		return payment.TestMode == testMode && (referenceNumber == "" || payment.ReferenceNumber == referenceNumber)
+	}
+	// The stream is written after the handler returns, so it must not be cut short by the request context.
+	ctx := context.WithoutCancel(c.UserContext())
+
+	c.Set(fiber.HeaderContentType, contentTypeNDJSON)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		encoder := json.NewEncoder(w)
+		after := ""
+		for {
+			batch, err := r.store.Scan(ctx, after, exportBatchSize)
+			if err != nil {
+				// The status line is already sent; the client sees a truncated stream.
+				log.Printf("Payment export stopped after id=%q: %v", after, err)
+				return
+			}
+			for _, payment := range batch {
+				if !matches(payment) {
+					continue
+				}
+				if err := encoder.Encode(newPaymentResponse(payment)); err != nil {
+					return
+				}
+			}
+			if err := w.Flush(); err != nil || len(batch) < exportBatchSize {
+				return
+			}
+			after = batch[len(batch)-1].ID
+		}
+	})
+	return nil
+}
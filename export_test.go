@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentExport(t *testing.T) {
+	ctx := context.Background()
+	newApp := func(t *testing.T, live, test int) *fiber.App {
+		store := NewMemoryPaymentStore()
+		now := time.Now().UTC()
+		for i := range live + test {
+			assert.NoError(t, store.Save(ctx, Payment{
+				ID: fmt.Sprintf("pay_%04d", i), Amount: int64(100 + i), Currency: "THB", Status: PaymentStatusAuthorized,
+				ReferenceNumber: fmt.Sprintf("REF%04d", i), TestMode: i >= live, CreatedAt: now, UpdatedAt: now,
+			}))
+		}
+		app := fiber.New()
+		(&APIRouter{store: store}).SetupRoutes(app, Config{AdminToken: "admin-secret", APIKeys: "sk_test_1=test"})
+		return app
+	}
+	export := func(t *testing.T, app *fiber.App, query string, headers map[string]string) (*http.Response, []PaymentResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/payments/export"+query, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		var payments []PaymentResponse
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var payment PaymentResponse
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &payment), scanner.Text())
+			payments = append(payments, payment)
+		}
+		assert.NoError(t, scanner.Err())
+		return resp, payments
+	}
+	admin := map[string]string{HeaderAdminToken: "admin-secret"}
+
+	t.Run("Streams Every Matching Payment", func(t *testing.T) {
+		app := newApp(t, 350, 20)
+
+		resp, payments := export(t, app, "", admin)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, contentTypeNDJSON, resp.Header.Get(fiber.HeaderContentType))
+		if assert.Len(t, payments, 350) {
+			assert.Equal(t, "pay_0000", payments[0].ID)
+			assert.Equal(t, "pay_0349", payments[349].ID)
+		}
+		for _, payment := range payments {
+			assert.False(t, payment.TestMode)
+		}
+	})
+
+	t.Run("Test Key Exports Test Payments", func(t *testing.T) {
+		app := newApp(t, 350, 20)
+
+		_, payments := export(t, app, "", map[string]string{
+			HeaderAdminToken: "admin-secret", fiber.HeaderAuthorization: "Bearer sk_test_1",
+		})
+		assert.Len(t, payments, 20)
+	})
+
+	t.Run("Filters By Reference Number", func(t *testing.T) {
+		app := newApp(t, 350, 0)
+
+		_, payments := export(t, app, "?reference_number=REF0123", admin)
+		if assert.Len(t, payments, 1) {
+			assert.Equal(t, "pay_0123", payments[0].ID)
+		}
+	})
+
+	t.Run("Admin Only", func(t *testing.T) {
+		app := newApp(t, 1, 0)
+
+		resp, _ := export(t, app, "", nil)
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestMemoryPaymentStoreScan(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryPaymentStore()
+	for i := range 5 {
+		assert.NoError(t, store.Save(ctx, Payment{ID: fmt.Sprintf("pay_%d", i)}))
+	}
+
+	first, err := store.Scan(ctx, "", 2)
+	assert.NoError(t, err)
+	second, _ := store.Scan(ctx, first[len(first)-1].ID, 2)
+	last, _ := store.Scan(ctx, second[len(second)-1].ID, 2)
+	assert.Equal(t, []string{"pay_0", "pay_1"}, []string{first[0].ID, first[1].ID})
+	assert.Equal(t, []string{"pay_2", "pay_3"}, []string{second[0].ID, second[1].ID})
+	if assert.Len(t, last, 1) {
+		assert.Equal(t, "pay_4", last[0].ID)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrGatewayUnavailable is returned when the gateway cannot be reached or answers with a transient failure.
+var ErrGatewayUnavailable = errors.New("gateway unavailable")
+
+// ErrGatewayTimeout is returned when the gateway did not answer in time; the operation may or may not have happened.
+var ErrGatewayTimeout = errors.New("gateway timeout")
+
+// GatewayOperation names a call made to a payment gateway.
+type GatewayOperation string
+
+const (
+	// GatewayOpAuthorize reserves funds on the payment method.
+	GatewayOpAuthorize GatewayOperation = "authorize"
+	// GatewayOpCapture settles previously authorized funds.
+	GatewayOpCapture GatewayOperation = "capture"
+	// GatewayOpVoid releases an authorization without settling it.
+	GatewayOpVoid GatewayOperation = "void"
+	// GatewayOpRefund returns captured funds to the payer.
+	GatewayOpRefund GatewayOperation = "refund"
+)
+
+// AuthorizeRequest carries the data a gateway needs to authorize a payment.
+type AuthorizeRequest struct {
+	PaymentID      string
+	IdempotencyKey string
+	Amount         Money
+	Method         string
+	Token          string
+	// StatementDescriptor is the text shown on the payer's statement; empty uses the gateway's default.
+	StatementDescriptor string
+	// CardBrand and IssuerCountry are resolved from the card's BIN when known, as routing hints.
+	CardBrand     string
+	IssuerCountry string
+	// GatewayMetadata carries processor-specific fields, limited to the gateway's AllowedMetadataKeys.
+	GatewayMetadata map[string]string
+	// ReturnURLs are where the gateway's hosted page or 3DS challenge sends the customer back to, if it has one.
+	ReturnURLs ReturnURLs
+}
+
+// AuthorizeResult is the gateway's answer to an authorization.
+type AuthorizeResult struct {
+	GatewayReference string
+	Approved         bool
+	DeclineReason    string
+	// Pending is set for asynchronous methods, where the customer still has to pay, e.g. by scanning a QR code.
+	Pending bool
+	// ApprovalCode is the issuer's authorization code; not every gateway returns one.
+	ApprovalCode string
+	// NetworkTransactionID is the card network's identifier for the transaction, when the gateway passes it on.
+	NetworkTransactionID string
+}
+
+// CaptureRequest carries the data a gateway needs to capture an authorization.
+type CaptureRequest struct {
+	PaymentID        string
+	IdempotencyKey   string
+	GatewayReference string
+	Amount           Money
+	// Method is the payment's method, used to route the call to the gateway serving it.
+	Method string
+}
+
+// CaptureResult is the gateway's answer to a capture.
+type CaptureResult struct {
+	GatewayReference string
+	// ApprovalCode and NetworkTransactionID are set by gateways that only report them on capture.
+	ApprovalCode         string
+	NetworkTransactionID string
+}
+
+// VoidRequest carries the data a gateway needs to release an authorization.
+type VoidRequest struct {
+	PaymentID        string
+	IdempotencyKey   string
+	GatewayReference string
+	// Method is the payment's method, used to route the call to the gateway serving it.
+	Method string
+	// Currency is the payment's currency, used to route the call to the gateway serving it.
+	Currency string
+}
+
+// RefundRequest carries the data a gateway needs to refund captured funds.
+type RefundRequest struct {
+	PaymentID        string
+	RefundID         string
+	IdempotencyKey   string
+	GatewayReference string
+	Amount           Money
+	// Method is the payment's method, used to route the call to the gateway serving it.
+	Method string
+}
+
+// RefundResult is the gateway's answer to a refund request.
+type RefundResult struct {
+	GatewayReference string
+	// Pending is set when the gateway accepted the refund but confirms its outcome later by webhook.
+	Pending bool
+}
+
+// PaymentGateway is implemented by every payment processor integration.
+// Implementations must forward the request's IdempotencyKey to the processor where its API supports it,
+// so that retrying a call with the same key never moves money twice.
+type PaymentGateway interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error)
+	Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error)
+	Void(ctx context.Context, req VoidRequest) error
+	Refund(ctx context.Context, req RefundRequest) (RefundResult, error)
+}
+
+// VerificationAmountRequirer is implemented by gateways that cannot authorize a zero amount for card
+// verification and need a minimal amount, which is voided right after.
+type VerificationAmountRequirer interface {
+	MinimumVerificationAmount(currency string) int64
+}
+
+// gatewayUnwrapper is implemented by gateway decorators to expose the gateway they wrap.
+type gatewayUnwrapper interface {
+	Unwrap() PaymentGateway
+}
+
+// gatewayAs returns the first gateway in a decorator chain that implements the optional capability T, so that
+// wrapping a gateway with retries or instrumentation does not hide what the underlying processor supports.
+func gatewayAs[T any](gateway PaymentGateway) (T, bool) {
+	for gateway != nil {
+		if capability, ok := gateway.(T); ok {
+			return capability, true
+		}
+		unwrapper, ok := gateway.(gatewayUnwrapper)
+		if !ok {
+			break
+		}
+		gateway = unwrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// GatewayIdempotencyKey derives the key sent to the gateway for an operation. The client's Idempotency-Key is
+// preferred when present; otherwise the key is derived from our own stable resource ID.
+func GatewayIdempotencyKey(resourceID string, op GatewayOperation, clientKey string) string {
+	if clientKey != "" {
+		return fmt.Sprintf("%s:%s", op, clientKey)
+	}
+	return fmt.Sprintf("%s:%s", op, resourceID)
+}
+
+// merchantClientKey scopes a client's Idempotency-Key to the merchant a payment belongs to before it becomes
+// part of a gateway key, since the gateway sees one key space for every merchant.
+func merchantClientKey(merchantID, clientKey string) string {
+	if merchantID == "" || clientKey == "" {
+		return clientKey
+	}
+	return merchantID + "/" + clientKey
+}
+
+// RetryingGateway decorates a PaymentGateway, retrying transient failures with the same idempotency key.
+type RetryingGateway struct {
+	PaymentGateway
+	Attempts int
+	Backoff  time.Duration
+}
+
+// NewRetryingGateway wraps gateway so that transient failures are retried up to attempts times in total.
+func NewRetryingGateway(gateway PaymentGateway, attempts int, backoff time.Duration) *RetryingGateway {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &RetryingGateway{PaymentGateway: gateway, Attempts: attempts, Backoff: backoff}
+}
+
+// Unwrap returns the decorated gateway.
+func (g *RetryingGateway) Unwrap() PaymentGateway {
+	return g.PaymentGateway
+}
+
+// Authorize implements PaymentGateway.
+func (g *RetryingGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = GatewayIdempotencyKey(req.PaymentID, GatewayOpAuthorize, "")
+	}
+	var result AuthorizeResult
+	err := g.retry(ctx, func() error {
+		var err error
+		result, err = g.PaymentGateway.Authorize(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// Capture implements PaymentGateway.
+func (g *RetryingGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = GatewayIdempotencyKey(req.PaymentID, GatewayOpCapture, "")
+	}
+	var result CaptureResult
+	err := g.retry(ctx, func() error {
+		var err error
+		result, err = g.PaymentGateway.Capture(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// Void implements PaymentGateway.
+func (g *RetryingGateway) Void(ctx context.Context, req VoidRequest) error {
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = GatewayIdempotencyKey(req.PaymentID, GatewayOpVoid, "")
+	}
+	return g.retry(ctx, func() error {
+		return g.PaymentGateway.Void(ctx, req)
+	})
+}
+
+// Refund implements PaymentGateway.
+func (g *RetryingGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = GatewayIdempotencyKey(req.RefundID, GatewayOpRefund, "")
+	}
+	var result RefundResult
+	err := g.retry(ctx, func() error {
+		var err error
+		result, err = g.PaymentGateway.Refund(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+func (g *RetryingGateway) retry(ctx context.Context, call func() error) error {
+	var err error
+	for attempt := 1; attempt <= g.Attempts; attempt++ {
+		if err = call(); err == nil || !isTransientGatewayError(err) {
+			return err
+		}
+		if attempt == g.Attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(g.Backoff * time.Duration(attempt)):
+		}
+	}
+	return err
+}
+
+func isTransientGatewayError(err error) bool {
+	return errors.Is(err, ErrGatewayUnavailable) || errors.Is(err, ErrGatewayTimeout)
+}
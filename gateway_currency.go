@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CurrencyGateway sends each call to the gateway configured for the payment's currency, such as THB to a Thai
+// acquirer and USD to an international one. Currencies without a route go to the fallback gateway.
+type CurrencyGateway struct {
+	fallback PaymentGateway
+	routes   map[string]PaymentGateway
+}
+
+// NewCurrencyGateway creates a CurrencyGateway over fallback. routes maps currency codes to gateway names, which
+// must be among gateways; an unknown name is an error rather than a silent fallback.
+func NewCurrencyGateway(fallback PaymentGateway, gateways []PaymentGateway, routes map[string]string) (*CurrencyGateway, error) {
+	byName := make(map[string]PaymentGateway, len(gateways))
+	for _, gateway := range gateways {
+		byName[gateway.Name()] = gateway
+	}
+	g := &CurrencyGateway{fallback: fallback, routes: make(map[string]PaymentGateway, len(routes))}
+	for currency, name := range routes {
+		gateway, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("GATEWAY_CURRENCY_ROUTES maps %s to unknown gateway %q", currency, name)
+		}
+		g.routes[currency] = gateway
+	}
+	return g, nil
+}
+
+// parseCurrencyRoutes parses GATEWAY_CURRENCY_ROUTES, a comma-separated list of "currency=gateway" pairs such as
+// "THB=kbank,USD=stripe".
+func parseCurrencyRoutes(spec string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		currency, gateway, ok := strings.Cut(entry, "=")
+		currency, gateway = strings.ToUpper(strings.TrimSpace(currency)), strings.TrimSpace(gateway)
+		if !ok || gateway == "" {
+			return nil, fmt.Errorf("invalid GATEWAY_CURRENCY_ROUTES entry %q: want currency=gateway", entry)
+		}
+		if err := ValidateCurrencyCode(currency); err != nil {
+			return nil, fmt.Errorf("invalid GATEWAY_CURRENCY_ROUTES entry %q: %w", entry, err)
+		}
+		if _, seen := routes[currency]; seen {
+			return nil, fmt.Errorf("invalid GATEWAY_CURRENCY_ROUTES: currency %s is listed twice", currency)
+		}
+		routes[currency] = gateway
+	}
+	return routes, nil
+}
+
+// newCurrencyGateway routes gateway's traffic by GATEWAY_CURRENCY_ROUTES over gateways, or returns gateway
+// unchanged when no routes are configured.
+func newCurrencyGateway(gateway PaymentGateway, gateways []PaymentGateway, config Config) (PaymentGateway, error) {
+	// Validate has already rejected malformed routes.
+	routes, _ := parseCurrencyRoutes(config.GatewayCurrencyRoutes)
+	if len(routes) == 0 {
+		return gateway, nil
+	}
+	return NewCurrencyGateway(gateway, gateways, routes)
+}
+
+// gatewayFor returns the gateway serving currency.
+func (g *CurrencyGateway) gatewayFor(currency string) PaymentGateway {
+	if gateway, ok := g.routes[strings.ToUpper(currency)]; ok {
+		return gateway
+	}
+	return g.fallback
+}
+
+// Name implements PaymentGateway.
+func (g *CurrencyGateway) Name() string {
+	return g.fallback.Name()
+}
+
+// Unwrap returns the fallback gateway.
+func (g *CurrencyGateway) Unwrap() PaymentGateway {
+	return g.fallback
+}
+
+// Authorize implements PaymentGateway.
+func (g *CurrencyGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	return g.gatewayFor(req.Amount.Currency).Authorize(ctx, req)
+}
+
+// Capture implements PaymentGateway.
+func (g *CurrencyGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	return g.gatewayFor(req.Amount.Currency).Capture(ctx, req)
+}
+
+// Void implements PaymentGateway.
+func (g *CurrencyGateway) Void(ctx context.Context, req VoidRequest) error {
+	return g.gatewayFor(req.Currency).Void(ctx, req)
+}
+
+// Refund implements PaymentGateway.
+func (g *CurrencyGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	return g.gatewayFor(req.Amount.Currency).Refund(ctx, req)
+}
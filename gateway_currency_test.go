@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCurrencyRoutes(t *testing.T) {
+	routes, err := parseCurrencyRoutes(" thb=kbank , USD=stripe ")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"THB": "kbank", "USD": "stripe"}, routes)
+
+	for _, spec := range []string{"THB", "THB=", "XXY=kbank", "THB=kbank,thb=stripe"} {
+		_, err := parseCurrencyRoutes(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCurrencyGateway(t *testing.T) {
+	ctx := context.Background()
+	newGateway := func(t *testing.T) (*CurrencyGateway, *SandboxGateway, *SandboxGateway, *SandboxGateway) {
+		fallback, kbank, stripe := NewSandboxGateway("sandbox"), NewSandboxGateway("kbank"), NewSandboxGateway("stripe")
+		gateway, err := NewCurrencyGateway(fallback, []PaymentGateway{kbank, stripe}, map[string]string{"THB": "kbank", "USD": "stripe"})
+		assert.NoError(t, err)
+		return gateway, fallback, kbank, stripe
+	}
+
+	t.Run("Routes Mapped Currencies", func(t *testing.T) {
+		gateway, fallback, kbank, stripe := newGateway(t)
+
+		_, err := gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay_thb", Amount: NewMoney(1000, "THB")})
+		assert.NoError(t, err)
+		_, err = gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay_usd", Amount: NewMoney(1000, "usd")})
+		assert.NoError(t, err)
+		_, err = gateway.Capture(ctx, CaptureRequest{PaymentID: "pay_usd", Amount: NewMoney(1000, "USD")})
+		assert.NoError(t, err)
+		assert.NoError(t, gateway.Void(ctx, VoidRequest{PaymentID: "pay_thb", Currency: "THB"}))
+
+		assert.Equal(t, 1, kbank.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 1, kbank.Processed(GatewayOpVoid))
+		assert.Equal(t, 1, stripe.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 1, stripe.Processed(GatewayOpCapture))
+		assert.Equal(t, 0, fallback.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Unmapped Currency Falls Back", func(t *testing.T) {
+		gateway, fallback, kbank, stripe := newGateway(t)
+
+		_, err := gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay_eur", Amount: NewMoney(1000, "EUR")})
+		assert.NoError(t, err)
+		_, err = gateway.Refund(ctx, RefundRequest{PaymentID: "pay_eur", Amount: NewMoney(500, "EUR")})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 1, fallback.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 1, fallback.Processed(GatewayOpRefund))
+		assert.Equal(t, 0, kbank.Processed(GatewayOpAuthorize)+stripe.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Unknown Gateway Rejected", func(t *testing.T) {
+		_, err := NewCurrencyGateway(NewSandboxGateway("sandbox"), []PaymentGateway{NewSandboxGateway("kbank")}, map[string]string{"USD": "stripe"})
+		assert.ErrorContains(t, err, `unknown gateway "stripe"`)
+	})
+
+	t.Run("No Routes Leaves Gateway Unchanged", func(t *testing.T) {
+		fallback := NewSandboxGateway("sandbox")
+		gateway, err := newCurrencyGateway(fallback, nil, Config{})
+		assert.NoError(t, err)
+		assert.Same(t, fallback, gateway)
+	})
+}
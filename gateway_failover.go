@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultFailbackRampUp is how long a recovered primary takes to get all of its traffic back by default.
+	defaultFailbackRampUp = 2 * time.Minute
+	// failoverProbeShare is the share of traffic a primary gets while its breaker is half-open, and the share a
+	// ramp-up starts from.
+	failoverProbeShare = 0.1
+	// failoverLatencyDecay is the weight of the newest call in the primary's moving latency average.
+	failoverLatencyDecay = 0.2
+	// failoverCreditScale converts shares to whole credits, so that rounding never drops a pick.
+	failoverCreditScale = 1000
+)
+
+// FailoverGateway sends traffic to a primary connection and shifts it to a secondary as the primary degrades.
+// The primary's share of calls follows its health: none while its breaker is open, a probe share while it is
+// half-open, and a share reduced in proportion to its moving latency average once that exceeds
+// LatencyThreshold. After the breaker closes again the share ramps back up linearly over RampUp, so a
+// recovering primary is not hit with full traffic at once. As with WeightedGateway, both connections must
+// reach the same processor account, since a capture may go through a different one than its authorization.
+type FailoverGateway struct {
+	mu        sync.Mutex
+	primary   GatewayEndpoint
+	secondary GatewayEndpoint
+
+	// RampUp is how long the primary takes to return to full traffic after recovering; 0 returns it at once.
+	RampUp time.Duration
+	// LatencyThreshold, when above zero, is the moving latency average above which the primary loses traffic.
+	LatencyThreshold time.Duration
+
+	latency     time.Duration
+	degraded    bool
+	recoveredAt time.Time
+	credit      int
+	now         func() time.Time
+}
+
+// NewFailoverGateway creates a FailoverGateway over primary and secondary, each guarded by its own breaker.
+func NewFailoverGateway(primary, secondary GatewayEndpoint, rampUp time.Duration) *FailoverGateway {
+	return &FailoverGateway{primary: primary, secondary: secondary, RampUp: rampUp, now: time.Now}
+}
+
+// Name implements PaymentGateway.
+func (g *FailoverGateway) Name() string {
+	return g.primary.Gateway.Name()
+}
+
+// Unwrap returns the primary gateway; both connections share the processor's capabilities.
+func (g *FailoverGateway) Unwrap() PaymentGateway {
+	return g.primary.Gateway
+}
+
+// PrimaryShare reports the fraction of calls currently routed to the primary, between 0 and 1.
+func (g *FailoverGateway) PrimaryShare() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.primaryShare()
+}
+
+// primaryShare derives the primary's share of traffic from its breaker state, ramp-up and latency. The caller
+// holds g.mu.
+func (g *FailoverGateway) primaryShare() float64 {
+	switch g.primary.Breaker.Status().State {
+	case CircuitOpen:
+		g.degraded = true
+		return 0
+	case CircuitHalfOpen:
+		g.degraded = true
+		return failoverProbeShare
+	}
+	now := g.now()
+	if g.degraded {
+		g.degraded = false
+		g.recoveredAt = now
+	}
+	share := 1.0
+	if elapsed := now.Sub(g.recoveredAt); !g.recoveredAt.IsZero() && elapsed < g.RampUp {
+		share = failoverProbeShare + (1-failoverProbeShare)*float64(elapsed)/float64(g.RampUp)
+	}
+	if g.LatencyThreshold > 0 && g.latency > g.LatencyThreshold {
+		share *= float64(g.LatencyThreshold) / float64(g.latency)
+	}
+	return share
+}
+
+// next picks the endpoint for the next call. Each call credits the primary with its share and the primary is
+// picked whenever a whole call's worth has accrued, which spreads its calls evenly instead of bursting them.
+func (g *FailoverGateway) next() (GatewayEndpoint, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.credit += int(math.Round(g.primaryShare() * failoverCreditScale))
+	if g.credit >= failoverCreditScale {
+		g.credit -= failoverCreditScale
+		if g.primary.Breaker.Allow() {
+			return g.primary, true
+		}
+	}
+	return g.secondary, false
+}
+
+// observe folds a primary call's duration into the moving latency average.
+func (g *FailoverGateway) observe(elapsed time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.latency == 0 {
+		g.latency = elapsed
+		return
+	}
+	g.latency = time.Duration(failoverLatencyDecay*float64(elapsed) + (1-failoverLatencyDecay)*float64(g.latency))
+}
+
+// call runs fn on the endpoint picked for it, counting transient failures against that endpoint's breaker.
+func (g *FailoverGateway) call(fn func(gateway PaymentGateway) error) error {
+	endpoint, primary := g.next()
+	if !primary && !endpoint.Breaker.Allow() {
+		return fmt.Errorf("%w: both %s connections are unavailable", ErrCircuitOpen, g.Name())
+	}
+	start := g.now()
+	err := fn(endpoint.Gateway)
+	if primary {
+		g.observe(g.now().Sub(start))
+	}
+	if err != nil && isTransientGatewayError(err) {
+		endpoint.Breaker.RecordFailure()
+	} else {
+		endpoint.Breaker.RecordSuccess()
+	}
+	return err
+}
+
+// Authorize implements PaymentGateway.
+func (g *FailoverGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	var result AuthorizeResult
+	err := g.call(func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Authorize(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// Capture implements PaymentGateway.
+func (g *FailoverGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	var result CaptureResult
+	err := g.call(func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Capture(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// Void implements PaymentGateway.
+func (g *FailoverGateway) Void(ctx context.Context, req VoidRequest) error {
+	return g.call(func(gateway PaymentGateway) error {
+		return gateway.Void(ctx, req)
+	})
+}
+
+// Refund implements PaymentGateway.
+func (g *FailoverGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	var result RefundResult
+	err := g.call(func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Refund(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// newFailoverGateway puts gateway behind a FailoverGateway when GATEWAY_FAILOVER is set, registering both
+// connections' breakers in breakers, or returns gateway unchanged. Until secondary credentials are
+// configurable both connections share gateway's connection.
+func newFailoverGateway(gateway PaymentGateway, config Config, breakers *CircuitBreakerRegistry) PaymentGateway {
+	if !config.GatewayFailover {
+		return gateway
+	}
+	primary := GatewayEndpoint{Gateway: gateway, Breaker: NewCircuitBreaker(gateway.Name()+".primary", 0, 0)}
+	secondary := GatewayEndpoint{Gateway: gateway, Breaker: NewCircuitBreaker(gateway.Name()+".secondary", 0, 0)}
+	breakers.Register(primary.Breaker)
+	breakers.Register(secondary.Breaker)
+	failover := NewFailoverGateway(primary, secondary, config.FailbackRampUp)
+	failover.LatencyThreshold = config.FailoverLatencyThreshold
+	return failover
+}
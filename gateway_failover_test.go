@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// outageGateway fails every call with ErrGatewayUnavailable while down, and advances clock by delay per call.
+type outageGateway struct {
+	*SandboxGateway
+	mu    sync.Mutex
+	down  bool
+	delay time.Duration
+	clock *time.Time
+}
+
+func (g *outageGateway) SetDown(down bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.down = down
+}
+
+func (g *outageGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	g.mu.Lock()
+	down := g.down
+	if g.clock != nil {
+		*g.clock = g.clock.Add(g.delay)
+	}
+	g.mu.Unlock()
+	if down {
+		return AuthorizeResult{}, ErrGatewayUnavailable
+	}
+	return g.SandboxGateway.Authorize(ctx, req)
+}
+
+func TestFailoverGateway(t *testing.T) {
+	ctx := context.Background()
+	newGateway := func() (*FailoverGateway, *outageGateway, *SandboxGateway, *time.Time) {
+		now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		clock := func() time.Time { return now }
+		primary := &outageGateway{SandboxGateway: NewSandboxGateway("primary"), clock: &now}
+		secondary := NewSandboxGateway("secondary")
+		primaryBreaker := NewCircuitBreaker("sandbox.primary", 3, 30*time.Second)
+		primaryBreaker.now = clock
+		gateway := NewFailoverGateway(
+			GatewayEndpoint{Gateway: primary, Breaker: primaryBreaker},
+			GatewayEndpoint{Gateway: secondary, Breaker: NewCircuitBreaker("sandbox.secondary", 3, 30*time.Second)},
+			time.Minute,
+		)
+		gateway.now = clock
+		return gateway, primary, secondary, &now
+	}
+	authorizeN := func(gateway PaymentGateway, n int) {
+		for i := 0; i < n; i++ {
+			_, _ = gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay"})
+		}
+	}
+
+	t.Run("Healthy Primary Takes All Traffic", func(t *testing.T) {
+		gateway, primary, secondary, _ := newGateway()
+
+		authorizeN(gateway, 20)
+		assert.Equal(t, 20, primary.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 0, secondary.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Primary Failure Triggers Failover", func(t *testing.T) {
+		gateway, primary, secondary, _ := newGateway()
+		primary.SetDown(true)
+
+		for i := 0; i < 3; i++ {
+			_, err := gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay"})
+			assert.ErrorIs(t, err, ErrGatewayUnavailable)
+		}
+		assert.Zero(t, gateway.PrimaryShare())
+
+		authorizeN(gateway, 10)
+		assert.Equal(t, 10, secondary.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Recovery Fails Back Gradually", func(t *testing.T) {
+		gateway, primary, secondary, now := newGateway()
+		primary.SetDown(true)
+		authorizeN(gateway, 3)
+		primary.SetDown(false)
+
+		// After the cooldown the half-open primary only gets probe traffic; a successful probe closes it.
+		*now = now.Add(30 * time.Second)
+		assert.Equal(t, failoverProbeShare, gateway.PrimaryShare())
+		authorizeN(gateway, 10)
+		assert.Equal(t, 1, primary.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 9, secondary.Processed(GatewayOpAuthorize))
+
+		// The ramp starts from the probe share, reaches about half way after half the ramp-up, then ends.
+		assert.InDelta(t, failoverProbeShare, gateway.PrimaryShare(), 0.01)
+		*now = now.Add(30 * time.Second)
+		assert.InDelta(t, 0.55, gateway.PrimaryShare(), 0.01)
+		authorizeN(gateway, 20)
+		assert.InDelta(t, 1+11, primary.Processed(GatewayOpAuthorize), 1)
+
+		*now = now.Add(30 * time.Second)
+		assert.Equal(t, 1.0, gateway.PrimaryShare())
+		before := secondary.Processed(GatewayOpAuthorize)
+		authorizeN(gateway, 10)
+		assert.Equal(t, before, secondary.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Slow Primary Loses Traffic", func(t *testing.T) {
+		gateway, primary, secondary, _ := newGateway()
+		gateway.LatencyThreshold = 100 * time.Millisecond
+		primary.delay = 400 * time.Millisecond
+
+		authorizeN(gateway, 1)
+		assert.InDelta(t, 0.25, gateway.PrimaryShare(), 0.01)
+
+		authorizeN(gateway, 20)
+		assert.Greater(t, secondary.Processed(GatewayOpAuthorize), 10)
+	})
+
+	t.Run("Both Connections Down", func(t *testing.T) {
+		gateway, primary, _, _ := newGateway()
+		primary.SetDown(true)
+		authorizeN(gateway, 3)
+		for i := 0; i < 3; i++ {
+			gateway.secondary.Breaker.RecordFailure()
+		}
+
+		_, err := gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay"})
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+}
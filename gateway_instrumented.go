@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// gatewayLatencyMetric is the histogram of gateway call durations in seconds, labelled by gateway and operation.
+const gatewayLatencyMetric = "payment_gateway_request_duration_seconds"
+
+// InstrumentedGateway decorates a PaymentGateway, recording call latency per operation and logging a
+// warning for calls slower than SlowThreshold even when they succeed. When Health is set, each call and
+// whether it failed also count towards the health score; declines are answers, not failures.
+type InstrumentedGateway struct {
+	PaymentGateway
+	Metrics       *MetricsRegistry
+	SlowThreshold time.Duration
+	Health        *DependencyHealth
+}
+
+// NewInstrumentedGateway wraps gateway with latency metrics and slow-call warnings; a zero slowThreshold
+// disables the warnings.
+func NewInstrumentedGateway(gateway PaymentGateway, metrics *MetricsRegistry, slowThreshold time.Duration) *InstrumentedGateway {
+	return &InstrumentedGateway{PaymentGateway: gateway, Metrics: metrics, SlowThreshold: slowThreshold}
+}
+
+// Unwrap returns the decorated gateway.
+func (g *InstrumentedGateway) Unwrap() PaymentGateway {
+	return g.PaymentGateway
+}
+
+// Authorize implements PaymentGateway.
+func (g *InstrumentedGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	started := time.Now()
+	result, err := g.PaymentGateway.Authorize(ctx, req)
+	g.observe(GatewayOpAuthorize, req.PaymentID, started, err)
+	return result, err
+}
+
+// Capture implements PaymentGateway.
+func (g *InstrumentedGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	started := time.Now()
+	result, err := g.PaymentGateway.Capture(ctx, req)
+	g.observe(GatewayOpCapture, req.PaymentID, started, err)
+	return result, err
+}
+
+// Void implements PaymentGateway.
+func (g *InstrumentedGateway) Void(ctx context.Context, req VoidRequest) error {
+	started := time.Now()
+	err := g.PaymentGateway.Void(ctx, req)
+	g.observe(GatewayOpVoid, req.PaymentID, started, err)
+	return err
+}
+
+// Refund implements PaymentGateway.
+func (g *InstrumentedGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	started := time.Now()
+	result, err := g.PaymentGateway.Refund(ctx, req)
+	g.observe(GatewayOpRefund, req.PaymentID, started, err)
+	return result, err
+}
+
+func (g *InstrumentedGateway) observe(op GatewayOperation, paymentID string, started time.Time, err error) {
+	elapsed := time.Since(started)
+	g.Metrics.Observe(gatewayLatencyMetric, Labels{"gateway": g.Name(), "operation": string(op)}, elapsed.Seconds())
+	g.Health.Record("gateway:"+g.Name(), elapsed, err != nil)
+
+	if g.SlowThreshold > 0 && elapsed > g.SlowThreshold {
+		log.Printf("WARN slow gateway call gateway=%s operation=%s payment_id=%s duration=%s threshold=%s",
+			g.Name(), op, paymentID, elapsed.Round(time.Millisecond), g.SlowThreshold)
+	}
+}
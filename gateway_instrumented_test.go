@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowGateway delays every authorization to simulate a degraded gateway.
+type slowGateway struct {
+	PaymentGateway
+	delay time.Duration
+}
+
+func (g *slowGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	time.Sleep(g.delay)
+	return g.PaymentGateway.Authorize(ctx, req)
+}
+
+func TestInstrumentedGateway(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer func() { log.SetOutput(os.Stderr) }()
+
+	t.Run("Records Latency Histogram Per Operation", func(t *testing.T) {
+		buf.Reset()
+		metrics := NewMetricsRegistry()
+		gateway := NewInstrumentedGateway(NewSandboxGateway("sandbox"), metrics, time.Second)
+
+		_, err := gateway.Authorize(context.Background(), AuthorizeRequest{PaymentID: "pay_1"})
+		assert.NoError(t, err)
+		_, err = gateway.Capture(context.Background(), CaptureRequest{PaymentID: "pay_1"})
+		assert.NoError(t, err)
+
+		assert.Equal(t, uint64(1), metrics.HistogramCount(gatewayLatencyMetric, Labels{"gateway": "sandbox", "operation": "authorize"}))
+		assert.Equal(t, uint64(1), metrics.HistogramCount(gatewayLatencyMetric, Labels{"gateway": "sandbox", "operation": "capture"}))
+		assert.NotContains(t, buf.String(), "slow gateway call")
+	})
+
+	t.Run("Slow Call Logs Warning Tagged With Payment", func(t *testing.T) {
+		buf.Reset()
+		metrics := NewMetricsRegistry()
+		slow := &slowGateway{PaymentGateway: NewSandboxGateway("sandbox"), delay: 20 * time.Millisecond}
+		gateway := NewInstrumentedGateway(slow, metrics, 5*time.Millisecond)
+
+		_, err := gateway.Authorize(context.Background(), AuthorizeRequest{PaymentID: "pay_slow"})
+		assert.NoError(t, err)
+
+		assert.Contains(t, buf.String(), "WARN slow gateway call")
+		assert.Contains(t, buf.String(), "operation=authorize")
+		assert.Contains(t, buf.String(), "payment_id=pay_slow")
+		assert.Equal(t, uint64(1), metrics.HistogramCount(gatewayLatencyMetric, Labels{"gateway": "sandbox", "operation": "authorize"}))
+	})
+}
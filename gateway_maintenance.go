@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrGatewayMaintenance is returned for calls made during one of a gateway's scheduled maintenance windows.
+var ErrGatewayMaintenance = errors.New("gateway is down for scheduled maintenance")
+
+// MaintenanceWindow is a period during which a gateway, or one of its payment methods, is known to be down.
+type MaintenanceWindow struct {
+	// Key is the gateway name or "gateway/method", as in SETTLEMENT_DELAYS.
+	Key   string
+	Start time.Time
+	End   time.Time
+}
+
+// MaintenanceError reports the maintenance window that rejected a call, so the client can be told when to retry.
+type MaintenanceError struct {
+	Gateway string
+	// Method is set when the window covers only that payment method of the gateway.
+	Method string
+	Until  time.Time
+}
+
+func (e *MaintenanceError) Error() string {
+	return fmt.Sprintf("%v: %s until %s", ErrGatewayMaintenance, e.Gateway, e.Until.Format(time.RFC3339))
+}
+
+// Unwrap makes errors.Is match ErrGatewayMaintenance.
+func (e *MaintenanceError) Unwrap() error {
+	return ErrGatewayMaintenance
+}
+
+// parseMaintenanceWindows parses GATEWAY_MAINTENANCE_WINDOWS, a comma-separated list of "key=start/end" entries
+// with RFC 3339 times, such as "kbank=2026-11-01T01:00:00+07:00/2026-11-01T03:00:00+07:00". The key is a gateway
+// name or "gateway/method" for one method only; a key may have several windows.
+func parseMaintenanceWindows(spec string) ([]MaintenanceWindow, error) {
+	var windows []MaintenanceWindow
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, period, ok := strings.Cut(entry, "=")
+		rawStart, rawEnd, hasEnd := strings.Cut(period, "/")
+		start, startErr := time.Parse(time.RFC3339, strings.TrimSpace(rawStart))
+		end, endErr := time.Parse(time.RFC3339, strings.TrimSpace(rawEnd))
+		key = strings.TrimSpace(key)
+		if !ok || !hasEnd || key == "" || startErr != nil || endErr != nil {
+			return nil, fmt.Errorf("invalid GATEWAY_MAINTENANCE_WINDOWS entry %q: want key=start/end in RFC 3339", entry)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("invalid GATEWAY_MAINTENANCE_WINDOWS entry %q: end must be after start", entry)
+		}
+		windows = append(windows, MaintenanceWindow{Key: key, Start: start, End: end})
+	}
+	return windows, nil
+}
+
+// MaintenanceGateway fails calls fast during the wrapped gateway's scheduled maintenance windows instead of
+// sending them to a processor that is known to be down and waiting for them to time out. The rejection does
+// not count against circuit breakers, since nothing was called.
+type MaintenanceGateway struct {
+	gateway PaymentGateway
+	windows []MaintenanceWindow
+	now     func() time.Time
+}
+
+// NewMaintenanceGateway wraps gateway with the windows whose key names it or one of its methods.
+func NewMaintenanceGateway(gateway PaymentGateway, windows []MaintenanceWindow) *MaintenanceGateway {
+	g := &MaintenanceGateway{gateway: gateway, now: time.Now}
+	for _, window := range windows {
+		if name, _, _ := strings.Cut(window.Key, "/"); name == gateway.Name() {
+			g.windows = append(g.windows, window)
+		}
+	}
+	return g
+}
+
+// newMaintenanceGateway wraps gateway in a MaintenanceGateway when GATEWAY_MAINTENANCE_WINDOWS schedules
+// windows for it, or returns gateway unchanged.
+func newMaintenanceGateway(gateway PaymentGateway, config Config) PaymentGateway {
+	// Validate has already rejected malformed GATEWAY_MAINTENANCE_WINDOWS.
+	windows, _ := parseMaintenanceWindows(config.GatewayMaintenanceWindows)
+	maintenance := NewMaintenanceGateway(gateway, windows)
+	if len(maintenance.windows) == 0 {
+		return gateway
+	}
+	return maintenance
+}
+
+// Name implements PaymentGateway.
+func (g *MaintenanceGateway) Name() string {
+	return g.gateway.Name()
+}
+
+// Unwrap returns the wrapped gateway.
+func (g *MaintenanceGateway) Unwrap() PaymentGateway {
+	return g.gateway
+}
+
+// check returns a MaintenanceError when a window covering the gateway or method is in progress. Of several
+// overlapping windows it reports the latest end, since the call cannot succeed before then.
+func (g *MaintenanceGateway) check(method string) error {
+	now := g.now()
+	var current *MaintenanceError
+	for _, window := range g.windows {
+		methodOnly := window.Key == g.Name()+"/"+method
+		if window.Key != g.Name() && !methodOnly {
+			continue
+		}
+		if now.Before(window.Start) || !now.Before(window.End) || (current != nil && !window.End.After(current.Until)) {
+			continue
+		}
+		current = &MaintenanceError{Gateway: g.Name(), Until: window.End}
+		if methodOnly {
+			current.Method = method
+		}
+	}
+	if current == nil {
+		return nil
+	}
+	return current
+}
+
+// Authorize implements PaymentGateway.
+func (g *MaintenanceGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	if err := g.check(req.Method); err != nil {
+		return AuthorizeResult{}, err
+	}
+	return g.gateway.Authorize(ctx, req)
+}
+
+// Capture implements PaymentGateway.
+func (g *MaintenanceGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	if err := g.check(req.Method); err != nil {
+		return CaptureResult{}, err
+	}
+	return g.gateway.Capture(ctx, req)
+}
+
+// Void implements PaymentGateway.
+func (g *MaintenanceGateway) Void(ctx context.Context, req VoidRequest) error {
+	if err := g.check(req.Method); err != nil {
+		return err
+	}
+	return g.gateway.Void(ctx, req)
+}
+
+// Refund implements PaymentGateway.
+func (g *MaintenanceGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	if err := g.check(req.Method); err != nil {
+		return RefundResult{}, err
+	}
+	return g.gateway.Refund(ctx, req)
+}
+
+// respondGatewayMaintenance answers a request rejected by a maintenance window with 503, naming the window's
+// end in the message and counting down to it in Retry-After, jittered so that clients do not all return at once.
+func respondGatewayMaintenance(c *fiber.Ctx, err *MaintenanceError, jitter time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, RetryAfter{Base: time.Until(err.Until), Jitter: jitter}.Header())
+	subject := "gateway " + err.Gateway
+	if err.Method != "" {
+		subject = "payment method " + err.Method + " on " + subject
+	}
+	return respondError(c, ErrCodeGatewayMaintenance,
+		fmt.Sprintf("%s is down for scheduled maintenance until %s", subject, err.Until.UTC().Format(time.RFC3339)))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatewayMaintenanceWindows(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	window := func(key string, start, end time.Time) MaintenanceWindow {
+		return MaintenanceWindow{Key: key, Start: start, End: end}
+	}
+	newApp := func(windows ...MaintenanceWindow) *fiber.App {
+		app := fiber.New()
+		(&APIRouter{gateway: NewMaintenanceGateway(NewSandboxGateway("sandbox"), windows)}).SetupRoutes(app, Config{})
+		return app
+	}
+	createPayment := func(t *testing.T, app *fiber.App, method string) (*http.Response, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/payments",
+			strings.NewReader(`{"amount":1000,"currency":"THB","method":"`+method+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var body map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp, body
+	}
+
+	t.Run("Payment During Window Rejected Until Its End", func(t *testing.T) {
+		end := now.Add(time.Hour)
+		app := newApp(window("sandbox", now.Add(-time.Minute), end))
+
+		resp, body := createPayment(t, app, "card")
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, string(ErrCodeGatewayMaintenance), body["code"])
+		assert.Contains(t, body["error"], end.Format(time.RFC3339))
+		retryAfter, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter))
+		assert.NoError(t, err)
+		assert.InDelta(t, time.Hour.Seconds(), retryAfter, 5)
+	})
+
+	t.Run("Retry-After Is Jittered", func(t *testing.T) {
+		app := fiber.New()
+		gateway := NewMaintenanceGateway(NewSandboxGateway("sandbox"), []MaintenanceWindow{window("sandbox", now.Add(-time.Minute), now.Add(time.Minute))})
+		(&APIRouter{gateway: gateway}).SetupRoutes(app, Config{RetryAfterJitter: time.Hour})
+
+		seen := make(map[string]bool)
+		for i := 0; i < 10; i++ {
+			resp, _ := createPayment(t, app, "card")
+			retryAfter, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter))
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, retryAfter, 55)
+			assert.LessOrEqual(t, retryAfter, 3660)
+			seen[resp.Header.Get(fiber.HeaderRetryAfter)] = true
+		}
+		assert.Greater(t, len(seen), 1, "clients are spread over the jitter")
+	})
+
+	t.Run("Method Window Leaves Other Methods Working", func(t *testing.T) {
+		app := newApp(window("sandbox/"+MethodPromptPay, now.Add(-time.Minute), now.Add(time.Hour)))
+
+		resp, body := createPayment(t, app, MethodPromptPay)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Contains(t, body["error"], "payment method "+MethodPromptPay)
+
+		resp, _ = createPayment(t, app, "card")
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Payment Outside Window Proceeds", func(t *testing.T) {
+		app := newApp(
+			window("sandbox", now.Add(-2*time.Hour), now.Add(-time.Hour)),
+			window("sandbox", now.Add(time.Hour), now.Add(2*time.Hour)),
+			window("kbank", now.Add(-time.Hour), now.Add(time.Hour)),
+		)
+
+		resp, body := createPayment(t, app, "card")
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, string(PaymentStatusAuthorized), body["status"])
+	})
+
+	t.Run("Overlapping Windows Report The Latest End", func(t *testing.T) {
+		gateway := NewMaintenanceGateway(NewSandboxGateway("sandbox"), []MaintenanceWindow{
+			window("sandbox", now.Add(-time.Hour), now.Add(time.Hour)),
+			window("sandbox/card", now.Add(-time.Hour), now.Add(3*time.Hour)),
+		})
+		err := gateway.check("card")
+		if assert.ErrorIs(t, err, ErrGatewayMaintenance) {
+			assert.Equal(t, now.Add(3*time.Hour), err.(*MaintenanceError).Until)
+			assert.Equal(t, "card", err.(*MaintenanceError).Method)
+		}
+	})
+
+	t.Run("Config Validation", func(t *testing.T) {
+		windows, err := parseMaintenanceWindows("kbank=2026-11-01T01:00:00+07:00/2026-11-01T03:00:00+07:00, kbank/promptpay=2026-11-02T00:00:00Z/2026-11-02T01:00:00Z")
+		assert.NoError(t, err)
+		assert.Len(t, windows, 2)
+		assert.Equal(t, "kbank/promptpay", windows[1].Key)
+
+		for _, spec := range []string{
+			"kbank",
+			"kbank=2026-11-01T01:00:00Z",
+			"kbank=2026-11-01/2026-11-02",
+			"kbank=2026-11-01T03:00:00Z/2026-11-01T01:00:00Z",
+			"=2026-11-01T01:00:00Z/2026-11-01T03:00:00Z",
+		} {
+			assert.ErrorContains(t, Config{GatewayMaintenanceWindows: spec}.Validate(), "GATEWAY_MAINTENANCE_WINDOWS", spec)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GatewayMetadataAcceptor is implemented by gateways that take processor-specific fields with an
+// authorization, such as a bank's reference1/reference2. Gateways without it accept no gateway_metadata.
+type GatewayMetadataAcceptor interface {
+	AllowedMetadataKeys() []string
+}
+
+// validateGatewayMetadata checks that every gateway_metadata key is one the gateway accepts.
+func validateGatewayMetadata(gateway PaymentGateway, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool)
+	if acceptor, ok := gatewayAs[GatewayMetadataAcceptor](gateway); ok {
+		for _, key := range acceptor.AllowedMetadataKeys() {
+			allowed[key] = true
+		}
+	}
+	var unknown []string
+	for key := range metadata {
+		if !allowed[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("gateway %s does not accept gateway_metadata keys %q", gateway.Name(), unknown)
+	}
+	return nil
+}
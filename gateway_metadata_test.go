@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type metadataRecordingGateway struct {
+	*SandboxGateway
+	metadata []map[string]string
+}
+
+func (g *metadataRecordingGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	g.metadata = append(g.metadata, req.GatewayMetadata)
+	return g.SandboxGateway.Authorize(ctx, req)
+}
+
+func (g *metadataRecordingGateway) AllowedMetadataKeys() []string {
+	return []string{"statement_descriptor_suffix"}
+}
+
+func TestGatewayMetadata(t *testing.T) {
+	newApp := func() (*fiber.App, *metadataRecordingGateway) {
+		gateway := &metadataRecordingGateway{SandboxGateway: NewSandboxGateway("stripe")}
+		router := &APIRouter{gateway: gateway}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{})
+		return app, gateway
+	}
+
+	t.Run("Passed Through To Gateway", func(t *testing.T) {
+		app, gateway := newApp()
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","gateway_metadata":{"statement_descriptor_suffix":"A123"}}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, []map[string]string{{"statement_descriptor_suffix": "A123"}}, gateway.metadata)
+	})
+
+	t.Run("Unknown Key Rejected", func(t *testing.T) {
+		app, gateway := newApp()
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","gateway_metadata":{"reference1":"A123"}}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Empty(t, gateway.metadata)
+	})
+
+	t.Run("Gateway Without Metadata Support", func(t *testing.T) {
+		err := validateGatewayMetadata(basicGateway{NewSandboxGateway("bank")}, map[string]string{"reference1": "A123"})
+		assert.ErrorContains(t, err, "reference1")
+		assert.NoError(t, validateGatewayMetadata(basicGateway{NewSandboxGateway("bank")}, nil))
+	})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrMethodUnavailable is returned when the gateway serving one payment method is down; other methods may still
+// be served.
+var ErrMethodUnavailable = errors.New("payment method unavailable")
+
+// methodUnavailableRetryAfter is the Retry-After hint sent when a payment method is unavailable: the breaker
+// cooldown, after which its breaker lets a request through again.
+const methodUnavailableRetryAfter = defaultBreakerCooldown
+
+// defaultPaymentMethods are the methods tracked for availability when PAYMENT_METHODS is not set.
+const defaultPaymentMethods = "card," + MethodPromptPay + "," + MethodBankTransfer
+
+// MethodAvailability reports whether one payment method can currently be served.
+type MethodAvailability struct {
+	Method    string       `json:"method"`
+	Gateway   string       `json:"gateway"`
+	Available bool         `json:"available"`
+	State     CircuitState `json:"state"`
+}
+
+// MethodAvailabilityReporter is implemented by gateways that track availability per payment method.
+type MethodAvailabilityReporter interface {
+	MethodAvailability() []MethodAvailability
+}
+
+type methodRoute struct {
+	gateway PaymentGateway
+	breaker *CircuitBreaker
+}
+
+// MethodGateway routes each call to the gateway serving the payment's method, with a circuit breaker per
+// method, so that one method's processor being down fails only that method instead of every payment. Methods
+// that are neither tracked nor routed go to the fallback gateway without a breaker.
+type MethodGateway struct {
+	fallback PaymentGateway
+	routes   map[string]methodRoute
+}
+
+// NewMethodGateway creates a MethodGateway tracking methods on fallback, with routes overriding the gateway
+// for some methods. Each method's breaker is registered in breakers as "method.<method>".
+func NewMethodGateway(fallback PaymentGateway, methods []string, routes map[string]PaymentGateway, breakers *CircuitBreakerRegistry) *MethodGateway {
+	g := &MethodGateway{fallback: fallback, routes: make(map[string]methodRoute)}
+	add := func(method string, gateway PaymentGateway) {
+		breaker := NewCircuitBreaker("method."+method, 0, 0)
+		breakers.Register(breaker)
+		g.routes[method] = methodRoute{gateway: gateway, breaker: breaker}
+	}
+	for _, method := range methods {
+		add(method, fallback)
+	}
+	for method, gateway := range routes {
+		add(method, gateway)
+	}
+	return g
+}
+
+// parsePaymentMethods splits the comma-separated PAYMENT_METHODS list.
+func parsePaymentMethods(spec string) []string {
+	var methods []string
+	for _, method := range strings.Split(spec, ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// Name implements PaymentGateway.
+func (g *MethodGateway) Name() string {
+	return g.fallback.Name()
+}
+
+// Unwrap returns the fallback gateway.
+func (g *MethodGateway) Unwrap() PaymentGateway {
+	return g.fallback
+}
+
+// MethodAvailability implements MethodAvailabilityReporter, sorted by method.
+func (g *MethodGateway) MethodAvailability() []MethodAvailability {
+	availability := make([]MethodAvailability, 0, len(g.routes))
+	for method, route := range g.routes {
+		state := route.breaker.Status().State
+		availability = append(availability, MethodAvailability{
+			Method:    method,
+			Gateway:   route.gateway.Name(),
+			Available: state != CircuitOpen,
+			State:     state,
+		})
+	}
+	sort.Slice(availability, func(i, j int) bool { return availability[i].Method < availability[j].Method })
+	return availability
+}
+
+// call runs fn on the method's gateway, counting transient failures against the method's breaker. Both a
+// tripped breaker and a transient failure are reported as ErrMethodUnavailable.
+func (g *MethodGateway) call(method string, fn func(gateway PaymentGateway) error) error {
+	route, ok := g.routes[method]
+	if !ok {
+		return fn(g.fallback)
+	}
+	if !route.breaker.Allow() {
+		return fmt.Errorf("%w: %s", ErrMethodUnavailable, method)
+	}
+	err := fn(route.gateway)
+	if err != nil && isTransientGatewayError(err) {
+		route.breaker.RecordFailure()
+		return fmt.Errorf("%w: %s: %w", ErrMethodUnavailable, method, err)
+	}
+	route.breaker.RecordSuccess()
+	return err
+}
+
+// Authorize implements PaymentGateway.
+func (g *MethodGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	var result AuthorizeResult
+	err := g.call(req.Method, func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Authorize(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// Capture implements PaymentGateway.
+func (g *MethodGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	var result CaptureResult
+	err := g.call(req.Method, func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Capture(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// Void implements PaymentGateway.
+func (g *MethodGateway) Void(ctx context.Context, req VoidRequest) error {
+	return g.call(req.Method, func(gateway PaymentGateway) error {
+		return gateway.Void(ctx, req)
+	})
+}
+
+// Refund implements PaymentGateway.
+func (g *MethodGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	var result RefundResult
+	err := g.call(req.Method, func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Refund(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// respondMethodUnavailable answers a request for a payment method that is down with 503 and a jittered
+// Retry-After.
+func respondMethodUnavailable(c *fiber.Ctx, message string, jitter time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, RetryAfter{Base: methodUnavailableRetryAfter, Jitter: jitter}.Header())
+	return respondError(c, ErrCodeServiceUnavailable, message)
+}
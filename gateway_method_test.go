@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethodGateway(t *testing.T) {
+	newApp := func() (*fiber.App, *SandboxGateway, *SandboxGateway) {
+		cards := NewSandboxGateway("cards")
+		qr := NewSandboxGateway("qr")
+		gateway := NewMethodGateway(cards, []string{"card"}, map[string]PaymentGateway{MethodPromptPay: qr}, NewCircuitBreakerRegistry())
+		app := fiber.New()
+		(&APIRouter{gateway: gateway}).SetupRoutes(app, Config{})
+		return app, cards, qr
+	}
+	tripQR := func(app *fiber.App, qr *SandboxGateway) {
+		for i := 0; i < defaultBreakerFailureThreshold; i++ {
+			qr.FailNext(ErrGatewayUnavailable)
+			postPayment(t, app, `{"amount":1000,"currency":"THB","method":"promptpay"}`, nil)
+		}
+	}
+	getReady := func(app *fiber.App) (*http.Response, ReadyResponse) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/ready", nil))
+		assert.NoError(t, err)
+		var ready ReadyResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&ready))
+		return resp, ready
+	}
+
+	t.Run("Only Affected Method Fails", func(t *testing.T) {
+		app, cards, qr := newApp()
+		qr.FailNext(ErrGatewayUnavailable)
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","method":"promptpay"}`, nil)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","method":"card","token":"tok_visa"}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, PaymentStatusAuthorized, payment.Status)
+		assert.Equal(t, 1, cards.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Tripped Method Rejected Without Calling Gateway", func(t *testing.T) {
+		app, cards, qr := newApp()
+		tripQR(app, qr)
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","method":"promptpay"}`, nil)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Len(t, qr.ReceivedKeys(GatewayOpAuthorize), defaultBreakerFailureThreshold)
+
+		resp, _ = postPayment(t, app, `{"amount":1000,"currency":"THB","method":"card","token":"tok_visa"}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, 1, cards.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Unavailable Method Sends Retry-After", func(t *testing.T) {
+		cards := NewSandboxGateway("cards")
+		gateway := NewMethodGateway(cards, []string{"card"}, nil, NewCircuitBreakerRegistry())
+		app := fiber.New()
+		(&APIRouter{gateway: gateway}).SetupRoutes(app, Config{RetryAfterJitter: 10 * time.Second})
+		assertRetryAfter := func(t *testing.T, resp *http.Response) {
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+			retryAfter, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter))
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, retryAfter, int(methodUnavailableRetryAfter.Seconds()))
+			assert.LessOrEqual(t, retryAfter, int((methodUnavailableRetryAfter + 10*time.Second).Seconds()))
+		}
+
+		cards.FailNext(ErrGatewayUnavailable)
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","method":"card","token":"tok_visa"}`, nil)
+		assertRetryAfter(t, resp)
+
+		_, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","method":"card","token":"tok_visa"}`, nil)
+		cards.FailNext(ErrGatewayUnavailable)
+		resp, _ = postCaptureRequest(t, app, payment.ID)
+		assertRetryAfter(t, resp)
+	})
+
+	t.Run("Ready Reports Method Availability", func(t *testing.T) {
+		app, _, qr := newApp()
+		resp, ready := getReady(app)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "ready", ready.Status)
+
+		tripQR(app, qr)
+		resp, ready = getReady(app)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "degraded", ready.Status)
+		assert.Equal(t, []MethodAvailability{
+			{Method: "card", Gateway: "cards", Available: true, State: CircuitClosed},
+			{Method: MethodPromptPay, Gateway: "qr", Available: false, State: CircuitOpen},
+		}, ready.Methods)
+	})
+
+	t.Run("Ready Unavailable When Every Method Is Down", func(t *testing.T) {
+		gateway := NewSandboxGateway("sandbox")
+		methods := NewMethodGateway(gateway, []string{"card"}, nil, NewCircuitBreakerRegistry())
+		for i := 0; i < defaultBreakerFailureThreshold; i++ {
+			gateway.FailNext(ErrGatewayUnavailable)
+			_, err := methods.Authorize(context.Background(), AuthorizeRequest{Method: "card"})
+			assert.ErrorIs(t, err, ErrMethodUnavailable)
+			assert.ErrorIs(t, err, ErrGatewayUnavailable)
+		}
+		app := fiber.New()
+		(&APIRouter{gateway: methods}).SetupRoutes(app, Config{})
+		resp, ready := getReady(app)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "unavailable", ready.Status)
+	})
+
+	t.Run("Untracked Method Uses Fallback", func(t *testing.T) {
+		gateway := NewSandboxGateway("sandbox")
+		methods := NewMethodGateway(gateway, nil, nil, NewCircuitBreakerRegistry())
+		gateway.FailNext(ErrGatewayUnavailable)
+		_, err := methods.Authorize(context.Background(), AuthorizeRequest{Method: "wallet"})
+		assert.ErrorIs(t, err, ErrGatewayUnavailable)
+		assert.NotErrorIs(t, err, ErrMethodUnavailable)
+	})
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Sandbox card tokens that trigger a declined authorization.
+const (
+	SandboxTokenDeclined          = "tok_declined"
+	SandboxTokenInsufficientFunds = "tok_insufficient_funds"
+)
+
+// SandboxGateway is an in-memory PaymentGateway that simulates a processor for development, sandbox traffic
+// and tests. Like real processors it deduplicates calls by idempotency key and replays the original result.
+type SandboxGateway struct {
+	mu        sync.Mutex
+	name      string
+	seq       int
+	results   map[string]interface{}
+	received  map[GatewayOperation][]string
+	processed map[GatewayOperation]int
+	failures  []sandboxFailure
+	credsErr  error
+	minVerify int64
+	async     bool
+	statuses  map[string]GatewayPaymentStatus
+	utf8      bool
+	noCodes   bool
+}
+
+type sandboxFailure struct {
+	err             error
+	afterProcessing bool
+}
+
+// NewSandboxGateway creates a SandboxGateway reporting the given name.
+func NewSandboxGateway(name string) *SandboxGateway {
+	return &SandboxGateway{
+		name:      name,
+		results:   make(map[string]interface{}),
+		received:  make(map[GatewayOperation][]string),
+		processed: make(map[GatewayOperation]int),
+		statuses:  make(map[string]GatewayPaymentStatus),
+	}
+}
+
+// Name implements PaymentGateway.
+func (g *SandboxGateway) Name() string {
+	return g.name
+}
+
+// FailNext makes the next call fail with err before the gateway processes it.
+func (g *SandboxGateway) FailNext(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = append(g.failures, sandboxFailure{err: err})
+}
+
+// FailNextAfterProcessing makes the next call succeed at the gateway but return err to the caller,
+// simulating a response lost to a timeout.
+func (g *SandboxGateway) FailNextAfterProcessing(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures = append(g.failures, sandboxFailure{err: err, afterProcessing: true})
+}
+
+// SetCredentialsError makes VerifyCredentials fail with err; nil restores valid credentials.
+func (g *SandboxGateway) SetCredentialsError(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.credsErr = err
+}
+
+// VerifyCredentials implements CredentialVerifier.
+func (g *SandboxGateway) VerifyCredentials(_ context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.credsErr
+}
+
+// SetMinimumVerificationAmount makes the sandbox behave like a gateway that rejects zero-amount
+// verifications and needs at least amount minor units instead; 0 accepts zero-amount verifications.
+func (g *SandboxGateway) SetMinimumVerificationAmount(amount int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.minVerify = amount
+}
+
+// MinimumVerificationAmount implements VerificationAmountRequirer.
+func (g *SandboxGateway) MinimumVerificationAmount(_ string) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.minVerify
+}
+
+// SetAsyncRefunds makes refunds come back pending, to be confirmed later by a gateway webhook.
+func (g *SandboxGateway) SetAsyncRefunds(async bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.async = async
+}
+
+// SetUTF8Descriptors makes the sandbox behave like a gateway that displays UTF-8 statement descriptors.
+func (g *SandboxGateway) SetUTF8Descriptors(supported bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.utf8 = supported
+}
+
+// SupportsUTF8Descriptors implements UTF8DescriptorSupporter; the sandbox is ASCII-only unless configured.
+func (g *SandboxGateway) SupportsUTF8Descriptors() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.utf8
+}
+
+// SetApprovalCodes controls whether approved authorizations carry an approval code and network transaction ID;
+// disabling them simulates a gateway that does not report either.
+func (g *SandboxGateway) SetApprovalCodes(provided bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.noCodes = !provided
+}
+
+// ReceivedKeys returns the idempotency keys the gateway received for an operation, in call order.
+func (g *SandboxGateway) ReceivedKeys(op GatewayOperation) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.received[op]...)
+}
+
+// Processed returns how many distinct operations of the given kind the gateway actually carried out.
+func (g *SandboxGateway) Processed(op GatewayOperation) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.processed[op]
+}
+
+// Authorize implements PaymentGateway.
+func (g *SandboxGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	minVerify := g.MinimumVerificationAmount(req.Amount.Currency)
+	g.mu.Lock()
+	noCodes := g.noCodes
+	g.mu.Unlock()
+	result, err := g.call(ctx, GatewayOpAuthorize, req.IdempotencyKey, func(ref string) interface{} {
+		switch {
+		case req.Amount.Amount < minVerify:
+			return AuthorizeResult{GatewayReference: ref, DeclineReason: "amount_too_small"}
+		case req.Token == SandboxTokenDeclined:
+			return AuthorizeResult{GatewayReference: ref, DeclineReason: "card_declined"}
+		case req.Token == SandboxTokenInsufficientFunds:
+			return AuthorizeResult{GatewayReference: ref, DeclineReason: "insufficient_funds"}
+		case isAsyncMethod(req.Method):
+			return AuthorizeResult{GatewayReference: ref, Pending: true}
+		case noCodes:
+			return AuthorizeResult{GatewayReference: ref, Approved: true}
+		default:
+			return AuthorizeResult{
+				GatewayReference:     ref,
+				Approved:             true,
+				ApprovalCode:         fmt.Sprintf("%06d", g.seq),
+				NetworkTransactionID: fmt.Sprintf("%015d", g.seq),
+			}
+		}
+	})
+	if err != nil {
+		return AuthorizeResult{}, err
+	}
+	return result.(AuthorizeResult), nil
+}
+
+// AllowedMetadataKeys implements GatewayMetadataAcceptor with the fields Thai bank gateways commonly take.
+func (g *SandboxGateway) AllowedMetadataKeys() []string {
+	return []string{"reference1", "reference2"}
+}
+
+// Capture implements PaymentGateway.
+func (g *SandboxGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	result, err := g.call(ctx, GatewayOpCapture, req.IdempotencyKey, func(ref string) interface{} {
+		return CaptureResult{GatewayReference: ref}
+	})
+	if err != nil {
+		return CaptureResult{}, err
+	}
+	return result.(CaptureResult), nil
+}
+
+// Void implements PaymentGateway.
+func (g *SandboxGateway) Void(ctx context.Context, req VoidRequest) error {
+	_, err := g.call(ctx, GatewayOpVoid, req.IdempotencyKey, func(ref string) interface{} {
+		return ref
+	})
+	return err
+}
+
+// Refund implements PaymentGateway.
+func (g *SandboxGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	g.mu.Lock()
+	async := g.async
+	g.mu.Unlock()
+	result, err := g.call(ctx, GatewayOpRefund, req.IdempotencyKey, func(ref string) interface{} {
+		return RefundResult{GatewayReference: ref, Pending: async}
+	})
+	if err != nil {
+		return RefundResult{}, err
+	}
+	return result.(RefundResult), nil
+}
+
+// IncrementAuthorization implements IncrementalAuthorizer.
+func (g *SandboxGateway) IncrementAuthorization(ctx context.Context, req IncrementAuthorizationRequest) (IncrementAuthorizationResult, error) {
+	result, err := g.call(ctx, GatewayOpIncrementAuthorization, req.IdempotencyKey, func(ref string) interface{} {
+		return IncrementAuthorizationResult{GatewayReference: ref, Approved: true}
+	})
+	if err != nil {
+		return IncrementAuthorizationResult{}, err
+	}
+	return result.(IncrementAuthorizationResult), nil
+}
+
+// SetPaymentStatus sets the status CheckPaymentStatus reports for an asynchronous payment, simulating the
+// customer paying or the gateway giving up.
+func (g *SandboxGateway) SetPaymentStatus(gatewayReference string, status GatewayPaymentStatus) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.statuses[gatewayReference] = status
+}
+
+// CheckPaymentStatus implements PaymentStatusChecker; payments are pending until SetPaymentStatus says otherwise.
+func (g *SandboxGateway) CheckPaymentStatus(_ context.Context, _ string, gatewayReference string) (GatewayPaymentStatus, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if status, ok := g.statuses[gatewayReference]; ok {
+		return status, nil
+	}
+	return GatewayPaymentPending, nil
+}
+
+func (g *SandboxGateway) call(ctx context.Context, op GatewayOperation, key string, process func(ref string) interface{}) (interface{}, error) {
+	// Like a real gateway client, a call whose request context is already done is never sent.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.received[op] = append(g.received[op], key)
+
+	var failure *sandboxFailure
+	if len(g.failures) > 0 {
+		failure = &g.failures[0]
+		g.failures = g.failures[1:]
+		if !failure.afterProcessing {
+			return nil, failure.err
+		}
+	}
+
+	storeKey := string(op) + "|" + key
+	result, seen := g.results[storeKey]
+	if !seen || key == "" {
+		g.seq++
+		result = process(fmt.Sprintf("%s_%s_%d", g.name, op, g.seq))
+		g.processed[op]++
+		if key != "" {
+			g.results[storeKey] = result
+		}
+	}
+
+	if failure != nil {
+		return nil, failure.err
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatewayIdempotencyKey(t *testing.T) {
+	assert.Equal(t, "authorize:pay_1", GatewayIdempotencyKey("pay_1", GatewayOpAuthorize, ""))
+	assert.Equal(t, "authorize:client-key", GatewayIdempotencyKey("pay_1", GatewayOpAuthorize, "client-key"))
+	assert.NotEqual(t,
+		GatewayIdempotencyKey("pay_1", GatewayOpAuthorize, ""),
+		GatewayIdempotencyKey("pay_1", GatewayOpCapture, ""))
+}
+
+func TestRetryingGatewayIdempotency(t *testing.T) {
+	t.Run("Retried Authorize Reuses Key And Returns Original Result", func(t *testing.T) {
+		sandbox := NewSandboxGateway("sandbox")
+		sandbox.FailNextAfterProcessing(ErrGatewayTimeout)
+		gateway := NewRetryingGateway(sandbox, 3, 0)
+
+		result, err := gateway.Authorize(context.Background(), AuthorizeRequest{
+			PaymentID: "pay_1",
+			Amount:    NewMoney(1000, "THB"),
+		})
+		assert.NoError(t, err)
+		assert.True(t, result.Approved)
+
+		keys := sandbox.ReceivedKeys(GatewayOpAuthorize)
+		assert.Equal(t, []string{"authorize:pay_1", "authorize:pay_1"}, keys)
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpAuthorize))
+		assert.Equal(t, "sandbox_authorize_1", result.GatewayReference)
+	})
+
+	t.Run("Client Key Forwarded", func(t *testing.T) {
+		sandbox := NewSandboxGateway("sandbox")
+		gateway := NewRetryingGateway(sandbox, 3, 0)
+
+		_, err := gateway.Capture(context.Background(), CaptureRequest{
+			PaymentID:      "pay_1",
+			IdempotencyKey: GatewayIdempotencyKey("pay_1", GatewayOpCapture, "abc"),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"capture:abc"}, sandbox.ReceivedKeys(GatewayOpCapture))
+	})
+
+	t.Run("Non-Transient Errors Are Not Retried", func(t *testing.T) {
+		sandbox := NewSandboxGateway("sandbox")
+		sandbox.FailNext(context.Canceled)
+		gateway := NewRetryingGateway(sandbox, 3, 0)
+
+		_, err := gateway.Authorize(context.Background(), AuthorizeRequest{PaymentID: "pay_1"})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Len(t, sandbox.ReceivedKeys(GatewayOpAuthorize), 1)
+	})
+
+	t.Run("Declined Token", func(t *testing.T) {
+		sandbox := NewSandboxGateway("sandbox")
+
+		result, err := sandbox.Authorize(context.Background(), AuthorizeRequest{
+			PaymentID:      "pay_1",
+			IdempotencyKey: "authorize:pay_1",
+			Token:          SandboxTokenDeclined,
+		})
+		assert.NoError(t, err)
+		assert.False(t, result.Approved)
+		assert.Equal(t, "card_declined", result.DeclineReason)
+	})
+}
+
+func TestGatewayAs(t *testing.T) {
+	sandbox := NewSandboxGateway("sandbox")
+	wrapped := NewRetryingGateway(NewInstrumentedGateway(sandbox, NewMetricsRegistry(), 0), 2, 0)
+
+	verifier, ok := gatewayAs[CredentialVerifier](wrapped)
+	assert.True(t, ok)
+	assert.Same(t, sandbox, verifier)
+
+	_, ok = gatewayAs[IncrementalAuthorizer](basicGateway{sandbox})
+	assert.False(t, ok)
+}
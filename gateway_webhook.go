@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GatewayEventType names a notification a gateway sends us asynchronously.
+type GatewayEventType string
+
+const (
+	// GatewayEventRefundSucceeded confirms a refund that was accepted as pending.
+	GatewayEventRefundSucceeded GatewayEventType = "refund.succeeded"
+	// GatewayEventRefundFailed reports that a pending refund was not carried out.
+	GatewayEventRefundFailed GatewayEventType = "refund.failed"
+)
+
+// gatewayWebhookRequest is the body accepted by POST /webhooks/gateways/:gateway.
+type gatewayWebhookRequest struct {
+	Type             GatewayEventType `json:"type"`
+	GatewayReference string           `json:"gateway_reference"`
+	FailureReason    string           `json:"failure_reason"`
+}
+
+// handleGatewayWebhook receives asynchronous outcomes from gateways. Deliveries for refunds that are no longer
+// pending are acknowledged without changes, since gateways retry webhooks until they get a 2xx.
+func (r *APIRouter) handleGatewayWebhook(c *fiber.Ctx) error {
+	if err := r.verifyGatewayWebhook(c); err != nil {
+		return respondError(c, ErrCodeInvalidSignature, err.Error())
+	}
+	var req gatewayWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	switch req.Type {
+	case GatewayEventRefundSucceeded, GatewayEventRefundFailed:
+	default:
+		return respondError(c, ErrCodeValidationFailed, "unsupported event type "+string(req.Type))
+	}
+
+	ctx := c.UserContext()
+	refund, err := r.refunds.GetByGatewayReference(ctx, req.GatewayReference)
+	if errors.Is(err, ErrRefundNotFound) {
+		return respondError(c, ErrCodeNotFound, "refund not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load refund")
+	}
+	if refund.Status != RefundStatusPending {
+		return c.JSON(newRefundResponse(refund))
+	}
+
+	if req.Type == GatewayEventRefundFailed {
+		refund.Status = RefundStatusFailed
+		refund.FailureReason = req.FailureReason
+		if err := r.refunds.Save(ctx, refund); err != nil {
+			return respondError(c, ErrCodeInternal, "failed to save refund")
+		}
+		if err := r.releaseRefundLineItems(ctx, refund); err != nil {
+			return respondError(c, ErrCodeInternal, "failed to release line items")
+		}
+		return c.JSON(newRefundResponse(refund))
+	}
+
+	payment, err := r.store.Get(ctx, refund.PaymentID)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	refund.Status = RefundStatusSucceeded
+	if err := r.refunds.Save(ctx, refund); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to save refund")
+	}
+	if err := r.applyRefund(ctx, payment, refund); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to apply refund")
+	}
+	return c.JSON(newRefundResponse(refund))
+}
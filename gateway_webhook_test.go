@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func postGatewayWebhook(t *testing.T, app *fiber.App, body string) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/gateways/sandbox", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestAsyncRefundWebhook(t *testing.T) {
+	ctx := context.Background()
+	newApp := func() (*fiber.App, *MemoryPaymentStore, *MemoryRefundStore, *MemoryLedger) {
+		store := NewMemoryPaymentStore()
+		refunds := NewMemoryRefundStore()
+		ledger := NewMemoryLedger()
+		gateway := NewSandboxGateway("sandbox")
+		gateway.SetAsyncRefunds(true)
+		seedCapturedPayment(t, store, "pay_1", 1000, "")
+
+		app := fiber.New()
+		router := &APIRouter{store: store, gateway: gateway, refunds: refunds, ledger: ledger}
+		router.SetupRoutes(app, Config{})
+		return app, store, refunds, ledger
+	}
+
+	t.Run("Pending Refund Completes Via Webhook", func(t *testing.T) {
+		app, store, refunds, ledger := newApp()
+
+		resp, refund := postRefund(t, app, "pay_1", `{"amount":400}`)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, RefundStatusPending, refund.Status)
+		payment, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(0), payment.AmountRefunded)
+
+		// The pending amount is held, so the rest of the payment can still be refunded but no more.
+		resp, _ = postRefund(t, app, "pay_1", `{"amount":700}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+		stored, _ := refunds.Get(ctx, refund.ID)
+		resp = postGatewayWebhook(t, app, `{"type":"refund.succeeded","gateway_reference":"`+stored.GatewayReference+`"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		stored, _ = refunds.Get(ctx, refund.ID)
+		assert.Equal(t, RefundStatusSucceeded, stored.Status)
+		payment, _ = store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(400), payment.AmountRefunded)
+		entries, _ := ledger.ListByPayment(ctx, "pay_1")
+		assert.Len(t, entries, 1)
+
+		// A redelivered webhook does not refund twice.
+		resp = postGatewayWebhook(t, app, `{"type":"refund.succeeded","gateway_reference":"`+stored.GatewayReference+`"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		payment, _ = store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(400), payment.AmountRefunded)
+	})
+
+	t.Run("Pending Refund Fails Via Webhook", func(t *testing.T) {
+		app, store, refunds, ledger := newApp()
+
+		_, refund := postRefund(t, app, "pay_1", `{"amount":1000}`)
+		stored, _ := refunds.Get(ctx, refund.ID)
+		resp := postGatewayWebhook(t, app, `{"type":"refund.failed","gateway_reference":"`+stored.GatewayReference+`","failure_reason":"account_closed"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		stored, _ = refunds.Get(ctx, refund.ID)
+		assert.Equal(t, RefundStatusFailed, stored.Status)
+		assert.Equal(t, "account_closed", stored.FailureReason)
+		payment, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(0), payment.AmountRefunded)
+		entries, _ := ledger.ListByPayment(ctx, "pay_1")
+		assert.Empty(t, entries)
+
+		// The released amount is refundable again.
+		resp, _ = postRefund(t, app, "pay_1", `{"amount":1000}`)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	})
+
+	t.Run("Unknown Refund", func(t *testing.T) {
+		app, _, _, _ := newApp()
+		resp := postGatewayWebhook(t, app, `{"type":"refund.succeeded","gateway_reference":"nope"}`)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
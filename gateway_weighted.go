@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GatewayEndpoint is one connection to a processor, such as a second set of credentials used for throughput.
+type GatewayEndpoint struct {
+	Gateway PaymentGateway
+	Weight  int
+	Breaker *CircuitBreaker
+}
+
+// WeightedGateway spreads calls over interchangeable connections to the same processor account with smooth
+// weighted round-robin, skipping endpoints whose circuit breaker is open. Because every endpoint reaches the
+// same account, a capture or refund may go through a different endpoint than the authorization did.
+type WeightedGateway struct {
+	mu        sync.Mutex
+	name      string
+	endpoints []GatewayEndpoint
+	current   []int
+}
+
+// NewWeightedGateway creates a WeightedGateway reporting name; endpoints with a weight below 1 count as 1.
+func NewWeightedGateway(name string, endpoints []GatewayEndpoint) *WeightedGateway {
+	for i := range endpoints {
+		if endpoints[i].Weight < 1 {
+			endpoints[i].Weight = 1
+		}
+	}
+	return &WeightedGateway{name: name, endpoints: endpoints, current: make([]int, len(endpoints))}
+}
+
+// Name implements PaymentGateway.
+func (g *WeightedGateway) Name() string {
+	return g.name
+}
+
+// Unwrap returns the first endpoint's gateway; all endpoints share the processor's capabilities.
+func (g *WeightedGateway) Unwrap() PaymentGateway {
+	if len(g.endpoints) == 0 {
+		return nil
+	}
+	return g.endpoints[0].Gateway
+}
+
+// next picks the endpoint for the next call. Each available endpoint gains its weight, the one with the highest
+// running total is chosen and pays back the total weight, which interleaves picks instead of bursting them.
+func (g *WeightedGateway) next() (GatewayEndpoint, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	best, total := -1, 0
+	for i, endpoint := range g.endpoints {
+		if endpoint.Breaker != nil && !endpoint.Breaker.Ready() {
+			continue
+		}
+		g.current[i] += endpoint.Weight
+		total += endpoint.Weight
+		if best < 0 || g.current[i] > g.current[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return GatewayEndpoint{}, false
+	}
+	g.current[best] -= total
+	return g.endpoints[best], true
+}
+
+// call runs fn on the next endpoint, counting transient failures against that endpoint's breaker.
+func (g *WeightedGateway) call(fn func(gateway PaymentGateway) error) error {
+	endpoint, ok := g.next()
+	// Only the chosen endpoint's breaker is asked to Allow the call, which claims its probe when half-open.
+	if !ok || (endpoint.Breaker != nil && !endpoint.Breaker.Allow()) {
+		return fmt.Errorf("%w: every %s endpoint is unavailable", ErrCircuitOpen, g.name)
+	}
+	err := fn(endpoint.Gateway)
+	if endpoint.Breaker != nil {
+		if err != nil && isTransientGatewayError(err) {
+			endpoint.Breaker.RecordFailure()
+		} else {
+			endpoint.Breaker.RecordSuccess()
+		}
+	}
+	return err
+}
+
+// Authorize implements PaymentGateway.
+func (g *WeightedGateway) Authorize(ctx context.Context, req AuthorizeRequest) (AuthorizeResult, error) {
+	var result AuthorizeResult
+	err := g.call(func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Authorize(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// Capture implements PaymentGateway.
+func (g *WeightedGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	var result CaptureResult
+	err := g.call(func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Capture(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// Void implements PaymentGateway.
+func (g *WeightedGateway) Void(ctx context.Context, req VoidRequest) error {
+	return g.call(func(gateway PaymentGateway) error {
+		return gateway.Void(ctx, req)
+	})
+}
+
+// Refund implements PaymentGateway.
+func (g *WeightedGateway) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	var result RefundResult
+	err := g.call(func(gateway PaymentGateway) error {
+		var err error
+		result, err = gateway.Refund(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+// newEndpointGateway spreads calls to gateway over the connections in GATEWAY_ENDPOINT_WEIGHTS, each with its
+// own circuit breaker registered in breakers, or returns gateway unchanged when none are configured. Until
+// per-endpoint credentials are configurable every endpoint shares gateway's connection.
+func newEndpointGateway(gateway PaymentGateway, config Config, breakers *CircuitBreakerRegistry) PaymentGateway {
+	// Validate has already rejected malformed weights.
+	weights, _ := parseGatewayEndpointWeights(config.GatewayEndpointWeights)
+	if len(weights) == 0 {
+		return gateway
+	}
+	endpoints := make([]GatewayEndpoint, 0, len(weights))
+	for _, w := range weights {
+		breaker := NewCircuitBreaker(gateway.Name()+"."+w.name, 0, 0)
+		breakers.Register(breaker)
+		endpoints = append(endpoints, GatewayEndpoint{Gateway: gateway, Weight: w.weight, Breaker: breaker})
+	}
+	return NewWeightedGateway(gateway.Name(), endpoints)
+}
+
+// gatewayEndpointWeight is one entry of GATEWAY_ENDPOINT_WEIGHTS.
+type gatewayEndpointWeight struct {
+	name   string
+	weight int
+}
+
+// parseGatewayEndpointWeights parses a comma-separated list of "name=weight" pairs, e.g. "primary=3,secondary=1".
+func parseGatewayEndpointWeights(spec string) ([]gatewayEndpointWeight, error) {
+	var weights []gatewayEndpointWeight
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rawWeight, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		weight, err := strconv.Atoi(strings.TrimSpace(rawWeight))
+		if !ok || name == "" || err != nil || weight < 1 {
+			return nil, fmt.Errorf("invalid GATEWAY_ENDPOINT_WEIGHTS entry %q: want name=positive integer", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("invalid GATEWAY_ENDPOINT_WEIGHTS: endpoint %q is listed twice", name)
+		}
+		seen[name] = true
+		weights = append(weights, gatewayEndpointWeight{name: name, weight: weight})
+	}
+	return weights, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedGateway(t *testing.T) {
+	ctx := context.Background()
+	authorizeN := func(t *testing.T, gateway PaymentGateway, n int) {
+		for i := 0; i < n; i++ {
+			_, err := gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay"})
+			assert.NoError(t, err)
+		}
+	}
+
+	t.Run("Distribution Matches Weights", func(t *testing.T) {
+		primary, secondary := NewSandboxGateway("primary"), NewSandboxGateway("secondary")
+		gateway := NewWeightedGateway("sandbox", []GatewayEndpoint{
+			{Gateway: primary, Weight: 3},
+			{Gateway: secondary, Weight: 1},
+		})
+
+		authorizeN(t, gateway, 40)
+		assert.Equal(t, 30, primary.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 10, secondary.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Picks Are Interleaved", func(t *testing.T) {
+		a, b := NewSandboxGateway("a"), NewSandboxGateway("b")
+		gateway := NewWeightedGateway("sandbox", []GatewayEndpoint{{Gateway: a, Weight: 1}, {Gateway: b, Weight: 1}})
+
+		authorizeN(t, gateway, 2)
+		assert.Equal(t, 1, a.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 1, b.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Open Breaker Endpoint Is Skipped", func(t *testing.T) {
+		primary, secondary := NewSandboxGateway("primary"), NewSandboxGateway("secondary")
+		breaker := NewCircuitBreaker("sandbox.primary", 1, time.Hour)
+		gateway := NewWeightedGateway("sandbox", []GatewayEndpoint{
+			{Gateway: primary, Weight: 3, Breaker: breaker},
+			{Gateway: secondary, Weight: 1, Breaker: NewCircuitBreaker("sandbox.secondary", 1, time.Hour)},
+		})
+
+		primary.FailNext(ErrGatewayUnavailable)
+		_, err := gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay"})
+		assert.ErrorIs(t, err, ErrGatewayUnavailable)
+		assert.Equal(t, CircuitOpen, breaker.Status().State)
+
+		authorizeN(t, gateway, 5)
+		assert.Equal(t, 0, primary.Processed(GatewayOpAuthorize))
+		assert.Equal(t, 5, secondary.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("All Breakers Open", func(t *testing.T) {
+		breaker := NewCircuitBreaker("sandbox.only", 1, time.Hour)
+		breaker.RecordFailure()
+		gateway := NewWeightedGateway("sandbox", []GatewayEndpoint{{Gateway: NewSandboxGateway("only"), Weight: 1, Breaker: breaker}})
+
+		_, err := gateway.Authorize(ctx, AuthorizeRequest{PaymentID: "pay"})
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+	})
+
+	t.Run("Capabilities Visible Through Endpoints", func(t *testing.T) {
+		gateway := NewWeightedGateway("sandbox", []GatewayEndpoint{{Gateway: NewSandboxGateway("sandbox"), Weight: 1}})
+		_, ok := gatewayAs[IncrementalAuthorizer](gateway)
+		assert.True(t, ok)
+	})
+}
+
+func TestParseGatewayEndpointWeights(t *testing.T) {
+	weights, err := parseGatewayEndpointWeights("primary=3, secondary=1")
+	assert.NoError(t, err)
+	assert.Equal(t, []gatewayEndpointWeight{{name: "primary", weight: 3}, {name: "secondary", weight: 1}}, weights)
+
+	for _, spec := range []string{"primary", "primary=0", "primary=x", "=2", "a=1,a=2"} {
+		_, err := parseGatewayEndpointWeights(spec)
+		assert.Error(t, err, spec)
+	}
+
+	breakers := NewCircuitBreakerRegistry()
+	gateway := newEndpointGateway(NewSandboxGateway("sandbox"), Config{GatewayEndpointWeights: "primary=2,secondary=1"}, breakers)
+	assert.IsType(t, &WeightedGateway{}, gateway)
+	assert.Len(t, breakers.Statuses(), 2)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// processStartedAt is when the process started, used to report uptime.
+var processStartedAt = time.Now()
+
+// HealthResponse is the JSON body of /health for clients that ask for application/json.
+type HealthResponse struct {
+	Status        string    `json:"status"`
+	StartedAt     time.Time `json:"started_at"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+}
+
+// getHealth answers "OK" as plain text, keeping existing probes working, and a JSON body with uptime when the
+// client sends Accept: application/json.
+func getHealth(c *fiber.Ctx) error {
+	if c.Accepts(fiber.MIMETextPlain, fiber.MIMEApplicationJSON) != fiber.MIMEApplicationJSON {
+		return c.SendString("OK")
+	}
+	return c.JSON(HealthResponse{
+		Status:        "ok",
+		StartedAt:     processStartedAt.UTC(),
+		UptimeSeconds: int64(time.Since(processStartedAt) / time.Second),
+	})
+}
+
+// ReadyResponse is the JSON body of /ready.
+type ReadyResponse struct {
+	// Status is "ready", "degraded" when some payment methods are unavailable, or "unavailable" when all are.
+	Status  string               `json:"status"`
+	Methods []MethodAvailability `json:"methods,omitempty"`
+}
+
+// getReady reports whether the service can take payments, listing per-method availability when the gateway
+// tracks it. A degraded service is still ready, since the methods that work keep succeeding; only when every
+// method is down does it answer 503.
+func (r *APIRouter) getReady(c *fiber.Ctx) error {
+	response := ReadyResponse{Status: "ready"}
+	reporter, ok := gatewayAs[MethodAvailabilityReporter](r.gateway)
+	if !ok {
+		return c.JSON(response)
+	}
+	response.Methods = reporter.MethodAvailability()
+	available := 0
+	for _, method := range response.Methods {
+		if method.Available {
+			available++
+		}
+	}
+	switch {
+	case len(response.Methods) > 0 && available == 0:
+		response.Status = "unavailable"
+		return c.Status(fiber.StatusServiceUnavailable).JSON(response)
+	case available < len(response.Methods):
+		response.Status = "degraded"
+	}
+	return c.JSON(response)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultHealthScoreWindow is how far back GET /admin/health-score looks when HEALTH_SCORE_WINDOW is not set.
+const defaultHealthScoreWindow = 5 * time.Minute
+
+// A dependency averaging up to healthLatencyTarget scores full marks for latency and one averaging
+// healthLatencyCeiling or more scores zero; an error rate of healthErrorRateCeiling or more scores zero.
+const (
+	healthLatencyTarget    = 500 * time.Millisecond
+	healthLatencyCeiling   = 5 * time.Second
+	healthErrorRateCeiling = 0.1
+)
+
+// Health score factors, as named in HEALTH_SCORE_WEIGHTS and the score's breakdown.
+const (
+	HealthFactorLatency         = "latency"
+	HealthFactorErrors          = "errors"
+	HealthFactorCircuitBreakers = "circuit_breakers"
+)
+
+// HealthScoreWeights are the relative weights of the health score's factors; they need not add up to 100.
+type HealthScoreWeights struct {
+	Latency         float64
+	Errors          float64
+	CircuitBreakers float64
+}
+
+// defaultHealthScoreWeights weigh errors most, since failed payments hurt more than slow ones.
+var defaultHealthScoreWeights = HealthScoreWeights{Latency: 30, Errors: 50, CircuitBreakers: 20}
+
+// parseHealthScoreWeights parses HEALTH_SCORE_WEIGHTS, a comma-separated list of factor=weight entries such as
+// "latency=20,errors=60,circuit_breakers=20". Factors left out keep their default weight.
+func parseHealthScoreWeights(spec string) (HealthScoreWeights, error) {
+	weights := defaultHealthScoreWeights
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		factor, raw, _ := strings.Cut(entry, "=")
+		weight, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil || weight < 0 || math.IsInf(weight, 0) {
+			return HealthScoreWeights{}, fmt.Errorf("invalid HEALTH_SCORE_WEIGHTS entry %q: want factor=non-negative number", entry)
+		}
+		switch strings.TrimSpace(factor) {
+		case HealthFactorLatency:
+			weights.Latency = weight
+		case HealthFactorErrors:
+			weights.Errors = weight
+		case HealthFactorCircuitBreakers:
+			weights.CircuitBreakers = weight
+		default:
+			return HealthScoreWeights{}, fmt.Errorf("invalid HEALTH_SCORE_WEIGHTS entry %q: unknown factor; want latency, errors or circuit_breakers", entry)
+		}
+	}
+	if weights.Latency+weights.Errors+weights.CircuitBreakers == 0 {
+		return HealthScoreWeights{}, fmt.Errorf("invalid HEALTH_SCORE_WEIGHTS %q: at least one weight must be above zero", spec)
+	}
+	return weights, nil
+}
+
+// healthScoreWindow returns HEALTH_SCORE_WINDOW, or the default when it is not set.
+func (c Config) healthScoreWindow() time.Duration {
+	if c.HealthScoreWindow > 0 {
+		return c.HealthScoreWindow
+	}
+	return defaultHealthScoreWindow
+}
+
+// dependencyCall is one call to a dependency, kept while it is inside the health window.
+type dependencyCall struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// DependencyHealth keeps the calls made to each dependency, such as the database or a gateway, over a sliding
+// window for the health score. Unlike the metrics registry it forgets calls once they leave the window, so the
+// score reflects the service's current state.
+type DependencyHealth struct {
+	mu     sync.Mutex
+	window time.Duration
+	now    func() time.Time
+	calls  map[string][]dependencyCall
+}
+
+// NewDependencyHealth creates a DependencyHealth keeping the calls of the last window.
+func NewDependencyHealth(window time.Duration) *DependencyHealth {
+	return &DependencyHealth{window: window, now: time.Now, calls: make(map[string][]dependencyCall)}
+}
+
+// Record adds a call to dependency that took latency and failed or not. It is a no-op on a nil DependencyHealth.
+func (h *DependencyHealth) Record(dependency string, latency time.Duration, failed bool) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	h.calls[dependency] = append(h.prune(h.calls[dependency], now), dependencyCall{at: now, latency: latency, failed: failed})
+}
+
+// prune drops the calls that have left the window; calls are kept in the order they were recorded.
+func (h *DependencyHealth) prune(calls []dependencyCall, now time.Time) []dependencyCall {
+	cutoff := now.Add(-h.window)
+	i := 0
+	for i < len(calls) && !calls[i].at.After(cutoff) {
+		i++
+	}
+	return calls[i:]
+}
+
+// DependencyStats summarizes the calls made to one dependency within the health window.
+type DependencyStats struct {
+	Name             string  `json:"name"`
+	Calls            int     `json:"calls"`
+	Failures         int     `json:"failures"`
+	ErrorRate        float64 `json:"error_rate"`
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+}
+
+// Stats returns the stats of every dependency called within the window, sorted by name.
+func (h *DependencyHealth) Stats() []DependencyStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	stats := make([]DependencyStats, 0, len(h.calls))
+	for name, calls := range h.calls {
+		calls = h.prune(calls, now)
+		if len(calls) == 0 {
+			delete(h.calls, name)
+			continue
+		}
+		h.calls[name] = calls
+		var total time.Duration
+		summary := DependencyStats{Name: name, Calls: len(calls)}
+		for _, call := range calls {
+			total += call.latency
+			if call.failed {
+				summary.Failures++
+			}
+		}
+		summary.ErrorRate = float64(summary.Failures) / float64(summary.Calls)
+		summary.AverageLatencyMs = float64(total) / float64(summary.Calls) / float64(time.Millisecond)
+		stats = append(stats, summary)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// HealthFactor is one factor of the health score.
+type HealthFactor struct {
+	Name string `json:"name"`
+	// Weight is the factor's share of the score, between 0 and 1.
+	Weight float64 `json:"weight"`
+	Score  int     `json:"score"`
+	Detail string  `json:"detail"`
+}
+
+// HealthScore is the body of GET /admin/health-score.
+type HealthScore struct {
+	Score           int                    `json:"score"`
+	Window          string                 `json:"window"`
+	Factors         []HealthFactor         `json:"factors"`
+	Dependencies    []DependencyStats      `json:"dependencies"`
+	CircuitBreakers []CircuitBreakerStatus `json:"circuit_breakers"`
+}
+
+// computeHealthScore scores the service from 0 to 100. Latency and errors are scored on the worst dependency,
+// since one failing dependency is enough to fail payments, and circuit breakers on the share of them that are
+// closed, with half-open ones counting half. Without calls or breakers in the window a factor scores 100.
+func computeHealthScore(dependencies []DependencyStats, breakers []CircuitBreakerStatus, weights HealthScoreWeights) HealthScore {
+	latency := HealthFactor{Name: HealthFactorLatency, Score: 100, Detail: "no dependency calls in the window"}
+	errors := HealthFactor{Name: HealthFactorErrors, Score: 100, Detail: "no dependency calls in the window"}
+	for i, dependency := range dependencies {
+		average := time.Duration(dependency.AverageLatencyMs * float64(time.Millisecond))
+		if score := latencyScore(average); i == 0 || score < latency.Score {
+			latency.Score = score
+			latency.Detail = fmt.Sprintf("%s averages %.1fms", dependency.Name, dependency.AverageLatencyMs)
+		}
+		if score := errorRateScore(dependency.ErrorRate); i == 0 || score < errors.Score {
+			errors.Score = score
+			errors.Detail = fmt.Sprintf("%s failed %d of %d calls", dependency.Name, dependency.Failures, dependency.Calls)
+		}
+	}
+
+	circuits := HealthFactor{Name: HealthFactorCircuitBreakers, Score: 100, Detail: "no circuit breakers registered"}
+	if len(breakers) > 0 {
+		var points, open, halfOpen int
+		for _, breaker := range breakers {
+			switch breaker.State {
+			case CircuitClosed:
+				points += 100
+			case CircuitHalfOpen:
+				points += 50
+				halfOpen++
+			default:
+				open++
+			}
+		}
+		circuits.Score = points / len(breakers)
+		circuits.Detail = fmt.Sprintf("%d of %d open, %d half-open", open, len(breakers), halfOpen)
+	}
+
+	total := weights.Latency + weights.Errors + weights.CircuitBreakers
+	latency.Weight = weights.Latency / total
+	errors.Weight = weights.Errors / total
+	circuits.Weight = weights.CircuitBreakers / total
+	factors := []HealthFactor{latency, errors, circuits}
+	var score float64
+	for _, factor := range factors {
+		score += factor.Weight * float64(factor.Score)
+	}
+	return HealthScore{Score: int(math.Round(score)), Factors: factors, Dependencies: dependencies, CircuitBreakers: breakers}
+}
+
+// latencyScore falls linearly from 100 at healthLatencyTarget to 0 at healthLatencyCeiling.
+func latencyScore(average time.Duration) int {
+	if average <= healthLatencyTarget {
+		return 100
+	}
+	if average >= healthLatencyCeiling {
+		return 0
+	}
+	return int(math.Round(100 * float64(healthLatencyCeiling-average) / float64(healthLatencyCeiling-healthLatencyTarget)))
+}
+
+// errorRateScore falls linearly from 100 at no errors to 0 at healthErrorRateCeiling.
+func errorRateScore(rate float64) int {
+	return int(math.Round(100 * (1 - math.Min(rate/healthErrorRateCeiling, 1))))
+}
+
+func (r *APIRouter) getHealthScore(c *fiber.Ctx) error {
+	// Validate has already rejected malformed HEALTH_SCORE_WEIGHTS.
+	weights, _ := parseHealthScoreWeights(r.config.HealthScoreWeights)
+	score := computeHealthScore(r.health.Stats(), r.breakers.Statuses(), weights)
+	score.Window = r.config.healthScoreWindow().String()
+	return c.JSON(score)
+}
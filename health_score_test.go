@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthScore(t *testing.T) {
+	now := time.Now()
+	newHealth := func() *DependencyHealth {
+		health := NewDependencyHealth(5 * time.Minute)
+		health.now = func() time.Time { return now }
+		// The gateway is slow but reliable, the database fast but failing one call in four.
+		for i := 0; i < 20; i++ {
+			health.Record("gateway:sandbox", 2750*time.Millisecond, false)
+		}
+		for i := 0; i < 4; i++ {
+			health.Record("database", 100*time.Millisecond, i == 0)
+		}
+		return health
+	}
+	newBreakers := func() *CircuitBreakerRegistry {
+		breakers := NewCircuitBreakerRegistry()
+		breakers.Register(NewCircuitBreaker("primary", 1, time.Minute))
+		tripped := NewCircuitBreaker("secondary", 1, time.Minute)
+		tripped.RecordFailure()
+		breakers.Register(tripped)
+		return breakers
+	}
+
+	t.Run("Score And Breakdown", func(t *testing.T) {
+		score := computeHealthScore(newHealth().Stats(), newBreakers().Statuses(), defaultHealthScoreWeights)
+
+		assert.Equal(t, []HealthFactor{
+			{Name: HealthFactorLatency, Weight: 0.3, Score: 50, Detail: "gateway:sandbox averages 2750.0ms"},
+			{Name: HealthFactorErrors, Weight: 0.5, Score: 0, Detail: "database failed 1 of 4 calls"},
+			{Name: HealthFactorCircuitBreakers, Weight: 0.2, Score: 50, Detail: "1 of 2 open, 0 half-open"},
+		}, score.Factors)
+		assert.Equal(t, 25, score.Score)
+		assert.Equal(t, []DependencyStats{
+			{Name: "database", Calls: 4, Failures: 1, ErrorRate: 0.25, AverageLatencyMs: 100},
+			{Name: "gateway:sandbox", Calls: 20, AverageLatencyMs: 2750},
+		}, score.Dependencies)
+	})
+
+	t.Run("Custom Weights", func(t *testing.T) {
+		weights, err := parseHealthScoreWeights("errors=0")
+		assert.NoError(t, err)
+		score := computeHealthScore(newHealth().Stats(), newBreakers().Statuses(), weights)
+
+		assert.Equal(t, 50, score.Score, "latency and circuit breakers at 50 each")
+		assert.InDelta(t, 0.6, score.Factors[0].Weight, 1e-9)
+		assert.Equal(t, 0.0, score.Factors[1].Weight)
+		assert.InDelta(t, 0.4, score.Factors[2].Weight, 1e-9)
+	})
+
+	t.Run("Calls Leave The Window", func(t *testing.T) {
+		health := newHealth()
+		now = now.Add(6 * time.Minute)
+		defer func() { now = now.Add(-6 * time.Minute) }()
+
+		assert.Empty(t, health.Stats())
+		score := computeHealthScore(health.Stats(), nil, defaultHealthScoreWeights)
+		assert.Equal(t, 100, score.Score)
+		assert.Equal(t, "no dependency calls in the window", score.Factors[0].Detail)
+		assert.Equal(t, "no circuit breakers registered", score.Factors[2].Detail)
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{health: newHealth(), breakers: newBreakers()}).SetupRoutes(app, Config{
+			HealthScoreWindow:  time.Minute,
+			HealthScoreWeights: "latency=1,errors=1,circuit_breakers=2",
+			AdminToken:         "admin-secret",
+		})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/health-score", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, "admin token required")
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/health-score", nil)
+		req.Header.Set(HeaderAdminToken, "admin-secret")
+		resp, err = app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var body HealthScore
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, 38, body.Score, "(50 + 0 + 2*50) / 4, rounded")
+		assert.Equal(t, "1m0s", body.Window)
+		assert.Len(t, body.Factors, 3)
+		assert.Len(t, body.Dependencies, 2)
+		assert.Len(t, body.CircuitBreakers, 2)
+	})
+
+	t.Run("Instrumented Gateway Records Calls", func(t *testing.T) {
+		health := NewDependencyHealth(time.Minute)
+		sandbox := NewSandboxGateway("sandbox")
+		gateway := NewInstrumentedGateway(sandbox, NewMetricsRegistry(), 0)
+		gateway.Health = health
+
+		_, err := gateway.Authorize(context.Background(), AuthorizeRequest{PaymentID: "pay_1"})
+		assert.NoError(t, err)
+		sandbox.FailNext(errors.New("gateway unavailable"))
+		_, err = gateway.Authorize(context.Background(), AuthorizeRequest{PaymentID: "pay_2"})
+		assert.Error(t, err)
+
+		stats := health.Stats()
+		if assert.Len(t, stats, 1) {
+			assert.Equal(t, "gateway:sandbox", stats[0].Name)
+			assert.Equal(t, 2, stats[0].Calls)
+			assert.Equal(t, 1, stats[0].Failures)
+		}
+	})
+
+	t.Run("Config Validation", func(t *testing.T) {
+		for _, spec := range []string{"latency", "latency=-1", "speed=10", "latency=0,errors=0,circuit_breakers=0"} {
+			assert.ErrorContains(t, Config{HealthScoreWeights: spec}.Validate(), "HEALTH_SCORE_WEIGHTS", spec)
+		}
+		assert.ErrorContains(t, Config{HealthScoreWindow: -time.Minute}.Validate(), "HEALTH_SCORE_WINDOW")
+
+		weights, err := parseHealthScoreWeights(" latency=20, errors=60 ")
+		assert.NoError(t, err)
+		assert.Equal(t, HealthScoreWeights{Latency: 20, Errors: 60, CircuitBreakers: 20}, weights)
+	})
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// HeaderIdempotencyKey is the request header clients use to make a mutating request safe to retry.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// HeaderIdempotentReplayed is set to "true" on responses replayed from a stored idempotency record.
+const HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+// defaultIdempotencyTTL is how long a stored response is replayed for a repeated key.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// defaultIdempotencyKeyMaxLength is the longest Idempotency-Key accepted when none is configured.
+const defaultIdempotencyKeyMaxLength = 255
+
+// idempotencyRequestsMetric counts keyed requests by whether a stored record was found ("hit") or not ("miss").
+const idempotencyRequestsMetric = "payment_idempotency_requests_total"
+
+const (
+	idempotencyHit  = "hit"
+	idempotencyMiss = "miss"
+)
+
+// IdempotencyKeyPolicy constrains the Idempotency-Key values clients may send, so that odd keys cannot break
+// the storage keys derived from them.
+type IdempotencyKeyPolicy struct {
+	// MaxLength is the longest accepted key in bytes; 0 uses 255.
+	MaxLength int
+	// Pattern, when set, must match the whole key; nil accepts printable ASCII.
+	Pattern *regexp.Regexp
+}
+
+// newIdempotencyKeyPolicy builds the policy from config, failing on an invalid IDEMPOTENCY_KEY_PATTERN.
+func newIdempotencyKeyPolicy(config Config) (IdempotencyKeyPolicy, error) {
+	policy := IdempotencyKeyPolicy{MaxLength: config.IdempotencyKeyMaxLength}
+	if config.IdempotencyKeyPattern != "" {
+		pattern, err := regexp.Compile(`^(?:` + config.IdempotencyKeyPattern + `)$`)
+		if err != nil {
+			return policy, fmt.Errorf("invalid IDEMPOTENCY_KEY_PATTERN: %w", err)
+		}
+		policy.Pattern = pattern
+	}
+	return policy, nil
+}
+
+// Validate reports why key is not acceptable, or nil if it is.
+func (p IdempotencyKeyPolicy) Validate(key string) error {
+	maxLength := p.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultIdempotencyKeyMaxLength
+	}
+	if len(key) > maxLength {
+		return fmt.Errorf("Idempotency-Key must be at most %d characters", maxLength)
+	}
+	if p.Pattern != nil {
+		if !p.Pattern.MatchString(key) {
+			return fmt.Errorf("Idempotency-Key must match %s", p.Pattern)
+		}
+		return nil
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return errors.New("Idempotency-Key must contain only printable ASCII characters")
+		}
+	}
+	return nil
+}
+
+// ErrIdempotencyInProgress is returned when a request with the same key is still being processed.
+var ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+
+// ErrIdempotencyRecordExists is returned by Reserve when the key already has a stored response, which happens
+// when the original request finishes between a duplicate's Get and Reserve.
+var ErrIdempotencyRecordExists = errors.New("idempotency key already has a stored response")
+
+// idempotencyPruneInterval is how often MemoryIdempotencyStore drops expired records as it is written to.
+const idempotencyPruneInterval = time.Minute
+
+// IdempotencyRecord is a stored response for an idempotency key.
+type IdempotencyRecord struct {
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"`
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `json:"content_type"`
+	Location    string    `json:"location,omitempty"`
+	Body        []byte    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// IdempotencyStore stores responses by idempotency key. Reserve claims a key before processing so that
+// concurrent duplicates are rejected instead of processed twice; it fails with ErrIdempotencyRecordExists once
+// the key has a stored response. Release drops a reservation and is a no-op for a key that is not reserved.
+type IdempotencyStore interface {
+	Get(ctx context.Context, key string) (IdempotencyRecord, bool, error)
+	Reserve(ctx context.Context, key string) error
+	Release(ctx context.Context, key string) error
+	Put(ctx context.Context, record IdempotencyRecord) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps records in memory. For single-instance dev/test
+// use it can be persisted to a local file on shutdown and reloaded on startup.
+type MemoryIdempotencyStore struct {
+	mu       sync.Mutex
+	records  map[string]IdempotencyRecord
+	reserved map[string]bool
+	now      func() time.Time
+	// lastPruned is when expired records were last dropped, so that records do not pile up forever.
+	lastPruned time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records:  make(map[string]IdempotencyRecord),
+		reserved: make(map[string]bool),
+		now:      time.Now,
+	}
+}
+
+// Get implements IdempotencyStore, ignoring expired records.
+func (s *MemoryIdempotencyStore) Get(_ context.Context, key string) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok || s.now().After(record.ExpiresAt) {
+		return IdempotencyRecord{}, false, nil
+	}
+	return record, true, nil
+}
+
+// Reserve implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Reserve(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reserved[key] {
+		return ErrIdempotencyInProgress
+	}
+	if record, ok := s.records[key]; ok && !s.now().After(record.ExpiresAt) {
+		return ErrIdempotencyRecordExists
+	}
+	s.reserved[key] = true
+	return nil
+}
+
+// Release implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reserved, key)
+	return nil
+}
+
+// Put implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Put(_ context.Context, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneExpired()
+	s.records[record.Key] = record
+	delete(s.reserved, record.Key)
+	return nil
+}
+
+// pruneExpired drops expired records, at most once per idempotencyPruneInterval. The caller holds s.mu.
+func (s *MemoryIdempotencyStore) pruneExpired() {
+	now := s.now()
+	if now.Sub(s.lastPruned) < idempotencyPruneInterval {
+		return
+	}
+	s.lastPruned = now
+	for key, record := range s.records {
+		if now.After(record.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// lookupOrReserve returns the stored record for key, or reserves key when there is none. A record stored between
+// the lookup and the reservation is returned after all, so that a late duplicate replays the original instead of
+// being processed a second time.
+func lookupOrReserve(ctx context.Context, store IdempotencyStore, key string) (IdempotencyRecord, bool, error) {
+	record, found, err := store.Get(ctx, key)
+	if err != nil || found {
+		return record, found, err
+	}
+	if err := store.Reserve(ctx, key); !errors.Is(err, ErrIdempotencyRecordExists) {
+		return IdempotencyRecord{}, false, err
+	}
+	record, found, err = store.Get(ctx, key)
+	if err == nil && !found {
+		// The record expired just now; the client can retry.
+		err = ErrIdempotencyInProgress
+	}
+	return record, found, err
+}
+
+// SaveToFile writes all unexpired records to path as JSON, replacing the file atomically.
+func (s *MemoryIdempotencyStore) SaveToFile(path string) error {
+	s.mu.Lock()
+	records := make([]IdempotencyRecord, 0, len(s.records))
+	for _, record := range s.records {
+		if !s.now().After(record.ExpiresAt) {
+			records = append(records, record)
+		}
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".idempotency-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFromFile merges the unexpired records saved at path into the store. A missing file is not an error.
+func (s *MemoryIdempotencyStore) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var records []IdempotencyRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range records {
+		if !s.now().After(record.ExpiresAt) {
+			s.records[record.Key] = record
+		}
+	}
+	return nil
+}
+
+// NewIdempotencyMiddleware returns middleware that replays the stored response for a repeated
+// Idempotency-Key on POST and PATCH requests. Reusing a key with a different body is rejected with 422,
+// and a duplicate arriving while the original is still processing is rejected with 409. Keys that break
+// policy are rejected with 400 before anything is stored.
+func NewIdempotencyMiddleware(store IdempotencyStore, policy IdempotencyKeyPolicy, metrics *MetricsRegistry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		clientKey := c.Get(HeaderIdempotencyKey)
+		if clientKey == "" || (c.Method() != fiber.MethodPost && c.Method() != fiber.MethodPatch) {
+			return c.Next()
+		}
+		if err := policy.Validate(clientKey); err != nil {
+			return respondError(c, ErrCodeInvalidIdempotencyKey, err.Error())
+		}
+
+		ctx := c.UserContext()
+		span := SpanFromContext(ctx)
+		span.SetAttribute("idempotency.key", clientKey)
+		decide := func(result, decision string) {
+			span.SetAttribute("idempotency.result", result)
+			span.AddEvent("idempotency.decision", map[string]string{"result": result, "decision": decision})
+			metrics.Inc(idempotencyRequestsMetric, Labels{"result": result})
+		}
+		key := idempotencyStorageKey(c, clientKey)
+		sum := sha256.Sum256(c.Body())
+		fingerprint := hex.EncodeToString(sum[:])
+
+		record, found, err := lookupOrReserve(ctx, store, key)
+		if errors.Is(err, ErrIdempotencyInProgress) {
+			decide(idempotencyHit, "rejected_in_progress")
+			return respondError(c, ErrCodeIdempotencyInProgress, err.Error())
+		}
+		if err != nil {
+			return respondError(c, ErrCodeInternal, "failed to reserve idempotency key")
+		}
+		if found {
+			if record.Fingerprint != fingerprint {
+				decide(idempotencyHit, "rejected_conflict")
+				return respondError(c, ErrCodeIdempotencyConflict, "idempotency key was already used with a different request body")
+			}
+			c.Set(HeaderIdempotentReplayed, "true")
+			decide(idempotencyHit, "replayed")
+			return replayResponse(c, record)
+		}
+		decide(idempotencyMiss, "processed")
+
+		// Deferred so that a panicking handler, which the recovery middleware answers with a 500, does not leave
+		// the key in progress forever. After a Put it has nothing left to release.
+		defer func() { _ = store.Release(ctx, key) }()
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		// Server errors and retryable conflicts are not cached so the client can retry them with the same key.
+		storeResponse(c, store, key, fingerprint, defaultIdempotencyTTL)
+		return nil
+	}
+}
+
+// replayResponse answers the request with a stored response.
+func replayResponse(c *fiber.Ctx, record IdempotencyRecord) error {
+	c.Set(fiber.HeaderContentType, record.ContentType)
+	if record.Location != "" {
+		c.Set(fiber.HeaderLocation, record.Location)
+	}
+	return c.Status(record.StatusCode).Send(record.Body)
+}
+
+// storeResponse stores the response the handler produced under the reserved key for ttl. A server error, or a
+// conflict such as the payment lock's payment_operation_in_progress, releases the key instead, so that the
+// request can be retried.
+func storeResponse(c *fiber.Ctx, store IdempotencyStore, key, fingerprint string, ttl time.Duration) {
+	ctx := c.UserContext()
+	if retryableResponse(c) {
+		_ = store.Release(ctx, key)
+		return
+	}
+	status := c.Response().StatusCode()
+	now := time.Now().UTC()
+	_ = store.Put(ctx, IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		StatusCode:  status,
+		ContentType: string(c.Response().Header.ContentType()),
+		Location:    utils.CopyString(c.GetRespHeader(fiber.HeaderLocation)),
+		Body:        append([]byte(nil), c.Response().Body()...),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	})
+}
+
+// idempotencyStorageKey scopes a client's Idempotency-Key to the API key mode, the tenant, method and path, so
+// the same key sent with a test key and a live key, by two merchants, or to two endpoints, is stored as
+// independent records.
+func idempotencyStorageKey(c *fiber.Ctx, clientKey string) string {
+	mode := apiKeyModeLive
+	if requestTestMode(c) {
+		mode = apiKeyModeTest
+	}
+	return mode + " " + idempotencyTenant(c) + " " + c.Method() + " " + c.Path() + " " + clientKey
+}
+
+// idempotencyTenant identifies who a request authenticated as: the merchant that issued its API key, so that
+// keys it rotates keep sharing its records, or otherwise the API key itself. Requests without a key share one
+// scope.
+func idempotencyTenant(c *fiber.Ctx) string {
+	key, ok := c.Locals(localsAPIKey).(APIKey)
+	switch {
+	case !ok:
+		return "anonymous"
+	case key.MerchantID != "":
+		return merchantKeyPrefix + key.MerchantID
+	default:
+		sum := sha256.Sum256([]byte(key.Key))
+		return "api_key:" + hex.EncodeToString(sum[:8])
+	}
+}
+
+// getIdempotencyKeyPayment tells support which payment a client's Idempotency-Key created. Admin only, since
+// keys are client-chosen and could otherwise be probed. The mode query parameter (live by default) and
+// merchant_id name whose key it is; without merchant_id the key was sent with an API key of no merchant.
+func (r *APIRouter) getIdempotencyKeyPayment(c *fiber.Ctx) error {
+	key, err := url.PathUnescape(c.Params("key"))
+	if err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid idempotency key")
+	}
+	mode := c.Query("mode", apiKeyModeLive)
+	if mode != apiKeyModeLive && mode != apiKeyModeTest {
+		return respondError(c, ErrCodeInvalidRequest, "mode must be live or test")
+	}
+	merchantID := c.Query("merchant_id")
+	payment, err := r.store.GetByIdempotencyKey(c.UserContext(), mode == apiKeyModeTest, merchantID, key)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return respondError(c, ErrCodeNotFound, "no payment was created with this idempotency key")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to look up idempotency key")
+	}
+	return c.JSON(fiber.Map{
+		"idempotency_key":  key,
+		"mode":             mode,
+		"merchant_id":      merchantID,
+		"payment_id":       payment.ID,
+		"reference_number": payment.ReferenceNumber,
+		"status":           payment.Status,
+		"created_at":       payment.CreatedAt,
+	})
+}
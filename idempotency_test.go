@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	newApp := func() (*fiber.App, *SandboxGateway) {
+		gateway := NewSandboxGateway("sandbox")
+		router := &APIRouter{gateway: gateway}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{})
+		return app, gateway
+	}
+	body := `{"amount":1000,"currency":"THB","token":"tok_visa"}`
+
+	t.Run("Replays Response For Repeated Key", func(t *testing.T) {
+		app, gateway := newApp()
+		headers := map[string]string{HeaderIdempotencyKey: "key-1"}
+
+		resp, first := postPayment(t, app, body, headers)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(HeaderIdempotentReplayed))
+		location := resp.Header.Get(fiber.HeaderLocation)
+
+		resp, second := postPayment(t, app, body, headers)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, location, resp.Header.Get(fiber.HeaderLocation))
+
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, 1, gateway.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Rejects Key Reuse With Different Body", func(t *testing.T) {
+		app, _ := newApp()
+		headers := map[string]string{HeaderIdempotencyKey: "key-1"}
+
+		postPayment(t, app, body, headers)
+		resp, _ := postPayment(t, app, `{"amount":2000,"currency":"THB"}`, headers)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Requests Without Key Are Not Deduplicated", func(t *testing.T) {
+		app, _ := newApp()
+
+		_, first := postPayment(t, app, body, nil)
+		resp, second := postPayment(t, app, body, nil)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.Empty(t, resp.Header.Get(HeaderIdempotentReplayed))
+	})
+
+	t.Run("Accepts Valid Key", func(t *testing.T) {
+		app, _ := newApp()
+		resp, _ := postPayment(t, app, body, map[string]string{HeaderIdempotencyKey: "order-42:attempt/1"})
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Rejects Over-Length Key", func(t *testing.T) {
+		app, gateway := newApp()
+		resp, _ := postPayment(t, app, body, map[string]string{HeaderIdempotencyKey: strings.Repeat("k", 256)})
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, 0, gateway.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Rejects Disallowed Characters", func(t *testing.T) {
+		app, _ := newApp()
+		resp, _ := postPayment(t, app, body, map[string]string{HeaderIdempotencyKey: "kéy"})
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Configured Length And Pattern", func(t *testing.T) {
+		policy, err := newIdempotencyKeyPolicy(Config{IdempotencyKeyMaxLength: 8, IdempotencyKeyPattern: "[a-z0-9-]+"})
+		assert.NoError(t, err)
+		assert.NoError(t, policy.Validate("abc-123"))
+		assert.Error(t, policy.Validate("abc-12345"))
+		assert.Error(t, policy.Validate("ABC"))
+		assert.Error(t, policy.Validate("abc def"))
+
+		_, err = newIdempotencyKeyPolicy(Config{IdempotencyKeyPattern: "[unclosed"})
+		assert.ErrorContains(t, err, "IDEMPOTENCY_KEY_PATTERN")
+	})
+
+	t.Run("Rejects Duplicate While In Progress", func(t *testing.T) {
+		store := NewMemoryIdempotencyStore()
+		assert.NoError(t, store.Reserve(context.Background(), "k"))
+		assert.ErrorIs(t, store.Reserve(context.Background(), "k"), ErrIdempotencyInProgress)
+	})
+
+	t.Run("Late Duplicate Replays Instead Of Reprocessing", func(t *testing.T) {
+		ctx := context.Background()
+		store := NewMemoryIdempotencyStore()
+		assert.NoError(t, store.Reserve(ctx, "k"))
+		assert.NoError(t, store.Put(ctx, IdempotencyRecord{Key: "k", ExpiresAt: time.Now().Add(time.Hour)}))
+		assert.ErrorIs(t, store.Reserve(ctx, "k"), ErrIdempotencyRecordExists)
+
+		// The duplicate's lookup misses because the original stores its response right after it.
+		gateway := NewSandboxGateway("sandbox")
+		app := fiber.New()
+		(&APIRouter{gateway: gateway, idempotency: &lateRecordStore{MemoryIdempotencyStore: NewMemoryIdempotencyStore()}}).SetupRoutes(app, Config{})
+		headers := map[string]string{HeaderIdempotencyKey: "key-1"}
+
+		first, created := postPayment(t, app, body, headers)
+		assert.Equal(t, fiber.StatusCreated, first.StatusCode)
+		second, replayed := postPayment(t, app, body, headers)
+		assert.Equal(t, fiber.StatusCreated, second.StatusCode)
+		assert.Equal(t, "true", second.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, created.ID, replayed.ID)
+		assert.Equal(t, 1, gateway.Processed(GatewayOpAuthorize))
+	})
+
+	t.Run("Panicking Handler Releases The Key", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(recover.New())
+		app.Use(NewIdempotencyMiddleware(NewMemoryIdempotencyStore(), IdempotencyKeyPolicy{}, NewMetricsRegistry()))
+		calls := 0
+		app.Post("/payments", func(c *fiber.Ctx) error {
+			calls++
+			if calls == 1 {
+				panic("boom")
+			}
+			return c.SendStatus(fiber.StatusCreated)
+		})
+		post := func() int {
+			req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+			req.Header.Set(HeaderIdempotencyKey, "key-1")
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, fiber.StatusInternalServerError, post())
+		assert.Equal(t, fiber.StatusCreated, post(), "the retry is processed rather than stuck in progress")
+	})
+
+	t.Run("Counts Hits And Misses", func(t *testing.T) {
+		metrics := NewMetricsRegistry()
+		router := &APIRouter{metrics: metrics}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{})
+		headers := map[string]string{HeaderIdempotencyKey: "key-1"}
+
+		postPayment(t, app, body, headers)
+		assert.Equal(t, 1.0, metrics.CounterValue(idempotencyRequestsMetric, Labels{"result": idempotencyMiss}))
+		assert.Equal(t, 0.0, metrics.CounterValue(idempotencyRequestsMetric, Labels{"result": idempotencyHit}))
+
+		postPayment(t, app, body, headers)
+		assert.Equal(t, 1.0, metrics.CounterValue(idempotencyRequestsMetric, Labels{"result": idempotencyMiss}))
+		assert.Equal(t, 1.0, metrics.CounterValue(idempotencyRequestsMetric, Labels{"result": idempotencyHit}))
+	})
+
+	t.Run("Annotates Request Span", func(t *testing.T) {
+		var spans []*Span
+		app := fiber.New()
+		app.Use(NewTracingMiddleware(func(span *Span) { spans = append(spans, span) }))
+		(&APIRouter{}).SetupRoutes(app, Config{})
+		headers := map[string]string{HeaderIdempotencyKey: "key-1"}
+
+		postPayment(t, app, body, headers)
+		postPayment(t, app, body, headers)
+		assert.Len(t, spans, 2)
+		assert.Equal(t, "key-1", spans[0].Attribute("idempotency.key"))
+		assert.Equal(t, idempotencyMiss, spans[0].Attribute("idempotency.result"))
+		assert.Equal(t, idempotencyHit, spans[1].Attribute("idempotency.result"))
+		events := spans[1].Events()
+		assert.Len(t, events, 1)
+		assert.Equal(t, "replayed", events[0].Attributes["decision"])
+	})
+}
+
+// lateRecordStore misses on the first lookup of each key, as if the original request stored its response just
+// after a duplicate looked it up.
+type lateRecordStore struct {
+	*MemoryIdempotencyStore
+	looked map[string]bool
+}
+
+func (s *lateRecordStore) Get(ctx context.Context, key string) (IdempotencyRecord, bool, error) {
+	if s.looked == nil {
+		s.looked = make(map[string]bool)
+	}
+	if !s.looked[key] {
+		s.looked[key] = true
+		return IdempotencyRecord{}, false, nil
+	}
+	return s.MemoryIdempotencyStore.Get(ctx, key)
+}
+
+func TestMemoryIdempotencyStorePersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	now := time.Now().UTC()
+
+	t.Run("Reloads Saved Keys", func(t *testing.T) {
+		store := NewMemoryIdempotencyStore()
+		record := IdempotencyRecord{
+			Key:         "POST /payments key-1",
+			Fingerprint: "abc",
+			StatusCode:  fiber.StatusCreated,
+			ContentType: fiber.MIMEApplicationJSON,
+			Body:        []byte(`{"id":"pay_1"}`),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(time.Hour),
+		}
+		assert.NoError(t, store.Put(ctx, record))
+		assert.NoError(t, store.SaveToFile(path))
+
+		reloaded := NewMemoryIdempotencyStore()
+		assert.NoError(t, reloaded.LoadFromFile(path))
+		got, found, err := reloaded.Get(ctx, record.Key)
+		assert.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, record.Body, got.Body)
+		assert.Equal(t, record.StatusCode, got.StatusCode)
+	})
+
+	t.Run("Skips Expired Keys", func(t *testing.T) {
+		store := NewMemoryIdempotencyStore()
+		assert.NoError(t, store.Put(ctx, IdempotencyRecord{Key: "old", ExpiresAt: now.Add(-time.Minute)}))
+		assert.NoError(t, store.SaveToFile(path))
+
+		reloaded := NewMemoryIdempotencyStore()
+		assert.NoError(t, reloaded.LoadFromFile(path))
+		_, found, _ := reloaded.Get(ctx, "old")
+		assert.False(t, found)
+	})
+
+	t.Run("Missing File Is Not An Error", func(t *testing.T) {
+		store := NewMemoryIdempotencyStore()
+		assert.NoError(t, store.LoadFromFile(filepath.Join(t.TempDir(), "missing.json")))
+	})
+
+	t.Run("Expired Records Pruned On Write", func(t *testing.T) {
+		clock := now
+		store := NewMemoryIdempotencyStore()
+		store.now = func() time.Time { return clock }
+		assert.NoError(t, store.Put(ctx, IdempotencyRecord{Key: "old", ExpiresAt: clock.Add(time.Minute)}))
+
+		clock = clock.Add(2 * idempotencyPruneInterval)
+		assert.NoError(t, store.Put(ctx, IdempotencyRecord{Key: "new", ExpiresAt: clock.Add(time.Hour)}))
+		assert.Len(t, store.records, 1)
+		assert.Contains(t, store.records, "new")
+	})
+
+	t.Run("Refused In Production", func(t *testing.T) {
+		assert.ErrorContains(t, Config{Env: "production", IdempotencyPersistFile: path}.Validate(), "IDEMPOTENCY_PERSIST_FILE")
+		assert.NoError(t, Config{IdempotencyPersistFile: path}.Validate())
+	})
+}
+
+func TestIdempotencyKeyLookup(t *testing.T) {
+	app := fiber.New()
+	(&APIRouter{}).SetupRoutes(app, Config{AdminToken: "admin-secret", LogIdempotencyKeys: true})
+	lookup := func(key, token string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/admin/idempotency/"+url.PathEscape(key), nil)
+		if token != "" {
+			req.Header.Set(HeaderAdminToken, token)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+	_, created := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_visa"}`,
+		map[string]string{HeaderIdempotencyKey: "order 42/retry"})
+
+	t.Run("Existing Key Returns Payment", func(t *testing.T) {
+		resp := lookup("order 42/retry", "admin-secret")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var result map[string]interface{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, created.ID, result["payment_id"])
+		assert.Equal(t, created.ReferenceNumber, result["reference_number"])
+	})
+
+	t.Run("Unknown Key Not Found", func(t *testing.T) {
+		resp := lookup("never-used", "admin-secret")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Admin Only", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, lookup("order 42/retry", "").StatusCode)
+		assert.Equal(t, http.StatusForbidden, lookup("order 42/retry", "wrong").StatusCode)
+	})
+
+	t.Run("Scoped By Mode And Merchant", func(t *testing.T) {
+		merchantKeys := NewMemoryMerchantAPIKeyStore()
+		for merchantID, key := range map[string]string{"m_1": "sk_live_m1", "m_2": "sk_live_m2"} {
+			assert.NoError(t, merchantKeys.Create(context.Background(), MerchantAPIKey{
+				ID: "key_" + merchantID, MerchantID: merchantID, Hash: hashAPIKey(key), CreatedAt: time.Now(),
+			}))
+		}
+		app := fiber.New()
+		(&APIRouter{merchantKeys: merchantKeys}).SetupRoutes(app, Config{AdminToken: "admin-secret", APIKeys: "sk_test_1=test"})
+		created := make(map[string]string)
+		for _, key := range []string{"sk_live_m1", "sk_live_m2", "sk_test_1"} {
+			_, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_visa"}`,
+				map[string]string{HeaderIdempotencyKey: "order-1", fiber.HeaderAuthorization: "Bearer " + key})
+			created[key] = payment.ID
+		}
+		lookupScoped := func(query string) (int, string) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/idempotency/order-1"+query, nil)
+			req.Header.Set(HeaderAdminToken, "admin-secret")
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			var result struct {
+				PaymentID string `json:"payment_id"`
+			}
+			_ = json.NewDecoder(resp.Body).Decode(&result)
+			return resp.StatusCode, result.PaymentID
+		}
+
+		for query, key := range map[string]string{
+			"?merchant_id=m_1": "sk_live_m1",
+			"?merchant_id=m_2": "sk_live_m2",
+			"?mode=test":       "sk_test_1",
+		} {
+			status, paymentID := lookupScoped(query)
+			assert.Equal(t, http.StatusOK, status, query)
+			assert.Equal(t, created[key], paymentID, query)
+		}
+		status, _ := lookupScoped("")
+		assert.Equal(t, http.StatusNotFound, status, "no live payment was created without a merchant")
+		status, _ = lookupScoped("?mode=sandbox")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
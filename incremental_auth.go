@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// GatewayOpIncrementAuthorization increases the amount held by an existing authorization.
+const GatewayOpIncrementAuthorization GatewayOperation = "increment_authorization"
+
+// IncrementAuthorizationRequest carries the data a gateway needs to increase an authorization.
+type IncrementAuthorizationRequest struct {
+	PaymentID        string
+	IncrementID      string
+	IdempotencyKey   string
+	GatewayReference string
+	// Amount is the additional amount to hold on top of what is already authorized.
+	Amount Money
+}
+
+// IncrementAuthorizationResult is the gateway's answer to an incremental authorization.
+type IncrementAuthorizationResult struct {
+	GatewayReference string
+	Approved         bool
+	DeclineReason    string
+}
+
+// IncrementalAuthorizer is implemented by gateways that can increase an authorization before capture, as used
+// by hotels and car rentals.
+type IncrementalAuthorizer interface {
+	IncrementAuthorization(ctx context.Context, req IncrementAuthorizationRequest) (IncrementAuthorizationResult, error)
+}
+
+// AuthorizationIncrement records one successful increase of a payment's authorized amount.
+type AuthorizationIncrement struct {
+	ID               string
+	PaymentID        string
+	Amount           int64
+	Currency         string
+	GatewayReference string
+	CreatedAt        time.Time
+}
+
+// AuthorizationIncrementStore persists authorization increments.
+type AuthorizationIncrementStore interface {
+	Save(ctx context.Context, increment AuthorizationIncrement) error
+	ListByPayment(ctx context.Context, paymentID string) ([]AuthorizationIncrement, error)
+}
+
+// MemoryAuthorizationIncrementStore is an AuthorizationIncrementStore that keeps increments in memory.
+type MemoryAuthorizationIncrementStore struct {
+	increments memoryCollection[AuthorizationIncrement]
+}
+
+// NewMemoryAuthorizationIncrementStore creates an empty MemoryAuthorizationIncrementStore.
+func NewMemoryAuthorizationIncrementStore() *MemoryAuthorizationIncrementStore {
+	return &MemoryAuthorizationIncrementStore{}
+}
+
+// Save implements AuthorizationIncrementStore.
+func (s *MemoryAuthorizationIncrementStore) Save(_ context.Context, increment AuthorizationIncrement) error {
+	s.increments.add(increment)
+	return nil
+}
+
+// ListByPayment implements AuthorizationIncrementStore.
+func (s *MemoryAuthorizationIncrementStore) ListByPayment(_ context.Context, paymentID string) ([]AuthorizationIncrement, error) {
+	return s.increments.filter(func(i AuthorizationIncrement) bool { return i.PaymentID == paymentID }), nil
+}
+
+// incrementAuthorizationRequest is the body accepted by POST /payments/:id/incremental-auth.
+type incrementAuthorizationRequest struct {
+	Amount int64 `json:"amount"`
+}
+
+func (r *APIRouter) incrementAuthorization(c *fiber.Ctx) error {
+	var req incrementAuthorizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	if req.Amount <= 0 {
+		return respondError(c, ErrCodeInvalidAmount, "amount must be a positive integer in minor units")
+	}
+
+	ctx := c.UserContext()
+	payment, err := r.loadVisiblePayment(c)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return respondError(c, ErrCodeNotFound, "payment not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	if payment.Status != PaymentStatusAuthorized {
+		return respondError(c, ErrCodeInvalidState, "only authorized payments can be incremented")
+	}
+	gateway := r.gatewayFor(payment.TestMode)
+	authorizer, ok := gatewayAs[IncrementalAuthorizer](gateway)
+	if !ok {
+		return respondError(c, ErrCodeUnsupportedOperation, "gateway "+gateway.Name()+" does not support incremental authorization")
+	}
+
+	increment := NewMoney(req.Amount, payment.Currency)
+	total, err := payment.Money().Add(increment)
+	if err != nil {
+		return respondError(c, ErrCodeInvalidAmount, err.Error())
+	}
+
+	incrementID := uuid.NewString()
+	result, err := authorizer.IncrementAuthorization(ctx, IncrementAuthorizationRequest{
+		PaymentID:        payment.ID,
+		IncrementID:      incrementID,
+		IdempotencyKey:   GatewayIdempotencyKey(incrementID, GatewayOpIncrementAuthorization, merchantClientKey(payment.MerchantID, c.Get(HeaderIdempotencyKey))),
+		GatewayReference: payment.GatewayReference,
+		Amount:           increment,
+	})
+	if err != nil {
+		return respondError(c, ErrCodeGatewayError, "payment gateway error")
+	}
+	if !result.Approved {
+		return respondError(c, declineErrorCode(result.DeclineReason), "incremental authorization declined: "+result.DeclineReason)
+	}
+
+	now := time.Now().UTC()
+	if err := r.increments.Save(ctx, AuthorizationIncrement{
+		ID:               incrementID,
+		PaymentID:        payment.ID,
+		Amount:           increment.Amount,
+		Currency:         increment.Currency,
+		GatewayReference: result.GatewayReference,
+		CreatedAt:        now,
+	}); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to save authorization increment")
+	}
+	before := payment
+	payment.Amount = total.Amount
+	payment.UpdatedAt = now
+	if err := r.updatePayment(ctx, before, &payment); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to save payment")
+	}
+	r.recordEvent(ctx, payment.ID, EventPaymentAuthorizationIncreased)
+
+	return c.JSON(newPaymentResponse(payment))
+}
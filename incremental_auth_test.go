@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// basicGateway hides the sandbox's optional capabilities, like a processor without incremental authorization.
+type basicGateway struct {
+	PaymentGateway
+}
+
+func postIncrement(t *testing.T, app *fiber.App, paymentID, body string) (*http.Response, PaymentResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/payments/"+paymentID+"/incremental-auth", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var payment PaymentResponse
+	_ = json.NewDecoder(resp.Body).Decode(&payment)
+	return resp, payment
+}
+
+func TestIncrementAuthorization(t *testing.T) {
+	ctx := context.Background()
+	seedAuthorized := func(store PaymentStore, id string) {
+		now := time.Now().UTC()
+		_ = store.Save(ctx, Payment{
+			ID: id, Amount: 10000, Currency: "THB", Status: PaymentStatusAuthorized,
+			GatewayReference: "sandbox_authorize_1", CreatedAt: now, UpdatedAt: now,
+		})
+	}
+
+	t.Run("Successful Increment", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		sandbox := NewSandboxGateway("sandbox")
+		increments := NewMemoryAuthorizationIncrementStore()
+		gateway := NewInstrumentedGateway(sandbox, NewMetricsRegistry(), 0)
+		router := &APIRouter{store: store, gateway: gateway, increments: increments}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{})
+		seedAuthorized(store, "pay_1")
+
+		resp, payment := postIncrement(t, app, "pay_1", `{"amount":2500}`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(12500), payment.Amount)
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpIncrementAuthorization))
+
+		recorded, _ := increments.ListByPayment(ctx, "pay_1")
+		assert.Len(t, recorded, 1)
+		assert.Equal(t, int64(2500), recorded[0].Amount)
+	})
+
+	t.Run("Unsupported Gateway", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		router := &APIRouter{store: store, gateway: basicGateway{NewSandboxGateway("basic")}}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{})
+		seedAuthorized(store, "pay_1")
+
+		resp, _ := postIncrement(t, app, "pay_1", `{"amount":2500}`)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+		stored, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(10000), stored.Amount)
+	})
+
+	t.Run("Payment Not Authorized", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		sandbox := NewSandboxGateway("sandbox")
+		router := &APIRouter{store: store, gateway: sandbox}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{})
+		seedCapturedPayment(t, store, "pay_1", 10000, "")
+
+		resp, _ := postIncrement(t, app, "pay_1", `{"amount":2500}`)
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		assert.Equal(t, 0, sandbox.Processed(GatewayOpIncrementAuthorization))
+	})
+}
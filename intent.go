@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ErrPaymentIntentNotFound is returned by a PaymentIntentStore when no intent exists for the requested ID.
+var ErrPaymentIntentNotFound = errors.New("payment intent not found")
+
+// PaymentIntentStatus is the lifecycle state of a payment intent.
+type PaymentIntentStatus string
+
+const (
+	// PaymentIntentStatusCreated is an intent the customer has not confirmed yet.
+	PaymentIntentStatusCreated PaymentIntentStatus = "created"
+	// PaymentIntentStatusRequiresAction is an intent waiting on the customer, e.g. for 3-D Secure; funds may be on hold.
+	PaymentIntentStatusRequiresAction PaymentIntentStatus = "requires_action"
+	// PaymentIntentStatusConfirmed is an intent the customer confirmed; it can no longer be canceled.
+	PaymentIntentStatusConfirmed PaymentIntentStatus = "confirmed"
+	// PaymentIntentStatusCanceled is an intent canceled before confirmation.
+	PaymentIntentStatusCanceled PaymentIntentStatus = "canceled"
+)
+
+// PaymentIntent represents a checkout in progress before it becomes a confirmed payment.
+type PaymentIntent struct {
+	ID                 string
+	Amount             int64
+	Currency           string
+	CustomerID         string
+	Status             PaymentIntentStatus
+	GatewayReference   string
+	CancellationReason string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	CanceledAt         *time.Time
+	// TestMode and MerchantID record the API key that created the intent; only requests of the same mode and
+	// merchant may act on it.
+	TestMode   bool
+	MerchantID string
+}
+
+// Money returns the intent amount as a currency-safe Money value.
+func (i PaymentIntent) Money() Money {
+	return NewMoney(i.Amount, i.Currency)
+}
+
+// Cancelable reports whether the intent can still be canceled.
+func (i PaymentIntent) Cancelable() bool {
+	return i.Status == PaymentIntentStatusCreated || i.Status == PaymentIntentStatusRequiresAction
+}
+
+// PaymentIntentStore persists payment intents.
+type PaymentIntentStore interface {
+	Save(ctx context.Context, intent PaymentIntent) error
+	Get(ctx context.Context, id string) (PaymentIntent, error)
+}
+
+// MemoryPaymentIntentStore is a PaymentIntentStore that keeps intents in memory.
+type MemoryPaymentIntentStore struct {
+	intents memoryCollection[PaymentIntent]
+}
+
+// NewMemoryPaymentIntentStore creates an empty MemoryPaymentIntentStore.
+func NewMemoryPaymentIntentStore() *MemoryPaymentIntentStore {
+	return &MemoryPaymentIntentStore{}
+}
+
+// Save implements PaymentIntentStore, inserting or replacing the intent.
+func (s *MemoryPaymentIntentStore) Save(_ context.Context, intent PaymentIntent) error {
+	replaced := s.intents.update(
+		func(i PaymentIntent) bool { return i.ID == intent.ID },
+		func(PaymentIntent) PaymentIntent { return intent },
+	)
+	if !replaced {
+		s.intents.add(intent)
+	}
+	return nil
+}
+
+// Get implements PaymentIntentStore.
+func (s *MemoryPaymentIntentStore) Get(_ context.Context, id string) (PaymentIntent, error) {
+	found := s.intents.filter(func(i PaymentIntent) bool { return i.ID == id })
+	if len(found) == 0 {
+		return PaymentIntent{}, ErrPaymentIntentNotFound
+	}
+	return found[0], nil
+}
+
+// createPaymentIntentRequest is the body accepted by POST /payment-intents.
+type createPaymentIntentRequest struct {
+	Amount     int64  `json:"amount"`
+	Currency   string `json:"currency"`
+	CustomerID string `json:"customer_id"`
+}
+
+// cancelPaymentIntentRequest is the optional body accepted by POST /payment-intents/:id/cancel.
+type cancelPaymentIntentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// PaymentIntentResponse is the JSON representation of a payment intent returned by the API.
+type PaymentIntentResponse struct {
+	ID                 string              `json:"id"`
+	Amount             int64               `json:"amount"`
+	Currency           string              `json:"currency"`
+	CustomerID         string              `json:"customer_id,omitempty"`
+	Status             PaymentIntentStatus `json:"status"`
+	CancellationReason string              `json:"cancellation_reason,omitempty"`
+	CreatedAt          time.Time           `json:"created_at"`
+	CanceledAt         *time.Time          `json:"canceled_at,omitempty"`
+	TestMode           bool                `json:"test_mode"`
+}
+
+func newPaymentIntentResponse(intent PaymentIntent) PaymentIntentResponse {
+	return PaymentIntentResponse{
+		ID:                 intent.ID,
+		Amount:             intent.Amount,
+		Currency:           intent.Currency,
+		CustomerID:         intent.CustomerID,
+		Status:             intent.Status,
+		CancellationReason: intent.CancellationReason,
+		CreatedAt:          intent.CreatedAt,
+		CanceledAt:         intent.CanceledAt,
+		TestMode:           intent.TestMode,
+	}
+}
+
+func (r *APIRouter) createPaymentIntent(c *fiber.Ctx) error {
+	var req createPaymentIntentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	if req.Amount <= 0 {
+		return respondError(c, ErrCodeInvalidAmount, "amount must be a positive integer in minor units")
+	}
+	if req.Currency == "" {
+		return respondError(c, ErrCodeInvalidCurrency, "currency is required")
+	}
+	if err := ValidateCurrencyCode(req.Currency); err != nil {
+		return respondError(c, ErrCodeInvalidCurrency, err.Error())
+	}
+
+	now := time.Now().UTC()
+	intent := PaymentIntent{
+		ID:         uuid.NewString(),
+		Amount:     req.Amount,
+		Currency:   NewMoney(0, req.Currency).Currency,
+		CustomerID: req.CustomerID,
+		Status:     PaymentIntentStatusCreated,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		TestMode:   requestTestMode(c),
+		MerchantID: requestMerchantID(c),
+	}
+	if err := r.intents.Save(c.UserContext(), intent); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to save payment intent")
+	}
+	r.setLocation(c, "payment-intents", intent.ID)
+	return c.Status(fiber.StatusCreated).JSON(newPaymentIntentResponse(intent))
+}
+
+// cancelPaymentIntent cancels an intent that has not been confirmed yet, voiding any hold placed on the
+// customer's funds while it was waiting for action. An intent of another mode or merchant is not found.
+func (r *APIRouter) cancelPaymentIntent(c *fiber.Ctx) error {
+	var req cancelPaymentIntentRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+		}
+	}
+
+	ctx := c.UserContext()
+	intent, err := r.intents.Get(ctx, c.Params("id"))
+	if err == nil && !visibleToRequest(c, intent.TestMode, intent.MerchantID) {
+		err = ErrPaymentIntentNotFound
+	}
+	if errors.Is(err, ErrPaymentIntentNotFound) {
+		return respondError(c, ErrCodeNotFound, "payment intent not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment intent")
+	}
+	if !intent.Cancelable() {
+		return respondError(c, ErrCodeInvalidState, "payment intent in status "+string(intent.Status)+" cannot be canceled")
+	}
+
+	if intent.GatewayReference != "" {
+		err := r.gatewayFor(intent.TestMode).Void(ctx, VoidRequest{
+			PaymentID:        intent.ID,
+			IdempotencyKey:   GatewayIdempotencyKey(intent.ID, GatewayOpVoid, merchantClientKey(intent.MerchantID, c.Get(HeaderIdempotencyKey))),
+			GatewayReference: intent.GatewayReference,
+			Currency:         intent.Currency,
+		})
+		if err != nil {
+			return respondError(c, ErrCodeGatewayError, "payment gateway error")
+		}
+	}
+
+	now := time.Now().UTC()
+	intent.Status = PaymentIntentStatusCanceled
+	intent.CancellationReason = req.Reason
+	intent.CanceledAt = &now
+	intent.UpdatedAt = now
+	if err := r.intents.Save(ctx, intent); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to save payment intent")
+	}
+	return c.JSON(newPaymentIntentResponse(intent))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func cancelIntent(t *testing.T, app *fiber.App, id, body string) (*http.Response, PaymentIntentResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/payment-intents/"+id+"/cancel", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var intent PaymentIntentResponse
+	_ = json.NewDecoder(resp.Body).Decode(&intent)
+	return resp, intent
+}
+
+func TestCancelPaymentIntent(t *testing.T) {
+	ctx := context.Background()
+	newApp := func() (*fiber.App, *MemoryPaymentIntentStore, *SandboxGateway) {
+		intents := NewMemoryPaymentIntentStore()
+		gateway := NewSandboxGateway("sandbox")
+		router := &APIRouter{intents: intents, gateway: gateway}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{})
+		return app, intents, gateway
+	}
+	seed := func(intents *MemoryPaymentIntentStore, id string, status PaymentIntentStatus, gatewayRef string) {
+		now := time.Now().UTC()
+		_ = intents.Save(ctx, PaymentIntent{
+			ID: id, Amount: 1000, Currency: "THB", Status: status,
+			GatewayReference: gatewayRef, CreatedAt: now, UpdatedAt: now,
+		})
+	}
+
+	t.Run("Unconfirmed Intent", func(t *testing.T) {
+		app, _, gateway := newApp()
+		req := httptest.NewRequest(http.MethodPost, "/payment-intents", strings.NewReader(`{"amount":1000,"currency":"thb"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		var created PaymentIntentResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		assert.Equal(t, PaymentIntentStatusCreated, created.Status)
+
+		resp, intent := cancelIntent(t, app, created.ID, `{"reason":"abandoned"}`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, PaymentIntentStatusCanceled, intent.Status)
+		assert.Equal(t, "abandoned", intent.CancellationReason)
+		assert.NotNil(t, intent.CanceledAt)
+		assert.Equal(t, 0, gateway.Processed(GatewayOpVoid))
+	})
+
+	t.Run("Requires Action Intent Releases Hold", func(t *testing.T) {
+		app, intents, gateway := newApp()
+		seed(intents, "pi_action", PaymentIntentStatusRequiresAction, "sandbox_authorize_1")
+
+		resp, intent := cancelIntent(t, app, "pi_action", "")
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, PaymentIntentStatusCanceled, intent.Status)
+		assert.Equal(t, 1, gateway.Processed(GatewayOpVoid))
+	})
+
+	t.Run("Confirmed Intent Rejected", func(t *testing.T) {
+		app, intents, gateway := newApp()
+		seed(intents, "pi_confirmed", PaymentIntentStatusConfirmed, "sandbox_authorize_1")
+
+		resp, _ := cancelIntent(t, app, "pi_confirmed", "")
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+		stored, _ := intents.Get(ctx, "pi_confirmed")
+		assert.Equal(t, PaymentIntentStatusConfirmed, stored.Status)
+		assert.Equal(t, 0, gateway.Processed(GatewayOpVoid))
+	})
+
+	t.Run("Scoped By Mode And Merchant", func(t *testing.T) {
+		intents := NewMemoryPaymentIntentStore()
+		live, sandbox := NewSandboxGateway("live"), NewSandboxGateway("sandbox")
+		merchantKeys := NewMemoryMerchantAPIKeyStore()
+		assert.NoError(t, merchantKeys.Create(ctx, MerchantAPIKey{ID: "key_m2", MerchantID: "m_2", Hash: hashAPIKey("sk_live_m2"), CreatedAt: time.Now()}))
+		app := fiber.New()
+		(&APIRouter{intents: intents, gateway: live, testGateway: sandbox, merchantKeys: merchantKeys}).
+			SetupRoutes(app, Config{APIKeys: "sk_live_1=live,sk_test_1=test"})
+		now := time.Now().UTC()
+		_ = intents.Save(ctx, PaymentIntent{
+			ID: "pi_test", Amount: 1000, Currency: "THB", Status: PaymentIntentStatusRequiresAction,
+			GatewayReference: "sandbox_authorize_1", CreatedAt: now, UpdatedAt: now, TestMode: true,
+		})
+		_ = intents.Save(ctx, PaymentIntent{
+			ID: "pi_m1", Amount: 1000, Currency: "THB", Status: PaymentIntentStatusCreated,
+			CreatedAt: now, UpdatedAt: now, MerchantID: "m_1",
+		})
+
+		assert.Equal(t, http.StatusNotFound, postWithKey(t, app, "/payment-intents/pi_test/cancel", "", "sk_live_1"))
+		assert.Equal(t, http.StatusNotFound, postWithKey(t, app, "/payment-intents/pi_m1/cancel", "", "sk_live_m2"))
+		assert.Equal(t, http.StatusOK, postWithKey(t, app, "/payment-intents/pi_test/cancel", "", "sk_test_1"))
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpVoid), "test intents are voided at the sandbox")
+		assert.Equal(t, 0, live.Processed(GatewayOpVoid))
+	})
+
+	t.Run("Unknown Intent", func(t *testing.T) {
+		app, _, _ := newApp()
+		resp, _ := cancelIntent(t, app, "missing", "")
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownCurrency is returned for a currency that is not an active ISO 4217 code.
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ErrUnknownCountry is returned for a country that is not an assigned ISO 3166-1 alpha-2 code.
+var ErrUnknownCountry = errors.New("unknown country")
+
+// ValidateCurrencyCode checks that code, case-insensitively, is an active ISO 4217 currency code.
+func ValidateCurrencyCode(code string) error {
+	if _, ok := iso4217Currencies[strings.ToUpper(strings.TrimSpace(code))]; !ok {
+		return fmt.Errorf("%w: %q is not an ISO 4217 currency code", ErrUnknownCurrency, code)
+	}
+	return nil
+}
+
+// ValidateCountryCode checks that code, case-insensitively, is an assigned ISO 3166-1 alpha-2 country code.
+func ValidateCountryCode(code string) error {
+	if _, ok := iso3166Countries[strings.ToUpper(strings.TrimSpace(code))]; !ok {
+		return fmt.Errorf("%w: %q is not an ISO 3166-1 alpha-2 country code", ErrUnknownCountry, code)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestISOCodes(t *testing.T) {
+	t.Run("Valid Codes Accepted", func(t *testing.T) {
+		for _, code := range []string{"THB", "USD", "JPY", "EUR", "thb", " KWD "} {
+			assert.NoError(t, ValidateCurrencyCode(code), code)
+		}
+		for _, code := range []string{"TH", "US", "JP", "GB", "th"} {
+			assert.NoError(t, ValidateCountryCode(code), code)
+		}
+	})
+
+	t.Run("Made Up Codes Rejected", func(t *testing.T) {
+		for _, code := range []string{"ABC", "THBX", "TH", "", "ZZZ"} {
+			assert.ErrorIs(t, ValidateCurrencyCode(code), ErrUnknownCurrency, code)
+		}
+		for _, code := range []string{"XX", "THA", "ZZ", ""} {
+			assert.ErrorIs(t, ValidateCountryCode(code), ErrUnknownCountry, code)
+		}
+	})
+
+	t.Run("Create Payment Rejects Unknown Codes", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{store: NewMemoryPaymentStore(), gateway: NewSandboxGateway("sandbox")}).SetupRoutes(app, Config{})
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"ABC"}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+		resp, _ = postPayment(t, app, `{"amount":1000,"currency":"THB","billing_country":"XX"}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"thb","billing_country":"th"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "THB", payment.Currency)
+		assert.Equal(t, "TH", payment.BillingCountry)
+	})
+
+	t.Run("BIN Table Rejects Unknown Country", func(t *testing.T) {
+		_, err := LoadBINTable(strings.NewReader("411111,411111,visa,XX\n"))
+		assert.ErrorIs(t, err, ErrUnknownCountry)
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func samplePaymentResponse() PaymentResponse {
+	capturedAt := time.Date(2026, 10, 14, 12, 30, 0, 123456789, time.UTC)
+	return PaymentResponse{
+		ID:             "5f1c7d9e-2b8a-4c3e-9f0a-1d2e3f4a5b6c",
+		Status:         PaymentStatusCaptured,
+		Amount:         math.MaxInt64 - 1,
+		Currency:       "THB",
+		Reference:      "order <A&B> \"quoted\" ร้านค้า",
+		Method:         "card",
+		AmountRefunded: 9007199254740993,
+		Metadata:       map[string]string{"z": "last", "a": "first", "order_id": "A123"},
+		Card:           &CardDetails{Brand: "visa", Last4: "4242", ExpMonth: 12, ExpYear: 2030},
+		CreatedAt:      capturedAt.Add(-time.Hour),
+		CapturedAt:     &capturedAt,
+	}
+}
+
+func TestJSONCodecs(t *testing.T) {
+	stdEncode, stdDecode, err := jsonCodec(JSONCodecStandard)
+	assert.NoError(t, err)
+	goEncode, goDecode, err := jsonCodec(JSONCodecGoJSON)
+	assert.NoError(t, err)
+
+	t.Run("Equivalent Payment Output", func(t *testing.T) {
+		expected, err := stdEncode(samplePaymentResponse())
+		assert.NoError(t, err)
+		actual, err := goEncode(samplePaymentResponse())
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual))
+		assert.Contains(t, string(actual), `"amount":9223372036854775806`)
+	})
+
+	t.Run("Int64 Amounts Decode Exactly", func(t *testing.T) {
+		body := []byte(`{"amount":9007199254740993,"currency":"THB"}`)
+		for _, decode := range []func([]byte, interface{}) error{stdDecode, goDecode} {
+			var req CreatePaymentRequest
+			assert.NoError(t, decode(body, &req))
+			amount, err := req.Amount.MinorUnits(AmountInputMinorUnits, req.Currency)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(9007199254740993), amount)
+		}
+	})
+
+	t.Run("Server Uses Configured Codec", func(t *testing.T) {
+		server := NewServer(Config{JSONCodec: JSONCodecGoJSON}, &APIRouter{})
+
+		resp, payment := postPayment(t, server.app, `{"amount":9007199254740993,"currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, int64(9007199254740993), payment.Amount)
+	})
+
+	t.Run("Unknown Codec Rejected", func(t *testing.T) {
+		_, _, err := jsonCodec("sonic")
+		assert.ErrorContains(t, err, "JSON_CODEC")
+	})
+}
+
+func BenchmarkJSONCodecs(b *testing.B) {
+	payment := samplePaymentResponse()
+	for _, name := range []string{JSONCodecStandard, JSONCodecGoJSON} {
+		encode, _, _ := jsonCodec(name)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := encode(payment); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
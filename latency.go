@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SimulatedLatency is an artificial delay added to payment responses for load testing, either fixed (Min only)
+// or drawn uniformly from [Min, Max].
+type SimulatedLatency struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// parseSimulatedLatency parses SIMULATED_LATENCY, a fixed duration such as "200ms" or a range such as
+// "100ms-2s"; an empty string disables the delay.
+func parseSimulatedLatency(spec string) (SimulatedLatency, error) {
+	if strings.TrimSpace(spec) == "" {
+		return SimulatedLatency{}, nil
+	}
+	low, high, isRange := strings.Cut(spec, "-")
+	minDelay, err := time.ParseDuration(strings.TrimSpace(low))
+	if err != nil || minDelay < 0 {
+		return SimulatedLatency{}, fmt.Errorf("invalid SIMULATED_LATENCY %q: want a duration or a min-max range", spec)
+	}
+	if !isRange {
+		return SimulatedLatency{Min: minDelay, Max: minDelay}, nil
+	}
+	maxDelay, err := time.ParseDuration(strings.TrimSpace(high))
+	if err != nil || maxDelay < minDelay {
+		return SimulatedLatency{}, fmt.Errorf("invalid SIMULATED_LATENCY %q: want a duration or a min-max range", spec)
+	}
+	return SimulatedLatency{Min: minDelay, Max: maxDelay}, nil
+}
+
+// Delay returns the delay for one request.
+func (l SimulatedLatency) Delay() time.Duration {
+	if l.Max <= l.Min {
+		return l.Min
+	}
+	return l.Min + rand.N(l.Max-l.Min+1)
+}
+
+// NewSimulatedLatencyMiddleware delays every /payments request by latency before handling it. The delay runs
+// inside the request's deadline, so a long enough delay ends in the same request_timeout a slow gateway causes;
+// a request whose deadline passes while it waits is abandoned without reaching the handler.
+func NewSimulatedLatencyMiddleware(latency SimulatedLatency) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !strings.HasPrefix(c.Path(), "/payments") {
+			return c.Next()
+		}
+		ctx := c.UserContext()
+		timer := time.NewTimer(latency.Delay())
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulatedLatency(t *testing.T) {
+	timeRequest := func(t *testing.T, config Config, path string) (*http.Response, time.Duration) {
+		app := fiber.New()
+		useMiddlewares(app, config)
+		app.Get(path, func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+		start := time.Now()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil), -1)
+		assert.NoError(t, err)
+		return resp, time.Since(start)
+	}
+
+	t.Run("Parse", func(t *testing.T) {
+		latency, err := parseSimulatedLatency("200ms")
+		assert.NoError(t, err)
+		assert.Equal(t, SimulatedLatency{Min: 200 * time.Millisecond, Max: 200 * time.Millisecond}, latency)
+
+		latency, err = parseSimulatedLatency("100ms-2s")
+		assert.NoError(t, err)
+		assert.Equal(t, SimulatedLatency{Min: 100 * time.Millisecond, Max: 2 * time.Second}, latency)
+
+		for _, spec := range []string{"slow", "2s-1s", "100ms-", "-1s"} {
+			_, err := parseSimulatedLatency(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+
+	t.Run("Random Delay Stays In Range", func(t *testing.T) {
+		latency := SimulatedLatency{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}
+		for i := 0; i < 100; i++ {
+			delay := latency.Delay()
+			assert.GreaterOrEqual(t, delay, latency.Min)
+			assert.LessOrEqual(t, delay, latency.Max)
+		}
+	})
+
+	t.Run("Delay Applied When Enabled", func(t *testing.T) {
+		resp, elapsed := timeRequest(t, Config{SimulatedLatency: "150ms"}, "/payments")
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.GreaterOrEqual(t, elapsed, 150*time.Millisecond)
+	})
+
+	t.Run("Delay Absent When Disabled", func(t *testing.T) {
+		_, elapsed := timeRequest(t, Config{}, "/payments")
+		assert.Less(t, elapsed, 100*time.Millisecond)
+		assert.NotContains(t, middlewareNames(Config{}), "simulated_latency")
+	})
+
+	t.Run("Non Payment Routes Are Not Delayed", func(t *testing.T) {
+		_, elapsed := timeRequest(t, Config{SimulatedLatency: "150ms"}, "/health")
+		assert.Less(t, elapsed, 100*time.Millisecond)
+	})
+
+	t.Run("Delay Past Deadline Times Out", func(t *testing.T) {
+		resp, _ := timeRequest(t, Config{SimulatedLatency: "200ms", RequestTimeout: 50 * time.Millisecond}, "/payments")
+		assert.Equal(t, fiber.StatusGatewayTimeout, resp.StatusCode)
+	})
+
+	t.Run("Off In Production", func(t *testing.T) {
+		config := Config{Env: "production", SimulatedLatency: "150ms"}
+		assert.NotContains(t, middlewareNames(config), "simulated_latency")
+		assert.ErrorContains(t, Config{Env: "production", SimulatedLatency: "150ms", Timezone: "UTC"}.Validate(), "SIMULATED_LATENCY")
+	})
+}
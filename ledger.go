@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// LedgerAccount names an account in the payment ledger.
+type LedgerAccount string
+
+const (
+	// AccountGatewayPayable holds funds collected by the gateway that it owes to the platform.
+	AccountGatewayPayable LedgerAccount = "gateway_payable"
+	// AccountMerchantReceivable holds funds the platform owes to merchants.
+	AccountMerchantReceivable LedgerAccount = "merchant_receivable"
+	// AccountPlatformFee holds fees earned by the platform.
+	AccountPlatformFee LedgerAccount = "platform_fee"
+	// AccountCustomerStoreCredit holds store credit the platform owes to customers.
+	AccountCustomerStoreCredit LedgerAccount = "customer_store_credit"
+	// AccountRecipientPayable holds funds the platform owes to marketplace split recipients, in one account per
+	// recipient named "recipient_payable:<recipient>".
+	AccountRecipientPayable LedgerAccount = "recipient_payable"
+)
+
+// PostingDirection is the side of the ledger a posting is written to.
+type PostingDirection string
+
+const (
+	// Debit increases asset accounts and decreases liability accounts.
+	Debit PostingDirection = "debit"
+	// Credit decreases asset accounts and increases liability accounts.
+	Credit PostingDirection = "credit"
+)
+
+// ErrUnbalancedTransaction is returned when a transaction's debits and credits differ.
+var ErrUnbalancedTransaction = errors.New("ledger transaction is unbalanced")
+
+// Posting is one debit or credit line of a ledger transaction.
+type Posting struct {
+	Account   LedgerAccount    `json:"account"`
+	Direction PostingDirection `json:"direction"`
+	Amount    Money            `json:"amount"`
+}
+
+// LedgerTransaction groups the postings produced by one business event; its debits always equal its credits.
+type LedgerTransaction struct {
+	ID        string    `json:"id"`
+	PaymentID string    `json:"payment_id"`
+	Kind      string    `json:"kind"`
+	Postings  []Posting `json:"postings"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks that the transaction has postings and that debits equal credits in every currency.
+func (t LedgerTransaction) Validate() error {
+	if len(t.Postings) == 0 {
+		return fmt.Errorf("%w: no postings", ErrUnbalancedTransaction)
+	}
+	net := make(map[string]Money)
+	for _, p := range t.Postings {
+		if p.Amount.IsNegative() {
+			return fmt.Errorf("%w: negative posting to %s", ErrUnbalancedTransaction, p.Account)
+		}
+		signed := p.Amount
+		if p.Direction == Credit {
+			signed.Amount = -signed.Amount
+		}
+		current, ok := net[signed.Currency]
+		if !ok {
+			current = NewMoney(0, signed.Currency)
+		}
+		sum, err := current.Add(signed)
+		if err != nil {
+			return err
+		}
+		net[signed.Currency] = sum
+	}
+	for currency, sum := range net {
+		if !sum.IsZero() {
+			return fmt.Errorf("%w: %s off by %d", ErrUnbalancedTransaction, currency, sum.Amount)
+		}
+	}
+	return nil
+}
+
+// AccountBalance is the net debit-minus-credit balance of an account in one currency.
+type AccountBalance struct {
+	Account LedgerAccount `json:"account"`
+	Balance Money         `json:"balance"`
+}
+
+// Ledger records double-entry transactions and reports account balances.
+type Ledger interface {
+	Post(ctx context.Context, tx LedgerTransaction) error
+	Balances(ctx context.Context) ([]AccountBalance, error)
+	ListByPayment(ctx context.Context, paymentID string) ([]LedgerTransaction, error)
+}
+
+// MemoryLedger is a Ledger that keeps transactions in memory.
+type MemoryLedger struct {
+	mu           sync.RWMutex
+	transactions []LedgerTransaction
+}
+
+// NewMemoryLedger creates an empty MemoryLedger.
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{}
+}
+
+// Post implements Ledger, rejecting unbalanced transactions.
+func (l *MemoryLedger) Post(_ context.Context, tx LedgerTransaction) error {
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.transactions = append(l.transactions, tx)
+	return nil
+}
+
+// Balances implements Ledger, returning balances sorted by account and currency.
+func (l *MemoryLedger) Balances(_ context.Context) ([]AccountBalance, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	type key struct {
+		account  LedgerAccount
+		currency string
+	}
+	totals := make(map[key]Money)
+	for _, tx := range l.transactions {
+		for _, p := range tx.Postings {
+			k := key{p.Account, p.Amount.Currency}
+			signed := p.Amount
+			if p.Direction == Credit {
+				signed.Amount = -signed.Amount
+			}
+			current, ok := totals[k]
+			if !ok {
+				current = NewMoney(0, k.currency)
+			}
+			sum, err := current.Add(signed)
+			if err != nil {
+				return nil, err
+			}
+			totals[k] = sum
+		}
+	}
+
+	balances := make([]AccountBalance, 0, len(totals))
+	for k, total := range totals {
+		balances = append(balances, AccountBalance{Account: k.account, Balance: total})
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Account != balances[j].Account {
+			return balances[i].Account < balances[j].Account
+		}
+		return balances[i].Balance.Currency < balances[j].Balance.Currency
+	})
+	return balances, nil
+}
+
+// ListByPayment implements Ledger.
+func (l *MemoryLedger) ListByPayment(_ context.Context, paymentID string) ([]LedgerTransaction, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var result []LedgerTransaction
+	for _, tx := range l.transactions {
+		if tx.PaymentID == paymentID {
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+// PlatformFee computes the platform fee for an amount in basis points, rounding half up to the minor unit.
+func PlatformFee(amount Money, basisPoints int) Money {
+	if basisPoints <= 0 {
+		return NewMoney(0, amount.Currency)
+	}
+	fee, remainder := mulBasisPoints(amount.Amount, int64(basisPoints))
+	if remainder >= 5000 {
+		fee++
+	}
+	return NewMoney(fee, amount.Currency)
+}
+
+// CaptureTransaction builds the postings for a captured payment: the gateway owes us the captured amount,
+// which we owe to the merchant less the platform fee.
+func CaptureTransaction(paymentID string, captured, fee Money) LedgerTransaction {
+	postings := []Posting{
+		{Account: AccountGatewayPayable, Direction: Debit, Amount: captured},
+		{Account: AccountMerchantReceivable, Direction: Credit, Amount: captured},
+	}
+	if !fee.IsZero() {
+		postings = append(postings,
+			Posting{Account: AccountMerchantReceivable, Direction: Debit, Amount: fee},
+			Posting{Account: AccountPlatformFee, Direction: Credit, Amount: fee},
+		)
+	}
+	return LedgerTransaction{ID: uuid.NewString(), PaymentID: paymentID, Kind: "capture", Postings: postings, CreatedAt: time.Now().UTC()}
+}
+
+// RefundTransaction builds the postings for a refund, reducing what we owe the merchant. Refunds to the
+// original method reduce what the gateway owes us; store credit refunds become a liability to the customer.
+func RefundTransaction(paymentID string, refunded Money, destination RefundDestination) LedgerTransaction {
+	counterAccount := AccountGatewayPayable
+	if destination == RefundToStoreCredit {
+		counterAccount = AccountCustomerStoreCredit
+	}
+	postings := []Posting{
+		{Account: AccountMerchantReceivable, Direction: Debit, Amount: refunded},
+		{Account: counterAccount, Direction: Credit, Amount: refunded},
+	}
+	return LedgerTransaction{ID: uuid.NewString(), PaymentID: paymentID, Kind: "refund", Postings: postings, CreatedAt: time.Now().UTC()}
+}
+
+// postCapture records the ledger transaction of a capture, including the configured platform fee, and the
+// payment's splits. Test payments move no real money and are not booked.
+func (r *APIRouter) postCapture(ctx context.Context, payment Payment) error {
+	if payment.TestMode {
+		return nil
+	}
+	captured := payment.Money()
+	if err := r.ledger.Post(ctx, CaptureTransaction(payment.ID, captured, PlatformFee(captured, r.config.PlatformFeeBasisPoints))); err != nil {
+		return err
+	}
+	return r.postSplits(ctx, payment)
+}
+
+func (r *APIRouter) getLedgerBalances(c *fiber.Ctx) error {
+	balances, err := r.ledger.Balances(c.UserContext())
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to compute balances")
+	}
+	return c.JSON(fiber.Map{
+		"balances": balances,
+	})
+}
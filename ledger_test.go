@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func balanceOf(balances []AccountBalance, account LedgerAccount) int64 {
+	for _, b := range balances {
+		if b.Account == account {
+			return b.Balance.Amount
+		}
+	}
+	return 0
+}
+
+func TestLedgerTransactions(t *testing.T) {
+	t.Run("Capture Produces Balanced Postings", func(t *testing.T) {
+		captured := NewMoney(10000, "THB")
+		tx := CaptureTransaction("pay_1", captured, PlatformFee(captured, 295))
+		assert.NoError(t, tx.Validate())
+		assert.Len(t, tx.Postings, 4)
+
+		var debits, credits int64
+		for _, p := range tx.Postings {
+			if p.Direction == Debit {
+				debits += p.Amount.Amount
+			} else {
+				credits += p.Amount.Amount
+			}
+		}
+		assert.Equal(t, debits, credits)
+	})
+
+	t.Run("Unbalanced Transaction Rejected", func(t *testing.T) {
+		ledger := NewMemoryLedger()
+		err := ledger.Post(context.Background(), LedgerTransaction{Postings: []Posting{
+			{Account: AccountGatewayPayable, Direction: Debit, Amount: NewMoney(100, "THB")},
+			{Account: AccountMerchantReceivable, Direction: Credit, Amount: NewMoney(90, "THB")},
+		}})
+		assert.ErrorIs(t, err, ErrUnbalancedTransaction)
+	})
+
+	t.Run("Cross-Currency Transaction Rejected", func(t *testing.T) {
+		tx := LedgerTransaction{Postings: []Posting{
+			{Account: AccountGatewayPayable, Direction: Debit, Amount: NewMoney(100, "THB")},
+			{Account: AccountMerchantReceivable, Direction: Credit, Amount: NewMoney(100, "USD")},
+		}}
+		assert.ErrorIs(t, tx.Validate(), ErrUnbalancedTransaction)
+	})
+
+	t.Run("Fee Rounding", func(t *testing.T) {
+		assert.Equal(t, NewMoney(30, "THB"), PlatformFee(NewMoney(1000, "THB"), 295))
+		assert.Equal(t, NewMoney(0, "THB"), PlatformFee(NewMoney(1000, "THB"), 0))
+	})
+}
+
+func TestLedgerBalancesReconcileAfterRefund(t *testing.T) {
+	store := NewMemoryPaymentStore()
+	ledger := NewMemoryLedger()
+	payment := seedCapturedPayment(t, store, "pay_1", 1000, "cus_1")
+
+	app := fiber.New()
+	router := &APIRouter{store: store, ledger: ledger}
+	router.SetupRoutes(app, Config{PlatformFeeBasisPoints: 300, AdminToken: "admin-secret"})
+	assert.NoError(t, router.postCapture(context.Background(), payment))
+
+	resp, _ := postRefund(t, app, "pay_1", `{"amount":400}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/admin/ledger/balances", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "admin token required")
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/ledger/balances", nil)
+	req.Header.Set(HeaderAdminToken, "admin-secret")
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Balances []AccountBalance `json:"balances"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+	gatewayPayable := balanceOf(result.Balances, AccountGatewayPayable)
+	merchantReceivable := balanceOf(result.Balances, AccountMerchantReceivable)
+	platformFee := balanceOf(result.Balances, AccountPlatformFee)
+
+	assert.Equal(t, int64(600), gatewayPayable)
+	assert.Equal(t, int64(-570), merchantReceivable)
+	assert.Equal(t, int64(-30), platformFee)
+	assert.Zero(t, gatewayPayable+merchantReceivable+platformFee)
+}
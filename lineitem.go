@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLineItemNotOnPayment is returned when a refund names a line item the payment does not have.
+var ErrLineItemNotOnPayment = errors.New("line item is not on the payment")
+
+// ErrLineItemAlreadyRefunded is returned when a refund names a line item an earlier refund already covers.
+var ErrLineItemAlreadyRefunded = errors.New("line item is already refunded")
+
+// LineItem is one item of an itemized payment. RefundID is set once a refund covers the item, so it cannot be
+// refunded twice; it is cleared again if that refund fails.
+type LineItem struct {
+	ID          string
+	Description string
+	Amount      int64
+	RefundID    string
+}
+
+// lineItemInput is a line item as sent on POST /payments; its amount follows the payment's amount input mode.
+type lineItemInput struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description"`
+	Amount      AmountInput `json:"amount"`
+}
+
+// LineItemResponse is the JSON representation of a line item returned by the API.
+type LineItemResponse struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Amount      int64  `json:"amount"`
+	Refunded    bool   `json:"refunded"`
+	RefundID    string `json:"refund_id,omitempty"`
+}
+
+func newLineItemResponses(items []LineItem) []LineItemResponse {
+	if len(items) == 0 {
+		return nil
+	}
+	responses := make([]LineItemResponse, 0, len(items))
+	for _, item := range items {
+		responses = append(responses, LineItemResponse{
+			ID:          item.ID,
+			Description: item.Description,
+			Amount:      item.Amount,
+			Refunded:    item.RefundID != "",
+			RefundID:    item.RefundID,
+		})
+	}
+	return responses
+}
+
+// newLineItems validates the line items of a create request: IDs must be present and unique, amounts positive,
+// and together they may not exceed the payment amount, nor overflow summing them.
+func newLineItems(inputs []lineItemInput, mode AmountInputMode, currency string, paymentAmount int64) ([]LineItem, error) {
+	items := make([]LineItem, 0, len(inputs))
+	seen := make(map[string]bool, len(inputs))
+	total := NewMoney(0, currency)
+	for _, input := range inputs {
+		if input.ID == "" {
+			return nil, errors.New("every line item needs an id")
+		}
+		if seen[input.ID] {
+			return nil, fmt.Errorf("line item %q is listed twice", input.ID)
+		}
+		seen[input.ID] = true
+		amount, err := input.Amount.MinorUnits(mode, currency)
+		if err != nil {
+			return nil, fmt.Errorf("line item %q: %w", input.ID, err)
+		}
+		if amount <= 0 {
+			return nil, fmt.Errorf("line item %q must have a positive amount", input.ID)
+		}
+		if total, err = total.Add(NewMoney(amount, currency)); err != nil {
+			return nil, fmt.Errorf("line items total: %w", err)
+		}
+		items = append(items, LineItem{ID: input.ID, Description: input.Description, Amount: amount})
+	}
+	if total.Amount > paymentAmount {
+		return nil, fmt.Errorf("line items total %d, more than the payment amount %d", total.Amount, paymentAmount)
+	}
+	return items, nil
+}
+
+// lineItemsRefundAmount sums the amounts of the named line items of a payment, rejecting items that are not on
+// the payment, are named twice or were already refunded.
+func lineItemsRefundAmount(items []LineItem, ids []string) (int64, error) {
+	byID := make(map[string]LineItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	seen := make(map[string]bool, len(ids))
+	var total Money
+	for _, id := range ids {
+		item, ok := byID[id]
+		if !ok || seen[id] {
+			return 0, fmt.Errorf("%w: %q", ErrLineItemNotOnPayment, id)
+		}
+		if item.RefundID != "" {
+			return 0, fmt.Errorf("%w: %q by refund %s", ErrLineItemAlreadyRefunded, id, item.RefundID)
+		}
+		seen[id] = true
+		var err error
+		if total, err = total.Add(Money{Amount: item.Amount}); err != nil {
+			return 0, fmt.Errorf("line items total: %w", err)
+		}
+	}
+	return total.Amount, nil
+}
+
+// markLineItemsRefunded returns a copy of items with the named ones attributed to refundID.
+func markLineItemsRefunded(items []LineItem, ids []string, refundID string) []LineItem {
+	if len(ids) == 0 {
+		return items
+	}
+	refunded := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		refunded[id] = true
+	}
+	marked := append([]LineItem(nil), items...)
+	for i := range marked {
+		if refunded[marked[i].ID] {
+			marked[i].RefundID = refundID
+		}
+	}
+	return marked
+}
+
+// releaseLineItems returns a copy of items with those attributed to refundID refundable again.
+func releaseLineItems(items []LineItem, refundID string) []LineItem {
+	released := append([]LineItem(nil), items...)
+	for i := range released {
+		if released[i].RefundID == refundID {
+			released[i].RefundID = ""
+		}
+	}
+	return released
+}
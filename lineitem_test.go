@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineItemRefunds(t *testing.T) {
+	ctx := context.Background()
+	var refunds *MemoryRefundStore
+	newApp := func() (*fiber.App, PaymentStore, *SandboxGateway) {
+		refunds = NewMemoryRefundStore()
+		store := NewMemoryPaymentStore()
+		capturedAt := time.Now().UTC()
+		assert.NoError(t, store.Save(ctx, Payment{
+			ID: "pay_1", Amount: 1000, Currency: "THB", Method: "card", Status: PaymentStatusCaptured,
+			GatewayReference: "sandbox_authorize_1", CreatedAt: capturedAt, CapturedAt: &capturedAt,
+			LineItems: []LineItem{
+				{ID: "li_shirt", Amount: 600},
+				{ID: "li_socks", Amount: 150},
+				{ID: "li_hat", Amount: 250},
+			},
+		}))
+		gateway := NewSandboxGateway("sandbox")
+		app := fiber.New()
+		(&APIRouter{store: store, gateway: gateway, refunds: refunds}).SetupRoutes(app, Config{})
+		return app, store, gateway
+	}
+	refundedItems := func(store PaymentStore) map[string]string {
+		payment, _ := store.Get(ctx, "pay_1")
+		refunded := make(map[string]string)
+		for _, item := range payment.LineItems {
+			if item.RefundID != "" {
+				refunded[item.ID] = item.RefundID
+			}
+		}
+		return refunded
+	}
+
+	t.Run("Refunds Specific Items", func(t *testing.T) {
+		app, store, _ := newApp()
+
+		resp, refund := postRefund(t, app, "pay_1", `{"line_item_ids":["li_shirt","li_socks"]}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, int64(750), refund.Amount)
+		assert.Equal(t, []string{"li_shirt", "li_socks"}, refund.LineItemIDs)
+		assert.Equal(t, map[string]string{"li_shirt": refund.ID, "li_socks": refund.ID}, refundedItems(store))
+
+		payment, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(750), payment.AmountRefunded)
+	})
+
+	t.Run("Already Refunded Item Rejected", func(t *testing.T) {
+		app, store, gateway := newApp()
+		resp, _ := postRefund(t, app, "pay_1", `{"line_item_ids":["li_shirt"]}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		resp, _ = postRefund(t, app, "pay_1", `{"line_item_ids":["li_hat","li_shirt"]}`)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, 1, gateway.Processed(GatewayOpRefund))
+		assert.Len(t, refundedItems(store), 1)
+	})
+
+	t.Run("Item Not On Payment Rejected", func(t *testing.T) {
+		app, store, gateway := newApp()
+
+		resp, _ := postRefund(t, app, "pay_1", `{"line_item_ids":["li_shirt","li_other"]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, 0, gateway.Processed(GatewayOpRefund))
+		assert.Empty(t, refundedItems(store))
+	})
+
+	t.Run("Amount Must Match Items", func(t *testing.T) {
+		app, _, _ := newApp()
+
+		resp, _ := postRefund(t, app, "pay_1", `{"amount":100,"line_item_ids":["li_hat"]}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Failed Pending Refund Releases Items", func(t *testing.T) {
+		app, store, gateway := newApp()
+		gateway.SetAsyncRefunds(true)
+
+		resp, refund := postRefund(t, app, "pay_1", `{"line_item_ids":["li_hat"]}`)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, map[string]string{"li_hat": refund.ID}, refundedItems(store))
+
+		resp, _ = postRefund(t, app, "pay_1", `{"line_item_ids":["li_hat"]}`)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+		stored, _ := refunds.Get(ctx, refund.ID)
+		resp = postGatewayWebhook(t, app, `{"type":"refund.failed","gateway_reference":"`+stored.GatewayReference+`"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, refundedItems(store))
+
+		resp, _ = postRefund(t, app, "pay_1", `{"line_item_ids":["li_hat"]}`)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	})
+
+	t.Run("Created With Payment", func(t *testing.T) {
+		app, _, _ := newApp()
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","line_items":[{"id":"li_1","description":"Shirt","amount":600},{"id":"li_2","amount":400}]}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, []LineItemResponse{{ID: "li_1", Description: "Shirt", Amount: 600}, {ID: "li_2", Amount: 400}}, payment.LineItems)
+
+		resp, _ = postPayment(t, app, `{"amount":1000,"currency":"THB","line_items":[{"id":"li_1","amount":1200}]}`, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Overflowing Total Rejected", func(t *testing.T) {
+		var inputs []lineItemInput
+		assert.NoError(t, json.Unmarshal([]byte(`[{"id":"li_1","amount":9223372036854775807},{"id":"li_2","amount":2}]`), &inputs))
+
+		_, err := newLineItems(inputs, AmountInputMinorUnits, "THB", 1000)
+		assert.ErrorIs(t, err, ErrAmountOverflow)
+
+		_, err = lineItemsRefundAmount([]LineItem{{ID: "li_1", Amount: math.MaxInt64}, {ID: "li_2", Amount: 2}}, []string{"li_1", "li_2"})
+		assert.ErrorIs(t, err, ErrAmountOverflow)
+	})
+}
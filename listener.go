@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net"
+	"sync"
+)
+
+// limitListener wraps a net.Listener so that at most a fixed number of connections are open at once.
+// Connections accepted beyond the limit are closed immediately, before any HTTP processing, so a flood of
+// idle connections cannot exhaust file descriptors.
+type limitListener struct {
+	net.Listener
+	slots chan struct{}
+}
+
+// NewLimitListener caps the simultaneous open connections accepted from l at maxConnections.
+func NewLimitListener(l net.Listener, maxConnections int) net.Listener {
+	return &limitListener{Listener: l, slots: make(chan struct{}, maxConnections)}
+}
+
+// Accept implements net.Listener, closing and skipping connections that exceed the limit.
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case l.slots <- struct{}{}:
+			return &limitConn{Conn: conn, release: func() { <-l.slots }}, nil
+		default:
+			_ = conn.Close()
+		}
+	}
+}
+
+// limitConn frees its listener slot once, on the first Close.
+type limitConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+// Close implements net.Conn.
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
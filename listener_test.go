@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener := NewLimitListener(inner, 2)
+	defer func() { _ = listener.Close() }()
+
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", inner.Addr().String())
+		assert.NoError(t, err)
+		return conn
+	}
+	closedByServer := func(conn net.Conn) bool {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := conn.Read(make([]byte, 1))
+		var netErr net.Error
+		return err != nil && !(errors.As(err, &netErr) && netErr.Timeout())
+	}
+
+	clients := []net.Conn{dial(), dial()}
+	defer func() {
+		for _, c := range clients {
+			_ = c.Close()
+		}
+	}()
+	first := <-accepted
+	second := <-accepted
+	defer func() { _ = second.Close() }()
+
+	t.Run("Excess Connection Refused", func(t *testing.T) {
+		excess := dial()
+		defer func() { _ = excess.Close() }()
+		assert.True(t, closedByServer(excess))
+		assert.Empty(t, accepted)
+	})
+
+	t.Run("Slot Freed On Close", func(t *testing.T) {
+		assert.NoError(t, first.Close())
+
+		next := dial()
+		defer func() { _ = next.Close() }()
+		select {
+		case conn := <-accepted:
+			_ = conn.Close()
+		case <-time.After(time.Second):
+			t.Fatal("connection was not accepted after a slot was freed")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// resourcePath builds the canonical GET path of a resource from its path segments, escaping each one and
+// prefixing the configured base path so the URL is valid as seen by clients behind a proxy.
+func (r *APIRouter) resourcePath(segments ...string) string {
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(r.config.BasePath, "/"))
+	for _, segment := range segments {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(segment))
+	}
+	return b.String()
+}
+
+// setLocation points the response's Location header at a newly created resource.
+func (r *APIRouter) setLocation(c *fiber.Ctx, segments ...string) {
+	c.Location(r.resourcePath(segments...))
+}
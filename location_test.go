@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationHeader(t *testing.T) {
+	t.Run("Payment", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_visa"}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "/payments/"+payment.ID, resp.Header.Get(fiber.HeaderLocation))
+	})
+
+	t.Run("Payment Respects Base Path", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{BasePath: "https://pay.example.com/v1/"})
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_visa"}`, nil)
+		assert.Equal(t, "https://pay.example.com/v1/payments/"+payment.ID, resp.Header.Get(fiber.HeaderLocation))
+	})
+
+	t.Run("Customer", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{BasePath: "/api", AdminToken: "admin-secret"})
+
+		resp, customer := postCustomer(t, app, "mer_1", `{"external_id":"ext-1","email":"a@example.com"}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "/api/merchants/mer_1/customers/"+customer.ID, resp.Header.Get(fiber.HeaderLocation))
+	})
+
+	t.Run("Refund", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		seedCapturedPayment(t, store, "pay_1", 1000, "cus_1")
+		app := fiber.New()
+		(&APIRouter{store: store}).SetupRoutes(app, Config{})
+
+		resp, refund := postRefund(t, app, "pay_1", `{"amount":400,"destination":"original"}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "/payments/pay_1/refunds/"+refund.ID, resp.Header.Get(fiber.HeaderLocation))
+	})
+
+	t.Run("Payment Intent", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{})
+
+		req := httptest.NewRequest(http.MethodPost, "/payment-intents", strings.NewReader(`{"amount":1000,"currency":"THB"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.True(t, strings.HasPrefix(resp.Header.Get(fiber.HeaderLocation), "/payment-intents/"))
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogFormat(t *testing.T) {
+	newApp := func(config Config, output *bytes.Buffer) *fiber.App {
+		app := fiber.New()
+		app.Use(NewRequestIDMiddleware(), newAccessLogger(config, output))
+		app.Get("/payments", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+		app.Get("/broken", func(c *fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusBadRequest, `bad "input"`+"\n")
+		})
+		return app
+	}
+
+	t.Run("JSON Lines Parse", func(t *testing.T) {
+		var output bytes.Buffer
+		app := newApp(Config{LogFormat: LogFormatJSON}, &output)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/payments", nil))
+		assert.NoError(t, err)
+		_, err = app.Test(httptest.NewRequest(http.MethodGet, "/broken", nil))
+		assert.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		if !assert.Len(t, lines, 2) {
+			return
+		}
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry), lines[0])
+		assert.Equal(t, http.MethodGet, entry["method"])
+		assert.Equal(t, "/payments", entry["path"])
+		assert.Equal(t, float64(http.StatusOK), entry["status"])
+		assert.Equal(t, resp.Header.Get(fiber.HeaderXRequestID), entry["request_id"])
+		assert.IsType(t, float64(0), entry["latency_ms"])
+		assert.Equal(t, "", entry["error"])
+
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &entry), "client-controlled values are quoted: %s", lines[1])
+		assert.Equal(t, float64(http.StatusBadRequest), entry["status"])
+		assert.Equal(t, `bad "input"`+"\n", entry["error"])
+	})
+
+	t.Run("Text Is The Default", func(t *testing.T) {
+		var output bytes.Buffer
+		app := newApp(Config{}, &output)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/payments", nil))
+		assert.NoError(t, err)
+		assert.Contains(t, output.String(), "| 200 |")
+		assert.Contains(t, output.String(), "| GET | /payments | "+resp.Header.Get(fiber.HeaderXRequestID)+" |")
+	})
+
+	t.Run("Info Reports The Format", func(t *testing.T) {
+		for _, format := range []string{"", LogFormatJSON} {
+			app := fiber.New()
+			(&APIRouter{}).SetupRoutes(app, Config{LogFormat: format})
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/info", nil))
+			assert.NoError(t, err)
+			var info map[string]any
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+			assert.Equal(t, Config{LogFormat: format}.logFormat(), info["log_format"])
+		}
+	})
+
+	t.Run("Config Validation", func(t *testing.T) {
+		assert.NoError(t, Config{LogFormat: LogFormatJSON}.validateLogFormat())
+		assert.ErrorContains(t, Config{LogFormat: "xml"}.Validate(), "LOG_FORMAT")
+	})
+}
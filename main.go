@@ -1,22 +1,438 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 )
 
 // Config represents the application configuration settings.
 type Config struct {
-	Env      string
-	Endpoint string
-	Port     string
+	Env            string
+	Endpoint       string
+	Port           string
+	EncryptionKeys string
+	// WebhookSigningSecret is the HMAC key outbound webhooks are signed with.
+	WebhookSigningSecret string
+	// GatewayWebhookSecret, when set, requires inbound gateway webhooks to carry a valid X-Webhook-Signature.
+	GatewayWebhookSecret string
+	// NotificationURLAllowedHosts lists the hosts a payment's notification_url may point at, comma-separated;
+	// "*.example.com" allows subdomains. Empty rejects every notification_url.
+	NotificationURLAllowedHosts string
+	// ReturnURLAllowedHosts lists the hosts a payment's return_urls may point at, in the same form as
+	// NotificationURLAllowedHosts. Empty rejects every return URL.
+	ReturnURLAllowedHosts string
+	// OutboundAllowCIDRs and OutboundDenyCIDRs are comma-separated CIDRs that adjust which addresses merchant
+	// webhooks and notification URLs may reach: internal addresses are blocked unless allowed, and denied
+	// ranges are blocked even when public.
+	OutboundAllowCIDRs string
+	OutboundDenyCIDRs  string
+	// WebhookMaxBodyBytes caps inbound gateway webhook bodies; larger ones get 413 before they are verified.
+	WebhookMaxBodyBytes int
+	// ReferenceNumberPrefix starts every generated payment reference number, e.g. "PAY"; up to 6 of A-Z and 0-9.
+	ReferenceNumberPrefix string
+	// ClockSkewLeeway widens webhook timestamp checks to absorb clock differences with senders; at most 2m.
+	ClockSkewLeeway time.Duration
+	// BasePath prefixes the URLs returned to clients, e.g. in Location headers, when the service is mounted
+	// below a path or host of a reverse proxy; it may be a path ("/api") or an absolute URL.
+	BasePath string
+	// StartupSelfTest enables the gateway credential check at startup; leave it off for offline/dev runs.
+	StartupSelfTest bool
+	// StrictStartupChecks aborts startup when a startup check fails instead of only logging it.
+	StrictStartupChecks bool
+	// MaxConcurrentRequests caps in-flight requests across the service; 0 disables the limit.
+	MaxConcurrentRequests int
+	// MaxConnections caps simultaneously open TCP connections, including idle keep-alive ones; 0 disables it.
+	MaxConnections int
+	// TLSCertFile and TLSKeyFile are the PEM certificate and key to serve HTTPS with; empty serves plain HTTP.
+	// TLSMinVersion is the oldest protocol accepted, "1.2" (the default) or "1.3", and TLSCipherSuites limits
+	// TLS 1.2 to the listed IANA cipher suite names.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSMinVersion   string
+	TLSCipherSuites string
+	// Timezone is the IANA business timezone for report day boundaries and date-only query parameters.
+	// Timestamps are always stored in UTC.
+	Timezone string
+	// PlatformFeeBasisPoints is the platform fee charged on captures, in hundredths of a percent.
+	PlatformFeeBasisPoints int
+	// SlowGatewayThreshold is the gateway call duration above which a warning is logged; 0 disables it.
+	SlowGatewayThreshold time.Duration
+	// HealthScoreWindow is how far back GET /admin/health-score looks at dependency calls; 0 uses 5m.
+	// HealthScoreWeights weighs its latency, errors and circuit_breakers factors, for example
+	// "latency=30,errors=50,circuit_breakers=20" (the default).
+	HealthScoreWindow  time.Duration
+	HealthScoreWeights string
+	// PaymentMethods lists the payment methods whose gateway availability is tracked separately, so one method
+	// being down does not fail the others.
+	PaymentMethods string
+	// GatewayEndpointWeights splits gateway traffic over several connections as "name=weight" pairs, e.g.
+	// "primary=3,secondary=1"; empty uses a single connection.
+	GatewayEndpointWeights string
+	// GatewayCurrencyRoutes sends each currency's payments to a named gateway as "currency=gateway" pairs, e.g.
+	// "THB=kbank,USD=stripe"; unlisted currencies use the default gateway.
+	GatewayCurrencyRoutes string
+	// GatewayMaintenanceWindows schedules periods when a gateway, or one of its methods, is down, as
+	// "gateway=start/end" or "gateway/method=start/end" entries with RFC 3339 times. Calls in a window are
+	// rejected with 503 and the window's end instead of being sent to the processor.
+	GatewayMaintenanceWindows string
+	// GatewayFailover shifts traffic from the primary gateway connection to a secondary as the primary's breaker
+	// opens or its latency passes FailoverLatencyThreshold (0 ignores latency), returning it gradually over
+	// FailbackRampUp once the primary recovers.
+	GatewayFailover          bool
+	FailoverLatencyThreshold time.Duration
+	FailbackRampUp           time.Duration
+	// SlowQueryThreshold is the repository query duration above which a warning is logged; 0 disables it.
+	SlowQueryThreshold time.Duration
+	// PaymentCacheTTL, when above zero, caches payment reads for that long; every write through this instance
+	// invalidates the payment's entry.
+	PaymentCacheTTL time.Duration
+	// DBConnectRetryBudget is how long startup keeps retrying the database connection, with exponential backoff,
+	// before giving up; 0 tries once.
+	DBConnectRetryBudget time.Duration
+	// ExpectedSchemaVersion, when above zero, makes startup wait up to SchemaWaitTimeout for the store's
+	// migrations to reach that version; StrictStartupChecks decides whether a schema still behind aborts startup.
+	// Both checks apply only to a PaymentDatabase; the in-memory store skips them.
+	ExpectedSchemaVersion int
+	SchemaWaitTimeout     time.Duration
+	// IdempotencyPersistFile, when set, saves in-memory idempotency keys there on shutdown and reloads them
+	// on startup. Intended for single-instance dev/test runs only.
+	IdempotencyPersistFile string
+	// DedupWindow, when above zero, replays the response to an identical POST /payments body from the same API
+	// key sent within the window without an Idempotency-Key.
+	DedupWindow time.Duration
+	// PaymentOperationLockWait is how long a capture or refund waits for another capture, void or refund of the
+	// same payment to finish before answering 409; 0 uses 5s.
+	PaymentOperationLockWait time.Duration
+	// MetadataMaxKeys, MetadataMaxKeyLength and MetadataMaxSize bound the metadata accepted on payments and
+	// customers; 0 uses 50 keys, 40-character keys and 8 KiB of serialized JSON.
+	MetadataMaxKeys      int
+	MetadataMaxKeyLength int
+	MetadataMaxSize      int
+	// DescriptorTemplate builds the statement descriptor from payment metadata, e.g. "ORDER {order_id}".
+	DescriptorTemplate string
+	// DescriptorTemplateStrict rejects payments whose metadata lacks a variable the template references.
+	DescriptorTemplateStrict bool
+	// DescriptorNonASCII is "transliterate" (default) or "reject": how non-ASCII descriptor text such as Thai
+	// is handled for gateways that only display ASCII. UTF-8 gateways get it unchanged.
+	DescriptorNonASCII string
+	// AmountInputMode is how create requests express amounts: "minor_units" (default) or "decimal_string".
+	// AmountInputModes overrides it per currency as "CURRENCY=mode" pairs, e.g. "THB=decimal_string".
+	AmountInputMode  AmountInputMode
+	AmountInputModes string
+	// AmountSerialization is how responses write amounts: "integer" (default) or "string", e.g. "1050", for
+	// clients that lose precision on large numbers. Clients override it with "Accept: application/json; amounts=string".
+	AmountSerialization AmountSerialization
+	// RequireIdempotencyKey rejects POST /payments requests that do not carry an Idempotency-Key header.
+	RequireIdempotencyKey bool
+	// LogIdempotencyKeys logs which payment each client Idempotency-Key created, for support lookups.
+	LogIdempotencyKeys bool
+	// IdempotencyKeyMaxLength and IdempotencyKeyPattern constrain accepted Idempotency-Key values; by default
+	// keys are printable ASCII of at most 255 characters.
+	IdempotencyKeyMaxLength int
+	IdempotencyKeyPattern   string
+	// MiddlewareRecovery, MiddlewareRequestID, MiddlewareTracing, MiddlewareLogger, MiddlewareMetrics and
+	// MiddlewareCORS toggle the server-wide middleware; see middlewareChain for the order they run in.
+	MiddlewareRecovery  bool
+	MiddlewareRequestID bool
+	MiddlewareTracing   bool
+	MiddlewareLogger    bool
+	MiddlewareMetrics   bool
+	MiddlewareCORS      bool
+	// MiddlewareAPIKeyDisabled turns API key authentication off (MIDDLEWARE_API_KEY=false), so that every
+	// request is anonymous live traffic. It is the one toggle that is on in a zero Config, and it cannot be
+	// turned off in production.
+	MiddlewareAPIKeyDisabled bool
+	// LogRedaction masks bearer tokens, JWTs and API keys in everything the service logs.
+	LogRedaction bool
+	// LogFormat is the access log format: "text" (default) or "json", one JSON object per request.
+	LogFormat string
+	// JSONCodec selects the JSON library for request and response bodies: "encoding/json" (default) or "go-json".
+	JSONCodec string
+	// CORSAllowOrigins is the comma-separated list of origins allowed when CORS is enabled.
+	CORSAllowOrigins string
+	// RequestTimeout bounds every request; 0 disables it. RouteTimeouts overrides it per route as
+	// "METHOD /path=duration" pairs, and MaxRequestTimeout caps both.
+	RequestTimeout    time.Duration
+	RouteTimeouts     string
+	MaxRequestTimeout time.Duration
+	// RateLimit is how many requests each client may make per RateLimitWindow; 0 disables rate limiting.
+	// MerchantRateLimits overrides it per merchant, named by the merchant its API key belongs to, as
+	// "merchant=limit" pairs.
+	RateLimit          int
+	RateLimitWindow    time.Duration
+	MerchantRateLimits string
+	// RetryAfterJitter is the maximum random delay added to Retry-After on 429 and 503 responses.
+	RetryAfterJitter time.Duration
+	// PaymentPollInterval is how often pending QR/bank-transfer payments are checked at the gateway; 0 disables it.
+	PaymentPollInterval time.Duration
+	// OutboxRelayInterval is how often the relay publishes up to OutboxBatchSize pending events; 0 disables
+	// it. OutboxFlushLimit caps the events published by one POST /admin/outbox/flush.
+	OutboxRelayInterval time.Duration
+	OutboxBatchSize     int
+	OutboxFlushLimit    int
+	// DailyMetricsInterval is how often yesterday's and today's metrics_daily snapshots are recomputed;
+	// 0 disables it.
+	DailyMetricsInterval time.Duration
+	// AuthorizationExpiryInterval is how often authorizations past their expected expiry are voided; 0 disables
+	// it. AuthorizationValidity sets how long each gateway holds an authorization as "gateway=duration" pairs,
+	// falling back to DefaultAuthorizationValidity.
+	AuthorizationExpiryInterval  time.Duration
+	AuthorizationValidity        string
+	DefaultAuthorizationValidity time.Duration
+	// SettlementDelays sets how many business days captured funds take to arrive, keyed by "gateway", "method"
+	// or "gateway/method", falling back to SettlementDelayDays (2 when zero). SettlementHolidays lists the days besides
+	// weekends that do not count, as "MM-DD" every year or "YYYY-MM-DD" once; it defaults to the fixed-date
+	// Thai public holidays.
+	SettlementDelays    string
+	SettlementDelayDays int
+	SettlementHolidays  string
+	// WorkerDrainTimeout is how long each background worker may take to finish its current item on shutdown.
+	WorkerDrainTimeout time.Duration
+	// AsyncPaymentExpiry is how long a customer has to complete a QR/bank-transfer payment.
+	AsyncPaymentExpiry time.Duration
+	// PageSizeDefault and PageSizeMax bound ?limit= on every list endpoint; 0 uses 50 and 200.
+	PageSizeDefault int
+	PageSizeMax     int
+	// PageSizeRejectOverMax rejects a limit above PageSizeMax with 400 instead of clamping it.
+	PageSizeRejectOverMax bool
+	// RefundWindowDays rejects refunds on payments captured longer ago than this; 0 allows refunds at any time.
+	RefundWindowDays int
+	// MaxRefundsPerPayment caps how many refunds one payment may have; 0 means no limit.
+	MaxRefundsPerPayment int
+	// APIKeys lists partner API keys as "key=live" or "key=test" pairs; requests send one as a bearer token.
+	// Test keys route to the sandbox gateway and their payments are flagged as test data.
+	APIKeys string
+	// APIKeyRevocationGrace is how long a merchant API key keeps working after it is revoked, so the merchant
+	// can roll over to a new one; 0 revokes it immediately.
+	APIKeyRevocationGrace time.Duration
+	// CaptureMode is the capture mode of payments that do not request one: "manual" leaves them authorized for
+	// a capture call, "automatic" captures them right after authorization.
+	CaptureMode CaptureMode
+	// SimulatedLatency delays /payments requests for load testing, by a fixed duration such as "200ms" or a
+	// random one within a range such as "100ms-2s". It is refused in production.
+	SimulatedLatency string
+	// RiskReviewAmount holds payments of at least this many minor units for manual review before they are
+	// authorized; 0 disables the review queue.
+	RiskReviewAmount int64
+	// AdminToken authorizes admin-only overrides sent with the X-Admin-Token header; empty disables them.
+	AdminToken string
+	// BINTableFile is a CSV of BIN ranges with issuing countries, loaded once at startup on top of the
+	// built-in card brand ranges.
+	BINTableFile string
+	// GRPCPort and MetricsPort are the ports of listeners run alongside HTTP; empty means not separate.
+	GRPCPort    string
+	MetricsPort string
+	// MetricsExporters lists where metrics go, "prometheus" (scraped from /metrics) and/or "otlp" (pushed to
+	// OTLPMetricsEndpoint every OTLPMetricsInterval); empty means Prometheus only.
+	MetricsExporters    string
+	OTLPMetricsEndpoint string
+	OTLPMetricsInterval time.Duration
+}
+
+// Validate checks the configuration for values that would make the service misbehave at runtime.
+func (c Config) Validate() error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid TIMEZONE %q: %w", c.Timezone, err)
+	}
+	if c.PlatformFeeBasisPoints < 0 || c.PlatformFeeBasisPoints > 10000 {
+		return fmt.Errorf("PLATFORM_FEE_BPS %d must be between 0 and 10000", c.PlatformFeeBasisPoints)
+	}
+	if c.ClockSkewLeeway < 0 || c.ClockSkewLeeway > maxClockSkewLeeway {
+		return fmt.Errorf("CLOCK_SKEW_LEEWAY %s must be between 0 and %s", c.ClockSkewLeeway, maxClockSkewLeeway)
+	}
+	if _, _, err := jsonCodec(c.JSONCodec); err != nil {
+		return err
+	}
+	if err := c.validateLogFormat(); err != nil {
+		return err
+	}
+	if _, err := parseSettlementDelays(c.SettlementDelays); err != nil {
+		return err
+	}
+	if c.SettlementDelayDays < 0 {
+		return fmt.Errorf("SETTLEMENT_DELAY_DAYS %d must not be negative", c.SettlementDelayDays)
+	}
+	if _, err := parseBusinessCalendar(c.SettlementHolidays); err != nil {
+		return err
+	}
+	if _, err := parseAuthorizationValidity(c.AuthorizationValidity); err != nil {
+		return err
+	}
+	if _, err := parseMerchantRateLimits(c.MerchantRateLimits); err != nil {
+		return err
+	}
+	if _, err := parseCurrencyRoutes(c.GatewayCurrencyRoutes); err != nil {
+		return err
+	}
+	if _, err := parseMaintenanceWindows(c.GatewayMaintenanceWindows); err != nil {
+		return err
+	}
+	if err := c.validateTLS(); err != nil {
+		return err
+	}
+	if _, err := parseGatewayEndpointWeights(c.GatewayEndpointWeights); err != nil {
+		return err
+	}
+	if c.AmountInputMode != "" && !validAmountInputMode(c.AmountInputMode) {
+		return fmt.Errorf("invalid AMOUNT_INPUT_MODE %q: want minor_units or decimal_string", c.AmountInputMode)
+	}
+	if _, err := parseAmountInputModes(c.AmountInputModes); err != nil {
+		return err
+	}
+	if c.AmountSerialization != "" && !validAmountSerialization(c.AmountSerialization) {
+		return fmt.Errorf("invalid AMOUNT_SERIALIZATION %q: want integer or string", c.AmountSerialization)
+	}
+	if c.CaptureMode != "" && !validCaptureMode(c.CaptureMode) {
+		return fmt.Errorf("invalid CAPTURE_MODE %q: want automatic or manual", c.CaptureMode)
+	}
+	if _, err := parseSimulatedLatency(c.SimulatedLatency); err != nil {
+		return err
+	}
+	if c.SimulatedLatency != "" && c.IsProduction() {
+		return fmt.Errorf("SIMULATED_LATENCY is for load testing and cannot be set in production")
+	}
+	if c.MiddlewareAPIKeyDisabled && c.IsProduction() {
+		return fmt.Errorf("MIDDLEWARE_API_KEY cannot be turned off in production")
+	}
+	if c.IdempotencyPersistFile != "" && c.IsProduction() {
+		return fmt.Errorf("IDEMPOTENCY_PERSIST_FILE is for single-instance dev/test runs and cannot be set in production")
+	}
+	if _, err := parseOutboundPolicy(c.OutboundAllowCIDRs, c.OutboundDenyCIDRs); err != nil {
+		return err
+	}
+	if c.DescriptorNonASCII != "" && c.DescriptorNonASCII != DescriptorTransliterate && c.DescriptorNonASCII != DescriptorReject {
+		return fmt.Errorf("invalid DESCRIPTOR_NON_ASCII %q: want transliterate or reject", c.DescriptorNonASCII)
+	}
+	if c.PaymentCacheTTL > maxPaymentCacheTTL {
+		return fmt.Errorf("PAYMENT_CACHE_TTL %s must be at most %s so that status changes made elsewhere show up promptly", c.PaymentCacheTTL, maxPaymentCacheTTL)
+	}
+	if c.HealthScoreWindow < 0 {
+		return fmt.Errorf("HEALTH_SCORE_WINDOW %s must not be negative", c.HealthScoreWindow)
+	}
+	if _, err := parseHealthScoreWeights(c.HealthScoreWeights); err != nil {
+		return err
+	}
+	if c.PaymentOperationLockWait < 0 {
+		return fmt.Errorf("PAYMENT_OPERATION_LOCK_WAIT %s must not be negative", c.PaymentOperationLockWait)
+	}
+	if c.APIKeyRevocationGrace < 0 {
+		return fmt.Errorf("API_KEY_REVOCATION_GRACE %s must not be negative", c.APIKeyRevocationGrace)
+	}
+	if c.RiskReviewAmount < 0 {
+		return fmt.Errorf("RISK_REVIEW_AMOUNT %d must not be negative", c.RiskReviewAmount)
+	}
+	if _, err := parseMetricsExporters(c.MetricsExporters); err != nil {
+		return err
+	}
+	if c.metricsExporterEnabled(MetricsExporterOTLP) && c.OTLPMetricsEndpoint == "" {
+		return fmt.Errorf("METRICS_EXPORTERS includes otlp but OTEL_EXPORTER_OTLP_METRICS_ENDPOINT is not set")
+	}
+	if _, err := parseAPIKeys(c.APIKeys); err != nil {
+		return err
+	}
+	if !referenceNumberPrefixPattern.MatchString(c.ReferenceNumberPrefix) {
+		return fmt.Errorf("invalid REFERENCE_NUMBER_PREFIX %q: want up to 6 characters of A-Z and 0-9", c.ReferenceNumberPrefix)
+	}
+	if _, err := newIdempotencyKeyPolicy(c); err != nil {
+		return err
+	}
+	if err := validateRequestTimeouts(c.RequestTimeout, c.RouteTimeouts, c.maxRequestTimeout()); err != nil {
+		return err
+	}
+	return validatePorts([]namedPort{
+		{name: "PORT", value: c.Port},
+		{name: "GRPC_PORT", value: c.GRPCPort},
+		{name: "METRICS_PORT", value: c.MetricsPort},
+	})
+}
+
+// rateLimited reports whether any client is rate limited, by default or by merchant.
+func (c Config) rateLimited() bool {
+	return c.RateLimit > 0 || c.MerchantRateLimits != ""
+}
+
+// maxRequestTimeout returns MaxRequestTimeout, defaulting to 5 minutes when unset.
+func (c Config) maxRequestTimeout() time.Duration {
+	if c.MaxRequestTimeout > 0 {
+		return c.MaxRequestTimeout
+	}
+	return defaultMaxRequestTimeout
+}
+
+type namedPort struct {
+	name  string
+	value string
+}
+
+// validatePorts checks that every configured port is a valid TCP port and that no two listeners share one,
+// so a misconfiguration fails at startup instead of as an obscure bind error.
+func validatePorts(ports []namedPort) error {
+	used := make(map[int]string)
+	for _, port := range ports {
+		if port.value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(port.value)
+		if err != nil || number < 1 || number > 65535 {
+			return fmt.Errorf("invalid %s %q: must be a number between 1 and 65535", port.name, port.value)
+		}
+		if other, ok := used[number]; ok {
+			return fmt.Errorf("%s and %s are both set to %d; each listener needs its own port", other, port.name, number)
+		}
+		used[number] = port.name
+	}
+	return nil
+}
+
+// Location returns the configured business timezone, falling back to UTC when it is unset or invalid.
+func (c Config) Location() *time.Location {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsProduction reports whether the service runs in the production environment, where sandbox tooling is off.
+func (c Config) IsProduction() bool {
+	return c.Env == "production"
+}
+
+// EnabledFeatures reports which optional subsystems the configuration turns on, for display in /info.
+// It only exposes on/off flags, never the underlying values such as keys or file paths.
+func (c Config) EnabledFeatures() map[string]bool {
+	return map[string]bool{
+		"field_encryption":        c.EncryptionKeys != "",
+		"startup_self_test":       c.StartupSelfTest,
+		"strict_startup_checks":   c.StrictStartupChecks,
+		"load_shedding":           c.MaxConcurrentRequests > 0,
+		"rate_limiting":           c.rateLimited(),
+		"simulated_latency":       c.SimulatedLatency != "",
+		"review_queue":            c.RiskReviewAmount > 0,
+		"tracing":                 c.MiddlewareTracing,
+		"platform_fee":            c.PlatformFeeBasisPoints > 0,
+		"slow_gateway_warnings":   c.SlowGatewayThreshold > 0,
+		"slow_query_log":          c.SlowQueryThreshold > 0,
+		"payment_cache":           c.PaymentCacheTTL > 0,
+		"idempotency_persistence": c.IdempotencyPersistFile != "",
+		"request_deduplication":   c.DedupWindow > 0,
+		"api_keys":                c.APIKeys != "",
+		"gateway_failover":        c.GatewayFailover,
+		"log_redaction":           c.LogRedaction,
+		"otlp_metrics":            c.metricsExporterEnabled(MetricsExporterOTLP),
+		"maintenance_windows":     c.GatewayMaintenanceWindows != "",
+		"tls":                     c.tlsEnabled(),
+	}
 }
 
 // Env is a type used for loading and managing environment-specific configuration settings.
@@ -27,11 +443,240 @@ func (l *Env) Load() Config {
 	env := getEnvOr("APP_ENV", "development")
 	endpoint := getEnvOr("ENDPOINT", "http://0.0.0.0")
 	port := getEnvOr("PORT", "8080")
+	encryptionKeys := getEnvOr("ENCRYPTION_KEYS", "")
+	startupSelfTest := getEnvBoolOr("STARTUP_SELF_TEST", false)
+	strictStartupChecks := getEnvBoolOr("STRICT_STARTUP_CHECKS", false)
+	maxConcurrentRequests := getEnvIntOr("MAX_CONCURRENT_REQUESTS", 0)
+	maxConnections := getEnvIntOr("MAX_CONNECTIONS", 0)
+	tlsCertFile := getEnvOr("TLS_CERT_FILE", "")
+	tlsKeyFile := getEnvOr("TLS_KEY_FILE", "")
+	tlsMinVersion := getEnvOr("TLS_MIN_VERSION", defaultTLSMinVersion)
+	tlsCipherSuites := getEnvOr("TLS_CIPHER_SUITES", "")
+	timezone := getEnvOr("TIMEZONE", "UTC")
+	platformFeeBasisPoints := getEnvIntOr("PLATFORM_FEE_BPS", 0)
+	slowGatewayThreshold := getEnvDurationOr("SLOW_GATEWAY_THRESHOLD", 2*time.Second)
+	healthScoreWindow := getEnvDurationOr("HEALTH_SCORE_WINDOW", defaultHealthScoreWindow)
+	healthScoreWeights := getEnvOr("HEALTH_SCORE_WEIGHTS", "")
+	paymentMethods := getEnvOr("PAYMENT_METHODS", defaultPaymentMethods)
+	gatewayEndpointWeights := getEnvOr("GATEWAY_ENDPOINT_WEIGHTS", "")
+	gatewayCurrencyRoutes := getEnvOr("GATEWAY_CURRENCY_ROUTES", "")
+	gatewayMaintenanceWindows := getEnvOr("GATEWAY_MAINTENANCE_WINDOWS", "")
+	gatewayFailover := getEnvBoolOr("GATEWAY_FAILOVER", false)
+	failoverLatencyThreshold := getEnvDurationOr("GATEWAY_FAILOVER_LATENCY_THRESHOLD", 0)
+	failbackRampUp := getEnvDurationOr("GATEWAY_FAILBACK_RAMP_UP", defaultFailbackRampUp)
+	slowQueryThreshold := getEnvDurationOr("SLOW_QUERY_THRESHOLD", 200*time.Millisecond)
+	paymentCacheTTL := getEnvDurationOr("PAYMENT_CACHE_TTL", 0)
+	dbConnectRetryBudget := getEnvDurationOr("DB_CONNECT_RETRY_BUDGET", 30*time.Second)
+	expectedSchemaVersion := getEnvIntOr("EXPECTED_SCHEMA_VERSION", 0)
+	schemaWaitTimeout := getEnvDurationOr("SCHEMA_WAIT_TIMEOUT", time.Minute)
+	idempotencyPersistFile := getEnvOr("IDEMPOTENCY_PERSIST_FILE", "")
+	dedupWindow := getEnvDurationOr("DEDUP_WINDOW", 0)
+	paymentOperationLockWait := getEnvDurationOr("PAYMENT_OPERATION_LOCK_WAIT", defaultPaymentOperationLockWait)
+	metadataMaxKeys := getEnvIntOr("METADATA_MAX_KEYS", defaultMetadataMaxKeys)
+	metadataMaxKeyLength := getEnvIntOr("METADATA_MAX_KEY_LENGTH", defaultMetadataMaxKeyLength)
+	metadataMaxSize := getEnvIntOr("METADATA_MAX_SIZE", defaultMetadataMaxSize)
+	descriptorTemplate := getEnvOr("DESCRIPTOR_TEMPLATE", "")
+	descriptorTemplateStrict := getEnvBoolOr("DESCRIPTOR_TEMPLATE_STRICT", false)
+	amountInputMode := getEnvOr("AMOUNT_INPUT_MODE", string(AmountInputMinorUnits))
+	amountInputModes := getEnvOr("AMOUNT_INPUT_MODES", "")
+	amountSerialization := getEnvOr("AMOUNT_SERIALIZATION", string(AmountsAsIntegers))
+	requireIdempotencyKey := getEnvBoolOr("REQUIRE_IDEMPOTENCY_KEY", false)
+	logIdempotencyKeys := getEnvBoolOr("LOG_IDEMPOTENCY_KEYS", false)
+	idempotencyKeyMaxLength := getEnvIntOr("IDEMPOTENCY_KEY_MAX_LENGTH", defaultIdempotencyKeyMaxLength)
+	idempotencyKeyPattern := getEnvOr("IDEMPOTENCY_KEY_PATTERN", "")
+	requestTimeout := getEnvDurationOr("REQUEST_TIMEOUT", 30*time.Second)
+	routeTimeouts := getEnvOr("ROUTE_TIMEOUTS", "GET /reports/settlement=2m,POST /admin/reconciliation/import=2m")
+	maxRequestTimeout := getEnvDurationOr("MAX_REQUEST_TIMEOUT", defaultMaxRequestTimeout)
+	rateLimit := getEnvIntOr("RATE_LIMIT", 0)
+	rateLimitWindow := getEnvDurationOr("RATE_LIMIT_WINDOW", defaultRateLimitWindow)
+	merchantRateLimits := getEnvOr("MERCHANT_RATE_LIMITS", "")
+	retryAfterJitter := getEnvDurationOr("RETRY_AFTER_JITTER", 0)
+	middlewareRecovery := getEnvBoolOr("MIDDLEWARE_RECOVERY", true)
+	middlewareRequestID := getEnvBoolOr("MIDDLEWARE_REQUEST_ID", true)
+	middlewareTracing := getEnvBoolOr("MIDDLEWARE_TRACING", false)
+	middlewareLogger := getEnvBoolOr("MIDDLEWARE_LOGGER", true)
+	middlewareMetrics := getEnvBoolOr("MIDDLEWARE_METRICS", false)
+	middlewareAPIKey := getEnvBoolOr("MIDDLEWARE_API_KEY", true)
+	logRedaction := getEnvBoolOr("LOG_REDACTION", true)
+	logFormat := getEnvOr("LOG_FORMAT", LogFormatText)
+	middlewareCORS := getEnvBoolOr("MIDDLEWARE_CORS", false)
+	jsonCodecName := getEnvOr("JSON_CODEC", JSONCodecStandard)
+	corsAllowOrigins := getEnvOr("CORS_ALLOW_ORIGINS", "*")
+	binTableFile := getEnvOr("BIN_TABLE_FILE", "")
+	paymentPollInterval := getEnvDurationOr("PAYMENT_POLL_INTERVAL", 15*time.Second)
+	outboxRelayInterval := getEnvDurationOr("OUTBOX_RELAY_INTERVAL", 5*time.Second)
+	dailyMetricsInterval := getEnvDurationOr("DAILY_METRICS_INTERVAL", time.Hour)
+	settlementDelays := getEnvOr("SETTLEMENT_DELAYS", "")
+	settlementDelayDays := getEnvIntOr("SETTLEMENT_DELAY_DAYS", defaultSettlementDelayDays)
+	settlementHolidays := getEnvOr("SETTLEMENT_HOLIDAYS", thaiPublicHolidays)
+	authorizationExpiryInterval := getEnvDurationOr("AUTHORIZATION_EXPIRY_INTERVAL", 10*time.Minute)
+	authorizationValidity := getEnvOr("AUTHORIZATION_VALIDITY", "")
+	defaultAuthorizationValidity := getEnvDurationOr("AUTHORIZATION_VALIDITY_DEFAULT", defaultAuthorizationValidity)
+	outboxBatchSize := getEnvIntOr("OUTBOX_BATCH_SIZE", defaultOutboxBatchSize)
+	outboxFlushLimit := getEnvIntOr("OUTBOX_FLUSH_LIMIT", defaultOutboxFlushLimit)
+	workerDrainTimeout := getEnvDurationOr("WORKER_DRAIN_TIMEOUT", 10*time.Second)
+	asyncPaymentExpiry := getEnvDurationOr("ASYNC_PAYMENT_EXPIRY", defaultAsyncPaymentExpiry)
+	pageSizeDefault := getEnvIntOr("PAGE_SIZE_DEFAULT", defaultPageSize)
+	pageSizeMax := getEnvIntOr("PAGE_SIZE_MAX", defaultMaxPageSize)
+	pageSizeRejectOverMax := getEnvBoolOr("PAGE_SIZE_REJECT_OVER_MAX", false)
+	refundWindowDays := getEnvIntOr("REFUND_WINDOW_DAYS", 0)
+	maxRefundsPerPayment := getEnvIntOr("MAX_REFUNDS_PER_PAYMENT", 0)
+	basePath := getEnvOr("BASE_PATH", "")
+	referenceNumberPrefix := getEnvOr("REFERENCE_NUMBER_PREFIX", "")
+	webhookSigningSecret := getEnvOr("WEBHOOK_SIGNING_SECRET", "")
+	gatewayWebhookSecret := getEnvOr("GATEWAY_WEBHOOK_SECRET", "")
+	webhookMaxBodyBytes := getEnvIntOr("WEBHOOK_MAX_BODY_BYTES", defaultWebhookMaxBodyBytes)
+	clockSkewLeeway := getEnvDurationOr("CLOCK_SKEW_LEEWAY", 30*time.Second)
+	adminToken := getEnvOr("ADMIN_TOKEN", "")
+	apiKeys := getEnvOr("API_KEYS", "")
+	apiKeyRevocationGrace := getEnvDurationOr("API_KEY_REVOCATION_GRACE", 24*time.Hour)
+	captureMode := getEnvOr("CAPTURE_MODE", string(CaptureManual))
+	simulatedLatency := getEnvOr("SIMULATED_LATENCY", "")
+	notificationURLAllowedHosts := getEnvOr("NOTIFICATION_URL_ALLOWED_HOSTS", "")
+	returnURLAllowedHosts := getEnvOr("RETURN_URL_ALLOWED_HOSTS", "")
+	outboundAllowCIDRs := getEnvOr("OUTBOUND_ALLOW_CIDRS", "")
+	outboundDenyCIDRs := getEnvOr("OUTBOUND_DENY_CIDRS", "")
+	descriptorNonASCII := getEnvOr("DESCRIPTOR_NON_ASCII", DescriptorTransliterate)
+	riskReviewAmount := getEnvIntOr("RISK_REVIEW_AMOUNT", 0)
+	grpcPort := getEnvOr("GRPC_PORT", "")
+	metricsPort := getEnvOr("METRICS_PORT", "")
+	metricsExporters := getEnvOr("METRICS_EXPORTERS", MetricsExporterPrometheus)
+	otlpMetricsEndpoint := getEnvOr("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+	otlpMetricsInterval := getEnvDurationOr("OTLP_METRICS_INTERVAL", time.Minute)
 
 	return Config{
-		Env:      env,
-		Endpoint: endpoint,
-		Port:     port,
+		Env:            env,
+		Endpoint:       endpoint,
+		Port:           port,
+		EncryptionKeys: encryptionKeys,
+
+		WebhookSigningSecret: webhookSigningSecret,
+		GatewayWebhookSecret: gatewayWebhookSecret,
+		WebhookMaxBodyBytes:  webhookMaxBodyBytes,
+		ClockSkewLeeway:      clockSkewLeeway,
+		BasePath:             basePath,
+
+		StartupSelfTest:     startupSelfTest,
+		StrictStartupChecks: strictStartupChecks,
+
+		ExpectedSchemaVersion: expectedSchemaVersion,
+		SchemaWaitTimeout:     schemaWaitTimeout,
+
+		MaxConcurrentRequests: maxConcurrentRequests,
+		MaxConnections:        maxConnections,
+		RateLimit:             rateLimit,
+		RateLimitWindow:       rateLimitWindow,
+		MerchantRateLimits:    merchantRateLimits,
+		RetryAfterJitter:      retryAfterJitter,
+
+		TLSCertFile:     tlsCertFile,
+		TLSKeyFile:      tlsKeyFile,
+		TLSMinVersion:   tlsMinVersion,
+		TLSCipherSuites: tlsCipherSuites,
+
+		RequestTimeout:    requestTimeout,
+		RouteTimeouts:     routeTimeouts,
+		MaxRequestTimeout: maxRequestTimeout,
+
+		MiddlewareRecovery:  middlewareRecovery,
+		MiddlewareRequestID: middlewareRequestID,
+		MiddlewareTracing:   middlewareTracing,
+		MiddlewareLogger:    middlewareLogger,
+		MiddlewareMetrics:   middlewareMetrics,
+		LogRedaction:        logRedaction,
+		LogFormat:           logFormat,
+		MiddlewareCORS:      middlewareCORS,
+		CORSAllowOrigins:    corsAllowOrigins,
+		JSONCodec:           jsonCodecName,
+		Timezone:            timezone,
+
+		MiddlewareAPIKeyDisabled: !middlewareAPIKey,
+
+		PlatformFeeBasisPoints: platformFeeBasisPoints,
+		SlowGatewayThreshold:   slowGatewayThreshold,
+		HealthScoreWindow:      healthScoreWindow,
+		HealthScoreWeights:     healthScoreWeights,
+		SlowQueryThreshold:     slowQueryThreshold,
+		GatewayEndpointWeights: gatewayEndpointWeights,
+		GatewayCurrencyRoutes:  gatewayCurrencyRoutes,
+
+		PaymentCacheTTL: paymentCacheTTL,
+
+		GatewayMaintenanceWindows: gatewayMaintenanceWindows,
+
+		GatewayFailover:          gatewayFailover,
+		FailoverLatencyThreshold: failoverLatencyThreshold,
+		FailbackRampUp:           failbackRampUp,
+
+		PaymentMethods:         paymentMethods,
+		IdempotencyPersistFile: idempotencyPersistFile,
+		DedupWindow:            dedupWindow,
+		DBConnectRetryBudget:   dbConnectRetryBudget,
+
+		PaymentOperationLockWait: paymentOperationLockWait,
+
+		DescriptorTemplate:       descriptorTemplate,
+		DescriptorTemplateStrict: descriptorTemplateStrict,
+		RequireIdempotencyKey:    requireIdempotencyKey,
+		IdempotencyKeyMaxLength:  idempotencyKeyMaxLength,
+		IdempotencyKeyPattern:    idempotencyKeyPattern,
+		BINTableFile:             binTableFile,
+		PaymentPollInterval:      paymentPollInterval,
+		WorkerDrainTimeout:       workerDrainTimeout,
+		AsyncPaymentExpiry:       asyncPaymentExpiry,
+		PageSizeDefault:          pageSizeDefault,
+		PageSizeMax:              pageSizeMax,
+		PageSizeRejectOverMax:    pageSizeRejectOverMax,
+		RefundWindowDays:         refundWindowDays,
+		MaxRefundsPerPayment:     maxRefundsPerPayment,
+		AdminToken:               adminToken,
+
+		MetadataMaxKeys:      metadataMaxKeys,
+		MetadataMaxKeyLength: metadataMaxKeyLength,
+		MetadataMaxSize:      metadataMaxSize,
+
+		AmountInputMode:  AmountInputMode(amountInputMode),
+		AmountInputModes: amountInputModes,
+
+		AmountSerialization: AmountSerialization(amountSerialization),
+
+		ReferenceNumberPrefix: referenceNumberPrefix,
+
+		OutboxRelayInterval: outboxRelayInterval,
+		OutboxBatchSize:     outboxBatchSize,
+		OutboxFlushLimit:    outboxFlushLimit,
+
+		DailyMetricsInterval: dailyMetricsInterval,
+
+		SettlementDelays:    settlementDelays,
+		SettlementDelayDays: settlementDelayDays,
+		SettlementHolidays:  settlementHolidays,
+
+		AuthorizationExpiryInterval:  authorizationExpiryInterval,
+		AuthorizationValidity:        authorizationValidity,
+		DefaultAuthorizationValidity: defaultAuthorizationValidity,
+
+		LogIdempotencyKeys: logIdempotencyKeys,
+		APIKeys:            apiKeys,
+
+		APIKeyRevocationGrace: apiKeyRevocationGrace,
+
+		CaptureMode:      CaptureMode(captureMode),
+		SimulatedLatency: simulatedLatency,
+
+		NotificationURLAllowedHosts: notificationURLAllowedHosts,
+		ReturnURLAllowedHosts:       returnURLAllowedHosts,
+		OutboundAllowCIDRs:          outboundAllowCIDRs,
+		OutboundDenyCIDRs:           outboundDenyCIDRs,
+		DescriptorNonASCII:          descriptorNonASCII,
+		RiskReviewAmount:            int64(riskReviewAmount),
+
+		GRPCPort:    grpcPort,
+		MetricsPort: metricsPort,
+
+		MetricsExporters:    metricsExporters,
+		OTLPMetricsEndpoint: otlpMetricsEndpoint,
+		OTLPMetricsInterval: otlpMetricsInterval,
 	}
 }
 
@@ -43,16 +688,221 @@ func getEnvOr(key, defaultValue string) string {
 	return value
 }
 
+func getEnvIntOr(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDurationOr(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBoolOr(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 // Router defines an interface for setting up application routes with a given Fiber app and configuration.
 type Router interface {
 	SetupRoutes(app *fiber.App, config Config)
 }
 
+// Authenticator is implemented by routers that authenticate requests. NewServer runs its middleware as the
+// server-wide api_key stage, so that rate limits apply to the authenticated merchant.
+type Authenticator interface {
+	Authenticate(config Config) fiber.Handler
+}
+
+// MetricsSource is implemented by routers that keep a metrics registry. NewServer records the server-wide
+// request metrics into it, so that they are served with the router's other metrics.
+type MetricsSource interface {
+	Metrics(config Config) *MetricsRegistry
+}
+
 // APIRouter is a struct used for setting up routes in a Fiber application.
-type APIRouter struct{}
+type APIRouter struct {
+	config      Config
+	store       PaymentStore
+	gateway     PaymentGateway
+	events      EventStore
+	refunds     RefundStore
+	disputes    DisputeStore
+	deliveries  DeliveryLog
+	storeCredit StoreCreditLedger
+	ledger      Ledger
+	webhooks    *WebhookRegistry
+	breakers    *CircuitBreakerRegistry
+	metrics     *MetricsRegistry
+	idempotency IdempotencyStore
+	dedup       IdempotencyStore
+	intents     PaymentIntentStore
+	increments  AuthorizationIncrementStore
+	bins        *BINTable
+	audit       AuditLog
+	email       EmailSender
+	customers   CustomerStore
+	references  *ReferenceNumberGenerator
+	testGateway PaymentGateway
+	outbox      OutboxStore
+	publisher   EventPublisher
+	relay       *OutboxRelay
+	sender      *WebhookSender
+
+	dailyMetrics DailyMetricsStore
+	risk         RiskScorer
+	merchantKeys MerchantAPIKeyStore
+	payouts      PayoutStore
+
+	operationLocks *PaymentOperationLocks
+	health         *DependencyHealth
+}
+
+// NewAPIRouter returns a router backed by store, such as a SQL-backed PaymentStore. The other dependencies get
+// their defaults when the routes are set up.
+func NewAPIRouter(store PaymentStore) *APIRouter {
+	return &APIRouter{store: store}
+}
+
+// ensureDependencies fills in default implementations for any dependency that was not injected.
+func (r *APIRouter) ensureDependencies(config Config) {
+	r.config = config
+	if r.store == nil {
+		r.store = NewMemoryPaymentStore()
+	}
+	if r.gateway == nil {
+		r.gateway = NewSandboxGateway("sandbox")
+	}
+	if r.outbox == nil {
+		r.outbox = NewMemoryOutbox()
+	}
+	if r.publisher == nil {
+		r.publisher = logEventPublisher{}
+	}
+	if r.risk == nil {
+		r.risk = AmountRiskScorer{ReviewAmount: config.RiskReviewAmount}
+	}
+	if r.dailyMetrics == nil {
+		r.dailyMetrics = NewMemoryDailyMetricsStore()
+	}
+	if r.testGateway == nil {
+		r.testGateway = NewSandboxGateway("sandbox")
+	}
+	if r.references == nil {
+		r.references = NewReferenceNumberGenerator(config.ReferenceNumberPrefix, config.Location())
+	}
+	if r.events == nil {
+		r.events = NewMemoryEventStore()
+	}
+	if r.refunds == nil {
+		r.refunds = NewMemoryRefundStore()
+	}
+	if r.disputes == nil {
+		r.disputes = NewMemoryDisputeStore()
+	}
+	if r.deliveries == nil {
+		r.deliveries = NewMemoryDeliveryLog()
+	}
+	if r.sender == nil {
+		r.sender = &WebhookSender{
+			Client: NewGuardedHTTPClient(r.outboundPolicy(), webhookDeliveryTimeout),
+			Secret: config.WebhookSigningSecret,
+		}
+	}
+	if r.webhooks == nil {
+		r.webhooks = NewWebhookRegistry(&HTTPWebhookChallenger{Client: NewGuardedHTTPClient(r.outboundPolicy(), webhookChallengeTimeout)})
+	}
+	if r.relay == nil {
+		r.relay = NewOutboxRelay(r.outbox, NewNotificationPublisher(r.publisher, r.store, r.deliveries, r.sender, r.webhooks))
+	}
+	if r.storeCredit == nil {
+		r.storeCredit = NewMemoryStoreCreditLedger()
+	}
+	if r.ledger == nil {
+		r.ledger = NewMemoryLedger()
+	}
+	if r.payouts == nil {
+		r.payouts = NewMemoryPayoutStore()
+	}
+	if r.operationLocks == nil {
+		r.operationLocks = NewPaymentOperationLocks()
+	}
+	if r.health == nil {
+		r.health = NewDependencyHealth(config.healthScoreWindow())
+	}
+	if r.breakers == nil {
+		r.breakers = NewCircuitBreakerRegistry()
+	}
+	if r.metrics == nil {
+		r.metrics = NewMetricsRegistry()
+	}
+	if r.idempotency == nil {
+		r.idempotency = NewMemoryIdempotencyStore()
+	}
+	if r.dedup == nil {
+		r.dedup = NewMemoryIdempotencyStore()
+	}
+	if r.intents == nil {
+		r.intents = NewMemoryPaymentIntentStore()
+	}
+	if r.increments == nil {
+		r.increments = NewMemoryAuthorizationIncrementStore()
+	}
+	if r.bins == nil {
+		r.bins = DefaultBINTable()
+	}
+	if r.audit == nil {
+		r.audit = NewMemoryAuditLog()
+	}
+	if r.email == nil {
+		r.email = LogEmailSender{}
+	}
+	if r.customers == nil {
+		r.customers = NewMemoryCustomerStore()
+	}
+	if r.merchantKeys == nil {
+		r.merchantKeys = NewMemoryMerchantAPIKeyStore()
+	}
+}
+
+// Authenticate returns the resolver that identifies a request's API key ahead of rate limiting.
+func (r *APIRouter) Authenticate(config Config) fiber.Handler {
+	r.ensureDependencies(config)
+	// Validate has already rejected malformed API keys.
+	apiKeys, _ := parseAPIKeys(config.APIKeys)
+	return NewAPIKeyResolver(apiKeys, r.merchantKeys)
+}
+
+// Metrics returns the registry the router records its metrics in.
+func (r *APIRouter) Metrics(config Config) *MetricsRegistry {
+	r.ensureDependencies(config)
+	return r.metrics
+}
 
 // SetupRoutes registers routes for the application, including root, info, and health endpoints, using the provided configuration.
 func (r *APIRouter) SetupRoutes(app *fiber.App, config Config) {
+	r.ensureDependencies(config)
+
+	// Validate has already rejected an invalid key pattern.
+	keyPolicy, _ := newIdempotencyKeyPolicy(config)
+	if !config.MiddlewareAPIKeyDisabled {
+		// Validate has already rejected malformed API keys.
+		apiKeys, _ := parseAPIKeys(config.APIKeys)
+		app.Use(NewAPIKeyMiddleware(apiKeys, r.merchantKeys))
+	}
+	// Amounts are rewritten outside idempotency so stored responses keep integers and replays follow the client.
+	app.Use(NewAmountSerializationMiddleware(config.AmountSerialization))
+	app.Use(NewIdempotencyMiddleware(r.idempotency, keyPolicy, r.metrics))
+
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Hello Payment!")
 	})
@@ -62,12 +912,63 @@ func (r *APIRouter) SetupRoutes(app *fiber.App, config Config) {
 			"env":      config.Env,
 			"port":     config.Port,
 			"endpoint": fmt.Sprintf("%s:%s", config.Endpoint, config.Port),
+
+			"log_format":       config.logFormat(),
+			"enabled_features": config.EnabledFeatures(),
 		})
 	})
 
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.SendString("OK")
-	})
+	app.Get("/health", getHealth)
+	app.Get("/ready", r.getReady)
+
+	if config.metricsExporterEnabled(MetricsExporterPrometheus) {
+		app.Get("/metrics", r.getMetrics)
+	}
+	app.Get("/errors", listErrorCodes)
+
+	app.Get("/payments", r.listPayments)
+	app.Get("/payments/:id", r.getPayment)
+	app.Patch("/payments/:id", r.patchPayment)
+	app.Post("/payments", NewDedupMiddleware(r.dedup, config.DedupWindow), r.createPayment)
+	app.Get("/payments/:id/timeline", r.getPaymentTimeline)
+	app.Get("/payments/:id/payouts", r.listPaymentPayouts)
+	app.Get("/payments/:id/return", r.returnToMerchant)
+	operationLock := NewPaymentOperationLock(r.operationLocks, config.paymentOperationLockWait())
+	app.Post("/payments/:id/refunds", operationLock, r.createRefund)
+	app.Post("/payments/:id/capture", operationLock, r.capturePaymentHandler)
+	app.Post("/payments/:id/incremental-auth", r.incrementAuthorization)
+	app.Post("/payments/:id/receipt/send", r.sendReceipt)
+
+	app.Post("/payment-intents", r.createPaymentIntent)
+	app.Post("/payment-intents/:id/cancel", operationLock, r.cancelPaymentIntent)
+
+	app.Post("/merchants/:id/webhooks", r.registerWebhook)
+	app.Get("/merchants/:id/customers", r.listCustomers)
+	app.Post("/merchants/:id/customers", r.createCustomer)
+	app.Get("/merchants/:id/api-keys", r.listMerchantAPIKeys)
+	app.Post("/merchants/:id/api-keys", r.createMerchantAPIKey)
+	app.Delete("/merchants/:id/api-keys/:key_id", r.revokeMerchantAPIKey)
+	app.Post("/webhooks/gateways/:gateway", NewBodyLimit(r.webhookMaxBodyBytes()), r.handleGatewayWebhook)
+	if !config.IsProduction() {
+		app.Post("/sandbox/webhooks/sign", r.signWebhookSample)
+	}
+
+	app.Get("/reports/settlement", r.getSettlementReport)
+
+	// Every /admin route requires the admin token; new admin routes belong in this group.
+	admin := app.Group("/admin", r.requireAdmin)
+	admin.Get("/circuit-breakers", r.listCircuitBreakers)
+	admin.Get("/health-score", r.getHealthScore)
+	admin.Get("/ledger/balances", r.getLedgerBalances)
+	admin.Post("/reconciliation/import", r.importSettlementFile)
+	admin.Post("/outbox/flush", r.flushOutbox)
+	admin.Get("/payments/export", r.exportPayments)
+	admin.Get("/idempotency/:key", r.getIdempotencyKeyPayment)
+	admin.Get("/metrics/daily", r.listDailyMetrics)
+	admin.Post("/metrics/daily/recompute", r.recomputeDailyMetrics)
+	admin.Get("/reviews", r.listReviews)
+	admin.Post("/reviews/:id/approve", r.approveReview)
+	admin.Post("/reviews/:id/reject", r.rejectReview)
 }
 
 // Server represents an HTTP server instance with application configuration and routing.
@@ -78,8 +979,17 @@ type Server struct {
 
 // NewServer initializes a new Server instance with the provided Config and Router and sets up routing for the application.
 func NewServer(config Config, router Router) *Server {
-	app := fiber.New()
-	app.Use(logger.New())
+	// Validate has already rejected an unknown codec.
+	encoder, decoder, _ := jsonCodec(config.JSONCodec)
+	app := fiber.New(fiber.Config{JSONEncoder: encoder, JSONDecoder: decoder})
+	var deps middlewareDeps
+	if authenticator, ok := router.(Authenticator); ok {
+		deps.authenticate = authenticator.Authenticate(config)
+	}
+	if source, ok := router.(MetricsSource); ok {
+		deps.metrics = source.Metrics(config)
+	}
+	installMiddlewares(app, config, deps)
 
 	router.SetupRoutes(app, config)
 
@@ -95,7 +1005,21 @@ func (s *Server) Start() {
 	log.Printf("Server starting on %s (Environment: %s)", endpoint, s.config.Env)
 
 	go func() {
-		if err := s.app.Listen(":" + s.config.Port); err != nil {
+		listener, err := net.Listen("tcp", ":"+s.config.Port)
+		if err != nil {
+			log.Fatalf("Error starting server: %v", err)
+		}
+		if s.config.MaxConnections > 0 {
+			listener = NewLimitListener(listener, s.config.MaxConnections)
+		}
+		if s.config.tlsEnabled() {
+			tlsConfig, err := s.config.serverTLSConfig()
+			if err != nil {
+				log.Fatalf("Error starting server: %v", err)
+			}
+			listener = tls.NewListener(listener, tlsConfig)
+		}
+		if err := s.app.Listener(listener); err != nil {
 			log.Fatalf("Error starting server: %v", err)
 		}
 	}()
@@ -114,16 +1038,100 @@ func (s *Server) Shutdown() {
 
 func main() {
 	env := &Env{}
-	router := &APIRouter{}
-
 	config := env.Load()
+	log.SetOutput(logOutput(config, os.Stderr))
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	encryptor, err := NewFieldEncryptorFromConfig(config)
+	if err != nil {
+		log.Fatalf("Invalid encryption configuration: %v", err)
+	}
+	memoryStore := NewMemoryPaymentStore()
+	memoryStore.SetEncryptor(encryptor)
+	if err := checkPaymentDatabase(context.Background(), memoryStore, config, defaultConnectInitialBackoff, schemaPollInterval); err != nil {
+		log.Fatalf("Startup payment store check failed: %v", err)
+	}
+
+	metrics := NewMetricsRegistry()
+	health := NewDependencyHealth(config.healthScoreWindow())
+	instrumentedStore := NewInstrumentedPaymentStore(memoryStore, metrics, config.SlowQueryThreshold)
+	instrumentedStore.Health = health
+	store := newCachedPaymentStore(instrumentedStore, config)
+	sandbox := NewSandboxGateway("sandbox")
+	if config.StartupSelfTest {
+		if err := RunGatewaySelfTest(context.Background(), []PaymentGateway{sandbox}, time.Second, config.StrictStartupChecks); err != nil {
+			log.Fatalf("Startup self-test failed: %v", err)
+		}
+	}
+	breakers := NewCircuitBreakerRegistry()
+	instrumented := NewInstrumentedGateway(newFailoverGateway(newEndpointGateway(sandbox, config, breakers), config, breakers), metrics, config.SlowGatewayThreshold)
+	instrumented.Health = health
+	gateway := newMaintenanceGateway(instrumented, config)
+	currencyGateway, err := newCurrencyGateway(gateway, []PaymentGateway{gateway}, config)
+	if err != nil {
+		log.Fatalf("Invalid gateway routing: %v", err)
+	}
+	methodGateway := NewMethodGateway(currencyGateway, parsePaymentMethods(config.PaymentMethods), nil, breakers)
+
+	idempotency := NewMemoryIdempotencyStore()
+	if config.IdempotencyPersistFile != "" {
+		if err := idempotency.LoadFromFile(config.IdempotencyPersistFile); err != nil {
+			log.Printf("Failed to load idempotency keys from %s: %v", config.IdempotencyPersistFile, err)
+		}
+	}
+
+	bins := DefaultBINTable()
+	if config.BINTableFile != "" {
+		if bins, err = LoadBINTableFile(config.BINTableFile); err != nil {
+			log.Fatalf("Failed to load BIN table from %s: %v", config.BINTableFile, err)
+		}
+	}
+
+	router := &APIRouter{store: store, gateway: methodGateway, metrics: metrics, idempotency: idempotency, bins: bins, breakers: breakers, health: health}
 
 	server := NewServer(config, router)
 	server.Start()
 
+	var workers []BackgroundWorker
+	if config.PaymentPollInterval > 0 {
+		workers = append(workers, NewPaymentStatusWorker(router, config.PaymentPollInterval))
+	}
+	if config.OutboxRelayInterval > 0 {
+		workers = append(workers, NewOutboxRelayWorker(router.relay, config.OutboxRelayInterval, config.OutboxBatchSize))
+	}
+	if config.AuthorizationExpiryInterval > 0 {
+		workers = append(workers, NewAuthorizationExpiryWorker(router, config.AuthorizationExpiryInterval))
+	}
+	if config.DailyMetricsInterval > 0 {
+		workers = append(workers, NewDailyMetricsWorker(router, config.DailyMetricsInterval))
+	}
+	var otlpMetrics *OTLPMetricsExporter
+	if config.metricsExporterEnabled(MetricsExporterOTLP) && config.OTLPMetricsInterval > 0 {
+		otlpMetrics = NewOTLPMetricsExporter(config.OTLPMetricsEndpoint, "payment-service")
+		workers = append(workers, NewOTLPMetricsWorker(metrics, otlpMetrics, config.OTLPMetricsInterval))
+	}
+	for _, worker := range workers {
+		worker.Start()
+	}
+
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
 	<-interrupt
 
 	server.Shutdown()
+	DrainWorkers(workers, config.WorkerDrainTimeout)
+	if otlpMetrics != nil {
+		// Push the final totals so the collector sees the requests served since the last interval.
+		if err := otlpMetrics.Export(context.Background(), metrics.Snapshot()); err != nil {
+			log.Printf("Failed to export final metrics over OTLP: %v", err)
+		}
+	}
+
+	if config.IdempotencyPersistFile != "" {
+		if err := idempotency.SaveToFile(config.IdempotencyPersistFile); err != nil {
+			log.Printf("Failed to persist idempotency keys to %s: %v", config.IdempotencyPersistFile, err)
+		}
+	}
 }
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -55,6 +56,26 @@ func TestGetEnvOr(t *testing.T) {
 	})
 }
 
+func TestGetEnvBoolOr(t *testing.T) {
+	t.Run("Valid Boolean", func(t *testing.T) {
+		_ = os.Setenv("TEST_BOOL", "true")
+		defer func() { _ = os.Unsetenv("TEST_BOOL") }()
+
+		assert.True(t, getEnvBoolOr("TEST_BOOL", false))
+	})
+
+	t.Run("Invalid Boolean Falls Back To Default", func(t *testing.T) {
+		_ = os.Setenv("TEST_BOOL", "yes please")
+		defer func() { _ = os.Unsetenv("TEST_BOOL") }()
+
+		assert.True(t, getEnvBoolOr("TEST_BOOL", true))
+	})
+
+	t.Run("Missing Boolean", func(t *testing.T) {
+		assert.False(t, getEnvBoolOr("NON_EXISTING_BOOL", false))
+	})
+}
+
 func TestEnvLoad(t *testing.T) {
 	t.Run("With Custom Environment Variables", func(t *testing.T) {
 		_ = os.Setenv("APP_ENV", "test_env")
@@ -85,6 +106,7 @@ func TestEnvLoad(t *testing.T) {
 		assert.Equal(t, "development", config.Env)
 		assert.Equal(t, "http://0.0.0.0", config.Endpoint)
 		assert.Equal(t, "8080", config.Port)
+		assert.Equal(t, "UTC", config.Timezone)
 	})
 
 	t.Run("With Mixed Values", func(t *testing.T) {
@@ -102,7 +124,65 @@ func TestEnvLoad(t *testing.T) {
 	})
 }
 
+func TestConfigValidate(t *testing.T) {
+	t.Run("Valid Timezone", func(t *testing.T) {
+		config := Config{Timezone: "Asia/Bangkok"}
+		assert.NoError(t, config.Validate())
+		assert.Equal(t, "Asia/Bangkok", config.Location().String())
+	})
+
+	t.Run("Invalid Timezone", func(t *testing.T) {
+		config := Config{Timezone: "Mars/Olympus_Mons"}
+		assert.ErrorContains(t, config.Validate(), "invalid TIMEZONE")
+		assert.Equal(t, time.UTC, config.Location())
+	})
+
+	t.Run("Distinct Valid Ports", func(t *testing.T) {
+		config := Config{Timezone: "UTC", Port: "8080", GRPCPort: "9090", MetricsPort: "9100"}
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("Port Conflict", func(t *testing.T) {
+		config := Config{Timezone: "UTC", Port: "8080", GRPCPort: "8080"}
+		assert.EqualError(t, config.Validate(), "PORT and GRPC_PORT are both set to 8080; each listener needs its own port")
+	})
+
+	t.Run("Invalid Port", func(t *testing.T) {
+		config := Config{Timezone: "UTC", Port: "8080", MetricsPort: "70000"}
+		assert.ErrorContains(t, config.Validate(), "invalid METRICS_PORT")
+	})
+
+	t.Run("Clock Skew Leeway Above Max", func(t *testing.T) {
+		config := Config{Timezone: "UTC", ClockSkewLeeway: time.Hour}
+		assert.ErrorContains(t, config.Validate(), "CLOCK_SKEW_LEEWAY")
+	})
+
+	t.Run("Route Timeout Above Max", func(t *testing.T) {
+		config := Config{Timezone: "UTC", RouteTimeouts: "GET /reports/settlement=1h"}
+		assert.ErrorContains(t, config.Validate(), "ROUTE_TIMEOUTS")
+	})
+}
+
 func TestAPIRouterSetupRoutes(t *testing.T) {
+	t.Run("Admin Routes Require The Admin Token", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{AdminToken: "admin-secret"})
+
+		checked := 0
+		for _, route := range app.GetRoutes(true) {
+			if route.Method == http.MethodHead || !strings.HasPrefix(route.Path, "/admin/") {
+				continue
+			}
+			req := httptest.NewRequest(route.Method, strings.ReplaceAll(route.Path, ":", "x_"), nil)
+			req.Header.Set(HeaderAdminToken, "wrong")
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode, "%s %s", route.Method, route.Path)
+			checked++
+		}
+		assert.GreaterOrEqual(t, checked, 12)
+	})
+
 	t.Run("Root Endpoint", func(t *testing.T) {
 		app := fiber.New()
 		config := Config{
@@ -121,7 +201,7 @@ func TestAPIRouterSetupRoutes(t *testing.T) {
 
 		body, err := io.ReadAll(resp.Body)
 		assert.NoError(t, err)
-		assert.Equal(t, "Hello Golf!", string(body))
+		assert.Equal(t, "Hello Payment!", string(body))
 	})
 
 	t.Run("Info Endpoint", func(t *testing.T) {
@@ -148,6 +228,36 @@ func TestAPIRouterSetupRoutes(t *testing.T) {
 		assert.Equal(t, "test_endpoint:1234", infoResponse["endpoint"])
 	})
 
+	t.Run("Info Endpoint Enabled Features", func(t *testing.T) {
+		app := fiber.New()
+		config := Config{
+			Env:                   "test_env",
+			Port:                  "1234",
+			EncryptionKeys:        "1:c2VjcmV0",
+			MaxConcurrentRequests: 10,
+		}
+
+		router := &APIRouter{}
+		router.SetupRoutes(app, config)
+
+		req := httptest.NewRequest(http.MethodGet, "/info", nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(body), "c2VjcmV0")
+
+		var infoResponse struct {
+			EnabledFeatures map[string]bool `json:"enabled_features"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &infoResponse))
+		assert.True(t, infoResponse.EnabledFeatures["field_encryption"])
+		assert.True(t, infoResponse.EnabledFeatures["load_shedding"])
+		assert.False(t, infoResponse.EnabledFeatures["startup_self_test"])
+		assert.False(t, infoResponse.EnabledFeatures["idempotency_persistence"])
+	})
+
 	t.Run("Health Endpoint", func(t *testing.T) {
 		app := fiber.New()
 		config := Config{
@@ -169,6 +279,24 @@ func TestAPIRouterSetupRoutes(t *testing.T) {
 		assert.Equal(t, "OK", string(body))
 	})
 
+	t.Run("Health Endpoint JSON", func(t *testing.T) {
+		app := fiber.New()
+		router := &APIRouter{}
+		router.SetupRoutes(app, Config{})
+
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set("Accept", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var health HealthResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&health))
+		assert.Equal(t, "ok", health.Status)
+		assert.GreaterOrEqual(t, health.UptimeSeconds, int64(0))
+		assert.Equal(t, processStartedAt.UTC().Unix(), health.StartedAt.Unix())
+	})
+
 	t.Run("Non-existent Endpoint", func(t *testing.T) {
 		app := fiber.New()
 		config := Config{
@@ -318,7 +446,7 @@ func TestAPIIntegration(t *testing.T) {
 
 		body, err := io.ReadAll(resp.Body)
 		assert.NoError(t, err)
-		assert.Equal(t, "Hello Golf!", string(body))
+		assert.Equal(t, "Hello Payment!", string(body))
 	})
 
 	t.Run("Info Endpoint", func(t *testing.T) {
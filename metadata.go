@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Default metadata limits, applied to every resource that accepts metadata.
+const (
+	defaultMetadataMaxKeys      = 50
+	defaultMetadataMaxKeyLength = 40
+	defaultMetadataMaxSize      = 8 * 1024
+)
+
+// MetadataLimits bounds the metadata a client may attach to a resource, so that it cannot bloat storage.
+// MaxSize is measured on the JSON-serialized map, in bytes.
+type MetadataLimits struct {
+	MaxKeys      int
+	MaxKeyLength int
+	MaxSize      int
+}
+
+// newMetadataLimits builds the limits from config, using the defaults for unset values.
+func newMetadataLimits(config Config) MetadataLimits {
+	limits := MetadataLimits{MaxKeys: defaultMetadataMaxKeys, MaxKeyLength: defaultMetadataMaxKeyLength, MaxSize: defaultMetadataMaxSize}
+	if config.MetadataMaxKeys > 0 {
+		limits.MaxKeys = config.MetadataMaxKeys
+	}
+	if config.MetadataMaxKeyLength > 0 {
+		limits.MaxKeyLength = config.MetadataMaxKeyLength
+	}
+	if config.MetadataMaxSize > 0 {
+		limits.MaxSize = config.MetadataMaxSize
+	}
+	return limits
+}
+
+// Validate reports the first limit metadata breaks.
+func (l MetadataLimits) Validate(metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	if len(metadata) > l.MaxKeys {
+		return fmt.Errorf("metadata has %d keys, at most %d are allowed", len(metadata), l.MaxKeys)
+	}
+	for key := range metadata {
+		if len(key) > l.MaxKeyLength {
+			return fmt.Errorf("metadata key %q is longer than %d characters", key, l.MaxKeyLength)
+		}
+	}
+	serialized, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if len(serialized) > l.MaxSize {
+		return fmt.Errorf("metadata is %d bytes, at most %d are allowed", len(serialized), l.MaxSize)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataLimits(t *testing.T) {
+	config := Config{MetadataMaxKeys: 3, MetadataMaxKeyLength: 10, MetadataMaxSize: 100, AdminToken: "admin-secret"}
+	newApp := func() *fiber.App {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, config)
+		return app
+	}
+	metadataJSON := func(keys, valueLength int) string {
+		entries := make([]string, keys)
+		for i := range entries {
+			entries[i] = fmt.Sprintf("%q:%q", fmt.Sprintf("k%d", i), strings.Repeat("v", valueLength))
+		}
+		return "{" + strings.Join(entries, ",") + "}"
+	}
+	resources := []struct {
+		name string
+		post func(app *fiber.App, metadata string) int
+	}{
+		{"Payment", func(app *fiber.App, metadata string) int {
+			resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","metadata":`+metadata+`}`, nil)
+			return resp.StatusCode
+		}},
+		{"Customer", func(app *fiber.App, metadata string) int {
+			resp, _ := postCustomer(t, app, "m_1", `{"name":"Somchai","metadata":`+metadata+`}`)
+			return resp.StatusCode
+		}},
+	}
+
+	for _, resource := range resources {
+		t.Run(resource.name+" Within Limits", func(t *testing.T) {
+			assert.Equal(t, fiber.StatusCreated, resource.post(newApp(), metadataJSON(3, 10)))
+		})
+
+		t.Run(resource.name+" Too Many Keys", func(t *testing.T) {
+			assert.Equal(t, fiber.StatusUnprocessableEntity, resource.post(newApp(), metadataJSON(4, 1)))
+		})
+
+		t.Run(resource.name+" Too Large", func(t *testing.T) {
+			assert.Equal(t, fiber.StatusUnprocessableEntity, resource.post(newApp(), metadataJSON(2, 60)))
+		})
+
+		t.Run(resource.name+" Key Too Long", func(t *testing.T) {
+			assert.Equal(t, fiber.StatusUnprocessableEntity, resource.post(newApp(), `{"a_very_long_key":"v"}`))
+		})
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		limits := newMetadataLimits(Config{})
+		assert.Equal(t, MetadataLimits{MaxKeys: 50, MaxKeyLength: 40, MaxSize: 8192}, limits)
+		assert.NoError(t, limits.Validate(nil))
+	})
+}
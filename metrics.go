@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+const (
+	httpRequestsMetric       = "payment_http_requests_total"
+	httpRequestLatencyMetric = "payment_http_request_duration_seconds"
+)
+
+// Labels are the dimension values of a metric series.
+type Labels map[string]string
+
+// defaultLatencyBuckets are the histogram upper bounds, in seconds, used for latency metrics.
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricKind distinguishes counters from histograms.
+type MetricKind string
+
+const (
+	// MetricCounter is a monotonically increasing value.
+	MetricCounter MetricKind = "counter"
+	// MetricHistogram is a distribution of observed values in buckets.
+	MetricHistogram MetricKind = "histogram"
+)
+
+// MetricSample is a point-in-time copy of one metric series, used by exporters.
+type MetricSample struct {
+	Name    string
+	Kind    MetricKind
+	Labels  Labels
+	Value   float64
+	Count   uint64
+	Sum     float64
+	Buckets []float64
+	Counts  []uint64
+}
+
+type metricSeries struct {
+	name    string
+	kind    MetricKind
+	labels  Labels
+	value   float64
+	count   uint64
+	sum     float64
+	buckets []float64
+	counts  []uint64
+}
+
+// MetricsRegistry holds the service's counters and histograms and renders them in the Prometheus text format.
+type MetricsRegistry struct {
+	mu     sync.Mutex
+	series map[string]*metricSeries
+}
+
+// NewMetricsRegistry creates an empty MetricsRegistry.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{series: make(map[string]*metricSeries)}
+}
+
+// Inc adds one to the counter identified by name and labels.
+func (m *MetricsRegistry) Inc(name string, labels Labels) {
+	m.Add(name, labels, 1)
+}
+
+// Add adds delta to the counter identified by name and labels.
+func (m *MetricsRegistry) Add(name string, labels Labels, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(name, MetricCounter, labels).value += delta
+}
+
+// Observe records value in the histogram identified by name and labels.
+func (m *MetricsRegistry) Observe(name string, labels Labels, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.get(name, MetricHistogram, labels)
+	s.count++
+	s.sum += value
+	for i, bound := range s.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+}
+
+// CounterValue returns the current value of a counter, or 0 if it was never incremented.
+func (m *MetricsRegistry) CounterValue(name string, labels Labels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.series[seriesKey(name, labels)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// HistogramCount returns how many values a histogram has observed.
+func (m *MetricsRegistry) HistogramCount(name string, labels Labels) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.series[seriesKey(name, labels)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// Snapshot returns a copy of every series, sorted by name and labels.
+func (m *MetricsRegistry) Snapshot() []MetricSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	samples := make([]MetricSample, 0, len(keys))
+	for _, key := range keys {
+		s := m.series[key]
+		samples = append(samples, MetricSample{
+			Name: s.name, Kind: s.kind, Labels: s.labels, Value: s.value, Count: s.count, Sum: s.sum,
+			Buckets: s.buckets, Counts: append([]uint64(nil), s.counts...),
+		})
+	}
+	return samples
+}
+
+// WritePrometheus renders every series in the Prometheus text exposition format.
+func (m *MetricsRegistry) WritePrometheus(w io.Writer) error {
+	typed := make(map[string]bool)
+	for _, s := range m.Snapshot() {
+		if !typed[s.Name] {
+			typed[s.Name] = true
+			if _, err := fmt.Fprintf(w, "# TYPE %s %s\n", s.Name, s.Kind); err != nil {
+				return err
+			}
+		}
+		var err error
+		switch s.Kind {
+		case MetricCounter:
+			_, err = fmt.Fprintf(w, "%s%s %s\n", s.Name, formatLabels(s.Labels, "", ""), formatFloat(s.Value))
+		case MetricHistogram:
+			for i, bound := range s.Buckets {
+				if _, err = fmt.Fprintf(w, "%s_bucket%s %d\n", s.Name, formatLabels(s.Labels, "le", formatFloat(bound)), s.Counts[i]); err != nil {
+					return err
+				}
+			}
+			if _, err = fmt.Fprintf(w, "%s_bucket%s %d\n", s.Name, formatLabels(s.Labels, "le", "+Inf"), s.Count); err != nil {
+				return err
+			}
+			if _, err = fmt.Fprintf(w, "%s_sum%s %s\n", s.Name, formatLabels(s.Labels, "", ""), formatFloat(s.Sum)); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s_count%s %d\n", s.Name, formatLabels(s.Labels, "", ""), s.Count)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MetricsRegistry) get(name string, kind MetricKind, labels Labels) *metricSeries {
+	key := seriesKey(name, labels)
+	s, ok := m.series[key]
+	if !ok {
+		copied := make(Labels, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+		s = &metricSeries{name: name, kind: kind, labels: copied}
+		if kind == MetricHistogram {
+			s.buckets = defaultLatencyBuckets
+			s.counts = make([]uint64, len(defaultLatencyBuckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+func seriesKey(name string, labels Labels) string {
+	return name + formatLabels(labels, "", "")
+}
+
+func formatLabels(labels Labels, extraKey, extraValue string) string {
+	pairs := make([]string, 0, len(labels)+1)
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(pairs)
+	if extraKey != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", extraKey, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+// NewRequestMetricsMiddleware counts every request in payment_http_requests_total and times it in
+// payment_http_request_duration_seconds, labelled with the method and the matched route rather than the path, so
+// that payment IDs do not each become a series.
+func NewRequestMetricsMiddleware(metrics *MetricsRegistry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		started := time.Now()
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler writes the status only after the chain returns.
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+		method, route := utils.CopyString(c.Method()), utils.CopyString(c.Route().Path)
+		metrics.Observe(httpRequestLatencyMetric, Labels{"method": method, "route": route}, time.Since(started).Seconds())
+		metrics.Inc(httpRequestsMetric, Labels{"method": method, "route": route, "status": strconv.Itoa(status)})
+		return err
+	}
+}
+
+func (r *APIRouter) getMetrics(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4")
+	return r.metrics.WritePrometheus(c)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DailyMetrics aggregates one business day's live payments in one currency, for dashboards that do not scrape
+// /metrics. Amounts are in minor units.
+type DailyMetrics struct {
+	Date     string `json:"date"`
+	Currency string `json:"currency"`
+	// Count is the number of payments created that day.
+	Count int `json:"count"`
+	// Captured, Refunded and Fees total the captures, succeeded refunds and platform fees booked that day.
+	Captured   int64     `json:"captured"`
+	Refunded   int64     `json:"refunded"`
+	Fees       int64     `json:"fees"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// DailyMetricsStore holds the metrics_daily snapshots, one row per date and currency.
+type DailyMetricsStore interface {
+	// ReplaceDay stores rows as the complete snapshot of date, dropping any earlier snapshot of that day.
+	ReplaceDay(ctx context.Context, date string, rows []DailyMetrics) error
+	// ListRange returns the rows from date from to date to, inclusive, ordered by date and currency.
+	ListRange(ctx context.Context, from, to string) ([]DailyMetrics, error)
+}
+
+// MemoryDailyMetricsStore is a DailyMetricsStore that keeps snapshots in memory.
+type MemoryDailyMetricsStore struct {
+	mu   sync.RWMutex
+	days map[string][]DailyMetrics
+}
+
+// NewMemoryDailyMetricsStore creates an empty MemoryDailyMetricsStore.
+func NewMemoryDailyMetricsStore() *MemoryDailyMetricsStore {
+	return &MemoryDailyMetricsStore{days: make(map[string][]DailyMetrics)}
+}
+
+// ReplaceDay implements DailyMetricsStore.
+func (s *MemoryDailyMetricsStore) ReplaceDay(_ context.Context, date string, rows []DailyMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.days[date] = append([]DailyMetrics(nil), rows...)
+	return nil
+}
+
+// ListRange implements DailyMetricsStore.
+func (s *MemoryDailyMetricsStore) ListRange(_ context.Context, from, to string) ([]DailyMetrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows := []DailyMetrics{}
+	for date, day := range s.days {
+		// Dates are YYYY-MM-DD, so they compare correctly as strings.
+		if date >= from && date <= to {
+			rows = append(rows, day...)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Date != rows[j].Date {
+			return rows[i].Date < rows[j].Date
+		}
+		return rows[i].Currency < rows[j].Currency
+	})
+	return rows, nil
+}
+
+// within reports whether t falls in [from, to).
+func within(t time.Time, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
+
+// ComputeDailyMetrics aggregates the live payments, refunds and capture fees of one business day in loc per
+// currency. It only reads the source records, so computing a day again gives the same totals.
+func (r *APIRouter) ComputeDailyMetrics(ctx context.Context, date string, loc *time.Location) ([]DailyMetrics, error) {
+	from, to, err := dayBounds(date, loc)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := r.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	byCurrency := make(map[string]*DailyMetrics)
+	row := func(currency string) *DailyMetrics {
+		if _, ok := byCurrency[currency]; !ok {
+			byCurrency[currency] = &DailyMetrics{Date: date, Currency: currency, ComputedAt: now}
+		}
+		return byCurrency[currency]
+	}
+	for _, p := range payments {
+		if p.TestMode {
+			continue
+		}
+		if within(p.CreatedAt, from, to) {
+			row(p.Currency).Count++
+		}
+		if p.CapturedAt != nil && within(*p.CapturedAt, from, to) {
+			row(p.Currency).Captured += p.Amount
+		}
+
+		refunds, err := r.refunds.ListByPayment(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, refund := range refunds {
+			if refund.Status == RefundStatusSucceeded && within(refund.CreatedAt, from, to) {
+				row(refund.Currency).Refunded += refund.Amount
+			}
+		}
+
+		transactions, err := r.ledger.ListByPayment(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range transactions {
+			if tx.Kind != "capture" || !within(tx.CreatedAt, from, to) {
+				continue
+			}
+			for _, posting := range tx.Postings {
+				if posting.Account == AccountPlatformFee && posting.Direction == Credit {
+					row(posting.Amount.Currency).Fees += posting.Amount.Amount
+				}
+			}
+		}
+	}
+
+	rows := make([]DailyMetrics, 0, len(byCurrency))
+	for _, m := range byCurrency {
+		rows = append(rows, *m)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Currency < rows[j].Currency })
+	return rows, nil
+}
+
+// snapshotDailyMetrics computes date and replaces its stored snapshot, so re-running a day never double counts.
+func (r *APIRouter) snapshotDailyMetrics(ctx context.Context, date string) ([]DailyMetrics, error) {
+	rows, err := r.ComputeDailyMetrics(ctx, date, r.config.Location())
+	if err != nil {
+		return nil, err
+	}
+	return rows, r.dailyMetrics.ReplaceDay(ctx, date, rows)
+}
+
+// NewDailyMetricsWorker creates the worker that snapshots yesterday and today every interval; yesterday is
+// included so that records landing just before midnight make it into the final numbers.
+func NewDailyMetricsWorker(r *APIRouter, interval time.Duration) *Worker[string] {
+	list := func(context.Context) ([]string, error) {
+		today := time.Now().In(r.config.Location())
+		return []string{today.AddDate(0, 0, -1).Format(dateLayout), today.Format(dateLayout)}, nil
+	}
+	process := func(ctx context.Context, date string) error {
+		_, err := r.snapshotDailyMetrics(ctx, date)
+		return err
+	}
+	return NewWorker("daily-metrics", interval, list, process)
+}
+
+// listDailyMetrics serves the stored snapshots between ?from= and ?to=, which defaults to from.
+func (r *APIRouter) listDailyMetrics(c *fiber.Ctx) error {
+	from := c.Query("from")
+	if from == "" {
+		return respondError(c, ErrCodeInvalidRequest, "from is required")
+	}
+	to := c.Query("to", from)
+	for _, date := range []string{from, to} {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return respondError(c, ErrCodeInvalidRequest, "from and to must be formatted as YYYY-MM-DD")
+		}
+	}
+	rows, err := r.dailyMetrics.ListRange(c.UserContext(), from, to)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load daily metrics")
+	}
+	return c.JSON(fiber.Map{"days": rows})
+}
+
+// recomputeDailyMetrics snapshots ?date= right away, e.g. after a late settlement correction.
+func (r *APIRouter) recomputeDailyMetrics(c *fiber.Ctx) error {
+	date := c.Query("date")
+	if date == "" {
+		return respondError(c, ErrCodeInvalidRequest, "date is required")
+	}
+	rows, err := r.snapshotDailyMetrics(c.UserContext(), date)
+	if err != nil {
+		return respondError(c, ErrCodeInvalidRequest, err.Error())
+	}
+	return c.JSON(fiber.Map{"days": rows})
+}
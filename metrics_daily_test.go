@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDailyMetrics(t *testing.T) {
+	ctx := context.Background()
+	today := time.Now().UTC().Format(dateLayout)
+	newApp := func(t *testing.T) (*fiber.App, *APIRouter) {
+		router := &APIRouter{store: NewMemoryPaymentStore(), gateway: NewSandboxGateway("sandbox")}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{CaptureMode: CaptureAutomatic, PlatformFeeBasisPoints: 250, AdminToken: "admin-secret"})
+
+		_, first := postPayment(t, app, `{"amount":10000,"currency":"THB"}`, nil)
+		postPayment(t, app, `{"amount":4000,"currency":"THB"}`, nil)
+		postPayment(t, app, `{"amount":2500,"currency":"USD"}`, nil)
+		postPayment(t, app, `{"amount":500,"currency":"THB","capture_mode":"manual"}`, nil)
+		resp, _ := postRefund(t, app, first.ID, `{"amount":3000}`)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		return app, router
+	}
+
+	t.Run("Computes A Day", func(t *testing.T) {
+		_, router := newApp(t)
+
+		rows, err := router.snapshotDailyMetrics(ctx, today)
+		assert.NoError(t, err)
+		if assert.Len(t, rows, 2) {
+			assert.Equal(t, "THB", rows[0].Currency)
+			assert.Equal(t, 3, rows[0].Count)
+			assert.Equal(t, int64(14000), rows[0].Captured)
+			assert.Equal(t, int64(3000), rows[0].Refunded)
+			assert.Equal(t, int64(350), rows[0].Fees)
+			assert.Equal(t, "USD", rows[1].Currency)
+			assert.Equal(t, 1, rows[1].Count)
+			assert.Equal(t, int64(2500), rows[1].Captured)
+			assert.Equal(t, int64(63), rows[1].Fees)
+		}
+
+		yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(dateLayout)
+		rows, err = router.snapshotDailyMetrics(ctx, yesterday)
+		assert.NoError(t, err)
+		assert.Empty(t, rows)
+	})
+
+	t.Run("Re-Running Gives The Same Result", func(t *testing.T) {
+		_, router := newApp(t)
+
+		first, err := router.snapshotDailyMetrics(ctx, today)
+		assert.NoError(t, err)
+		_, err = router.snapshotDailyMetrics(ctx, today)
+		assert.NoError(t, err)
+
+		stored, err := router.dailyMetrics.ListRange(ctx, today, today)
+		assert.NoError(t, err)
+		assert.Len(t, stored, len(first))
+		for i := range stored {
+			stored[i].ComputedAt, first[i].ComputedAt = time.Time{}, time.Time{}
+		}
+		assert.Equal(t, first, stored)
+	})
+
+	t.Run("Admin Endpoints", func(t *testing.T) {
+		app, _ := newApp(t)
+		call := func(method, target string, admin bool) *http.Response {
+			req := httptest.NewRequest(method, target, nil)
+			if admin {
+				req.Header.Set(HeaderAdminToken, "admin-secret")
+			}
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp
+		}
+
+		assert.Equal(t, fiber.StatusForbidden, call(http.MethodPost, "/admin/metrics/daily/recompute?date="+today, false).StatusCode)
+		assert.Equal(t, fiber.StatusForbidden, call(http.MethodGet, "/admin/metrics/daily?from="+today, false).StatusCode)
+
+		resp := call(http.MethodPost, "/admin/metrics/daily/recompute?date="+today, true)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		resp = call(http.MethodGet, "/admin/metrics/daily?from="+today, true)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var body struct {
+			Days []DailyMetrics `json:"days"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Len(t, body.Days, 2)
+
+		resp = call(http.MethodGet, "/admin/metrics/daily?from=yesterday", true)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
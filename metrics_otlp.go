@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Metrics exporters selectable in METRICS_EXPORTERS.
+const (
+	MetricsExporterPrometheus = "prometheus"
+	MetricsExporterOTLP       = "otlp"
+)
+
+const (
+	otlpExportTimeout = 10 * time.Second
+	// otlpTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE: every export carries the running total
+	// since the service started, the same values /metrics serves.
+	otlpTemporalityCumulative = 2
+)
+
+// parseMetricsExporters parses a comma-separated list of metrics exporters; an empty list means Prometheus only.
+func parseMetricsExporters(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return []string{MetricsExporterPrometheus}, nil
+	}
+	var exporters []string
+	for _, entry := range strings.Split(spec, ",") {
+		exporter := strings.TrimSpace(entry)
+		if exporter != MetricsExporterPrometheus && exporter != MetricsExporterOTLP {
+			return nil, fmt.Errorf("invalid METRICS_EXPORTERS entry %q: want prometheus or otlp", entry)
+		}
+		if !slices.Contains(exporters, exporter) {
+			exporters = append(exporters, exporter)
+		}
+	}
+	return exporters, nil
+}
+
+// metricsExporterEnabled reports whether METRICS_EXPORTERS selects exporter.
+func (c Config) metricsExporterEnabled(exporter string) bool {
+	// Validate has already rejected a malformed METRICS_EXPORTERS.
+	exporters, _ := parseMetricsExporters(c.MetricsExporters)
+	return slices.Contains(exporters, exporter)
+}
+
+// OTLPMetricsExporter pushes MetricsRegistry snapshots to an OpenTelemetry collector with OTLP/HTTP JSON. It
+// sends cumulative values rather than deltas, so it reads the same registry /metrics renders without either
+// exporter resetting or double-counting what the other reports.
+type OTLPMetricsExporter struct {
+	// Endpoint is the collector's metrics URL, such as http://otel-collector:4318/v1/metrics.
+	Endpoint    string
+	ServiceName string
+	Client      *http.Client
+
+	start time.Time
+	now   func() time.Time
+}
+
+// NewOTLPMetricsExporter creates an OTLPMetricsExporter whose cumulative series start now.
+func NewOTLPMetricsExporter(endpoint, serviceName string) *OTLPMetricsExporter {
+	return &OTLPMetricsExporter{Endpoint: endpoint, ServiceName: serviceName, start: time.Now(), now: time.Now}
+}
+
+// Export sends samples to the collector in one request.
+func (e *OTLPMetricsExporter) Export(ctx context.Context, samples []MetricSample) error {
+	payload, err := json.Marshal(e.request(samples))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: otlpExportTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("otlp collector returned %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// The otlp* types are the subset of the OTLP ExportMetricsServiceRequest JSON encoding the exporter sends;
+// 64-bit integers are strings, as the encoding requires.
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name      string         `json:"name"`
+	Sum       *otlpSum       `json:"sum,omitempty"`
+	Histogram *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func (e *OTLPMetricsExporter) request(samples []MetricSample) otlpMetricsRequest {
+	start := strconv.FormatInt(e.start.UnixNano(), 10)
+	now := strconv.FormatInt(e.now().UnixNano(), 10)
+
+	var metrics []otlpMetric
+	index := make(map[string]int)
+	for _, s := range samples {
+		i, ok := index[s.Name]
+		if !ok {
+			i = len(metrics)
+			index[s.Name] = i
+			metric := otlpMetric{Name: s.Name}
+			if s.Kind == MetricHistogram {
+				metric.Histogram = &otlpHistogram{AggregationTemporality: otlpTemporalityCumulative}
+			} else {
+				metric.Sum = &otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}
+			}
+			metrics = append(metrics, metric)
+		}
+
+		attributes := otlpAttributes(s.Labels)
+		switch s.Kind {
+		case MetricCounter:
+			metrics[i].Sum.DataPoints = append(metrics[i].Sum.DataPoints, otlpNumberDataPoint{
+				Attributes: attributes, StartTimeUnixNano: start, TimeUnixNano: now, AsDouble: s.Value,
+			})
+		case MetricHistogram:
+			metrics[i].Histogram.DataPoints = append(metrics[i].Histogram.DataPoints, otlpHistogramDataPoint{
+				Attributes: attributes, StartTimeUnixNano: start, TimeUnixNano: now,
+				Count: strconv.FormatUint(s.Count, 10), Sum: s.Sum,
+				BucketCounts: otlpBucketCounts(s), ExplicitBounds: s.Buckets,
+			})
+		}
+	}
+
+	return otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: otlpAttributes(Labels{"service.name": e.ServiceName})},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: e.ServiceName},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+func otlpAttributes(labels Labels) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(labels))
+	for key, value := range labels {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: otlpAnyValue{StringValue: value}})
+	}
+	slices.SortFunc(attributes, func(a, b otlpAttribute) int { return strings.Compare(a.Key, b.Key) })
+	return attributes
+}
+
+// otlpBucketCounts converts the registry's cumulative "le" bucket counts into the per-bucket counts OTLP
+// expects, with the trailing overflow bucket above the last bound.
+func otlpBucketCounts(s MetricSample) []string {
+	counts := make([]string, 0, len(s.Counts)+1)
+	var below uint64
+	for _, cumulative := range s.Counts {
+		counts = append(counts, strconv.FormatUint(cumulative-below, 10))
+		below = cumulative
+	}
+	return append(counts, strconv.FormatUint(s.Count-below, 10))
+}
+
+// NewOTLPMetricsWorker exports a snapshot of registry every interval.
+func NewOTLPMetricsWorker(registry *MetricsRegistry, exporter *OTLPMetricsExporter, interval time.Duration) *Worker[[]MetricSample] {
+	list := func(context.Context) ([][]MetricSample, error) {
+		return [][]MetricSample{registry.Snapshot()}, nil
+	}
+	return NewWorker("otlp-metrics", interval, list, exporter.Export)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOTLPCollector records the metrics requests it receives.
+type fakeOTLPCollector struct {
+	mu       sync.Mutex
+	requests []otlpMetricsRequest
+}
+
+func (c *fakeOTLPCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request otlpMetricsRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, request)
+}
+
+func (c *fakeOTLPCollector) last() otlpMetricsRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests[len(c.requests)-1]
+}
+
+func (r otlpMetricsRequest) metric(name string) *otlpMetric {
+	for _, metric := range r.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if metric.Name == name {
+			return &metric
+		}
+	}
+	return nil
+}
+
+func TestOTLPMetricsExporter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Both Exporters Report The Same Counts", func(t *testing.T) {
+		collector := &fakeOTLPCollector{}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		metrics := NewMetricsRegistry()
+		app := fiber.New()
+		(&APIRouter{metrics: metrics}).SetupRoutes(app, Config{MetricsExporters: "prometheus,otlp"})
+		exporter := NewOTLPMetricsExporter(server.URL+"/v1/metrics", "payment-service")
+
+		metrics.Inc("payments_total", Labels{"status": "captured"})
+		metrics.Inc("payments_total", Labels{"status": "captured"})
+		metrics.Observe("latency_seconds", nil, 0.2)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), `payments_total{status="captured"} 2`)
+
+		assert.NoError(t, exporter.Export(ctx, metrics.Snapshot()))
+		payments := collector.last().metric("payments_total")
+		if assert.NotNil(t, payments) && assert.Len(t, payments.Sum.DataPoints, 1) {
+			assert.Equal(t, float64(2), payments.Sum.DataPoints[0].AsDouble)
+			assert.Equal(t, otlpTemporalityCumulative, payments.Sum.AggregationTemporality)
+			assert.Equal(t, []otlpAttribute{{Key: "status", Value: otlpAnyValue{StringValue: "captured"}}}, payments.Sum.DataPoints[0].Attributes)
+		}
+		latency := collector.last().metric("latency_seconds")
+		if assert.NotNil(t, latency) && assert.Len(t, latency.Histogram.DataPoints, 1) {
+			point := latency.Histogram.DataPoints[0]
+			assert.Equal(t, "1", point.Count)
+			assert.Equal(t, defaultLatencyBuckets, point.ExplicitBounds)
+			assert.Len(t, point.BucketCounts, len(defaultLatencyBuckets)+1)
+			assert.Equal(t, "1", point.BucketCounts[5], "0.2 falls in the (0.1, 0.25] bucket only")
+		}
+	})
+
+	t.Run("Repeated Exports Do Not Double Count", func(t *testing.T) {
+		collector := &fakeOTLPCollector{}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		metrics := NewMetricsRegistry()
+		exporter := NewOTLPMetricsExporter(server.URL, "payment-service")
+		metrics.Inc("payments_total", nil)
+
+		assert.NoError(t, exporter.Export(ctx, metrics.Snapshot()))
+		assert.NoError(t, exporter.Export(ctx, metrics.Snapshot()))
+		assert.Equal(t, float64(1), collector.last().metric("payments_total").Sum.DataPoints[0].AsDouble)
+		assert.Equal(t, float64(1), metrics.CounterValue("payments_total", nil))
+	})
+
+	t.Run("Worker Exports Snapshots", func(t *testing.T) {
+		collector := &fakeOTLPCollector{}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		metrics := NewMetricsRegistry()
+		metrics.Inc("payments_total", nil)
+		worker := NewOTLPMetricsWorker(metrics, NewOTLPMetricsExporter(server.URL, "payment-service"), time.Hour)
+
+		items, err := worker.list(ctx)
+		assert.NoError(t, err)
+		for _, item := range items {
+			assert.NoError(t, worker.process(ctx, item))
+		}
+		assert.Equal(t, float64(1), collector.last().metric("payments_total").Sum.DataPoints[0].AsDouble)
+	})
+
+	t.Run("Collector Error Is Returned", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		err := NewOTLPMetricsExporter(server.URL, "payment-service").Export(ctx, nil)
+		assert.ErrorContains(t, err, "503")
+	})
+
+	t.Run("OTLP Only Disables Prometheus Endpoint", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{MetricsExporters: "otlp"})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Config Validation", func(t *testing.T) {
+		assert.NoError(t, Config{MetricsExporters: "prometheus"}.Validate())
+		assert.NoError(t, Config{MetricsExporters: "prometheus, otlp", OTLPMetricsEndpoint: "http://collector:4318/v1/metrics"}.Validate())
+		assert.ErrorContains(t, Config{MetricsExporters: "otlp"}.Validate(), "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT")
+		assert.ErrorContains(t, Config{MetricsExporters: "statsd"}.Validate(), "METRICS_EXPORTERS")
+	})
+}
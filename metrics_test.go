@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	t.Run("Counters", func(t *testing.T) {
+		metrics := NewMetricsRegistry()
+		metrics.Inc("payments_total", Labels{"status": "captured"})
+		metrics.Add("payments_total", Labels{"status": "captured"}, 2)
+		metrics.Inc("payments_total", Labels{"status": "failed"})
+
+		assert.Equal(t, float64(3), metrics.CounterValue("payments_total", Labels{"status": "captured"}))
+		assert.Equal(t, float64(1), metrics.CounterValue("payments_total", Labels{"status": "failed"}))
+		assert.Equal(t, float64(0), metrics.CounterValue("payments_total", Labels{"status": "pending"}))
+	})
+
+	t.Run("Prometheus Endpoint", func(t *testing.T) {
+		metrics := NewMetricsRegistry()
+		metrics.Inc("payments_total", Labels{"status": "captured"})
+		metrics.Observe("latency_seconds", nil, 0.2)
+
+		app := fiber.New()
+		router := &APIRouter{metrics: metrics}
+		router.SetupRoutes(app, Config{})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "# TYPE payments_total counter")
+		assert.Contains(t, string(body), `payments_total{status="captured"} 1`)
+		assert.Contains(t, string(body), `latency_seconds_bucket{le="0.25"} 1`)
+		assert.Contains(t, string(body), `latency_seconds_bucket{le="0.1"} 0`)
+		assert.Contains(t, string(body), "latency_seconds_count 1")
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+// serverMiddleware is one entry of the server-wide middleware chain.
+type serverMiddleware struct {
+	name    string
+	enabled func(Config, middlewareDeps) bool
+	build   func(Config, middlewareDeps) fiber.Handler
+}
+
+// middlewareDeps holds what server-wide middleware takes from the router rather than from config.
+type middlewareDeps struct {
+	// authenticate resolves the request's API key; nil when the router does not authenticate requests.
+	authenticate fiber.Handler
+	// metrics is where request metrics are recorded; nil when the router keeps no metrics.
+	metrics *MetricsRegistry
+}
+
+// middlewareChain lists every server-wide middleware in the order it must run. The order is fixed and each
+// entry is only toggled on or off by config:
+//   - recovery is first so that a panic anywhere below it becomes a 500 instead of killing the connection;
+//   - request_id comes before logger so that log lines carry the request ID;
+//   - tracing comes right after so that its span covers everything but recovery and the request ID;
+//   - metrics comes before cors, api_key, rate_limit and load_shedding so that the requests they answer are
+//     counted too;
+//   - cors answers preflight requests before they count against rate limits or load shedding;
+//   - api_key resolves the caller's API key so that rate limits follow the authenticated merchant;
+//   - rate_limit comes before load_shedding so that a client over its quota never takes a concurrency slot,
+//     while merchants within their own quota still share the global concurrency cap;
+//   - load_shedding sheds before a request's timeout starts, so waiting in line never eats into it;
+//   - timeout comes after them so that its deadline covers only the route-level middleware and the handler;
+//   - simulated_latency is last so that its delay counts against that deadline, like a slow gateway would.
+//
+// api_key is on unless MIDDLEWARE_API_KEY turns authentication off, which also drops the rejection of unknown
+// API keys. That rejection, and route-level middleware such as idempotency, run after this chain and before the
+// handlers.
+var middlewareChain = []serverMiddleware{
+	{
+		name:    "recovery",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.MiddlewareRecovery },
+		build:   func(Config, middlewareDeps) fiber.Handler { return recover.New() },
+	},
+	{
+		name:    "request_id",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.MiddlewareRequestID },
+		build:   func(Config, middlewareDeps) fiber.Handler { return NewRequestIDMiddleware() },
+	},
+	{
+		name:    "tracing",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.MiddlewareTracing },
+		build:   func(Config, middlewareDeps) fiber.Handler { return NewTracingMiddleware(logSpan) },
+	},
+	{
+		name:    "logger",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.MiddlewareLogger },
+		build: func(c Config, _ middlewareDeps) fiber.Handler {
+			return newAccessLogger(c, logOutput(c, os.Stdout))
+		},
+	},
+	{
+		name:    "metrics",
+		enabled: func(c Config, deps middlewareDeps) bool { return c.MiddlewareMetrics && deps.metrics != nil },
+		build:   func(_ Config, deps middlewareDeps) fiber.Handler { return NewRequestMetricsMiddleware(deps.metrics) },
+	},
+	{
+		name:    "cors",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.MiddlewareCORS },
+		build: func(c Config, _ middlewareDeps) fiber.Handler {
+			return cors.New(cors.Config{AllowOrigins: c.CORSAllowOrigins})
+		},
+	},
+	{
+		name: "api_key",
+		enabled: func(c Config, deps middlewareDeps) bool {
+			return !c.MiddlewareAPIKeyDisabled && deps.authenticate != nil
+		},
+		build: func(_ Config, deps middlewareDeps) fiber.Handler { return deps.authenticate },
+	},
+	{
+		name:    "rate_limit",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.rateLimited() },
+		build: func(c Config, _ middlewareDeps) fiber.Handler {
+			return NewRateLimitMiddleware(newMerchantRateLimiter(c), merchantKey, c.RetryAfterJitter)
+		},
+	},
+	{
+		name:    "load_shedding",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.MaxConcurrentRequests > 0 },
+		build: func(c Config, _ middlewareDeps) fiber.Handler {
+			return NewConcurrencyLimiter(c.MaxConcurrentRequests, RetryAfter{Base: defaultShedRetryAfter, Jitter: c.RetryAfterJitter})
+		},
+	},
+	{
+		name:    "timeout",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.RequestTimeout > 0 || c.RouteTimeouts != "" },
+		build: func(c Config, _ middlewareDeps) fiber.Handler {
+			// Validate has already rejected malformed overrides.
+			routes, _ := parseRouteTimeouts(c.RouteTimeouts)
+			return NewRequestTimeout(c.RequestTimeout, routes)
+		},
+	},
+	{
+		name:    "simulated_latency",
+		enabled: func(c Config, _ middlewareDeps) bool { return c.SimulatedLatency != "" && !c.IsProduction() },
+		build: func(c Config, _ middlewareDeps) fiber.Handler {
+			// Validate has already rejected a malformed delay.
+			latency, _ := parseSimulatedLatency(c.SimulatedLatency)
+			return NewSimulatedLatencyMiddleware(latency)
+		},
+	},
+}
+
+// enabledMiddlewares returns the middleware chain the config turns on, in execution order.
+func enabledMiddlewares(config Config, deps middlewareDeps) []serverMiddleware {
+	var enabled []serverMiddleware
+	for _, middleware := range middlewareChain {
+		if middleware.enabled(config, deps) {
+			enabled = append(enabled, middleware)
+		}
+	}
+	return enabled
+}
+
+// useMiddlewares installs the enabled server-wide middleware on app, for a router that neither authenticates
+// nor keeps metrics.
+func useMiddlewares(app *fiber.App, config Config) {
+	installMiddlewares(app, config, middlewareDeps{})
+}
+
+// installMiddlewares installs the enabled server-wide middleware on app.
+func installMiddlewares(app *fiber.App, config Config, deps middlewareDeps) {
+	for _, middleware := range enabledMiddlewares(config, deps) {
+		app.Use(middleware.build(config, deps))
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// routerDeps stands in for a router that both authenticates and keeps metrics.
+var routerDeps = middlewareDeps{
+	authenticate: func(c *fiber.Ctx) error { return c.Next() },
+	metrics:      NewMetricsRegistry(),
+}
+
+func middlewareNames(config Config) []string {
+	var names []string
+	for _, middleware := range enabledMiddlewares(config, routerDeps) {
+		names = append(names, middleware.name)
+	}
+	return names
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	t.Run("Order Is Preserved", func(t *testing.T) {
+		config := Config{
+			MiddlewareRecovery:    true,
+			MiddlewareRequestID:   true,
+			MiddlewareTracing:     true,
+			MiddlewareLogger:      true,
+			MiddlewareMetrics:     true,
+			MiddlewareCORS:        true,
+			MaxConcurrentRequests: 10,
+		}
+		assert.Equal(t, []string{"recovery", "request_id", "tracing", "logger", "metrics", "cors", "api_key", "load_shedding"}, middlewareNames(config))
+	})
+
+	t.Run("Disabled Middleware Is Absent", func(t *testing.T) {
+		config := Config{MiddlewareRecovery: true, MiddlewareCORS: true, MiddlewareAPIKeyDisabled: true}
+		assert.Equal(t, []string{"recovery", "cors"}, middlewareNames(config))
+	})
+
+	t.Run("Router Stages Need The Router", func(t *testing.T) {
+		config := Config{MiddlewareMetrics: true}
+		assert.Equal(t, []string{"metrics", "api_key"}, middlewareNames(config))
+		assert.Empty(t, enabledMiddlewares(config, middlewareDeps{}))
+	})
+
+	t.Run("Defaults From Env", func(t *testing.T) {
+		config := (&Env{}).Load()
+		assert.Equal(t, []string{"recovery", "request_id", "logger", "api_key", "timeout"}, middlewareNames(config))
+	})
+
+	t.Run("Metrics Count Requests By Route", func(t *testing.T) {
+		router := &APIRouter{}
+		server := NewServer(Config{MiddlewareMetrics: true}, router)
+		for i := 0; i < 2; i++ {
+			resp, err := server.app.Test(httptest.NewRequest(http.MethodGet, "/payments/"+uuid.NewString(), nil))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		}
+		assert.Equal(t, float64(2), router.metrics.CounterValue(httpRequestsMetric, Labels{"method": "GET", "route": "/payments/:id", "status": "404"}))
+		assert.Equal(t, uint64(2), router.metrics.HistogramCount(httpRequestLatencyMetric, Labels{"method": "GET", "route": "/payments/:id"}))
+	})
+
+	t.Run("API Key Authentication Can Be Turned Off", func(t *testing.T) {
+		send := func(config Config) int {
+			server := NewServer(config, &APIRouter{})
+			req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer sk_unknown")
+			resp, err := server.app.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+		assert.Equal(t, http.StatusUnauthorized, send(Config{}))
+		assert.Equal(t, http.StatusOK, send(Config{MiddlewareAPIKeyDisabled: true}), "the key is ignored")
+
+		assert.ErrorContains(t, Config{Env: "production", MiddlewareAPIKeyDisabled: true}.Validate(), "MIDDLEWARE_API_KEY")
+	})
+
+	t.Run("Recovery Turns Panics Into 500", func(t *testing.T) {
+		app := fiber.New()
+		useMiddlewares(app, Config{MiddlewareRecovery: true})
+		app.Get("/panic", func(*fiber.Ctx) error { panic("boom") })
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/panic", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("Request ID Header When Enabled", func(t *testing.T) {
+		app := fiber.New()
+		useMiddlewares(app, Config{MiddlewareRequestID: true})
+		app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.Header.Get(fiber.HeaderXRequestID))
+	})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrCurrencyMismatch is returned when an operation combines Money values of different currencies.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrAmountOverflow is returned when Money arithmetic would overflow the int64 minor-unit amount.
+var ErrAmountOverflow = errors.New("amount overflow")
+
+// Money represents an amount in minor units (e.g. satang, cents) together with its ISO 4217 currency code.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NewMoney creates a Money value for the given minor-unit amount, normalizing the currency code to upper case.
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(strings.TrimSpace(currency))}
+}
+
+// Add returns the sum of m and other, failing with ErrCurrencyMismatch when the currencies differ.
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Subtract returns m minus other, failing with ErrCurrencyMismatch when the currencies differ.
+func (m Money) Subtract(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, ErrAmountOverflow
+	}
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// Compare returns -1, 0, or 1 when m is less than, equal to, or greater than other, failing when the currencies differ.
+func (m Money) Compare(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the Money value as "<amount> <currency>" in minor units.
+func (m Money) String() string {
+	return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+}
+
+// Decimal formats the amount in major units using the currency's exponent, e.g. 12345 THB as "123.45".
+func (m Money) Decimal() string {
+	exponent := CurrencyExponent(m.Currency)
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if exponent == 0 {
+		return fmt.Sprintf("%s%d", sign, amount)
+	}
+	scale := int64(1)
+	for i := 0; i < exponent; i++ {
+		scale *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, exponent, amount%scale)
+}
+
+func (m Money) sameCurrency(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return nil
+}
+
+// SumMoney adds the given values in the given currency, used for order and line-item totals.
+func SumMoney(currency string, values ...Money) (Money, error) {
+	total := NewMoney(0, currency)
+	for _, v := range values {
+		var err error
+		if total, err = total.Add(v); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// currencyExponents lists ISO 4217 currencies whose minor unit is not two decimal places.
+var currencyExponents = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KMF": 0,
+	"KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// CurrencyExponent returns the number of decimal places of the currency's minor unit, defaulting to 2.
+func CurrencyExponent(currency string) int {
+	if exp, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// ErrInvalidAmount is returned when an amount string cannot be parsed for its currency.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ParseDecimalAmount parses a major-unit decimal string such as "1050.25" into Money using the currency's exponent.
+// More fractional digits than the currency allows are rejected rather than rounded.
+func ParseDecimalAmount(value, currency string) (Money, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, frac, _ := strings.Cut(value, ".")
+	exp := CurrencyExponent(currency)
+	if whole == "" || !isDigits(whole) || !isDigits(frac) || len(frac) > exp {
+		return Money{}, fmt.Errorf("%w: %q for %s", ErrInvalidAmount, value, strings.ToUpper(currency))
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	var amount int64
+	for _, ch := range whole + frac {
+		digit := int64(ch - '0')
+		if amount > (math.MaxInt64-digit)/10 {
+			return Money{}, ErrAmountOverflow
+		}
+		amount = amount*10 + digit
+	}
+	if negative {
+		amount = -amount
+	}
+	return NewMoney(amount, currency), nil
+}
+
+func isDigits(s string) bool {
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoneyArithmetic(t *testing.T) {
+	t.Run("Add Same Currency", func(t *testing.T) {
+		sum, err := NewMoney(1050, "thb").Add(NewMoney(250, "THB"))
+		assert.NoError(t, err)
+		assert.Equal(t, NewMoney(1300, "THB"), sum)
+	})
+
+	t.Run("Subtract Same Currency", func(t *testing.T) {
+		diff, err := NewMoney(1050, "THB").Subtract(NewMoney(2000, "THB"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-950), diff.Amount)
+		assert.True(t, diff.IsNegative())
+	})
+
+	t.Run("Compare Same Currency", func(t *testing.T) {
+		cmp, err := NewMoney(100, "USD").Compare(NewMoney(200, "USD"))
+		assert.NoError(t, err)
+		assert.Equal(t, -1, cmp)
+
+		cmp, err = NewMoney(200, "USD").Compare(NewMoney(200, "USD"))
+		assert.NoError(t, err)
+		assert.Equal(t, 0, cmp)
+	})
+
+	t.Run("Sum Order Lines", func(t *testing.T) {
+		total, err := SumMoney("THB", NewMoney(100, "THB"), NewMoney(250, "THB"), NewMoney(50, "THB"))
+		assert.NoError(t, err)
+		assert.Equal(t, NewMoney(400, "THB"), total)
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		_, err := NewMoney(math.MaxInt64, "THB").Add(NewMoney(1, "THB"))
+		assert.ErrorIs(t, err, ErrAmountOverflow)
+	})
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	thb := NewMoney(100, "THB")
+	usd := NewMoney(100, "USD")
+
+	_, err := thb.Add(usd)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = thb.Subtract(usd)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = thb.Compare(usd)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = SumMoney("THB", thb, usd)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	assert.Equal(t, "123.45", NewMoney(12345, "THB").Decimal())
+	assert.Equal(t, "0.05", NewMoney(5, "USD").Decimal())
+	assert.Equal(t, "-1.50", NewMoney(-150, "USD").Decimal())
+	assert.Equal(t, "500", NewMoney(500, "JPY").Decimal())
+	assert.Equal(t, "1.234", NewMoney(1234, "KWD").Decimal())
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// webhookDeliveryTimeout bounds one outbound notification request.
+const webhookDeliveryTimeout = 10 * time.Second
+
+// parseNotificationHosts parses NOTIFICATION_URL_ALLOWED_HOSTS, a comma-separated list of host names where
+// "*.example.com" allows any subdomain of example.com.
+func parseNotificationHosts(spec string) []string {
+	var hosts []string
+	for _, host := range strings.Split(spec, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// validateNotificationURL checks a per-payment notification_url: it must be an absolute https URL, or http
+// outside production, without credentials and on one of the allowed hosts, so clients cannot make us call
+// arbitrary addresses.
+func validateNotificationURL(raw string, allowedHosts []string, allowHTTP bool) error {
+	return validateClientURL("notification_url", raw, allowedHosts, allowHTTP)
+}
+
+// validateClientURL checks a URL a client supplied in field the way validateNotificationURL does.
+func validateClientURL(field, raw string, allowedHosts []string, allowHTTP bool) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && !(allowHTTP && parsed.Scheme == "http")) {
+		if allowHTTP {
+			return fmt.Errorf("%s must be an absolute http or https URL", field)
+		}
+		return fmt.Errorf("%s must be an absolute https URL", field)
+	}
+	if parsed.User != nil {
+		return fmt.Errorf("%s must not contain credentials", field)
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range allowedHosts {
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s host %q is not allowed", field, host)
+}
+
+// notificationHost returns the host name of a notification URL that validateNotificationURL accepted.
+func notificationHost(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+// WebhookSender POSTs event payloads to webhook URLs, signed with X-Webhook-Signature when a secret is set.
+type WebhookSender struct {
+	Client *http.Client
+	Secret string
+}
+
+// Send delivers payload to endpointURL and returns the response status and the start of the response body.
+func (s *WebhookSender) Send(ctx context.Context, endpointURL string, payload []byte) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Secret != "" {
+		req.Header.Set(HeaderWebhookSignature, SignWebhookPayload(s.Secret, payload, time.Now()))
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: webhookDeliveryTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return resp.StatusCode, string(body), nil
+}
+
+// webhookEventPayload is the body of an outbound payment notification.
+type webhookEventPayload struct {
+	ID         string          `json:"id"`
+	Type       EventType       `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       PaymentResponse `json:"data"`
+}
+
+// NotificationPublisher publishes events with next and then notifies the payment's notification_url, if it
+// has one, and the webhook endpoint of the payment's merchant, if it is active and subscribed to the event
+// type. A failed notification is recorded in the delivery log rather than returned, so one unreachable
+// endpoint cannot hold up the outbox for every other payment.
+type NotificationPublisher struct {
+	next       EventPublisher
+	store      PaymentStore
+	deliveries DeliveryLog
+	sender     *WebhookSender
+	webhooks   *WebhookRegistry
+}
+
+// NewNotificationPublisher creates a NotificationPublisher in front of next.
+func NewNotificationPublisher(next EventPublisher, store PaymentStore, deliveries DeliveryLog, sender *WebhookSender, webhooks *WebhookRegistry) *NotificationPublisher {
+	return &NotificationPublisher{next: next, store: store, deliveries: deliveries, sender: sender, webhooks: webhooks}
+}
+
+// Publish implements EventPublisher.
+func (p *NotificationPublisher) Publish(ctx context.Context, event PaymentEvent) error {
+	if err := p.next.Publish(ctx, event); err != nil {
+		return err
+	}
+	payment, err := p.store.Get(ctx, event.PaymentID)
+	if err != nil {
+		return nil
+	}
+	endpointURL := p.merchantEndpoint(payment.MerchantID, event.Type)
+	if payment.NotificationURL == "" && endpointURL == "" {
+		return nil
+	}
+	payload, err := json.Marshal(webhookEventPayload{
+		ID:         event.ID,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+		Data:       newPaymentResponse(payment),
+	})
+	if err != nil {
+		return err
+	}
+
+	if payment.NotificationURL != "" {
+		if err := p.deliver(ctx, event, payment, "", payment.NotificationURL, payload); err != nil {
+			return err
+		}
+	}
+	if endpointURL != "" {
+		return p.deliver(ctx, event, payment, payment.MerchantID, endpointURL, payload)
+	}
+	return nil
+}
+
+// merchantEndpoint returns the URL of the merchant's active webhook endpoint when it subscribes to eventType.
+func (p *NotificationPublisher) merchantEndpoint(merchantID string, eventType EventType) string {
+	if p.webhooks == nil || merchantID == "" {
+		return ""
+	}
+	endpoint, ok := p.webhooks.Get(merchantID)
+	if !ok || endpoint.Status != WebhookEndpointActive || !endpoint.Subscribes(eventType) {
+		return ""
+	}
+	return endpoint.URL
+}
+
+// deliver sends payload to endpointURL and records the attempt.
+func (p *NotificationPublisher) deliver(ctx context.Context, event PaymentEvent, payment Payment, merchantID, endpointURL string, payload []byte) error {
+	delivery := WebhookDelivery{
+		ID:          uuid.NewString(),
+		MerchantID:  merchantID,
+		PaymentID:   payment.ID,
+		EventType:   event.Type,
+		URL:         endpointURL,
+		AttemptedAt: time.Now().UTC(),
+	}
+	status, body, err := p.sender.Send(ctx, endpointURL, payload)
+	delivery.StatusCode = status
+	delivery.ResponseBody = body
+	delivery.Succeeded = err == nil && status >= 200 && status <= 299
+	if !delivery.Succeeded {
+		log.Printf("Notification delivery failed event_id=%s payment_id=%s status=%d err=%v", event.ID, payment.ID, status, err)
+	}
+	return p.deliveries.Record(ctx, delivery)
+}
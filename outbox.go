@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// defaultOutboxBatchSize is how many events the relay publishes per run when OUTBOX_BATCH_SIZE is not set.
+	defaultOutboxBatchSize = 100
+	// defaultOutboxFlushLimit caps how many events one POST /admin/outbox/flush publishes by default.
+	defaultOutboxFlushLimit = 1000
+)
+
+// OutboxMessage is a payment event waiting to be published to the message broker.
+type OutboxMessage struct {
+	Event       PaymentEvent
+	CreatedAt   time.Time
+	PublishedAt *time.Time
+}
+
+// OutboxStore holds events recorded alongside payment changes until the relay has published them.
+type OutboxStore interface {
+	Add(ctx context.Context, message OutboxMessage) error
+	// Pending returns up to limit unpublished messages, oldest first; a limit of 0 returns all of them.
+	Pending(ctx context.Context, limit int) ([]OutboxMessage, error)
+	MarkPublished(ctx context.Context, eventID string, at time.Time) error
+}
+
+// MemoryOutbox is an OutboxStore that keeps messages in memory.
+type MemoryOutbox struct {
+	messages memoryCollection[OutboxMessage]
+}
+
+// NewMemoryOutbox creates an empty MemoryOutbox.
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{}
+}
+
+// Add implements OutboxStore.
+func (o *MemoryOutbox) Add(_ context.Context, message OutboxMessage) error {
+	o.messages.add(message)
+	return nil
+}
+
+// Pending implements OutboxStore.
+func (o *MemoryOutbox) Pending(_ context.Context, limit int) ([]OutboxMessage, error) {
+	pending := o.messages.filter(func(m OutboxMessage) bool { return m.PublishedAt == nil })
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+// MarkPublished implements OutboxStore.
+func (o *MemoryOutbox) MarkPublished(_ context.Context, eventID string, at time.Time) error {
+	o.messages.update(func(m OutboxMessage) bool { return m.Event.ID == eventID }, func(m OutboxMessage) OutboxMessage {
+		m.PublishedAt = &at
+		return m
+	})
+	return nil
+}
+
+// EventPublisher delivers payment events to the message broker.
+type EventPublisher interface {
+	Publish(ctx context.Context, event PaymentEvent) error
+}
+
+// logEventPublisher stands in for a broker by logging each event; it is used when no broker is configured.
+type logEventPublisher struct{}
+
+// Publish implements EventPublisher.
+func (logEventPublisher) Publish(_ context.Context, event PaymentEvent) error {
+	log.Printf("Published event id=%s type=%s payment_id=%s", event.ID, event.Type, event.PaymentID)
+	return nil
+}
+
+// OutboxRelay publishes pending outbox messages in the order they were recorded. Runs are serialized, so the
+// background worker and a manual flush never publish the same message twice.
+type OutboxRelay struct {
+	mu        sync.Mutex
+	outbox    OutboxStore
+	publisher EventPublisher
+}
+
+// NewOutboxRelay creates a relay publishing messages from outbox with publisher.
+func NewOutboxRelay(outbox OutboxStore, publisher EventPublisher) *OutboxRelay {
+	return &OutboxRelay{outbox: outbox, publisher: publisher}
+}
+
+// Flush publishes up to limit pending messages and returns how many were published. It stops at the first
+// failure so that events are never published out of order; the rest stay pending for the next run.
+func (r *OutboxRelay) Flush(ctx context.Context, limit int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending, err := r.outbox.Pending(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for _, message := range pending {
+		if err := r.publisher.Publish(ctx, message.Event); err != nil {
+			return published, fmt.Errorf("publish event %s: %w", message.Event.ID, err)
+		}
+		if err := r.outbox.MarkPublished(ctx, message.Event.ID, time.Now().UTC()); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// NewOutboxRelayWorker creates the worker that publishes one batch of batchSize pending events every interval.
+func NewOutboxRelayWorker(relay *OutboxRelay, interval time.Duration, batchSize int) *Worker[int] {
+	list := func(context.Context) ([]int, error) { return []int{batchSize}, nil }
+	process := func(ctx context.Context, limit int) error {
+		_, err := relay.Flush(ctx, limit)
+		return err
+	}
+	return NewWorker("outbox-relay", interval, list, process)
+}
+
+// enqueueEvent records event in the outbox for the relay to publish.
+func (r *APIRouter) enqueueEvent(ctx context.Context, event PaymentEvent) {
+	if err := r.outbox.Add(ctx, OutboxMessage{Event: event, CreatedAt: event.OccurredAt}); err != nil {
+		log.Printf("Failed to add event %s to the outbox: %v", event.ID, err)
+	}
+}
+
+// outboxFlushLimit is the most events one manual flush publishes.
+func (r *APIRouter) outboxFlushLimit() int {
+	if r.config.OutboxFlushLimit > 0 {
+		return r.config.OutboxFlushLimit
+	}
+	return defaultOutboxFlushLimit
+}
+
+// flushOutbox publishes the pending outbox backlog right away, e.g. after the relay fell behind or a
+// batch failed. ?limit= lowers the number of events published, which is capped by OUTBOX_FLUSH_LIMIT so a
+// large backlog does not overwhelm the broker.
+func (r *APIRouter) flushOutbox(c *fiber.Ctx) error {
+	limit := r.outboxFlushLimit()
+	if raw := c.Query("limit"); raw != "" {
+		requested, err := strconv.Atoi(raw)
+		if err != nil || requested < 1 {
+			return respondError(c, ErrCodeInvalidRequest, "limit must be a positive integer")
+		}
+		limit = min(requested, limit)
+	}
+
+	ctx := c.UserContext()
+	published, err := r.relay.Flush(ctx, limit)
+	if err != nil {
+		return respondError(c, ErrCodeServiceUnavailable, fmt.Sprintf("published %d events before failing: %v", published, err))
+	}
+	pending, err := r.outbox.Pending(ctx, 0)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to count pending events")
+	}
+	return c.JSON(fiber.Map{
+		"published": published,
+		"pending":   len(pending),
+	})
+}
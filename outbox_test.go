@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingPublisher struct {
+	mu        sync.Mutex
+	published []PaymentEvent
+	failAfter int
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event PaymentEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failAfter > 0 && len(p.published) >= p.failAfter {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
+func TestOutboxFlush(t *testing.T) {
+	ctx := context.Background()
+	newApp := func(publisher *recordingPublisher, config Config) (*fiber.App, *APIRouter) {
+		app := fiber.New()
+		router := &APIRouter{publisher: publisher}
+		config.AdminToken = "admin-secret"
+		router.SetupRoutes(app, config)
+		for _, id := range []string{"pay_1", "pay_2", "pay_3"} {
+			router.recordEvent(ctx, id, EventPaymentCreated)
+		}
+		return app, router
+	}
+	flush := func(t *testing.T, app *fiber.App, query string) (*http.Response, map[string]int) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/outbox/flush"+query, nil)
+		req.Header.Set(HeaderAdminToken, "admin-secret")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]int
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		}
+		return resp, result
+	}
+
+	t.Run("Pending Backlog Published", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		app, router := newApp(publisher, Config{})
+		resp, result := flush(t, app, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, map[string]int{"published": 3, "pending": 0}, result)
+		if assert.Len(t, publisher.published, 3) {
+			assert.Equal(t, "pay_1", publisher.published[0].PaymentID)
+			assert.Equal(t, "pay_3", publisher.published[2].PaymentID)
+		}
+		pending, _ := router.outbox.Pending(ctx, 0)
+		assert.Empty(t, pending)
+
+		// A second flush has nothing left to publish.
+		_, result = flush(t, app, "")
+		assert.Equal(t, 0, result["published"])
+		assert.Len(t, publisher.published, 3)
+	})
+
+	t.Run("Limit Caps Flush", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		app, _ := newApp(publisher, Config{OutboxFlushLimit: 2})
+		_, result := flush(t, app, "?limit=10")
+		assert.Equal(t, map[string]int{"published": 2, "pending": 1}, result)
+
+		_, result = flush(t, app, "?limit=1")
+		assert.Equal(t, map[string]int{"published": 1, "pending": 0}, result)
+	})
+
+	t.Run("Invalid Limit Rejected", func(t *testing.T) {
+		app, _ := newApp(&recordingPublisher{}, Config{})
+		resp, _ := flush(t, app, "?limit=0")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Publish Failure Keeps Rest Pending", func(t *testing.T) {
+		publisher := &recordingPublisher{failAfter: 1}
+		app, router := newApp(publisher, Config{})
+		resp, _ := flush(t, app, "")
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		pending, _ := router.outbox.Pending(ctx, 0)
+		if assert.Len(t, pending, 2) {
+			assert.Equal(t, "pay_2", pending[0].Event.PaymentID)
+		}
+	})
+}
+
+func TestOutboxRelayWorker(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewMemoryOutbox()
+	for _, id := range []string{"evt_1", "evt_2", "evt_3"} {
+		assert.NoError(t, outbox.Add(ctx, OutboxMessage{Event: PaymentEvent{ID: id}}))
+	}
+	publisher := &recordingPublisher{}
+	worker := NewOutboxRelayWorker(NewOutboxRelay(outbox, publisher), time.Second, 2)
+
+	worker.runBatch(ctx)
+	assert.Len(t, publisher.published, 2)
+	pending, _ := outbox.Pending(ctx, 0)
+	assert.Len(t, pending, 1)
+}
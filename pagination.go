@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Page sizes used when the config does not set them.
+const (
+	defaultPageSize    = 50
+	defaultMaxPageSize = 200
+)
+
+// Page is the limit/offset window requested by a list endpoint's query parameters.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// parsePage reads ?limit= and ?offset= with the configured default and maximum page size. A limit above the
+// maximum is clamped, or rejected when PageSizeRejectOverMax is set.
+func (r *APIRouter) parsePage(c *fiber.Ctx) (Page, error) {
+	defaultSize, maxSize := r.config.PageSizeDefault, r.config.PageSizeMax
+	if maxSize <= 0 {
+		maxSize = defaultMaxPageSize
+	}
+	if defaultSize <= 0 {
+		defaultSize = min(defaultPageSize, maxSize)
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", strconv.Itoa(defaultSize)))
+	if err != nil || limit <= 0 {
+		return Page{}, fmt.Errorf("limit must be a positive integer")
+	}
+	if limit > maxSize {
+		if r.config.PageSizeRejectOverMax {
+			return Page{}, fmt.Errorf("limit must not exceed %d", maxSize)
+		}
+		limit = maxSize
+	}
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		return Page{}, fmt.Errorf("offset must be a non-negative integer")
+	}
+	return Page{Limit: limit, Offset: offset}, nil
+}
+
+// respondPage writes the page of items as the standard list envelope {"data", "total", "has_more"}.
+func respondPage[T any](c *fiber.Ctx, items []T, page Page) error {
+	total := len(items)
+	start := min(page.Offset, total)
+	end := min(start+page.Limit, total)
+	data := items[start:end]
+	if data == nil {
+		data = []T{}
+	}
+	return c.JSON(fiber.Map{
+		"data":     data,
+		"total":    total,
+		"has_more": end < total,
+	})
+}
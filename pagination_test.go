@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPagination(t *testing.T) {
+	type listResponse struct {
+		Data    []PaymentResponse `json:"data"`
+		Total   int               `json:"total"`
+		HasMore bool              `json:"has_more"`
+	}
+	newApp := func(config Config) *fiber.App {
+		store := NewMemoryPaymentStore()
+		for i := 0; i < 5; i++ {
+			seedCapturedPayment(t, store, fmt.Sprintf("pay_%d", i), 1000, "")
+		}
+		app := fiber.New()
+		(&APIRouter{store: store}).SetupRoutes(app, config)
+		return app
+	}
+	list := func(app *fiber.App, query string) (*http.Response, listResponse) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/payments"+query, nil))
+		assert.NoError(t, err)
+		var body listResponse
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp, body
+	}
+
+	t.Run("Default Applied", func(t *testing.T) {
+		_, body := list(newApp(Config{PageSizeDefault: 2, PageSizeMax: 3}), "")
+		assert.Len(t, body.Data, 2)
+		assert.Equal(t, 5, body.Total)
+		assert.True(t, body.HasMore)
+	})
+
+	t.Run("Explicit Size Honored", func(t *testing.T) {
+		_, body := list(newApp(Config{PageSizeDefault: 2, PageSizeMax: 3}), "?limit=3&offset=3")
+		assert.Len(t, body.Data, 2)
+		assert.Equal(t, "pay_3", body.Data[0].ID)
+		assert.False(t, body.HasMore)
+	})
+
+	t.Run("Over Max Clamped", func(t *testing.T) {
+		resp, body := list(newApp(Config{PageSizeDefault: 2, PageSizeMax: 3}), "?limit=100")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Len(t, body.Data, 3)
+	})
+
+	t.Run("Over Max Rejected", func(t *testing.T) {
+		resp, _ := list(newApp(Config{PageSizeDefault: 2, PageSizeMax: 3, PageSizeRejectOverMax: true}), "?limit=100")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Invalid Offset", func(t *testing.T) {
+		resp, _ := list(newApp(Config{}), "?offset=-1")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import "time"
+
+// PaymentStatus is the lifecycle state of a payment.
+type PaymentStatus string
+
+const (
+	// PaymentStatusPending is a payment that has been created but not yet authorized.
+	PaymentStatusPending PaymentStatus = "pending"
+	// PaymentStatusAuthorized is a payment whose funds are reserved but not yet captured.
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	// PaymentStatusCaptured is a payment whose funds have been settled.
+	PaymentStatusCaptured PaymentStatus = "captured"
+	// PaymentStatusFailed is a payment that was declined or errored.
+	PaymentStatusFailed PaymentStatus = "failed"
+	// PaymentStatusCanceled is a payment that was canceled before capture.
+	PaymentStatusCanceled PaymentStatus = "canceled"
+	// PaymentStatusExpired is an asynchronous payment the customer did not complete before its deadline.
+	PaymentStatusExpired PaymentStatus = "expired"
+	// PaymentStatusVerified is a verify-only payment whose card passed the zero-amount check.
+	PaymentStatusVerified PaymentStatus = "verified"
+	// PaymentStatusInReview is a payment the risk scorer held for manual review before authorization.
+	PaymentStatusInReview PaymentStatus = "in_review"
+)
+
+// Payment represents a single payment and its current state.
+type Payment struct {
+	ID        string
+	Amount    int64
+	Currency  string
+	Reference string
+	// Description is free text the merchant attaches to the payment; it can be changed after creation.
+	Description string
+	// ReferenceNumber is the short generated reference customers quote in bank transfers; unique per payment.
+	ReferenceNumber string
+	Method          string
+	CustomerID      string
+	Status          PaymentStatus
+	CardToken       string
+	Metadata        map[string]string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	CapturedAt      *time.Time
+	// EstimatedSettlementDate is the business date (YYYY-MM-DD) a captured payment's funds are expected to arrive.
+	EstimatedSettlementDate string
+	// ExpiresAt is the deadline for the customer to complete an asynchronous (QR/transfer) payment.
+	ExpiresAt *time.Time
+	// AuthorizationExpiresAt is when the gateway is expected to release an authorized payment's funds; an
+	// authorization still uncaptured by then is voided.
+	AuthorizationExpiresAt *time.Time
+
+	GatewayReference string
+	AmountRefunded   int64
+	DeclineReason    string
+	VerifyOnly       bool
+
+	// ApprovalCode and NetworkTransactionID are the gateway-reported identifiers support staff quote to issuers
+	// and card networks when tracing a transaction; empty when the gateway does not provide them.
+	ApprovalCode         string
+	NetworkTransactionID string
+
+	// MerchantID is the merchant whose API key created the payment; its webhook endpoint receives the events.
+	MerchantID string
+
+	// Version is the number of times the payment has been saved; the store rejects a save of a stale version,
+	// so concurrent read-modify-write cycles cannot overwrite each other.
+	Version int64
+
+	StatementDescriptor string
+	CardBrand           string
+	IssuerCountry       string
+	BillingCountry      string
+	CardLast4           string
+	CardExpMonth        int
+	CardExpYear         int
+
+	GatewayMetadata map[string]string
+	LineItems       []LineItem
+	// Splits divide a marketplace payment between the platform and its recipients once it is captured.
+	Splits []Split
+	// NotificationURL receives this payment's event notifications in addition to the merchant's webhook.
+	NotificationURL string
+	// ReturnURLs are where hosted flows send the customer back to.
+	ReturnURLs ReturnURLs
+	// TestMode marks a payment created with a test API key; it went to the sandbox gateway and is kept out of
+	// live listings, reports and the ledger.
+	TestMode bool
+	// CaptureMode records whether the payment is captured right after authorization or awaits a capture call.
+	CaptureMode CaptureMode
+	// IdempotencyKey is the client's Idempotency-Key the payment was created with, kept for support lookups.
+	IdempotencyKey string
+}
+
+// Money returns the payment amount as a currency-safe Money value.
+func (p Payment) Money() Money {
+	return NewMoney(p.Amount, p.Currency)
+}
+
+// RefundedMoney returns the amount already refunded as a currency-safe Money value.
+func (p Payment) RefundedMoney() Money {
+	return NewMoney(p.AmountRefunded, p.Currency)
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+// CreatePaymentRequest is the body accepted by POST /payments.
+type CreatePaymentRequest struct {
+	Amount     AmountInput       `json:"amount"`
+	Currency   string            `json:"currency"`
+	Reference  string            `json:"reference"`
+	Method     string            `json:"method"`
+	Token      string            `json:"token"`
+	CustomerID string            `json:"customer_id"`
+	Metadata   map[string]string `json:"metadata"`
+	// Description is free text shown to the merchant; it can be changed later with PATCH /payments/:id.
+	Description string `json:"description"`
+	// VerifyOnly checks that the card is valid without charging it; the amount must then be zero.
+	VerifyOnly bool `json:"verify_only"`
+	// CardBIN is the card's leading 6-8 digits as reported by the tokenizer, used for issuer lookup.
+	CardBIN string `json:"card_bin"`
+	// CardLast4, CardExpMonth and CardExpYear describe the tokenized card for display.
+	CardLast4    string `json:"card_last4"`
+	CardExpMonth int    `json:"card_exp_month"`
+	CardExpYear  int    `json:"card_exp_year"`
+	// GatewayMetadata is passed through to the gateway; its keys must be ones the gateway accepts.
+	GatewayMetadata map[string]string `json:"gateway_metadata"`
+	// LineItems itemize the payment so refunds can target specific items; they may not exceed the amount.
+	LineItems []lineItemInput `json:"line_items"`
+	// Splits share a marketplace payment with recipients by amount or percentage; they may not exceed the amount.
+	Splits []splitInput `json:"splits"`
+	// CaptureMode is "automatic" to capture right after authorization or "manual" to leave the payment
+	// authorized for POST /payments/:id/capture; empty uses the configured default.
+	CaptureMode CaptureMode `json:"capture_mode"`
+	// NotificationURL receives the payment's signed event notifications; its host must be allowlisted.
+	NotificationURL string `json:"notification_url"`
+	// ReturnURLs are where a hosted QR page or 3DS challenge sends the customer back to; their hosts must be
+	// allowlisted.
+	ReturnURLs ReturnURLs `json:"return_urls"`
+	// BillingCountry is the payer's ISO 3166-1 alpha-2 billing country, if known.
+	BillingCountry string `json:"billing_country"`
+}
+
+// Verification outcomes reported for verify-only payments.
+const (
+	VerificationVerified = "verified"
+	VerificationDeclined = "declined"
+)
+
+// PaymentResponse is the JSON representation of a payment returned by the API.
+type PaymentResponse struct {
+	ID              string            `json:"id"`
+	Status          PaymentStatus     `json:"status"`
+	Amount          int64             `json:"amount"`
+	Currency        string            `json:"currency"`
+	Reference       string            `json:"reference,omitempty"`
+	Description     string            `json:"description,omitempty"`
+	ReferenceNumber string            `json:"reference_number"`
+	Method          string            `json:"method,omitempty"`
+	CustomerID      string            `json:"customer_id,omitempty"`
+	AmountRefunded  int64             `json:"amount_refunded"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Verification    string            `json:"verification,omitempty"`
+	DeclineReason   string            `json:"decline_reason,omitempty"`
+
+	StatementDescriptor string `json:"statement_descriptor,omitempty"`
+	CardBrand           string `json:"card_brand,omitempty"`
+	IssuerCountry       string `json:"issuer_country,omitempty"`
+	BillingCountry      string `json:"billing_country,omitempty"`
+	NotificationURL     string `json:"notification_url,omitempty"`
+	// Card is limited to brand, last4 and expiry; a full card number is never returned.
+	Card      *CardDetails       `json:"card,omitempty"`
+	LineItems []LineItemResponse `json:"line_items,omitempty"`
+	Splits    []SplitResponse    `json:"splits,omitempty"`
+	TestMode  bool               `json:"test_mode"`
+
+	CaptureMode CaptureMode `json:"capture_mode,omitempty"`
+	ReturnURLs  *ReturnURLs `json:"return_urls,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+
+	AuthorizationExpiresAt  *time.Time `json:"authorization_expires_at,omitempty"`
+	EstimatedSettlementDate string     `json:"estimated_settlement_date,omitempty"`
+
+	ApprovalCode         string `json:"approval_code,omitempty"`
+	NetworkTransactionID string `json:"network_transaction_id,omitempty"`
+
+	MerchantID string `json:"merchant_id,omitempty"`
+}
+
+// newPaymentResponse is the only place a Payment is serialized for clients. It masks card numbers in free-text
+// fields regardless of what was stored, so one leaking into a reference or metadata never reaches a response.
+func newPaymentResponse(payment Payment) PaymentResponse {
+	response := PaymentResponse{
+		ID:              payment.ID,
+		Status:          payment.Status,
+		Amount:          payment.Amount,
+		Currency:        payment.Currency,
+		Reference:       maskPANs(payment.Reference),
+		Description:     maskPANs(payment.Description),
+		ReferenceNumber: payment.ReferenceNumber,
+		Method:          payment.Method,
+		CustomerID:      payment.CustomerID,
+		AmountRefunded:  payment.AmountRefunded,
+		Metadata:        maskPANsInMap(payment.Metadata),
+		DeclineReason:   payment.DeclineReason,
+
+		StatementDescriptor: payment.StatementDescriptor,
+		CardBrand:           payment.CardBrand,
+		IssuerCountry:       payment.IssuerCountry,
+		BillingCountry:      payment.BillingCountry,
+		NotificationURL:     payment.NotificationURL,
+		Card:                newCardDetails(payment),
+		LineItems:           newLineItemResponses(payment.LineItems),
+		Splits:              newSplitResponses(payment.Splits),
+		TestMode:            payment.TestMode,
+		CaptureMode:         payment.CaptureMode,
+
+		CreatedAt:  payment.CreatedAt,
+		CapturedAt: payment.CapturedAt,
+		ExpiresAt:  payment.ExpiresAt,
+
+		AuthorizationExpiresAt:  payment.AuthorizationExpiresAt,
+		EstimatedSettlementDate: payment.EstimatedSettlementDate,
+
+		ApprovalCode:         payment.ApprovalCode,
+		NetworkTransactionID: payment.NetworkTransactionID,
+
+		MerchantID: payment.MerchantID,
+	}
+	if !payment.ReturnURLs.IsZero() {
+		response.ReturnURLs = &payment.ReturnURLs
+	}
+	if payment.VerifyOnly {
+		response.Verification = VerificationDeclined
+		if payment.Status == PaymentStatusVerified {
+			response.Verification = VerificationVerified
+		}
+	}
+	return response
+}
+
+func (r *APIRouter) createPayment(c *fiber.Ctx) error {
+	if r.config.RequireIdempotencyKey && c.Get(HeaderIdempotencyKey) == "" {
+		return respondError(c, ErrCodeIdempotencyKeyRequired, "Idempotency-Key header is required for payment creation")
+	}
+	var req CreatePaymentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	amountMode := r.amountInputMode(req.Currency)
+	amount, err := req.Amount.MinorUnits(amountMode, req.Currency)
+	if err != nil {
+		return respondError(c, ErrCodeInvalidAmount, err.Error())
+	}
+	if req.VerifyOnly && amount != 0 {
+		return respondError(c, ErrCodeInvalidAmount, "amount must be zero for verify-only payments")
+	}
+	if !req.VerifyOnly && amount <= 0 {
+		return respondError(c, ErrCodeInvalidAmount, "amount must be a positive integer in minor units")
+	}
+	if req.Currency == "" {
+		return respondError(c, ErrCodeInvalidCurrency, "currency is required")
+	}
+	if err := ValidateCurrencyCode(req.Currency); err != nil {
+		return respondError(c, ErrCodeInvalidCurrency, err.Error())
+	}
+	if req.NotificationURL != "" {
+		allowed := parseNotificationHosts(r.config.NotificationURLAllowedHosts)
+		if err := validateNotificationURL(req.NotificationURL, allowed, !r.config.IsProduction()); err != nil {
+			return respondError(c, ErrCodeValidationFailed, err.Error())
+		}
+		if err := r.outboundPolicy().CheckHost(notificationHost(req.NotificationURL)); err != nil {
+			return respondError(c, ErrCodeValidationFailed, "notification_url must not point at an internal address")
+		}
+	}
+	if err := r.validateReturnURLs(req.ReturnURLs); err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+	if req.BillingCountry != "" {
+		if err := ValidateCountryCode(req.BillingCountry); err != nil {
+			return respondError(c, ErrCodeInvalidCountry, err.Error())
+		}
+	}
+	if req.CaptureMode != "" && !validCaptureMode(req.CaptureMode) {
+		return respondError(c, ErrCodeValidationFailed, "capture_mode must be automatic or manual")
+	}
+	if err := validateDescription(req.Description); err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+	if err := newMetadataLimits(r.config).Validate(req.Metadata); err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+	testMode := requestTestMode(c)
+	descriptor, err := r.statementDescriptor(r.gatewayFor(testMode), req.Metadata)
+	if err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+	if err := validateGatewayMetadata(r.gatewayFor(testMode), req.GatewayMetadata); err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+	lineItems, err := newLineItems(req.LineItems, amountMode, req.Currency, amount)
+	if err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+	fee := PlatformFee(NewMoney(amount, req.Currency), r.config.PlatformFeeBasisPoints)
+	splits, err := newSplits(req.Splits, amountMode, req.Currency, amount, fee.Amount)
+	if err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+	issuer := r.resolveBIN(req.CardBIN)
+
+	now := time.Now().UTC()
+	payment := Payment{
+		ID:         uuid.NewString(),
+		Amount:     amount,
+		Currency:   NewMoney(0, req.Currency).Currency,
+		Reference:  req.Reference,
+		Method:     req.Method,
+		CustomerID: req.CustomerID,
+		Status:     PaymentStatusPending,
+		CardToken:  req.Token,
+		Metadata:   req.Metadata,
+		VerifyOnly: req.VerifyOnly,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+
+		Description:         req.Description,
+		StatementDescriptor: descriptor,
+		CardBrand:           issuer.Brand,
+		IssuerCountry:       issuer.Country,
+		BillingCountry:      strings.ToUpper(strings.TrimSpace(req.BillingCountry)),
+		NotificationURL:     req.NotificationURL,
+		ReturnURLs:          req.ReturnURLs,
+		CardLast4:           lastDigits(req.CardLast4, 4),
+		CardExpMonth:        req.CardExpMonth,
+		CardExpYear:         req.CardExpYear,
+		GatewayMetadata:     req.GatewayMetadata,
+		LineItems:           lineItems,
+		Splits:              splits,
+		IdempotencyKey:      utils.CopyString(c.Get(HeaderIdempotencyKey)),
+		TestMode:            testMode,
+		CaptureMode:         r.captureMode(req.CaptureMode),
+
+		MerchantID: requestMerchantID(c),
+	}
+	ctx := c.UserContext()
+	payment, err = r.saveNewPayment(ctx, payment)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to save payment")
+	}
+	r.recordEvent(ctx, payment.ID, EventPaymentCreated)
+
+	clientKey := payment.IdempotencyKey
+	if clientKey != "" && r.config.LogIdempotencyKeys {
+		log.Printf("Idempotency key correlated key=%q payment_id=%s", clientKey, payment.ID)
+	}
+	switch {
+	case payment.VerifyOnly:
+		payment, err = r.verifyCard(ctx, payment, clientKey)
+	case r.riskDecision(ctx, payment) == RiskReview:
+		payment, err = r.holdForReview(ctx, payment)
+		if err != nil {
+			return respondError(c, ErrCodeInternal, "failed to hold payment for review")
+		}
+	default:
+		payment, err = r.proceedPayment(ctx, payment, clientKey)
+	}
+	if errors.Is(err, ErrMethodUnavailable) {
+		return respondMethodUnavailable(c,
+			fmt.Sprintf("payment method %q is temporarily unavailable; other methods are not affected", payment.Method),
+			r.config.RetryAfterJitter)
+	}
+	var maintenance *MaintenanceError
+	if errors.As(err, &maintenance) {
+		return respondGatewayMaintenance(c, maintenance, r.config.RetryAfterJitter)
+	}
+	if err != nil {
+		return respondError(c, ErrCodeGatewayError, "payment gateway error")
+	}
+
+	r.setLocation(c, "payments", payment.ID)
+	return c.Status(fiber.StatusCreated).JSON(newPaymentResponse(payment))
+}
+
+func (r *APIRouter) listPayments(c *fiber.Ctx) error {
+	page, err := r.parsePage(c)
+	if err != nil {
+		return respondError(c, ErrCodeInvalidRequest, err.Error())
+	}
+	payments, err := r.listPaymentsMatching(c, c.Query("reference_number"))
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to list payments")
+	}
+	responses := make([]PaymentResponse, 0, len(payments))
+	for _, payment := range payments {
+		responses = append(responses, newPaymentResponse(payment))
+	}
+	return respondPage(c, responses, page)
+}
+
+// getPayment returns one payment. Payment IDs are UUIDs, so anything else is rejected before the store is
+// queried. A payment the request may not see is reported as not found, like one that does not exist.
+func (r *APIRouter) getPayment(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "payment ID must be a UUID")
+	}
+	payment, err := r.loadVisiblePayment(c)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return respondError(c, ErrCodeNotFound, "payment not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	return c.JSON(newPaymentResponse(payment))
+}
+
+// listPaymentsMatching lists the payments the request may see, or only the one holding referenceNumber when it is
+// set. Test and live data never appear in the same listing.
+func (r *APIRouter) listPaymentsMatching(c *fiber.Ctx, referenceNumber string) ([]Payment, error) {
+	ctx := c.UserContext()
+	var payments []Payment
+	if referenceNumber == "" {
+		all, err := r.store.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		payments = all
+	} else {
+		payment, err := r.store.GetByReferenceNumber(ctx, referenceNumber)
+		if errors.Is(err, ErrPaymentNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		payments = []Payment{payment}
+	}
+	matching := payments[:0]
+	for _, payment := range payments {
+		if paymentVisible(c, payment) {
+			matching = append(matching, payment)
+		}
+	}
+	return matching, nil
+}
+
+// loadVisiblePayment loads the payment named by the id route parameter. A payment the request may not see is
+// reported as ErrPaymentNotFound, so that no route reveals or acts on another mode's or merchant's payment.
+func (r *APIRouter) loadVisiblePayment(c *fiber.Ctx) (Payment, error) {
+	payment, err := r.store.Get(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return Payment{}, err
+	}
+	if !paymentVisible(c, payment) {
+		return Payment{}, ErrPaymentNotFound
+	}
+	return payment, nil
+}
+
+// paymentVisible reports whether the request may see payment.
+func paymentVisible(c *fiber.Ctx, payment Payment) bool {
+	return visibleToRequest(c, payment.TestMode, payment.MerchantID)
+}
+
+// visibleToRequest reports whether the request may see a resource created in testMode for merchantID: only
+// resources of its API key's mode, and with a merchant's own key only that merchant's.
+func visibleToRequest(c *fiber.Ctx, testMode bool, merchantID string) bool {
+	if testMode != requestTestMode(c) {
+		return false
+	}
+	own := requestMerchantID(c)
+	return own == "" || merchantID == own
+}
+
+// authorizePayment reserves the payment's funds at the gateway and stores the outcome.
+func (r *APIRouter) authorizePayment(ctx context.Context, payment Payment, clientKey string) (Payment, error) {
+	gateway := r.gatewayFor(payment.TestMode)
+	result, err := gateway.Authorize(ctx, AuthorizeRequest{
+		PaymentID:      payment.ID,
+		IdempotencyKey: GatewayIdempotencyKey(payment.ID, GatewayOpAuthorize, merchantClientKey(payment.MerchantID, clientKey)),
+		Amount:         payment.Money(),
+		Method:         payment.Method,
+		Token:          payment.CardToken,
+
+		StatementDescriptor: payment.StatementDescriptor,
+		CardBrand:           payment.CardBrand,
+		IssuerCountry:       payment.IssuerCountry,
+		GatewayMetadata:     payment.GatewayMetadata,
+		ReturnURLs:          payment.ReturnURLs,
+	})
+	if err != nil {
+		return payment, err
+	}
+
+	before := payment
+	payment.GatewayReference = result.GatewayReference
+	payment.UpdatedAt = time.Now().UTC()
+	if result.Pending {
+		// The customer still has to pay; the payment status poller captures or expires it.
+		expiresAt := payment.UpdatedAt.Add(r.asyncPaymentExpiry())
+		payment.ExpiresAt = &expiresAt
+		err := r.updatePayment(ctx, before, &payment)
+		return payment, err
+	}
+
+	event := EventPaymentAuthorized
+	payment.Status = PaymentStatusAuthorized
+	if result.Approved {
+		authorizationExpiresAt := payment.UpdatedAt.Add(r.authorizationValidity(gateway))
+		payment.AuthorizationExpiresAt = &authorizationExpiresAt
+		payment.ApprovalCode = result.ApprovalCode
+		payment.NetworkTransactionID = result.NetworkTransactionID
+	} else {
+		event = EventPaymentFailed
+		payment.Status = PaymentStatusFailed
+		payment.DeclineReason = result.DeclineReason
+	}
+	if err := r.updatePayment(ctx, before, &payment); err != nil {
+		return payment, err
+	}
+	r.recordEvent(ctx, payment.ID, event)
+	return payment, nil
+}
+
+// proceedPayment authorizes a payment and, in automatic capture mode, captures it once authorized.
+func (r *APIRouter) proceedPayment(ctx context.Context, payment Payment, clientKey string) (Payment, error) {
+	payment, err := r.authorizePayment(ctx, payment, clientKey)
+	if err == nil && payment.Status == PaymentStatusAuthorized && payment.CaptureMode == CaptureAutomatic {
+		// A failed capture leaves the payment authorized, so it can still be captured later.
+		payment, err = r.capturePayment(ctx, payment, clientKey)
+	}
+	return payment, err
+}
+
+// asyncPaymentExpiry is how long a customer has to complete an asynchronous payment.
+func (r *APIRouter) asyncPaymentExpiry() time.Duration {
+	if r.config.AsyncPaymentExpiry > 0 {
+		return r.config.AsyncPaymentExpiry
+	}
+	return defaultAsyncPaymentExpiry
+}
+
+// verifyCard checks the card with a zero-amount authorization, or with the gateway's minimum amount followed
+// by an immediate void for gateways that do not accept zero. A verified payment never moves to captured.
+func (r *APIRouter) verifyCard(ctx context.Context, payment Payment, clientKey string) (Payment, error) {
+	amount := NewMoney(0, payment.Currency)
+	gateway := r.gatewayFor(payment.TestMode)
+	if requirer, ok := gatewayAs[VerificationAmountRequirer](gateway); ok {
+		amount.Amount = requirer.MinimumVerificationAmount(payment.Currency)
+	}
+
+	result, err := gateway.Authorize(ctx, AuthorizeRequest{
+		PaymentID:      payment.ID,
+		IdempotencyKey: GatewayIdempotencyKey(payment.ID, GatewayOpAuthorize, merchantClientKey(payment.MerchantID, clientKey)),
+		Amount:         amount,
+		Method:         payment.Method,
+		Token:          payment.CardToken,
+
+		CardBrand:     payment.CardBrand,
+		IssuerCountry: payment.IssuerCountry,
+	})
+	if err != nil {
+		return payment, err
+	}
+
+	before := payment
+	payment.GatewayReference = result.GatewayReference
+	if result.Approved && !amount.IsZero() {
+		err = gateway.Void(ctx, VoidRequest{
+			PaymentID:        payment.ID,
+			IdempotencyKey:   GatewayIdempotencyKey(payment.ID, GatewayOpVoid, merchantClientKey(payment.MerchantID, clientKey)),
+			GatewayReference: result.GatewayReference,
+			Method:           payment.Method,
+			Currency:         payment.Currency,
+		})
+		if err != nil {
+			return payment, err
+		}
+	}
+
+	payment.Status = PaymentStatusVerified
+	if !result.Approved {
+		payment.Status = PaymentStatusFailed
+		payment.DeclineReason = result.DeclineReason
+	}
+	payment.UpdatedAt = time.Now().UTC()
+	if err := r.updatePayment(ctx, before, &payment); err != nil {
+		return payment, err
+	}
+	return payment, nil
+}
+
+// recordEvent appends a payment event; failures are not fatal to the request that triggered them.
+func (r *APIRouter) recordEvent(ctx context.Context, paymentID string, eventType EventType) {
+	r.appendEvent(ctx, paymentID, eventType, nil)
+}
+
+func (r *APIRouter) appendEvent(ctx context.Context, paymentID string, eventType EventType, changes []string) {
+	event := PaymentEvent{
+		ID:         uuid.NewString(),
+		PaymentID:  paymentID,
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Changes:    changes,
+	}
+	if err := r.events.Append(ctx, event); err != nil {
+		return
+	}
+	r.enqueueEvent(ctx, event)
+}
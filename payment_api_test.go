@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func postPayment(t *testing.T, app *fiber.App, body string, headers map[string]string) (*http.Response, PaymentResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var payment PaymentResponse
+	_ = json.NewDecoder(resp.Body).Decode(&payment)
+	return resp, payment
+}
+
+func TestVerifyOnlyPayments(t *testing.T) {
+	t.Run("Verified Card", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		gateway := NewSandboxGateway("sandbox")
+		app := fiber.New()
+		router := &APIRouter{store: store, gateway: gateway}
+		router.SetupRoutes(app, Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":0,"currency":"THB","method":"card","token":"tok_visa","verify_only":true}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, PaymentStatusVerified, payment.Status)
+		assert.Equal(t, VerificationVerified, payment.Verification)
+		assert.Equal(t, 0, gateway.Processed(GatewayOpCapture))
+
+		stored, err := store.Get(context.Background(), payment.ID)
+		assert.NoError(t, err)
+		assert.NotEqual(t, PaymentStatusCaptured, stored.Status)
+	})
+
+	t.Run("Declined Verification", func(t *testing.T) {
+		app := fiber.New()
+		router := &APIRouter{}
+		router.SetupRoutes(app, Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":0,"currency":"THB","method":"card","token":"tok_declined","verify_only":true}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, PaymentStatusFailed, payment.Status)
+		assert.Equal(t, VerificationDeclined, payment.Verification)
+		assert.Equal(t, "card_declined", payment.DeclineReason)
+	})
+
+	t.Run("Gateway Requiring Minimal Amount", func(t *testing.T) {
+		gateway := NewSandboxGateway("sandbox")
+		gateway.SetMinimumVerificationAmount(100)
+		app := fiber.New()
+		router := &APIRouter{gateway: gateway}
+		router.SetupRoutes(app, Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":0,"currency":"THB","method":"card","token":"tok_visa","verify_only":true}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, VerificationVerified, payment.Verification)
+		assert.Equal(t, 1, gateway.Processed(GatewayOpVoid))
+	})
+
+	t.Run("Non-Zero Amount Rejected", func(t *testing.T) {
+		app := fiber.New()
+		router := &APIRouter{}
+		router.SetupRoutes(app, Config{})
+
+		resp, _ := postPayment(t, app, `{"amount":100,"currency":"THB","verify_only":true}`, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+}
+
+func TestApprovalCodes(t *testing.T) {
+	t.Run("Stored And Returned", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		app := fiber.New()
+		(&APIRouter{store: store, gateway: NewSandboxGateway("sandbox")}).SetupRoutes(app, Config{})
+
+		resp, payment := postPayment(t, app, `{"amount":10000,"currency":"THB","capture_mode":"manual"}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Regexp(t, `^\d{6}$`, payment.ApprovalCode)
+		assert.NotEmpty(t, payment.NetworkTransactionID)
+
+		_, captured := postCaptureRequest(t, app, payment.ID)
+		assert.Equal(t, payment.ApprovalCode, captured.ApprovalCode)
+		assert.Equal(t, payment.NetworkTransactionID, captured.NetworkTransactionID)
+
+		stored, err := store.Get(context.Background(), payment.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.ApprovalCode, stored.ApprovalCode)
+		receipt, err := RenderReceipt(stored)
+		assert.NoError(t, err)
+		assert.Contains(t, receipt, "Approval:  "+payment.ApprovalCode)
+	})
+
+	t.Run("Absent When Gateway Does Not Provide Them", func(t *testing.T) {
+		gateway := NewSandboxGateway("sandbox")
+		gateway.SetApprovalCodes(false)
+		store := NewMemoryPaymentStore()
+		app := fiber.New()
+		(&APIRouter{store: store, gateway: gateway}).SetupRoutes(app, Config{})
+
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount":10000,"currency":"THB","capture_mode":"automatic"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, string(PaymentStatusCaptured), body["status"])
+		assert.NotContains(t, body, "approval_code")
+		assert.NotContains(t, body, "network_transaction_id")
+
+		stored, err := store.Get(context.Background(), body["id"].(string))
+		assert.NoError(t, err)
+		receipt, err := RenderReceipt(stored)
+		assert.NoError(t, err)
+		assert.NotContains(t, receipt, "Approval:")
+	})
+
+	t.Run("Declined Payment Has No Approval Code", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{})
+
+		_, payment := postPayment(t, app, `{"amount":10000,"currency":"THB","token":"tok_declined"}`, nil)
+		assert.Equal(t, PaymentStatusFailed, payment.Status)
+		assert.Empty(t, payment.ApprovalCode)
+		assert.Empty(t, payment.NetworkTransactionID)
+	})
+}
+
+func TestRequireIdempotencyKey(t *testing.T) {
+	newApp := func(required bool) *fiber.App {
+		router := &APIRouter{}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{RequireIdempotencyKey: required})
+		return app
+	}
+	body := `{"amount":1000,"currency":"THB"}`
+
+	t.Run("Required Mode Rejects Missing Key", func(t *testing.T) {
+		resp, _ := postPayment(t, newApp(true), body, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Required Mode Accepts Present Key", func(t *testing.T) {
+		resp, _ := postPayment(t, newApp(true), body, map[string]string{HeaderIdempotencyKey: "key-1"})
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+
+	t.Run("Optional Mode Accepts Both", func(t *testing.T) {
+		app := newApp(false)
+		resp, _ := postPayment(t, app, body, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		resp, _ = postPayment(t, app, body, map[string]string{HeaderIdempotencyKey: "key-1"})
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+}
+
+func TestCreatePaymentValidation(t *testing.T) {
+	app := fiber.New()
+	(&APIRouter{}).SetupRoutes(app, Config{})
+
+	cases := []struct {
+		name    string
+		body    string
+		status  int
+		code    ErrorCode
+		message string
+	}{
+		{"Valid", `{"amount":1000,"currency":"THB","reference":"order-1","method":"card"}`, http.StatusCreated, "", ""},
+		{"Malformed JSON", `{"amount":1000,`, http.StatusBadRequest, ErrCodeInvalidRequest, "invalid request body"},
+		{"Wrong Field Type", `{"amount":1000,"currency":42}`, http.StatusBadRequest, ErrCodeInvalidRequest, "invalid request body"},
+		{"Missing Amount", `{"currency":"THB"}`, http.StatusUnprocessableEntity, ErrCodeInvalidAmount, "amount must be a positive integer"},
+		{"Zero Amount", `{"amount":0,"currency":"THB"}`, http.StatusUnprocessableEntity, ErrCodeInvalidAmount, "amount must be a positive integer"},
+		{"Fractional Amount", `{"amount":10.5,"currency":"THB"}`, http.StatusUnprocessableEntity, ErrCodeInvalidAmount, "amount"},
+		{"Missing Currency", `{"amount":1000}`, http.StatusUnprocessableEntity, ErrCodeInvalidCurrency, "currency is required"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, resp.StatusCode)
+
+			if tc.code == "" {
+				var payment PaymentResponse
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+				assert.NotEmpty(t, payment.ID)
+				assert.Equal(t, PaymentStatusAuthorized, payment.Status)
+				assert.Equal(t, "order-1", payment.Reference)
+				assert.False(t, payment.CreatedAt.IsZero())
+				assert.Equal(t, "/payments/"+payment.ID, resp.Header.Get(fiber.HeaderLocation))
+				return
+			}
+			var body struct {
+				Error string    `json:"error"`
+				Code  ErrorCode `json:"code"`
+			}
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tc.code, body.Code)
+			assert.Contains(t, body.Error, tc.message)
+		})
+	}
+}
+
+func TestNewAPIRouter(t *testing.T) {
+	store := NewMemoryPaymentStore()
+	app := fiber.New()
+	NewAPIRouter(store).SetupRoutes(app, Config{})
+
+	resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB"}`, nil)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	saved, err := store.Get(context.Background(), payment.ID)
+	assert.NoError(t, err, "handlers write to the injected store")
+	assert.Equal(t, int64(1000), saved.Amount)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/payments/pay_missing/timeline", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// failingGetStore fails every Get, as a database outage would.
+type failingGetStore struct {
+	PaymentStore
+}
+
+func (s failingGetStore) Get(ctx context.Context, id string) (Payment, error) {
+	return Payment{}, errors.New("connection refused")
+}
+
+func TestGetPayment(t *testing.T) {
+	store := NewMemoryPaymentStore()
+	app := fiber.New()
+	NewAPIRouter(store).SetupRoutes(app, Config{})
+	get := func(t *testing.T, app *fiber.App, id string) (*http.Response, map[string]any) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/payments/"+id, nil))
+		assert.NoError(t, err)
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp, body
+	}
+
+	t.Run("Existing Payment", func(t *testing.T) {
+		_, created := postPayment(t, app, `{"amount":1000,"currency":"THB","reference":"order-1"}`, nil)
+
+		resp, body := get(t, app, created.ID)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, created.ID, body["id"])
+		assert.Equal(t, "order-1", body["reference"])
+		assert.Equal(t, string(created.Status), body["status"])
+	})
+
+	t.Run("Missing Payment", func(t *testing.T) {
+		resp, body := get(t, app, "8f14e45f-ceea-467f-a8f5-4c7d5a1a9e2b")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "payment not found", body["error"])
+	})
+
+	t.Run("Malformed ID", func(t *testing.T) {
+		for _, id := range []string{"pay_123", "8f14e45f-ceea-467f", "%20"} {
+			resp, body := get(t, app, id)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, id)
+			assert.Equal(t, string(ErrCodeInvalidRequest), body["code"], id)
+		}
+	})
+
+	t.Run("Store Failure Is Not A 404", func(t *testing.T) {
+		app := fiber.New()
+		NewAPIRouter(failingGetStore{store}).SetupRoutes(app, Config{})
+
+		resp, body := get(t, app, "8f14e45f-ceea-467f-a8f5-4c7d5a1a9e2b")
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, string(ErrCodeInternal), body["code"])
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+)
+
+// paymentChanges lists the material fields that differ between two versions of a payment, by their API names.
+// Bookkeeping such as UpdatedAt is ignored, so re-saving an unchanged payment yields no changes.
+func paymentChanges(before, after Payment) []string {
+	var changes []string
+	changed := func(field string, differs bool) {
+		if differs {
+			changes = append(changes, field)
+		}
+	}
+	changed("status", before.Status != after.Status)
+	changed("amount", before.Amount != after.Amount)
+	changed("currency", before.Currency != after.Currency)
+	changed("amount_refunded", before.AmountRefunded != after.AmountRefunded)
+	changed("reference", before.Reference != after.Reference)
+	changed("description", before.Description != after.Description)
+	changed("customer_id", before.CustomerID != after.CustomerID)
+	changed("metadata", !maps.Equal(before.Metadata, after.Metadata))
+	changed("decline_reason", before.DeclineReason != after.DeclineReason)
+	changed("statement_descriptor", before.StatementDescriptor != after.StatementDescriptor)
+	changed("gateway_reference", before.GatewayReference != after.GatewayReference)
+	changed("approval_code", before.ApprovalCode != after.ApprovalCode)
+	changed("network_transaction_id", before.NetworkTransactionID != after.NetworkTransactionID)
+	changed("captured_at", !timesEqual(before.CapturedAt, after.CapturedAt))
+	changed("expires_at", !timesEqual(before.ExpiresAt, after.ExpiresAt))
+	changed("line_items", !slices.Equal(before.LineItems, after.LineItems))
+	return changes
+}
+
+func timesEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// updatePayment stores a modified payment, advancing after to the stored version, and records payment.updated
+// with the fields that changed. A save that changes no material field, such as one caused by a replayed
+// webhook, publishes no event. It returns ErrPaymentVersionConflict when the payment changed since before
+// was read.
+func (r *APIRouter) updatePayment(ctx context.Context, before Payment, after *Payment) error {
+	if err := r.store.Save(ctx, *after); err != nil {
+		return err
+	}
+	if after.Version > 0 {
+		after.Version++
+	}
+	if changes := paymentChanges(before, *after); len(changes) > 0 {
+		r.appendEvent(ctx, after.ID, EventPaymentUpdated, changes)
+	}
+	return nil
+}
+
+// maxPaymentUpdateAttempts bounds how often retryPaymentUpdate reloads a payment that keeps losing races.
+const maxPaymentUpdateAttempts = 3
+
+// retryPaymentUpdate runs update on payment and, while it fails with ErrPaymentVersionConflict, reloads the
+// payment and runs it again. It suits updates that can be reapplied to whatever the winner stored, such as
+// adding a refund to the refunded total.
+func (r *APIRouter) retryPaymentUpdate(ctx context.Context, payment Payment, update func(Payment) (Payment, error)) (Payment, error) {
+	for attempt := 1; ; attempt++ {
+		updated, err := update(payment)
+		if !errors.Is(err, ErrPaymentVersionConflict) || attempt == maxPaymentUpdateAttempts {
+			return updated, err
+		}
+		if payment, err = r.store.Get(ctx, payment.ID); err != nil {
+			return payment, err
+		}
+	}
+}
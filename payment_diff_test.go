@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatePaymentEvents(t *testing.T) {
+	ctx := context.Background()
+	newRouter := func() (*APIRouter, Payment) {
+		router := &APIRouter{}
+		router.ensureDependencies(Config{})
+		payment := Payment{ID: "pay_1", Amount: 1000, Currency: "THB", Status: PaymentStatusPending, Metadata: map[string]string{"order_id": "A1"}}
+		assert.NoError(t, router.store.Save(ctx, payment))
+		return router, payment
+	}
+	updates := func(router *APIRouter) []PaymentEvent {
+		events, _ := router.events.ListByPayment(ctx, "pay_1")
+		var updated []PaymentEvent
+		for _, event := range events {
+			if event.Type == EventPaymentUpdated {
+				updated = append(updated, event)
+			}
+		}
+		return updated
+	}
+
+	t.Run("Published On Status Change", func(t *testing.T) {
+		router, payment := newRouter()
+
+		changed := payment
+		changed.Status = PaymentStatusAuthorized
+		changed.UpdatedAt = time.Now()
+		assert.NoError(t, router.updatePayment(ctx, payment, &changed))
+
+		recorded := updates(router)
+		assert.Len(t, recorded, 1)
+		assert.Equal(t, []string{"status"}, recorded[0].Changes)
+	})
+
+	t.Run("Suppressed On No-Op Update", func(t *testing.T) {
+		router, payment := newRouter()
+
+		replayed := payment
+		replayed.Metadata = map[string]string{"order_id": "A1"}
+		replayed.UpdatedAt = time.Now()
+		assert.NoError(t, router.updatePayment(ctx, payment, &replayed))
+
+		assert.Empty(t, updates(router))
+		stored, _ := router.store.Get(ctx, "pay_1")
+		assert.False(t, stored.UpdatedAt.IsZero())
+	})
+}
+
+// blockingCaptureGateway holds every capture until release is closed, signaling on captures when one starts.
+type blockingCaptureGateway struct {
+	PaymentGateway
+	captures chan struct{}
+	release  chan struct{}
+}
+
+func (g blockingCaptureGateway) Capture(ctx context.Context, req CaptureRequest) (CaptureResult, error) {
+	g.captures <- struct{}{}
+	<-g.release
+	return g.PaymentGateway.Capture(ctx, req)
+}
+
+func TestPaymentVersioning(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Stale Save Rejected", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		assert.NoError(t, store.Save(ctx, Payment{ID: "pay_1", Amount: 1000, Currency: "THB", Status: PaymentStatusPending}))
+		first, _ := store.Get(ctx, "pay_1")
+		second, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(1), first.Version)
+
+		first.Status = PaymentStatusAuthorized
+		assert.NoError(t, store.Save(ctx, first))
+		second.Status = PaymentStatusFailed
+		assert.ErrorIs(t, store.Save(ctx, second), ErrPaymentVersionConflict)
+
+		stored, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, PaymentStatusAuthorized, stored.Status)
+		assert.Equal(t, int64(2), stored.Version)
+	})
+
+	t.Run("Concurrent Updates Of One Version Let One Win", func(t *testing.T) {
+		router := &APIRouter{}
+		router.ensureDependencies(Config{})
+		assert.NoError(t, router.store.Save(ctx, Payment{ID: "pay_1", Amount: 1000, Currency: "THB", Status: PaymentStatusPending}))
+		payment, _ := router.store.Get(ctx, "pay_1")
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, status := range []PaymentStatus{PaymentStatusAuthorized, PaymentStatusFailed} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				updated := payment
+				updated.Status = status
+				errs[i] = router.updatePayment(ctx, payment, &updated)
+			}()
+		}
+		wg.Wait()
+
+		var conflicts int
+		for _, err := range errs {
+			if errors.Is(err, ErrPaymentVersionConflict) {
+				conflicts++
+			} else {
+				assert.NoError(t, err)
+			}
+		}
+		assert.Equal(t, 1, conflicts)
+	})
+
+	t.Run("Concurrent Refunds Are Both Applied", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		seedCapturedPayment(t, store, "pay_1", 1000, "")
+		router := &APIRouter{store: store}
+		router.ensureDependencies(Config{})
+		payment, _ := store.Get(ctx, "pay_1")
+
+		var wg sync.WaitGroup
+		for _, id := range []string{"ref_1", "ref_2"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, router.applyRefund(ctx, payment, Refund{ID: id, PaymentID: "pay_1", Amount: 300, Currency: "THB"}))
+			}()
+		}
+		wg.Wait()
+
+		stored, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(600), stored.AmountRefunded)
+	})
+
+	newCaptureApp := func() (*fiber.App, PaymentStore, *MemoryLedger, blockingCaptureGateway) {
+		gateway := blockingCaptureGateway{NewSandboxGateway("sandbox"), make(chan struct{}), make(chan struct{})}
+		store := NewMemoryPaymentStore()
+		ledger := NewMemoryLedger()
+		app := fiber.New()
+		(&APIRouter{store: store, gateway: gateway, ledger: ledger}).SetupRoutes(app, Config{})
+		return app, store, ledger, gateway
+	}
+	startCapture := func(app *fiber.App, paymentID string) chan *http.Response {
+		responses := make(chan *http.Response, 1)
+		go func() {
+			resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/payments/"+paymentID+"/capture", nil), -1)
+			assert.NoError(t, err)
+			responses <- resp
+		}()
+		return responses
+	}
+
+	t.Run("Capture Racing A Patch Keeps Both Changes", func(t *testing.T) {
+		app, store, ledger, gateway := newCaptureApp()
+		_, payment := postPayment(t, app, `{"amount":10000,"currency":"THB","capture_mode":"manual"}`, nil)
+
+		captured := startCapture(app, payment.ID)
+		<-gateway.captures
+		resp, _ := patchPaymentRequest(t, app, payment.ID, `{"metadata":{"order_id":"A1"}}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		close(gateway.release)
+		assert.Equal(t, http.StatusOK, (<-captured).StatusCode)
+
+		stored, _ := store.Get(ctx, payment.ID)
+		assert.Equal(t, PaymentStatusCaptured, stored.Status)
+		assert.Equal(t, "A1", stored.Metadata["order_id"])
+		transactions, _ := ledger.ListByPayment(ctx, payment.ID)
+		assert.Len(t, transactions, 1)
+	})
+
+	t.Run("Concurrent Captures Book Once", func(t *testing.T) {
+		app, store, ledger, gateway := newCaptureApp()
+		_, payment := postPayment(t, app, `{"amount":10000,"currency":"THB","capture_mode":"manual"}`, nil)
+		// A second instance over the same store has its own operation locks, so only the versioned save stops
+		// the second capture.
+		other := fiber.New()
+		(&APIRouter{store: store, gateway: gateway, ledger: ledger}).SetupRoutes(other, Config{})
+
+		first := startCapture(app, payment.ID)
+		second := startCapture(other, payment.ID)
+		<-gateway.captures
+		<-gateway.captures
+		close(gateway.release)
+
+		statuses := []int{(<-first).StatusCode, (<-second).StatusCode}
+		assert.ElementsMatch(t, []int{http.StatusOK, http.StatusConflict}, statuses)
+		stored, _ := store.Get(ctx, payment.ID)
+		assert.Equal(t, PaymentStatusCaptured, stored.Status)
+		transactions, _ := ledger.ListByPayment(ctx, payment.ID)
+		assert.Len(t, transactions, 1)
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// defaultPaymentOperationLockWait is how long a capture, void or refund waits for another one on the same payment
+// when PAYMENT_OPERATION_LOCK_WAIT is not set.
+const defaultPaymentOperationLockWait = 5 * time.Second
+
+// PaymentOperationLocks lets one capture, void or refund per payment or payment intent run at a time, keyed by
+// its ID. A lock lives only as long as the operation holding it. Locks are per instance; across instances the
+// versioned payment save still keeps concurrent operations from both being applied.
+type PaymentOperationLocks struct {
+	mu  sync.Mutex
+	ops map[string]*paymentOperation
+}
+
+// paymentOperation is the operation holding a payment's lock.
+type paymentOperation struct {
+	fingerprint string
+	done        chan struct{}
+	// response is what the operation answered, set before done is closed. It is nil when the operation failed
+	// with a server error, so that waiting duplicates try again themselves.
+	response *IdempotencyRecord
+}
+
+// NewPaymentOperationLocks creates an empty set of payment locks.
+func NewPaymentOperationLocks() *PaymentOperationLocks {
+	return &PaymentOperationLocks{ops: make(map[string]*paymentOperation)}
+}
+
+// acquire claims paymentID's lock for the operation identified by fingerprint and returns it with true. When
+// another operation holds the lock, acquire returns that one with false.
+func (l *PaymentOperationLocks) acquire(paymentID, fingerprint string) (*paymentOperation, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if op, ok := l.ops[paymentID]; ok {
+		return op, false
+	}
+	op := &paymentOperation{fingerprint: fingerprint, done: make(chan struct{})}
+	l.ops[paymentID] = op
+	return op, true
+}
+
+// release frees paymentID's lock and hands response to the duplicates waiting on op.
+func (l *PaymentOperationLocks) release(paymentID string, op *paymentOperation, response *IdempotencyRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	op.response = response
+	delete(l.ops, paymentID)
+	close(op.done)
+}
+
+// paymentOperationLockWait returns PAYMENT_OPERATION_LOCK_WAIT, or the default when it is not set.
+func (c Config) paymentOperationLockWait() time.Duration {
+	if c.PaymentOperationLockWait > 0 {
+		return c.PaymentOperationLockWait
+	}
+	return defaultPaymentOperationLockWait
+}
+
+// NewPaymentOperationLock guards a capture, refund or intent cancel route against request storms on one payment
+// or payment intent. The first request takes the lock and proceeds. An identical request from the same sender
+// arriving while it runs waits for it and gets its response replayed, marked with X-Deduplicated, instead of
+// calling the gateway again. Any other operation on the payment waits for the lock and then proceeds. A request still
+// waiting after wait gets 409 with Retry-After.
+func NewPaymentOperationLock(locks *PaymentOperationLocks, wait time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		paymentID := utils.CopyString(c.Params("id"))
+		fingerprint := dedupKey(c)
+		timeout := time.NewTimer(wait)
+		defer timeout.Stop()
+
+		for {
+			op, acquired := locks.acquire(paymentID, fingerprint)
+			if acquired {
+				return runLocked(c, locks, paymentID, op)
+			}
+			select {
+			case <-op.done:
+				if op.fingerprint == fingerprint && op.response != nil {
+					c.Set(HeaderDeduplicated, "true")
+					return replayResponse(c, *op.response)
+				}
+			case <-timeout.C:
+				c.Set(fiber.HeaderRetryAfter, RetryAfter{Base: time.Second}.Header())
+				return respondError(c, ErrCodePaymentOperationInProgress, "another capture, refund or cancellation of this payment is in progress")
+			}
+		}
+	}
+}
+
+// runLocked runs the rest of the chain while holding paymentID's lock. The lock is released even when a handler
+// panics, with a nil response so that waiting duplicates try again themselves.
+func runLocked(c *fiber.Ctx, locks *PaymentOperationLocks, paymentID string, op *paymentOperation) (err error) {
+	var response *IdempotencyRecord
+	defer func() { locks.release(paymentID, op, response) }()
+	err = c.Next()
+	response = lockedResponse(c, err)
+	return err
+}
+
+// lockedResponse copies the response an operation produced for its waiting duplicates, or returns nil after a
+// server error or a retryable conflict.
+func lockedResponse(c *fiber.Ctx, err error) *IdempotencyRecord {
+	if err != nil || retryableResponse(c) {
+		return nil
+	}
+	return &IdempotencyRecord{
+		StatusCode:  c.Response().StatusCode(),
+		ContentType: string(c.Response().Header.ContentType()),
+		Location:    utils.CopyString(c.GetRespHeader(fiber.HeaderLocation)),
+		Body:        append([]byte(nil), c.Response().Body()...),
+	}
+}
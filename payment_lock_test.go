@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentOperationLock(t *testing.T) {
+	ctx := context.Background()
+	newApp := func(config Config) (*fiber.App, *SandboxGateway, blockingCaptureGateway) {
+		sandbox := NewSandboxGateway("sandbox")
+		gateway := blockingCaptureGateway{sandbox, make(chan struct{}), make(chan struct{})}
+		app := fiber.New()
+		(&APIRouter{gateway: gateway}).SetupRoutes(app, config)
+		return app, sandbox, gateway
+	}
+	send := func(app *fiber.App, method, path, body string) chan *http.Response {
+		responses := make(chan *http.Response, 1)
+		go func() {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req, -1)
+			assert.NoError(t, err)
+			responses <- resp
+		}()
+		return responses
+	}
+
+	t.Run("Capture Storm Calls The Gateway Once", func(t *testing.T) {
+		app, sandbox, gateway := newApp(Config{})
+		_, payment := postPayment(t, app, `{"amount":10000,"currency":"THB"}`, nil)
+
+		first := send(app, http.MethodPost, "/payments/"+payment.ID+"/capture", "")
+		<-gateway.captures
+		var duplicates []chan *http.Response
+		for i := 0; i < 20; i++ {
+			duplicates = append(duplicates, send(app, http.MethodPost, "/payments/"+payment.ID+"/capture", ""))
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(gateway.release)
+
+		resp := <-first
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var captured PaymentResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&captured))
+		for _, duplicate := range duplicates {
+			resp := <-duplicate
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "true", resp.Header.Get(HeaderDeduplicated))
+			var replayed PaymentResponse
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&replayed))
+			assert.Equal(t, captured, replayed)
+		}
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpCapture))
+		assert.Len(t, sandbox.ReceivedKeys(GatewayOpCapture), 1)
+	})
+
+	t.Run("Duplicate Past The Lock Wait Gets 409", func(t *testing.T) {
+		app, _, gateway := newApp(Config{PaymentOperationLockWait: 10 * time.Millisecond})
+		_, payment := postPayment(t, app, `{"amount":10000,"currency":"THB"}`, nil)
+
+		first := send(app, http.MethodPost, "/payments/"+payment.ID+"/capture", "")
+		<-gateway.captures
+		resp := <-send(app, http.MethodPost, "/payments/"+payment.ID+"/capture", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, ErrCodePaymentOperationInProgress, decodeErrorCode(t, resp))
+		assert.Equal(t, "1", resp.Header.Get(fiber.HeaderRetryAfter))
+
+		close(gateway.release)
+		assert.Equal(t, http.StatusOK, (<-first).StatusCode)
+	})
+
+	t.Run("Lock Timeout Is Not Replayed For The Same Idempotency Key", func(t *testing.T) {
+		app, sandbox, gateway := newApp(Config{PaymentOperationLockWait: 10 * time.Millisecond})
+		_, payment := postPayment(t, app, `{"amount":10000,"currency":"THB"}`, nil)
+		refund := func() *http.Response {
+			req := httptest.NewRequest(http.MethodPost, "/payments/"+payment.ID+"/refunds", strings.NewReader(`{"amount":3000}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(HeaderIdempotencyKey, "refund-1")
+			resp, err := app.Test(req, -1)
+			assert.NoError(t, err)
+			return resp
+		}
+
+		capture := send(app, http.MethodPost, "/payments/"+payment.ID+"/capture", "")
+		<-gateway.captures
+		resp := refund()
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, ErrCodePaymentOperationInProgress, decodeErrorCode(t, resp))
+		close(gateway.release)
+		assert.Equal(t, http.StatusOK, (<-capture).StatusCode)
+
+		resp = refund()
+		assert.Equal(t, http.StatusCreated, resp.StatusCode, "the retry runs the refund instead of replaying the 409")
+		assert.Empty(t, resp.Header.Get(HeaderIdempotentReplayed))
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpRefund))
+	})
+
+	t.Run("Other Operation Waits For The Lock", func(t *testing.T) {
+		app, sandbox, gateway := newApp(Config{})
+		_, payment := postPayment(t, app, `{"amount":10000,"currency":"THB"}`, nil)
+
+		capture := send(app, http.MethodPost, "/payments/"+payment.ID+"/capture", "")
+		<-gateway.captures
+		refund := send(app, http.MethodPost, "/payments/"+payment.ID+"/refunds", `{"amount":3000}`)
+		time.Sleep(50 * time.Millisecond)
+		close(gateway.release)
+
+		assert.Equal(t, http.StatusOK, (<-capture).StatusCode)
+		resp := <-refund
+		assert.Equal(t, http.StatusCreated, resp.StatusCode, "the refund runs once the capture has finished")
+		assert.Empty(t, resp.Header.Get(HeaderDeduplicated))
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpRefund))
+	})
+
+	t.Run("Expiry Void Skips A Locked Payment", func(t *testing.T) {
+		sandbox := NewSandboxGateway("sandbox")
+		router := &APIRouter{gateway: sandbox}
+		router.ensureDependencies(Config{})
+		expiresAt := time.Now().UTC().Add(-time.Hour)
+		payment := Payment{ID: "pay_1", Amount: 1000, Currency: "THB", Status: PaymentStatusAuthorized, AuthorizationExpiresAt: &expiresAt}
+		assert.NoError(t, router.store.Save(ctx, payment))
+
+		op, acquired := router.operationLocks.acquire(payment.ID, "capture")
+		assert.True(t, acquired)
+		assert.NoError(t, router.voidExpiredAuthorization(ctx, payment))
+		assert.Equal(t, 0, sandbox.Processed(GatewayOpVoid))
+
+		router.operationLocks.release(payment.ID, op, nil)
+		assert.NoError(t, router.voidExpiredAuthorization(ctx, payment))
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpVoid))
+	})
+
+	t.Run("Intent Cancel Takes The Lock", func(t *testing.T) {
+		sandbox := NewSandboxGateway("sandbox")
+		router := &APIRouter{gateway: sandbox}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{PaymentOperationLockWait: 10 * time.Millisecond})
+		now := time.Now().UTC()
+		assert.NoError(t, router.intents.Save(ctx, PaymentIntent{
+			ID: "pi_1", Amount: 1000, Currency: "THB", Status: PaymentIntentStatusRequiresAction,
+			GatewayReference: "sandbox_authorize_1", CreatedAt: now, UpdatedAt: now,
+		}))
+
+		op, acquired := router.operationLocks.acquire("pi_1", "void")
+		assert.True(t, acquired)
+		resp, _ := cancelIntent(t, app, "pi_1", "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Equal(t, 0, sandbox.Processed(GatewayOpVoid))
+
+		router.operationLocks.release("pi_1", op, nil)
+		resp, intent := cancelIntent(t, app, "pi_1", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, PaymentIntentStatusCanceled, intent.Status)
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpVoid))
+	})
+
+	t.Run("Locks Are Released", func(t *testing.T) {
+		locks := NewPaymentOperationLocks()
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					op, acquired := locks.acquire("pay_1", "capture")
+					if acquired {
+						locks.release("pay_1", op, nil)
+						return
+					}
+					<-op.done
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Empty(t, locks.ops)
+	})
+
+	t.Run("Panicking Handler Releases The Lock", func(t *testing.T) {
+		locks := NewPaymentOperationLocks()
+		app := fiber.New()
+		app.Use(recover.New())
+		calls := 0
+		app.Post("/payments/:id/capture", NewPaymentOperationLock(locks, 50*time.Millisecond), func(c *fiber.Ctx) error {
+			calls++
+			if calls == 1 {
+				panic("gateway client bug")
+			}
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/payments/pay_1/capture", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Empty(t, locks.ops)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/payments/pay_1/capture", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "the retry takes the lock instead of waiting out a 409")
+		assert.Empty(t, resp.Header.Get(HeaderDeduplicated))
+	})
+
+	t.Run("Config Validation", func(t *testing.T) {
+		assert.ErrorContains(t, Config{PaymentOperationLockWait: -time.Second}.Validate(), "PAYMENT_OPERATION_LOCK_WAIT")
+		assert.Equal(t, defaultPaymentOperationLockWait, Config{}.paymentOperationLockWait())
+	})
+}
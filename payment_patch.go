@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxDescriptionLength is the longest payment description accepted, in characters.
+const maxDescriptionLength = 1000
+
+// patchablePaymentFields are the payment fields PATCH /payments/:id may change. Every other field is fixed
+// once the payment exists and is rejected with 422.
+var patchablePaymentFields = map[string]bool{"metadata": true, "description": true}
+
+// validateDescription reports whether description is short enough to store.
+func validateDescription(description string) error {
+	if utf8.RuneCountInString(description) > maxDescriptionLength {
+		return fmt.Errorf("description must be at most %d characters", maxDescriptionLength)
+	}
+	return nil
+}
+
+// isJSONNull reports whether raw is the JSON literal null.
+func isJSONNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// mergeMetadata applies a JSON merge patch (RFC 7386) to metadata: null removes all metadata, and within an
+// object a null value removes that key while a string sets it.
+func mergeMetadata(metadata map[string]string, raw json.RawMessage) (map[string]string, error) {
+	if isJSONNull(raw) {
+		return nil, nil
+	}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		return nil, errors.New("metadata must be an object or null")
+	}
+	merged := maps.Clone(metadata)
+	if merged == nil {
+		merged = make(map[string]string, len(patch))
+	}
+	for key, value := range patch {
+		if isJSONNull(value) {
+			delete(merged, key)
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, fmt.Errorf("metadata value for %q must be a string or null", key)
+		}
+		merged[key] = s
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+// patchPayment applies a JSON merge patch to a payment's mutable fields, metadata and description. A patch
+// naming any other field is rejected with 422 without changing anything.
+func (r *APIRouter) patchPayment(c *fiber.Ctx) error {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(c.Body(), &patch); err != nil || patch == nil {
+		return respondError(c, ErrCodeInvalidRequest, "request body must be a JSON object")
+	}
+	var immutable []string
+	for field := range patch {
+		if !patchablePaymentFields[field] {
+			immutable = append(immutable, field)
+		}
+	}
+	if len(immutable) > 0 {
+		sort.Strings(immutable)
+		return respondError(c, ErrCodeValidationFailed, fmt.Sprintf("fields %q cannot be changed; only metadata and description can", immutable))
+	}
+
+	ctx := c.UserContext()
+	payment, err := r.loadVisiblePayment(c)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return respondError(c, ErrCodeNotFound, "payment not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+
+	before := payment
+	if raw, ok := patch["metadata"]; ok {
+		metadata, err := mergeMetadata(payment.Metadata, raw)
+		if err != nil {
+			return respondError(c, ErrCodeValidationFailed, err.Error())
+		}
+		if err := newMetadataLimits(r.config).Validate(metadata); err != nil {
+			return respondError(c, ErrCodeValidationFailed, err.Error())
+		}
+		payment.Metadata = metadata
+	}
+	if raw, ok := patch["description"]; ok {
+		var description string
+		if !isJSONNull(raw) {
+			if err := json.Unmarshal(raw, &description); err != nil {
+				return respondError(c, ErrCodeValidationFailed, "description must be a string or null")
+			}
+		}
+		if err := validateDescription(description); err != nil {
+			return respondError(c, ErrCodeValidationFailed, err.Error())
+		}
+		payment.Description = description
+	}
+
+	if paymentChanges(before, payment) == nil {
+		return c.JSON(newPaymentResponse(payment))
+	}
+	payment.UpdatedAt = time.Now().UTC()
+	err = r.updatePayment(ctx, before, &payment)
+	if errors.Is(err, ErrPaymentVersionConflict) {
+		return respondError(c, ErrCodeConcurrentModification, "payment was changed by another request")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to update payment")
+	}
+	return c.JSON(newPaymentResponse(payment))
+}
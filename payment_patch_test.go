@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func patchPaymentRequest(t *testing.T, app *fiber.App, paymentID, body string) (*http.Response, PaymentResponse) {
+	req := httptest.NewRequest(http.MethodPatch, "/payments/"+paymentID, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var payment PaymentResponse
+	_ = json.NewDecoder(resp.Body).Decode(&payment)
+	return resp, payment
+}
+
+func TestPatchPayment(t *testing.T) {
+	ctx := context.Background()
+	newApp := func(t *testing.T) (*fiber.App, *MemoryPaymentStore, *MemoryEventStore, PaymentResponse) {
+		store := NewMemoryPaymentStore()
+		events := NewMemoryEventStore()
+		app := fiber.New()
+		(&APIRouter{store: store, events: events}).SetupRoutes(app, Config{})
+		_, created := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_visa","description":"Order 1","metadata":{"order_id":"o_1","channel":"web"}}`, nil)
+		return app, store, events, created
+	}
+
+	t.Run("Updates Metadata And Description", func(t *testing.T) {
+		app, store, events, created := newApp(t)
+
+		resp, payment := patchPaymentRequest(t, app, created.ID, `{"metadata":{"channel":"app","campaign":"songkran"},"description":"Order 1 (gift)"}`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, map[string]string{"order_id": "o_1", "channel": "app", "campaign": "songkran"}, payment.Metadata)
+		assert.Equal(t, "Order 1 (gift)", payment.Description)
+		assert.Equal(t, created.Amount, payment.Amount)
+		assert.Equal(t, created.Status, payment.Status)
+
+		stored, _ := store.Get(ctx, created.ID)
+		assert.Equal(t, payment.Metadata, stored.Metadata)
+		recorded, _ := events.ListByPayment(ctx, created.ID)
+		last := recorded[len(recorded)-1]
+		assert.Equal(t, EventPaymentUpdated, last.Type)
+	})
+
+	t.Run("Null Deletes Metadata Key", func(t *testing.T) {
+		app, _, _, created := newApp(t)
+
+		_, payment := patchPaymentRequest(t, app, created.ID, `{"metadata":{"channel":null}}`)
+		assert.Equal(t, map[string]string{"order_id": "o_1"}, payment.Metadata)
+
+		_, payment = patchPaymentRequest(t, app, created.ID, `{"metadata":null,"description":null}`)
+		assert.Empty(t, payment.Metadata)
+		assert.Empty(t, payment.Description)
+	})
+
+	t.Run("Immutable Fields Rejected", func(t *testing.T) {
+		app, store, _, created := newApp(t)
+
+		for _, body := range []string{`{"amount":5000}`, `{"currency":"USD"}`, `{"status":"captured","description":"x"}`} {
+			resp, _ := patchPaymentRequest(t, app, created.ID, body)
+			assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode, body)
+		}
+		stored, _ := store.Get(ctx, created.ID)
+		assert.Equal(t, int64(1000), stored.Amount)
+		assert.Equal(t, "Order 1", stored.Description)
+	})
+
+	t.Run("Invalid Patches Rejected", func(t *testing.T) {
+		app, _, _, created := newApp(t)
+
+		resp, _ := patchPaymentRequest(t, app, created.ID, `{"metadata":{"order_id":42}}`)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+		resp, _ = patchPaymentRequest(t, app, created.ID, `{"metadata":"o_1"}`)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+		resp, _ = patchPaymentRequest(t, app, created.ID, `[1]`)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Missing Payment", func(t *testing.T) {
+		app, _, _, _ := newApp(t)
+
+		resp, _ := patchPaymentRequest(t, app, "pay_missing", `{"description":"x"}`)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
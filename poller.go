@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Payment methods whose outcome is only known after the customer acts outside our flow, e.g. by scanning a
+// QR code in their banking app.
+const (
+	MethodPromptPay    = "promptpay"
+	MethodBankTransfer = "bank_transfer"
+)
+
+// defaultAsyncPaymentExpiry is how long a customer has to complete an asynchronous payment by default.
+const defaultAsyncPaymentExpiry = 15 * time.Minute
+
+// isAsyncMethod reports whether payments with method stay pending until the customer pays.
+func isAsyncMethod(method string) bool {
+	return method == MethodPromptPay || method == MethodBankTransfer
+}
+
+// GatewayPaymentStatus is a gateway's view of an asynchronous payment.
+type GatewayPaymentStatus string
+
+const (
+	// GatewayPaymentPending means the customer has not paid yet.
+	GatewayPaymentPending GatewayPaymentStatus = "pending"
+	// GatewayPaymentPaid means the funds were received.
+	GatewayPaymentPaid GatewayPaymentStatus = "paid"
+	// GatewayPaymentFailed means the gateway gave up on the payment.
+	GatewayPaymentFailed GatewayPaymentStatus = "failed"
+)
+
+// PaymentStatusChecker is implemented by gateways that can be polled for the status of an asynchronous
+// payment because they do not push webhooks for it.
+type PaymentStatusChecker interface {
+	CheckPaymentStatus(ctx context.Context, paymentID, gatewayReference string) (GatewayPaymentStatus, error)
+}
+
+// pollPendingPayments checks every pending asynchronous payment once, capturing those the gateway reports
+// paid and expiring those past their deadline.
+func (r *APIRouter) pollPendingPayments(ctx context.Context) error {
+	payments, err := r.pendingAsyncPayments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, payment := range payments {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := r.pollPayment(ctx, payment); err != nil {
+			log.Printf("Failed to poll payment %s: %v", payment.ID, err)
+		}
+	}
+	return nil
+}
+
+// pendingAsyncPayments lists the payments waiting on the customer that the gateway can be polled for.
+func (r *APIRouter) pendingAsyncPayments(ctx context.Context) ([]Payment, error) {
+	if _, ok := gatewayAs[PaymentStatusChecker](r.gateway); !ok {
+		return nil, nil
+	}
+	payments, err := r.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Payment
+	for _, payment := range payments {
+		if payment.Status == PaymentStatusPending && isAsyncMethod(payment.Method) && payment.GatewayReference != "" {
+			pending = append(pending, payment)
+		}
+	}
+	return pending, nil
+}
+
+// pollPayment asks the gateway for the status of one pending asynchronous payment and stores the outcome.
+func (r *APIRouter) pollPayment(ctx context.Context, payment Payment) error {
+	checker, ok := gatewayAs[PaymentStatusChecker](r.gatewayFor(payment.TestMode))
+	if !ok {
+		return nil
+	}
+	status, err := checker.CheckPaymentStatus(ctx, payment.ID, payment.GatewayReference)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	before := payment
+	var event EventType
+	switch {
+	case status == GatewayPaymentPaid:
+		payment.Status = PaymentStatusCaptured
+		payment.CapturedAt = &now
+		payment.EstimatedSettlementDate = r.estimateSettlementDate(payment)
+		if err := r.postCapture(ctx, payment); err != nil {
+			return err
+		}
+		event = EventPaymentCaptured
+	case status == GatewayPaymentFailed:
+		payment.Status = PaymentStatusFailed
+		event = EventPaymentFailed
+	case payment.ExpiresAt != nil && now.After(*payment.ExpiresAt):
+		payment.Status = PaymentStatusExpired
+		event = EventPaymentExpired
+	default:
+		return nil
+	}
+	payment.UpdatedAt = now
+	if err := r.updatePayment(ctx, before, &payment); err != nil {
+		return err
+	}
+	r.recordEvent(ctx, payment.ID, event)
+	return nil
+}
+
+// NewPaymentStatusWorker creates the worker that polls pending asynchronous payments every interval.
+func NewPaymentStatusWorker(r *APIRouter, interval time.Duration) *Worker[Payment] {
+	return NewWorker("payment-status", interval, r.pendingAsyncPayments, r.pollPayment)
+}
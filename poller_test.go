@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedStatusGateway reports a scripted sequence of statuses for asynchronous payments, one per poll, and
+// repeats the last one once the script runs out.
+type scriptedStatusGateway struct {
+	PaymentGateway
+	mu       sync.Mutex
+	statuses []GatewayPaymentStatus
+	checks   int
+}
+
+func (g *scriptedStatusGateway) CheckPaymentStatus(_ context.Context, _, _ string) (GatewayPaymentStatus, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status := g.statuses[len(g.statuses)-1]
+	if g.checks < len(g.statuses) {
+		status = g.statuses[g.checks]
+	}
+	g.checks++
+	return status, nil
+}
+
+func (g *scriptedStatusGateway) Checks() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.checks
+}
+
+func TestPollPendingPayments(t *testing.T) {
+	ctx := context.Background()
+	seedPending := func(store PaymentStore, id string, expiresAt time.Time) {
+		now := time.Now().UTC()
+		_ = store.Save(ctx, Payment{
+			ID: id, Amount: 10000, Currency: "THB", Method: MethodPromptPay, Status: PaymentStatusPending,
+			GatewayReference: "sandbox_authorize_1", ExpiresAt: &expiresAt, CreatedAt: now, UpdatedAt: now,
+		})
+	}
+
+	t.Run("Captures Once Gateway Reports Paid", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		events := NewMemoryEventStore()
+		ledger := NewMemoryLedger()
+		gateway := &scriptedStatusGateway{
+			PaymentGateway: NewSandboxGateway("sandbox"),
+			statuses:       []GatewayPaymentStatus{GatewayPaymentPending, GatewayPaymentPending, GatewayPaymentPaid},
+		}
+		router := &APIRouter{store: store, gateway: gateway, events: events, ledger: ledger}
+		router.ensureDependencies(Config{})
+		seedPending(store, "pay_1", time.Now().Add(time.Hour))
+
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, router.pollPendingPayments(ctx))
+			payment, _ := store.Get(ctx, "pay_1")
+			assert.Equal(t, PaymentStatusPending, payment.Status)
+		}
+
+		assert.NoError(t, router.pollPendingPayments(ctx))
+		payment, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, PaymentStatusCaptured, payment.Status)
+		assert.NotNil(t, payment.CapturedAt)
+
+		recorded, _ := events.ListByPayment(ctx, "pay_1")
+		assert.Equal(t, EventPaymentCaptured, recorded[len(recorded)-1].Type)
+		transactions, _ := ledger.ListByPayment(ctx, "pay_1")
+		assert.Len(t, transactions, 1)
+
+		// A captured payment is terminal and is not polled again.
+		assert.NoError(t, router.pollPendingPayments(ctx))
+		assert.Equal(t, 3, gateway.Checks())
+	})
+
+	t.Run("Expires Unpaid Payment Past Deadline", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		events := NewMemoryEventStore()
+		gateway := &scriptedStatusGateway{
+			PaymentGateway: NewSandboxGateway("sandbox"),
+			statuses:       []GatewayPaymentStatus{GatewayPaymentPending},
+		}
+		router := &APIRouter{store: store, gateway: gateway, events: events}
+		router.ensureDependencies(Config{})
+		seedPending(store, "pay_1", time.Now().Add(-time.Minute))
+
+		assert.NoError(t, router.pollPendingPayments(ctx))
+		payment, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, PaymentStatusExpired, payment.Status)
+		recorded, _ := events.ListByPayment(ctx, "pay_1")
+		assert.Equal(t, EventPaymentExpired, recorded[len(recorded)-1].Type)
+
+		assert.NoError(t, router.pollPendingPayments(ctx))
+		assert.Equal(t, 1, gateway.Checks())
+	})
+
+	t.Run("Skips Card Payments", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		gateway := &scriptedStatusGateway{
+			PaymentGateway: NewSandboxGateway("sandbox"),
+			statuses:       []GatewayPaymentStatus{GatewayPaymentPaid},
+		}
+		router := &APIRouter{store: store, gateway: gateway}
+		router.ensureDependencies(Config{})
+		now := time.Now().UTC()
+		_ = store.Save(ctx, Payment{ID: "pay_1", Amount: 100, Currency: "THB", Method: "card", Status: PaymentStatusPending, GatewayReference: "ref", CreatedAt: now, UpdatedAt: now})
+
+		assert.NoError(t, router.pollPendingPayments(ctx))
+		assert.Equal(t, 0, gateway.Checks())
+	})
+
+	t.Run("PromptPay Payment Created Pending Then Captured By Sandbox", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		sandbox := NewSandboxGateway("sandbox")
+		router := &APIRouter{store: store, gateway: NewInstrumentedGateway(sandbox, NewMetricsRegistry(), 0)}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{AsyncPaymentExpiry: time.Hour})
+
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{"amount":5000,"currency":"THB","method":"promptpay"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+		payments, _ := store.List(ctx)
+		assert.Len(t, payments, 1)
+		payment := payments[0]
+		assert.Equal(t, PaymentStatusPending, payment.Status)
+		assert.NotNil(t, payment.ExpiresAt)
+
+		sandbox.SetPaymentStatus(payment.GatewayReference, GatewayPaymentPaid)
+		assert.NoError(t, router.pollPendingPayments(ctx))
+		payment, _ = store.Get(ctx, payment.ID)
+		assert.Equal(t, PaymentStatusCaptured, payment.Status)
+	})
+}
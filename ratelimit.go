@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Rate limit headers sent on every rate-limited response so clients can throttle themselves before a 429.
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	// HeaderRateLimitReset is the Unix time in seconds at which the current window ends.
+	HeaderRateLimitReset = "X-RateLimit-Reset"
+)
+
+// defaultRateLimitWindow is the length of a rate limit window when RATE_LIMIT_WINDOW is not set.
+const defaultRateLimitWindow = time.Minute
+
+// merchantKeyPrefix namespaces merchant rate limit keys so they cannot collide with client IPs.
+const merchantKeyPrefix = "merchant:"
+
+// RateLimiter counts requests per client in fixed windows. Each client gets the default limit unless SetLimit
+// gave it its own; a limit of 0 leaves the client unlimited.
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	limits    map[string]int
+	window    time.Duration
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimitDecision is the outcome of counting one request against a client's quota.
+type RateLimitDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing limit requests per client in each window.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	if window <= 0 {
+		window = defaultRateLimitWindow
+	}
+	return &RateLimiter{limit: limit, limits: make(map[string]int), window: window, windows: make(map[string]*rateWindow), now: time.Now}
+}
+
+// SetLimit overrides the default limit for one client key.
+func (l *RateLimiter) SetLimit(key string, limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits[key] = limit
+}
+
+// Take counts a request for key and reports whether it is within the quota of the current window.
+func (l *RateLimiter) Take(key string) RateLimitDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit, ok := l.limits[key]
+	if !ok {
+		limit = l.limit
+	}
+	if limit <= 0 {
+		return RateLimitDecision{Allowed: true}
+	}
+
+	now := l.now()
+	l.sweep(now)
+	w, ok := l.windows[key]
+	if !ok || !now.Before(w.start.Add(l.window)) {
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+	decision := RateLimitDecision{Limit: limit, Reset: w.start.Add(l.window)}
+	if w.count >= limit {
+		return decision
+	}
+	w.count++
+	decision.Allowed = true
+	decision.Remaining = limit - w.count
+	return decision
+}
+
+// sweep drops windows that have ended, at most once per window, so idle clients do not accumulate.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, w := range l.windows {
+		if !now.Before(w.start.Add(l.window)) {
+			delete(l.windows, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// NewRateLimitMiddleware returns middleware that counts each request against the quota of the client key
+// returns and sets the X-RateLimit headers on the response. Requests over quota get 429 with Retry-After
+// pointing at the end of the window; probe paths and unlimited clients are exempt.
+func NewRateLimitMiddleware(limiter *RateLimiter, key func(c *fiber.Ctx) string, jitter time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if probePaths[c.Path()] {
+			return c.Next()
+		}
+
+		decision := limiter.Take(key(c))
+		if decision.Limit == 0 {
+			return c.Next()
+		}
+		c.Set(HeaderRateLimitLimit, strconv.Itoa(decision.Limit))
+		c.Set(HeaderRateLimitRemaining, strconv.Itoa(decision.Remaining))
+		c.Set(HeaderRateLimitReset, strconv.FormatInt(decision.Reset.Unix(), 10))
+		if !decision.Allowed {
+			retryAfter := RetryAfter{Base: decision.Reset.Sub(limiter.now()), Jitter: jitter}
+			c.Set(fiber.HeaderRetryAfter, retryAfter.Header())
+			return respondError(c, ErrCodeRateLimited, "rate limit exceeded, retry after the window resets")
+		}
+		return c.Next()
+	}
+}
+
+// clientIPKey identifies a client by its IP address for rate limiting.
+func clientIPKey(c *fiber.Ctx) string {
+	return c.IP()
+}
+
+// merchantKey identifies a client by the merchant whose API key it authenticated with, falling back to its IP
+// address for requests without one. Nothing the client sends besides the key names the merchant.
+func merchantKey(c *fiber.Ctx) string {
+	if merchantID := requestMerchantID(c); merchantID != "" {
+		return merchantKeyPrefix + merchantID
+	}
+	return clientIPKey(c)
+}
+
+// parseMerchantRateLimits parses MERCHANT_RATE_LIMITS, per-merchant request limits per window such as
+// "m_small=60,m_large=6000"; 0 leaves a merchant unlimited.
+func parseMerchantRateLimits(spec string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		merchantID, rawLimit, ok := strings.Cut(entry, "=")
+		merchantID = strings.TrimSpace(merchantID)
+		limit, err := strconv.Atoi(strings.TrimSpace(rawLimit))
+		if !ok || merchantID == "" || err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid MERCHANT_RATE_LIMITS entry %q: want merchant=non-negative integer", entry)
+		}
+		limits[merchantID] = limit
+	}
+	return limits, nil
+}
+
+// newMerchantRateLimiter builds the rate limiter from config: RateLimit is the default per client and
+// MerchantRateLimits overrides it for the merchants listed.
+func newMerchantRateLimiter(config Config) *RateLimiter {
+	limiter := NewRateLimiter(config.RateLimit, config.RateLimitWindow)
+	// Validate has already rejected malformed MERCHANT_RATE_LIMITS.
+	limits, _ := parseMerchantRateLimits(config.MerchantRateLimits)
+	for merchantID, limit := range limits {
+		limiter.SetLimit(merchantKeyPrefix+merchantID, limit)
+	}
+	return limiter
+}
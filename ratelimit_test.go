@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	newApp := func(limit int) (*fiber.App, *RateLimiter) {
+		limiter := NewRateLimiter(limit, time.Minute)
+		limiter.now = func() time.Time { return now }
+		app := fiber.New()
+		app.Use(NewRateLimitMiddleware(limiter, clientIPKey, 0))
+		app.Get("/payments", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+		app.Get("/health", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+		return app, limiter
+	}
+	get := func(t *testing.T, app *fiber.App, path string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		assert.NoError(t, err)
+		return resp
+	}
+	reset := strconv.FormatInt(now.Add(time.Minute).Unix(), 10)
+
+	t.Run("Headers Reflect Remaining Quota And Reset", func(t *testing.T) {
+		app, _ := newApp(3)
+		for _, remaining := range []string{"2", "1", "0"} {
+			resp := get(t, app, "/payments")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "3", resp.Header.Get(HeaderRateLimitLimit))
+			assert.Equal(t, remaining, resp.Header.Get(HeaderRateLimitRemaining))
+			assert.Equal(t, reset, resp.Header.Get(HeaderRateLimitReset))
+		}
+	})
+
+	t.Run("Breach Returns 429 With Retry-After", func(t *testing.T) {
+		app, _ := newApp(1)
+		get(t, app, "/payments")
+		now = now.Add(20 * time.Second)
+		defer func() { now = now.Add(-20 * time.Second) }()
+
+		resp := get(t, app, "/payments")
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "0", resp.Header.Get(HeaderRateLimitRemaining))
+		assert.Equal(t, "40", resp.Header.Get(fiber.HeaderRetryAfter))
+		assert.Equal(t, ErrCodeRateLimited, decodeErrorCode(t, resp))
+	})
+
+	t.Run("Quota Resets With The Window", func(t *testing.T) {
+		app, limiter := newApp(1)
+		get(t, app, "/payments")
+		limiter.now = func() time.Time { return now.Add(time.Minute) }
+
+		resp := get(t, app, "/payments")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, strconv.FormatInt(now.Add(2*time.Minute).Unix(), 10), resp.Header.Get(HeaderRateLimitReset))
+	})
+
+	t.Run("Probe Paths Are Exempt", func(t *testing.T) {
+		app, _ := newApp(1)
+		get(t, app, "/payments")
+
+		resp := get(t, app, "/health")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(HeaderRateLimitLimit))
+	})
+}
+
+func TestRateLimiterSweepsEndedWindows(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(5, time.Minute)
+	limiter.now = func() time.Time { return now }
+	limiter.Take("a")
+	limiter.Take("b")
+
+	now = now.Add(2 * time.Minute)
+	limiter.Take("c")
+	assert.Len(t, limiter.windows, 1)
+}
+
+func TestMerchantRateLimits(t *testing.T) {
+	keys := []APIKey{
+		{Key: "sk_m_small", MerchantID: "m_small"},
+		{Key: "sk_m_large", MerchantID: "m_large"},
+		{Key: "sk_m_other", MerchantID: "m_other"},
+	}
+	newApp := func(config Config) *fiber.App {
+		app := fiber.New()
+		app.Use(NewAPIKeyResolver(keys, NewMemoryMerchantAPIKeyStore()))
+		app.Use(NewRateLimitMiddleware(newMerchantRateLimiter(config), merchantKey, 0))
+		app.Get("/payments", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+		return app
+	}
+	get := func(t *testing.T, app *fiber.App, merchantID string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+		if merchantID != "" {
+			req.Header.Set(fiber.HeaderAuthorization, "Bearer sk_"+merchantID)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Merchants Enforced Independently", func(t *testing.T) {
+		app := newApp(Config{RateLimit: 5, MerchantRateLimits: "m_small=1,m_large=3"})
+
+		assert.Equal(t, http.StatusOK, get(t, app, "m_small").StatusCode)
+		assert.Equal(t, http.StatusTooManyRequests, get(t, app, "m_small").StatusCode)
+
+		for i := 0; i < 3; i++ {
+			resp := get(t, app, "m_large")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "3", resp.Header.Get(HeaderRateLimitLimit))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, get(t, app, "m_large").StatusCode)
+	})
+
+	t.Run("Unlisted Merchant Falls Back To Default", func(t *testing.T) {
+		app := newApp(Config{RateLimit: 2, MerchantRateLimits: "m_small=1"})
+
+		resp := get(t, app, "m_other")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get(HeaderRateLimitLimit))
+	})
+
+	t.Run("No Default Leaves Others Unlimited", func(t *testing.T) {
+		app := newApp(Config{MerchantRateLimits: "m_small=1"})
+
+		for i := 0; i < 3; i++ {
+			resp := get(t, app, "")
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get(HeaderRateLimitLimit))
+		}
+		assert.Equal(t, http.StatusOK, get(t, app, "m_small").StatusCode)
+		assert.Equal(t, http.StatusTooManyRequests, get(t, app, "m_small").StatusCode)
+	})
+
+	t.Run("Merchant Header Is Ignored", func(t *testing.T) {
+		app := newApp(Config{RateLimit: 2, MerchantRateLimits: "m_large=100"})
+
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/payments", nil)
+			req.Header.Set("X-Merchant-ID", "m_large")
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			assert.Equal(t, "2", resp.Header.Get(HeaderRateLimitLimit))
+		}
+		assert.Equal(t, http.StatusTooManyRequests, get(t, app, "").StatusCode, "counted against the client IP")
+	})
+
+	t.Run("Server Resolves Merchant Keys Before Limiting", func(t *testing.T) {
+		merchantKeys := NewMemoryMerchantAPIKeyStore()
+		assert.NoError(t, merchantKeys.Create(context.Background(), MerchantAPIKey{
+			ID:         "key_1",
+			MerchantID: "m_small",
+			Hash:       hashAPIKey("sk_m_small"),
+			CreatedAt:  time.Now(),
+		}))
+		server := NewServer(Config{RateLimit: 5, MerchantRateLimits: "m_small=1"}, &APIRouter{merchantKeys: merchantKeys})
+
+		assert.Equal(t, http.StatusOK, get(t, server.app, "m_small").StatusCode)
+		assert.Equal(t, http.StatusTooManyRequests, get(t, server.app, "m_small").StatusCode)
+
+		resp := get(t, server.app, "m_unknown")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, "5", resp.Header.Get(HeaderRateLimitLimit), "unknown keys are limited by client IP")
+	})
+
+	t.Run("Invalid Spec Rejected", func(t *testing.T) {
+		_, err := parseMerchantRateLimits("m_small=lots")
+		assert.ErrorContains(t, err, "MERCHANT_RATE_LIMITS")
+	})
+}
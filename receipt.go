@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/mail"
+	"text/template"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EmailMessage is a plain-text email handed to an EmailSender.
+type EmailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// EmailSender queues emails for delivery. Implementations wrap an email provider; Send returning nil means the
+// message was accepted for delivery, not that it was delivered.
+type EmailSender interface {
+	Send(ctx context.Context, message EmailMessage) error
+}
+
+// LogEmailSender is an EmailSender for development that only logs the messages it is given.
+type LogEmailSender struct{}
+
+// Send implements EmailSender.
+func (LogEmailSender) Send(_ context.Context, message EmailMessage) error {
+	log.Printf("Email to %s: %s", message.To, message.Subject)
+	return nil
+}
+
+var receiptTemplate = template.Must(template.New("receipt").Parse(`Thank you for your payment.
+
+Payment:   {{.ID}}
+{{- if .Reference}}
+Reference: {{.Reference}}
+{{- end}}
+Amount:    {{.Amount}} {{.Currency}}
+{{- if .Refunded}}
+Refunded:  {{.Refunded}} {{.Currency}}
+{{- end}}
+Paid at:   {{.CapturedAt}}
+{{- if .ApprovalCode}}
+Approval:  {{.ApprovalCode}}
+{{- end}}
+`))
+
+type receiptData struct {
+	ID         string
+	Reference  string
+	Amount     string
+	Refunded   string
+	Currency   string
+	CapturedAt string
+
+	ApprovalCode string
+}
+
+// RenderReceipt renders the plain-text receipt of a captured payment.
+func RenderReceipt(payment Payment) (string, error) {
+	data := receiptData{
+		ID:        payment.ID,
+		Reference: payment.Reference,
+		Amount:    payment.Money().Decimal(),
+		Currency:  payment.Currency,
+
+		ApprovalCode: payment.ApprovalCode,
+	}
+	if payment.AmountRefunded > 0 {
+		data.Refunded = payment.RefundedMoney().Decimal()
+	}
+	if payment.CapturedAt != nil {
+		data.CapturedAt = payment.CapturedAt.UTC().Format(time.RFC1123)
+	}
+	var body bytes.Buffer
+	if err := receiptTemplate.Execute(&body, data); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
+
+// sendReceiptRequest is the body accepted by POST /payments/:id/receipt/send.
+type sendReceiptRequest struct {
+	Email string `json:"email"`
+}
+
+func (r *APIRouter) sendReceipt(c *fiber.Ctx) error {
+	var req sendReceiptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	address, err := mail.ParseAddress(req.Email)
+	if err != nil || address.Address != req.Email {
+		return respondError(c, ErrCodeValidationFailed, "email must be a valid email address")
+	}
+
+	ctx := c.UserContext()
+	payment, err := r.loadVisiblePayment(c)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return respondError(c, ErrCodeNotFound, "payment not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	if payment.Status != PaymentStatusCaptured {
+		return respondError(c, ErrCodeInvalidState, "receipts are only available for captured payments")
+	}
+
+	body, err := RenderReceipt(payment)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to render receipt")
+	}
+	err = r.email.Send(ctx, EmailMessage{
+		To:      address.Address,
+		Subject: "Your receipt for payment " + payment.ID,
+		Body:    body,
+	})
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to send receipt")
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "queued"})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingEmailSender struct {
+	mu       sync.Mutex
+	messages []EmailMessage
+}
+
+func (s *recordingEmailSender) Send(_ context.Context, message EmailMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func postReceipt(t *testing.T, app *fiber.App, paymentID, body string) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, "/payments/"+paymentID+"/receipt/send", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestSendReceipt(t *testing.T) {
+	newApp := func() (*fiber.App, *MemoryPaymentStore, *recordingEmailSender) {
+		store := NewMemoryPaymentStore()
+		sender := &recordingEmailSender{}
+		app := fiber.New()
+		router := &APIRouter{store: store, email: sender}
+		router.SetupRoutes(app, Config{})
+		return app, store, sender
+	}
+
+	t.Run("Sends Receipt For Captured Payment", func(t *testing.T) {
+		app, store, sender := newApp()
+		seedCapturedPayment(t, store, "pay_1", 12345, "")
+
+		resp := postReceipt(t, app, "pay_1", `{"email":"customer@example.com"}`)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Len(t, sender.messages, 1)
+		assert.Equal(t, "customer@example.com", sender.messages[0].To)
+		assert.Contains(t, sender.messages[0].Body, "123.45 THB")
+	})
+
+	t.Run("Rejects Non Captured Payment", func(t *testing.T) {
+		app, store, sender := newApp()
+		now := time.Now().UTC()
+		assert.NoError(t, store.Save(context.Background(), Payment{
+			ID: "pay_1", Amount: 1000, Currency: "THB", Status: PaymentStatusAuthorized, CreatedAt: now, UpdatedAt: now,
+		}))
+
+		resp := postReceipt(t, app, "pay_1", `{"email":"customer@example.com"}`)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		assert.Empty(t, sender.messages)
+	})
+
+	t.Run("Rejects Invalid Email", func(t *testing.T) {
+		app, store, sender := newApp()
+		seedCapturedPayment(t, store, "pay_1", 1000, "")
+
+		resp := postReceipt(t, app, "pay_1", `{"email":"Customer <customer@example.com>"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Empty(t, sender.messages)
+	})
+}
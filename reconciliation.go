@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrUnknownSettlementFormat is returned when no FileParser is registered for a settlement file format.
+var ErrUnknownSettlementFormat = errors.New("unknown settlement file format")
+
+// SettlementRecord is the bank-independent representation of one settled transaction in a settlement file.
+type SettlementRecord struct {
+	Reference string    `json:"reference"`
+	Amount    Money     `json:"amount"`
+	Fee       Money     `json:"fee"`
+	SettledAt time.Time `json:"settled_at"`
+	// PaymentID is set on import when Reference matches a payment's reference number.
+	PaymentID string `json:"payment_id,omitempty"`
+}
+
+// FileParser turns a bank-specific settlement file into normalized SettlementRecords.
+type FileParser interface {
+	Parse(r io.Reader) ([]SettlementRecord, error)
+}
+
+// settlementParsers maps the format parameter of the importer to the parser for that bank's layout.
+var settlementParsers = map[string]FileParser{
+	"kbank": &KBankCSVParser{},
+	"scb":   &SCBFixedWidthParser{},
+}
+
+// SettlementParserFor returns the FileParser registered for the given format.
+func SettlementParserFor(format string) (FileParser, error) {
+	parser, ok := settlementParsers[strings.ToLower(strings.TrimSpace(format))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSettlementFormat, format)
+	}
+	return parser, nil
+}
+
+// SettlementFormats returns the names of all registered settlement file formats, sorted.
+func SettlementFormats() []string {
+	formats := make([]string, 0, len(settlementParsers))
+	for format := range settlementParsers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// KBankCSVParser parses comma-separated settlement files with a header row of
+// txn_ref,settle_date,currency,amount,fee where amounts are major-unit decimals and dates are YYYY-MM-DD.
+type KBankCSVParser struct{}
+
+// Parse implements FileParser.
+func (p *KBankCSVParser) Parse(r io.Reader) ([]SettlementRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 5
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("kbank: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	records := make([]SettlementRecord, 0, len(rows)-1)
+	for i, row := range rows[1:] {
+		settledAt, err := time.Parse("2006-01-02", row[1])
+		if err != nil {
+			return nil, fmt.Errorf("kbank: line %d: invalid settle_date: %w", i+2, err)
+		}
+		amount, err := ParseDecimalAmount(row[3], row[2])
+		if err != nil {
+			return nil, fmt.Errorf("kbank: line %d: %w", i+2, err)
+		}
+		fee, err := ParseDecimalAmount(row[4], row[2])
+		if err != nil {
+			return nil, fmt.Errorf("kbank: line %d: %w", i+2, err)
+		}
+		records = append(records, SettlementRecord{
+			Reference: row[0],
+			Amount:    amount,
+			Fee:       fee,
+			SettledAt: settledAt.UTC(),
+		})
+	}
+	return records, nil
+}
+
+// SCBFixedWidthParser parses fixed-width settlement files where each line holds a 20-character reference,
+// an 8-digit YYYYMMDD date, a 3-letter currency, a 15-digit amount and a 9-digit fee in minor units.
+type SCBFixedWidthParser struct{}
+
+// scbLineLength is the exact width of an SCB settlement detail line.
+const scbLineLength = 20 + 8 + 3 + 15 + 9
+
+// Parse implements FileParser.
+func (p *SCBFixedWidthParser) Parse(r io.Reader) ([]SettlementRecord, error) {
+	var records []SettlementRecord
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if len(text) != scbLineLength {
+			return nil, fmt.Errorf("scb: line %d: expected %d characters, got %d", line, scbLineLength, len(text))
+		}
+
+		settledAt, err := time.Parse("20060102", text[20:28])
+		if err != nil {
+			return nil, fmt.Errorf("scb: line %d: invalid date: %w", line, err)
+		}
+		currency := text[28:31]
+		amount, err := strconv.ParseInt(text[31:46], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("scb: line %d: invalid amount: %w", line, err)
+		}
+		fee, err := strconv.ParseInt(text[46:55], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("scb: line %d: invalid fee: %w", line, err)
+		}
+		records = append(records, SettlementRecord{
+			Reference: strings.TrimSpace(text[0:20]),
+			Amount:    NewMoney(amount, currency),
+			Fee:       NewMoney(fee, currency),
+			SettledAt: settledAt.UTC(),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scb: %w", err)
+	}
+	return records, nil
+}
+
+func (r *APIRouter) importSettlementFile(c *fiber.Ctx) error {
+	parser, err := SettlementParserFor(c.Query("format"))
+	if err != nil {
+		return respondError(c, ErrCodeValidationFailed,
+			fmt.Sprintf("%v; supported formats: %s", err, strings.Join(SettlementFormats(), ", ")))
+	}
+
+	records, err := parser.Parse(bytes.NewReader(c.Body()))
+	if err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+
+	matched, err := r.matchSettlementRecords(c.UserContext(), records)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to match settlement records")
+	}
+
+	return c.JSON(fiber.Map{
+		"count":   len(records),
+		"matched": matched,
+		"records": records,
+	})
+}
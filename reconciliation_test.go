@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const kbankSettlementFile = `txn_ref,settle_date,currency,amount,fee
+ORD-1001,2026-10-14,THB,1050.00,25.50
+ORD-1002,2026-10-14,THB,99.5,1.99
+`
+
+func scbLine(ref, date, currency string, amount, fee int64) string {
+	return fmt.Sprintf("%-20s%s%s%015d%09d", ref, date, currency, amount, fee)
+}
+
+func TestSettlementParsers(t *testing.T) {
+	expected := []SettlementRecord{
+		{
+			Reference: "ORD-1001",
+			Amount:    NewMoney(105000, "THB"),
+			Fee:       NewMoney(2550, "THB"),
+			SettledAt: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Reference: "ORD-1002",
+			Amount:    NewMoney(9950, "THB"),
+			Fee:       NewMoney(199, "THB"),
+			SettledAt: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	t.Run("KBank CSV Layout", func(t *testing.T) {
+		parser, err := SettlementParserFor("kbank")
+		assert.NoError(t, err)
+
+		records, err := parser.Parse(strings.NewReader(kbankSettlementFile))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, records)
+	})
+
+	t.Run("SCB Fixed-Width Layout", func(t *testing.T) {
+		parser, err := SettlementParserFor("SCB")
+		assert.NoError(t, err)
+
+		file := scbLine("ORD-1001", "20261014", "THB", 105000, 2550) + "\n" +
+			scbLine("ORD-1002", "20261014", "THB", 9950, 199) + "\n"
+		records, err := parser.Parse(strings.NewReader(file))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, records)
+	})
+
+	t.Run("Unknown Format", func(t *testing.T) {
+		_, err := SettlementParserFor("bbl")
+		assert.ErrorIs(t, err, ErrUnknownSettlementFormat)
+	})
+}
+
+func TestSettlementImportEndpoint(t *testing.T) {
+	app := fiber.New()
+	router := &APIRouter{}
+	router.SetupRoutes(app, Config{AdminToken: "admin-secret"})
+	importFile := func(format string, admin bool) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/admin/reconciliation/import?format="+format,
+			strings.NewReader(kbankSettlementFile))
+		req.Header.Set(fiber.HeaderAccept, MIMEApplicationProblemJSON)
+		if admin {
+			req.Header.Set(HeaderAdminToken, "admin-secret")
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Admin Token Required", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, importFile("kbank", false).StatusCode)
+	})
+
+	t.Run("Known Format", func(t *testing.T) {
+		resp := importFile("kbank", true)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result map[string]interface{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, float64(2), result["count"])
+	})
+
+	t.Run("Unknown Format Rejected", func(t *testing.T) {
+		resp := importFile("unknown", true)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, MIMEApplicationProblemJSON, resp.Header.Get(fiber.HeaderContentType))
+
+		var problem ProblemDetails
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, ErrCodeValidationFailed, problem.Code)
+		assert.Contains(t, problem.Detail, "supported formats: kbank, scb")
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redactedPrefixLength is how many leading characters of a secret stay readable in logs.
+const redactedPrefixLength = 4
+
+var (
+	// bearerTokenPattern matches the token of a "Bearer <token>" credential, wherever it appears in a line.
+	bearerTokenPattern = regexp.MustCompile(`(?i)\b(bearer\s+)([^\s"',;]+)`)
+	// jwtPattern matches a JSON Web Token: three base64url segments, the first an encoded JSON header.
+	jwtPattern = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// apiKeyParamPattern matches key=value and "key": "value" pairs whose name marks the value as a credential.
+	apiKeyParamPattern = regexp.MustCompile(`(?i)\b(api[_-]?key|access[_-]?token|admin[_-]?token)(["']?\s*[:=]\s*["']?)([^\s"'&,;]+)`)
+	// redactedPattern matches a value redactSecret already masked, so it is not masked twice.
+	redactedPattern = regexp.MustCompile(`\*\*\*\[[0-9a-f]{8}\]$`)
+)
+
+// redactSecret masks secret as its first few characters followed by a short hash, e.g. "sk_l***[1a2b3c4d]",
+// so log lines from the same caller can still be correlated without exposing the credential.
+func redactSecret(secret string) string {
+	if redactedPattern.MatchString(secret) {
+		return secret
+	}
+	sum := sha256.Sum256([]byte(secret))
+	prefix := ""
+	if len(secret) > 2*redactedPrefixLength {
+		prefix = secret[:redactedPrefixLength]
+	}
+	return prefix + "***[" + hex.EncodeToString(sum[:4]) + "]"
+}
+
+// Redactor masks credentials in log output: bearer tokens, JWTs, api_key-style parameters and the exact API
+// keys and admin token the service is configured with.
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor creates a Redactor that also masks the configured API keys and admin token verbatim, wherever
+// they appear.
+func NewRedactor(config Config) *Redactor {
+	var secrets []string
+	// Validate has already rejected malformed API_KEYS.
+	keys, _ := parseAPIKeys(config.APIKeys)
+	for _, key := range keys {
+		secrets = append(secrets, key.Key)
+	}
+	if config.AdminToken != "" {
+		secrets = append(secrets, config.AdminToken)
+	}
+	// Longer secrets go first so that one containing another is masked whole.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return &Redactor{secrets: secrets}
+}
+
+// Redact returns s with every credential it recognizes masked.
+func (r *Redactor) Redact(s string) string {
+	s = bearerTokenPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := bearerTokenPattern.FindStringSubmatch(match)
+		return parts[1] + redactSecret(parts[2])
+	})
+	s = jwtPattern.ReplaceAllStringFunc(s, redactSecret)
+	s = apiKeyParamPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := apiKeyParamPattern.FindStringSubmatch(match)
+		return parts[1] + parts[2] + redactSecret(parts[3])
+	})
+	for _, secret := range r.secrets {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, redactSecret(secret))
+		}
+	}
+	return s
+}
+
+// redactingWriter passes every write through a Redactor. The log package and the request logger write one
+// whole line per call, so a credential is never split across writes.
+type redactingWriter struct {
+	w        io.Writer
+	redactor *Redactor
+}
+
+// Write implements io.Writer, reporting the length of p rather than of the redacted line.
+func (w redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logOutput wraps w so that credentials are masked in everything logged to it, unless LOG_REDACTION is off.
+// Both the standard logger and the request logger write through it, so new log sites are covered as well.
+func logOutput(config Config, w io.Writer) io.Writer {
+	if !config.LogRedaction {
+		return w
+	}
+	return redactingWriter{w: w, redactor: NewRedactor(config)}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDuplicateReferenceNumber is returned by a PaymentStore when another payment already holds the reference number.
+var ErrDuplicateReferenceNumber = errors.New("reference number already in use")
+
+const (
+	// referenceSequenceDigits is the width of the per-day sequence in a reference number.
+	referenceSequenceDigits = 8
+	// referenceNumberAttempts bounds how often a payment is saved with a fresh reference number after a collision.
+	referenceNumberAttempts = 5
+)
+
+// referenceNumberPrefixPattern limits REFERENCE_NUMBER_PREFIX to what bank transfer reference fields accept.
+var referenceNumberPrefixPattern = regexp.MustCompile(`^[A-Z0-9]{0,6}$`)
+
+// ReferenceNumberGenerator issues short, human-friendly payment reference numbers for bank-transfer matching:
+// an optional prefix, the business date as YYMMDD, an 8-digit sequence and a Luhn check digit, e.g.
+// "PAY261014000000013". Sequences restart each day. Uniqueness is enforced by the PaymentStore; after a
+// collision, e.g. with numbers issued before a restart or by another instance, the sequence jumps to a random
+// offset so the next attempt is unlikely to collide again.
+type ReferenceNumberGenerator struct {
+	mu     sync.Mutex
+	prefix string
+	loc    *time.Location
+	now    func() time.Time
+	day    string
+	seq    int64
+}
+
+// NewReferenceNumberGenerator creates a generator dating references in loc; a nil loc uses UTC.
+func NewReferenceNumberGenerator(prefix string, loc *time.Location) *ReferenceNumberGenerator {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &ReferenceNumberGenerator{prefix: prefix, loc: loc, now: time.Now}
+}
+
+// Next returns the next reference number; it is safe for concurrent use.
+func (g *ReferenceNumberGenerator) Next() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	day := g.now().In(g.loc).Format("060102")
+	if day != g.day {
+		g.day = day
+		g.seq = 0
+	}
+	g.seq++
+	digits := fmt.Sprintf("%s%0*d", day, referenceSequenceDigits, g.seq%referenceSequenceSpace)
+	return g.prefix + digits + string(rune('0'+luhnCheckDigit(digits)))
+}
+
+// referenceSequenceSpace is the number of distinct sequences per day.
+const referenceSequenceSpace = 100_000_000
+
+// reseed moves the sequence to a random offset within the first half of the day's space.
+func (g *ReferenceNumberGenerator) reseed() {
+	offset, err := rand.Int(rand.Reader, big.NewInt(referenceSequenceSpace/2))
+	if err != nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seq = offset.Int64()
+}
+
+// ValidReferenceNumber reports whether reference has the generated shape and a correct check digit, so a
+// mistyped reference in a bank transfer can be told apart from an unknown one.
+func ValidReferenceNumber(reference string) bool {
+	digits := strings.TrimLeft(reference, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	if len(digits) != 6+referenceSequenceDigits+1 || len(reference)-len(digits) > 6 {
+		return false
+	}
+	for _, ch := range digits {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	body, check := digits[:len(digits)-1], int(digits[len(digits)-1]-'0')
+	return luhnCheckDigit(body) == check
+}
+
+// luhnCheckDigit returns the digit that makes digits followed by it pass the Luhn check.
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// saveNewPayment assigns the payment a reference number and saves it, drawing a new number when the store
+// reports it taken.
+func (r *APIRouter) saveNewPayment(ctx context.Context, payment Payment) (Payment, error) {
+	var err error
+	for attempt := 0; attempt < referenceNumberAttempts; attempt++ {
+		payment.ReferenceNumber = r.references.Next()
+		if err = r.store.Save(ctx, payment); !errors.Is(err, ErrDuplicateReferenceNumber) {
+			if err == nil {
+				payment.Version++
+			}
+			return payment, err
+		}
+		r.references.reseed()
+	}
+	return payment, err
+}
+
+// matchSettlementRecords links settlement records to payments by reference number; records whose reference
+// is not one of ours are left unmatched.
+func (r *APIRouter) matchSettlementRecords(ctx context.Context, records []SettlementRecord) (int, error) {
+	matched := 0
+	for i, record := range records {
+		payment, err := r.store.GetByReferenceNumber(ctx, record.Reference)
+		if errors.Is(err, ErrPaymentNotFound) {
+			continue
+		}
+		if err != nil {
+			return matched, err
+		}
+		records[i].PaymentID = payment.ID
+		matched++
+	}
+	return matched, nil
+}
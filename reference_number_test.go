@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferenceNumberGenerator(t *testing.T) {
+	fixedNow := func() time.Time { return time.Date(2026, 10, 14, 20, 0, 0, 0, time.UTC) }
+
+	t.Run("Format", func(t *testing.T) {
+		g := NewReferenceNumberGenerator("PAY", time.UTC)
+		g.now = fixedNow
+		assert.Equal(t, "PAY261014000000013", g.Next())
+		assert.Equal(t, "PAY261014000000021", g.Next())
+	})
+
+	t.Run("Date In Business Timezone", func(t *testing.T) {
+		bangkok, err := time.LoadLocation("Asia/Bangkok")
+		assert.NoError(t, err)
+		g := NewReferenceNumberGenerator("", bangkok)
+		g.now = fixedNow
+		assert.True(t, strings.HasPrefix(g.Next(), "261015"))
+	})
+
+	t.Run("Sequence Restarts Each Day", func(t *testing.T) {
+		now := fixedNow()
+		g := NewReferenceNumberGenerator("", time.UTC)
+		g.now = func() time.Time { return now }
+		g.Next()
+		g.Next()
+		now = now.Add(24 * time.Hour)
+		assert.Equal(t, "26101500000001", g.Next()[:14])
+	})
+
+	t.Run("Check Digit Valid", func(t *testing.T) {
+		g := NewReferenceNumberGenerator("PAY", time.UTC)
+		for i := 0; i < 100; i++ {
+			assert.True(t, ValidReferenceNumber(g.Next()))
+		}
+	})
+
+	t.Run("Mistyped Reference Rejected", func(t *testing.T) {
+		assert.True(t, ValidReferenceNumber("PAY261014000000013"))
+		assert.False(t, ValidReferenceNumber("PAY261014000000014"))
+		assert.False(t, ValidReferenceNumber("PAY261014000000103"))
+		assert.False(t, ValidReferenceNumber("PAY26101400000013"))
+		assert.False(t, ValidReferenceNumber("PAY2610140000000I3"))
+	})
+
+	t.Run("Unique Across Concurrent Generations", func(t *testing.T) {
+		g := NewReferenceNumberGenerator("", time.UTC)
+		const workers, perWorker = 8, 1000
+		results := make(chan string, workers*perWorker)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					results <- g.Next()
+				}
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		seen := make(map[string]bool)
+		for reference := range results {
+			assert.False(t, seen[reference], "duplicate reference %s", reference)
+			seen[reference] = true
+		}
+		assert.Len(t, seen, workers*perWorker)
+	})
+}
+
+func TestPaymentReferenceNumbers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Assigned On Create And Usable For Lookup", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{ReferenceNumberPrefix: "PAY"})
+		_, created := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_ok"}`, nil)
+		assert.True(t, strings.HasPrefix(created.ReferenceNumber, "PAY"))
+		assert.True(t, ValidReferenceNumber(created.ReferenceNumber))
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/payments?reference_number="+created.ReferenceNumber, nil))
+		assert.NoError(t, err)
+		var page struct {
+			Data []PaymentResponse `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		if assert.Len(t, page.Data, 1) {
+			assert.Equal(t, created.ID, page.Data[0].ID)
+		}
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/payments?reference_number=PAY000000000000000", nil))
+		assert.NoError(t, err)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		assert.Empty(t, page.Data)
+	})
+
+	t.Run("Taken Reference Number Redrawn", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		references := NewReferenceNumberGenerator("", time.UTC)
+		// A payment saved before a restart already holds the first number of the day.
+		taken := NewReferenceNumberGenerator("", time.UTC).Next()
+		assert.NoError(t, store.Save(ctx, Payment{ID: "pay_old", ReferenceNumber: taken}))
+
+		app := fiber.New()
+		(&APIRouter{store: store, references: references}).SetupRoutes(app, Config{})
+		resp, created := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_ok"}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.NotEqual(t, taken, created.ReferenceNumber)
+		assert.True(t, ValidReferenceNumber(created.ReferenceNumber))
+	})
+
+	t.Run("Store Rejects Duplicate", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		assert.NoError(t, store.Save(ctx, Payment{ID: "pay_1", ReferenceNumber: "261014000000013"}))
+		assert.NoError(t, store.Save(ctx, Payment{ID: "pay_1", ReferenceNumber: "261014000000013", Amount: 5}))
+		assert.ErrorIs(t, store.Save(ctx, Payment{ID: "pay_2", ReferenceNumber: "261014000000013"}), ErrDuplicateReferenceNumber)
+	})
+
+	t.Run("Settlement Records Matched", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		assert.NoError(t, store.Save(ctx, Payment{ID: "pay_1", ReferenceNumber: "ORD-1001"}))
+		app := fiber.New()
+		(&APIRouter{store: store}).SetupRoutes(app, Config{AdminToken: "admin-secret"})
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/reconciliation/import?format=kbank",
+			strings.NewReader(kbankSettlementFile))
+		req.Header.Set(HeaderAdminToken, "admin-secret")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result struct {
+			Matched int                `json:"matched"`
+			Records []SettlementRecord `json:"records"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, 1, result.Matched)
+		assert.Equal(t, "pay_1", result.Records[0].PaymentID)
+		assert.Empty(t, result.Records[1].PaymentID)
+	})
+}
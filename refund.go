@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ErrRefundNotFound is returned by a RefundStore when no refund exists for the requested ID.
+var ErrRefundNotFound = errors.New("refund not found")
+
+// ErrRefundExceedsRefundable is returned when a refund is zero or larger than what is left to refund.
+var ErrRefundExceedsRefundable = errors.New("amount exceeds the refundable amount")
+
+// RefundStatus is the lifecycle state of a refund.
+type RefundStatus string
+
+const (
+	// RefundStatusPending is a refund requested from the gateway but not yet confirmed. Its amount is held
+	// against the refundable balance but not added to the payment's refunded total.
+	RefundStatusPending RefundStatus = "pending"
+	// RefundStatusSucceeded is a refund the gateway confirmed.
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	// RefundStatusFailed is a refund the gateway rejected.
+	RefundStatusFailed RefundStatus = "failed"
+)
+
+// RefundDestination selects where refunded money is returned to.
+type RefundDestination string
+
+const (
+	// RefundToOriginalMethod returns the money to the payment method through the gateway.
+	RefundToOriginalMethod RefundDestination = "original"
+	// RefundToStoreCredit grants the customer store credit without calling the gateway.
+	RefundToStoreCredit RefundDestination = "store_credit"
+)
+
+// Refund represents money returned to the payer against a captured payment.
+type Refund struct {
+	ID          string
+	PaymentID   string
+	Amount      int64
+	Currency    string
+	Status      RefundStatus
+	Destination RefundDestination
+	Reason      string
+	CreatedAt   time.Time
+
+	GatewayReference string
+	FailureReason    string
+	// LineItemIDs are the payment's line items this refund covers, if it was requested by item.
+	LineItemIDs []string
+}
+
+// Money returns the refund amount as a currency-safe Money value.
+func (r Refund) Money() Money {
+	return NewMoney(r.Amount, r.Currency)
+}
+
+// RefundStore persists refunds.
+type RefundStore interface {
+	Save(ctx context.Context, refund Refund) error
+	Get(ctx context.Context, id string) (Refund, error)
+	ListByPayment(ctx context.Context, paymentID string) ([]Refund, error)
+	GetByGatewayReference(ctx context.Context, gatewayReference string) (Refund, error)
+}
+
+// MemoryRefundStore is a RefundStore that keeps refunds in memory.
+type MemoryRefundStore struct {
+	refunds memoryCollection[Refund]
+}
+
+// NewMemoryRefundStore creates an empty MemoryRefundStore.
+func NewMemoryRefundStore() *MemoryRefundStore {
+	return &MemoryRefundStore{}
+}
+
+// Save implements RefundStore, inserting or replacing the refund.
+func (s *MemoryRefundStore) Save(_ context.Context, refund Refund) error {
+	replaced := s.refunds.update(
+		func(r Refund) bool { return r.ID == refund.ID },
+		func(Refund) Refund { return refund },
+	)
+	if !replaced {
+		s.refunds.add(refund)
+	}
+	return nil
+}
+
+// Get implements RefundStore.
+func (s *MemoryRefundStore) Get(_ context.Context, id string) (Refund, error) {
+	found := s.refunds.filter(func(r Refund) bool { return r.ID == id })
+	if len(found) == 0 {
+		return Refund{}, ErrRefundNotFound
+	}
+	return found[0], nil
+}
+
+// ListByPayment implements RefundStore.
+func (s *MemoryRefundStore) ListByPayment(_ context.Context, paymentID string) ([]Refund, error) {
+	return s.refunds.filter(func(r Refund) bool { return r.PaymentID == paymentID }), nil
+}
+
+// GetByGatewayReference implements RefundStore.
+func (s *MemoryRefundStore) GetByGatewayReference(_ context.Context, gatewayReference string) (Refund, error) {
+	found := s.refunds.filter(func(r Refund) bool { return r.GatewayReference != "" && r.GatewayReference == gatewayReference })
+	if len(found) == 0 {
+		return Refund{}, ErrRefundNotFound
+	}
+	return found[0], nil
+}
+
+// createRefundRequest is the body accepted by POST /payments/:id/refunds. An omitted amount refunds
+// everything that is still refundable, or the named line items' total when LineItemIDs is set. Force lets an
+// admin refund outside the refund window.
+type createRefundRequest struct {
+	Amount      int64             `json:"amount"`
+	Reason      string            `json:"reason"`
+	Destination RefundDestination `json:"destination"`
+	Force       bool              `json:"force"`
+	LineItemIDs []string          `json:"line_item_ids"`
+}
+
+// RefundResponse is the JSON representation of a refund returned by the API.
+type RefundResponse struct {
+	ID          string            `json:"id"`
+	PaymentID   string            `json:"payment_id"`
+	Amount      int64             `json:"amount"`
+	Currency    string            `json:"currency"`
+	Status      RefundStatus      `json:"status"`
+	Destination RefundDestination `json:"destination"`
+	Reason      string            `json:"reason,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+
+	FailureReason string   `json:"failure_reason,omitempty"`
+	LineItemIDs   []string `json:"line_item_ids,omitempty"`
+}
+
+func newRefundResponse(refund Refund) RefundResponse {
+	return RefundResponse{
+		ID:          refund.ID,
+		PaymentID:   refund.PaymentID,
+		Amount:      refund.Amount,
+		Currency:    refund.Currency,
+		Status:      refund.Status,
+		Destination: refund.Destination,
+		Reason:      refund.Reason,
+		CreatedAt:   refund.CreatedAt,
+
+		FailureReason: refund.FailureReason,
+		LineItemIDs:   refund.LineItemIDs,
+	}
+}
+
+func (r *APIRouter) createRefund(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var req createRefundRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	if req.Destination == "" {
+		req.Destination = RefundToOriginalMethod
+	}
+	if req.Destination != RefundToOriginalMethod && req.Destination != RefundToStoreCredit {
+		return respondError(c, ErrCodeValidationFailed, "destination must be one of: original, store_credit")
+	}
+	if req.Amount < 0 {
+		return respondError(c, ErrCodeInvalidAmount, "amount must be positive")
+	}
+
+	payment, err := r.loadVisiblePayment(c)
+	if err != nil {
+		if errors.Is(err, ErrPaymentNotFound) {
+			return respondError(c, ErrCodeNotFound, "payment not found")
+		}
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	if payment.Status != PaymentStatusCaptured {
+		return respondError(c, ErrCodeInvalidState, "only captured payments can be refunded")
+	}
+	if req.Force && !r.isAdmin(c) {
+		return respondError(c, ErrCodeForbidden, "only admins can force a refund")
+	}
+	outsideWindow := r.outsideRefundWindow(payment)
+	if outsideWindow && !req.Force {
+		return respondError(c, ErrCodeRefundWindowExpired,
+			fmt.Sprintf("refunds are only allowed within %d days of capture", r.config.RefundWindowDays))
+	}
+	overLimit, err := r.refundLimitReached(ctx, payment)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to count refunds")
+	}
+	if overLimit && !req.Force {
+		return respondError(c, ErrCodeRefundLimitReached,
+			fmt.Sprintf("payments can have at most %d refunds", r.config.MaxRefundsPerPayment))
+	}
+	if req.Destination == RefundToStoreCredit && payment.CustomerID == "" {
+		return respondError(c, ErrCodeValidationFailed, "store credit refunds require a payment with a customer")
+	}
+
+	remaining, err := r.refundableAmount(ctx, payment)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to compute refundable amount")
+	}
+	amount := NewMoney(req.Amount, payment.Currency)
+	if req.Amount == 0 {
+		amount = remaining
+	}
+	if len(req.LineItemIDs) > 0 {
+		itemsTotal, err := lineItemsRefundAmount(payment.LineItems, req.LineItemIDs)
+		if errors.Is(err, ErrLineItemAlreadyRefunded) {
+			return respondError(c, ErrCodeInvalidState, err.Error())
+		}
+		if err != nil {
+			return respondError(c, ErrCodeValidationFailed, err.Error())
+		}
+		itemsAmount := NewMoney(itemsTotal, payment.Currency)
+		if cmp, err := amount.Compare(itemsAmount); req.Amount != 0 && (err != nil || cmp != 0) {
+			return respondError(c, ErrCodeInvalidAmount, "amount must be omitted or equal the line items' total")
+		}
+		amount = itemsAmount
+	}
+	if err := checkRefundable(amount, remaining); err != nil {
+		if errors.Is(err, ErrRefundExceedsRefundable) {
+			return respondError(c, ErrCodeAmountExceedsRefundable, err.Error())
+		}
+		return respondError(c, ErrCodeInternal, "failed to compute refundable amount")
+	}
+
+	refund := Refund{
+		ID:          uuid.NewString(),
+		PaymentID:   payment.ID,
+		Amount:      amount.Amount,
+		Currency:    amount.Currency,
+		Status:      RefundStatusSucceeded,
+		Destination: req.Destination,
+		Reason:      req.Reason,
+		CreatedAt:   time.Now().UTC(),
+		LineItemIDs: req.LineItemIDs,
+	}
+
+	if outsideWindow {
+		err := r.recordRefundOverride(ctx, "refund.window_override", payment, refund, map[string]string{
+			"captured_at":        payment.CapturedAt.Format(time.RFC3339),
+			"refund_window_days": strconv.Itoa(r.config.RefundWindowDays),
+		})
+		if err != nil {
+			return respondError(c, ErrCodeInternal, "failed to record audit entry")
+		}
+	}
+	if overLimit {
+		err := r.recordRefundOverride(ctx, "refund.limit_override", payment, refund, map[string]string{
+			"max_refunds_per_payment": strconv.Itoa(r.config.MaxRefundsPerPayment),
+		})
+		if err != nil {
+			return respondError(c, ErrCodeInternal, "failed to record audit entry")
+		}
+	}
+
+	switch req.Destination {
+	case RefundToStoreCredit:
+		err = r.storeCredit.Record(ctx, StoreCreditEntry{
+			ID:         uuid.NewString(),
+			CustomerID: payment.CustomerID,
+			PaymentID:  payment.ID,
+			RefundID:   refund.ID,
+			Amount:     amount,
+			CreatedAt:  refund.CreatedAt,
+		})
+		if err != nil {
+			return respondError(c, ErrCodeInternal, "failed to record store credit")
+		}
+	default:
+		result, err := r.gatewayFor(payment.TestMode).Refund(ctx, RefundRequest{
+			PaymentID:        payment.ID,
+			RefundID:         refund.ID,
+			IdempotencyKey:   GatewayIdempotencyKey(refund.ID, GatewayOpRefund, merchantClientKey(payment.MerchantID, c.Get(HeaderIdempotencyKey))),
+			GatewayReference: payment.GatewayReference,
+			Amount:           amount,
+			Method:           payment.Method,
+		})
+		if err != nil {
+			refund.Status = RefundStatusFailed
+			_ = r.refunds.Save(ctx, refund)
+			var maintenance *MaintenanceError
+			if errors.As(err, &maintenance) {
+				return respondGatewayMaintenance(c, maintenance, r.config.RetryAfterJitter)
+			}
+			return respondError(c, ErrCodeGatewayError, "gateway refund failed")
+		}
+		refund.GatewayReference = result.GatewayReference
+		if result.Pending {
+			refund.Status = RefundStatusPending
+			if err := r.refunds.Save(ctx, refund); err != nil {
+				return respondError(c, ErrCodeInternal, "failed to save refund")
+			}
+			// The items are held by the pending refund so they cannot be refunded again meanwhile.
+			held := payment
+			held.LineItems = markLineItemsRefunded(payment.LineItems, refund.LineItemIDs, refund.ID)
+			if err := r.updatePayment(ctx, payment, &held); err != nil {
+				return respondError(c, ErrCodeInternal, "failed to save payment")
+			}
+			r.setLocation(c, "payments", payment.ID, "refunds", refund.ID)
+			return c.Status(fiber.StatusAccepted).JSON(newRefundResponse(refund))
+		}
+	}
+
+	if err := r.refunds.Save(ctx, refund); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to save refund")
+	}
+	if err := r.applyRefund(ctx, payment, refund); err != nil {
+		return respondError(c, ErrCodeInternal, "failed to apply refund")
+	}
+
+	r.setLocation(c, "payments", payment.ID, "refunds", refund.ID)
+	return c.Status(fiber.StatusCreated).JSON(newRefundResponse(refund))
+}
+
+// outsideRefundWindow reports whether the payment was captured longer ago than the configured refund window.
+func (r *APIRouter) outsideRefundWindow(payment Payment) bool {
+	if r.config.RefundWindowDays <= 0 || payment.CapturedAt == nil {
+		return false
+	}
+	window := time.Duration(r.config.RefundWindowDays) * 24 * time.Hour
+	return time.Since(*payment.CapturedAt) > window
+}
+
+// refundLimitReached reports whether the payment already has the configured maximum number of refunds.
+// Failed refunds do not count, since they never moved money.
+func (r *APIRouter) refundLimitReached(ctx context.Context, payment Payment) (bool, error) {
+	if r.config.MaxRefundsPerPayment <= 0 {
+		return false, nil
+	}
+	refunds, err := r.refunds.ListByPayment(ctx, payment.ID)
+	if err != nil {
+		return false, err
+	}
+	count := 0
+	for _, refund := range refunds {
+		if refund.Status != RefundStatusFailed {
+			count++
+		}
+	}
+	return count >= r.config.MaxRefundsPerPayment, nil
+}
+
+// recordRefundOverride audits an admin forcing a refund past one of the refund rules.
+func (r *APIRouter) recordRefundOverride(ctx context.Context, action string, payment Payment, refund Refund, details map[string]string) error {
+	details["refund_id"] = refund.ID
+	details["amount"] = refund.Money().String()
+	return r.audit.Record(ctx, AuditEntry{
+		ID:           uuid.NewString(),
+		Actor:        "admin",
+		Action:       action,
+		ResourceType: "payment",
+		ResourceID:   payment.ID,
+		Reason:       refund.Reason,
+		Details:      details,
+		OccurredAt:   refund.CreatedAt,
+	})
+}
+
+// refundableAmount is what can still be refunded on a payment: the captured amount minus succeeded refunds
+// and refunds still pending at the gateway.
+func (r *APIRouter) refundableAmount(ctx context.Context, payment Payment) (Money, error) {
+	remaining, err := payment.Money().Subtract(payment.RefundedMoney())
+	if err != nil {
+		return Money{}, err
+	}
+	refunds, err := r.refunds.ListByPayment(ctx, payment.ID)
+	if err != nil {
+		return Money{}, err
+	}
+	for _, refund := range refunds {
+		if refund.Status != RefundStatusPending {
+			continue
+		}
+		if remaining, err = remaining.Subtract(refund.Money()); err != nil {
+			return Money{}, err
+		}
+	}
+	return remaining, nil
+}
+
+// checkRefundable reports whether amount can be refunded out of remaining. Comparing Money values in different
+// currencies fails with ErrCurrencyMismatch instead of comparing the bare minor-unit numbers.
+func checkRefundable(amount, remaining Money) error {
+	exceeds, err := amount.Compare(remaining)
+	if err != nil {
+		return err
+	}
+	if exceeds > 0 || amount.IsZero() || amount.IsNegative() {
+		return ErrRefundExceedsRefundable
+	}
+	return nil
+}
+
+// applyRefund books a succeeded refund: it adds the amount to the payment's refunded total, which may never
+// exceed the payment amount, and posts the ledger transactions, which take back the refunded share of each
+// split. A refund applied concurrently to the same payment is added on top rather than overwritten.
+func (r *APIRouter) applyRefund(ctx context.Context, payment Payment, refund Refund) error {
+	var refundedBefore int64
+	payment, err := r.retryPaymentUpdate(ctx, payment, func(payment Payment) (Payment, error) {
+		refundedBefore = payment.AmountRefunded
+		refunded, err := payment.RefundedMoney().Add(refund.Money())
+		if err != nil {
+			return payment, err
+		}
+		over, err := refunded.Compare(payment.Money())
+		if err != nil {
+			return payment, err
+		}
+		if over > 0 {
+			return payment, ErrRefundExceedsRefundable
+		}
+		before := payment
+		payment.AmountRefunded = refunded.Amount
+		payment.LineItems = markLineItemsRefunded(payment.LineItems, refund.LineItemIDs, refund.ID)
+		payment.UpdatedAt = time.Now().UTC()
+		err = r.updatePayment(ctx, before, &payment)
+		return payment, err
+	})
+	if err != nil {
+		return err
+	}
+	if !payment.TestMode {
+		if err := r.ledger.Post(ctx, RefundTransaction(payment.ID, refund.Money(), refund.Destination)); err != nil {
+			return err
+		}
+		reversal := SplitReversalTransaction(payment.ID, payment.Currency, payment.Splits, payment.Amount, refundedBefore, payment.AmountRefunded)
+		if len(reversal.Postings) > 0 {
+			if err := r.ledger.Post(ctx, reversal); err != nil {
+				return err
+			}
+		}
+	}
+	r.recordEvent(ctx, payment.ID, EventPaymentRefunded)
+	return nil
+}
+
+// releaseRefundLineItems makes the line items held by a failed refund refundable again.
+func (r *APIRouter) releaseRefundLineItems(ctx context.Context, refund Refund) error {
+	if len(refund.LineItemIDs) == 0 {
+		return nil
+	}
+	payment, err := r.store.Get(ctx, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+	released := payment
+	released.LineItems = releaseLineItems(payment.LineItems, refund.ID)
+	released.UpdatedAt = time.Now().UTC()
+	return r.updatePayment(ctx, payment, &released)
+}
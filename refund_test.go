@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func seedCapturedPayment(t *testing.T, store PaymentStore, id string, amount int64, customerID string) Payment {
+	capturedAt := time.Now().UTC()
+	payment := Payment{
+		ID: id, Amount: amount, Currency: "THB", Method: "card", CustomerID: customerID,
+		Status: PaymentStatusCaptured, GatewayReference: "sandbox_authorize_1",
+		CreatedAt: capturedAt, UpdatedAt: capturedAt, CapturedAt: &capturedAt,
+	}
+	assert.NoError(t, store.Save(context.Background(), payment))
+	return payment
+}
+
+func postRefund(t *testing.T, app *fiber.App, paymentID, body string) (*http.Response, RefundResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/payments/"+paymentID+"/refunds", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var refund RefundResponse
+	_ = json.NewDecoder(resp.Body).Decode(&refund)
+	return resp, refund
+}
+
+func TestCreateRefund(t *testing.T) {
+	t.Run("Original Method Refund Hits Gateway", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		gateway := NewSandboxGateway("sandbox")
+		seedCapturedPayment(t, store, "pay_1", 1000, "cus_1")
+
+		app := fiber.New()
+		router := &APIRouter{store: store, gateway: gateway}
+		router.SetupRoutes(app, Config{})
+
+		resp, refund := postRefund(t, app, "pay_1", `{"amount":400,"destination":"original"}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, RefundStatusSucceeded, refund.Status)
+		assert.Equal(t, RefundToOriginalMethod, refund.Destination)
+		assert.Equal(t, 1, gateway.Processed(GatewayOpRefund))
+
+		payment, _ := store.Get(context.Background(), "pay_1")
+		assert.Equal(t, int64(400), payment.AmountRefunded)
+	})
+
+	t.Run("Store Credit Refund Creates Ledger Entry Without Gateway Call", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		gateway := NewSandboxGateway("sandbox")
+		credit := NewMemoryStoreCreditLedger()
+		seedCapturedPayment(t, store, "pay_1", 1000, "cus_1")
+
+		app := fiber.New()
+		router := &APIRouter{store: store, gateway: gateway, storeCredit: credit}
+		router.SetupRoutes(app, Config{})
+
+		resp, refund := postRefund(t, app, "pay_1", `{"amount":250,"destination":"store_credit"}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, RefundToStoreCredit, refund.Destination)
+		assert.Equal(t, 0, gateway.Processed(GatewayOpRefund))
+
+		balance, err := credit.Balance(context.Background(), "cus_1", "THB")
+		assert.NoError(t, err)
+		assert.Equal(t, NewMoney(250, "THB"), balance)
+
+		entries, _ := credit.ListByCustomer(context.Background(), "cus_1")
+		assert.Len(t, entries, 1)
+		assert.Equal(t, refund.ID, entries[0].RefundID)
+	})
+
+	t.Run("Invalid Destination", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		seedCapturedPayment(t, store, "pay_1", 1000, "cus_1")
+
+		app := fiber.New()
+		router := &APIRouter{store: store}
+		router.SetupRoutes(app, Config{})
+
+		resp, _ := postRefund(t, app, "pay_1", `{"amount":100,"destination":"bank_transfer"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Amount Exceeds Refundable", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		seedCapturedPayment(t, store, "pay_1", 1000, "")
+
+		app := fiber.New()
+		router := &APIRouter{store: store}
+		router.SetupRoutes(app, Config{})
+
+		resp, _ := postRefund(t, app, "pay_1", `{"amount":1500}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Unknown Payment", func(t *testing.T) {
+		app := fiber.New()
+		router := &APIRouter{}
+		router.SetupRoutes(app, Config{})
+
+		resp, _ := postRefund(t, app, "missing", `{"amount":100}`)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestRefundWindow(t *testing.T) {
+	ctx := context.Background()
+	newApp := func(capturedAgo time.Duration) (*fiber.App, *MemoryAuditLog) {
+		store := NewMemoryPaymentStore()
+		payment := seedCapturedPayment(t, store, "pay_1", 1000, "")
+		capturedAt := time.Now().UTC().Add(-capturedAgo)
+		payment.CapturedAt = &capturedAt
+		assert.NoError(t, store.Save(ctx, payment))
+
+		audit := NewMemoryAuditLog()
+		app := fiber.New()
+		router := &APIRouter{store: store, audit: audit}
+		router.SetupRoutes(app, Config{RefundWindowDays: 180, AdminToken: "admin-secret"})
+		return app, audit
+	}
+
+	t.Run("Within Window", func(t *testing.T) {
+		app, audit := newApp(179 * 24 * time.Hour)
+		resp, _ := postRefund(t, app, "pay_1", `{"amount":100}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		entries, _ := audit.List(ctx)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Outside Window Rejected", func(t *testing.T) {
+		app, _ := newApp(181 * 24 * time.Hour)
+		req := httptest.NewRequest(http.MethodPost, "/payments/pay_1/refunds", strings.NewReader(`{"amount":100}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, ErrCodeRefundWindowExpired, decodeErrorCode(t, resp))
+	})
+
+	t.Run("Force Requires Admin", func(t *testing.T) {
+		app, _ := newApp(181 * 24 * time.Hour)
+		resp, _ := postRefund(t, app, "pay_1", `{"amount":100,"force":true}`)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Admin Force Override", func(t *testing.T) {
+		app, audit := newApp(181 * 24 * time.Hour)
+		req := httptest.NewRequest(http.MethodPost, "/payments/pay_1/refunds", strings.NewReader(`{"amount":100,"force":true,"reason":"goodwill"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(HeaderAdminToken, "admin-secret")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		entries, _ := audit.List(ctx)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "refund.window_override", entries[0].Action)
+		assert.Equal(t, "pay_1", entries[0].ResourceID)
+		assert.Equal(t, "goodwill", entries[0].Reason)
+	})
+}
+
+func TestMaxRefundsPerPayment(t *testing.T) {
+	ctx := context.Background()
+	newApp := func() (*fiber.App, *MemoryAuditLog) {
+		store := NewMemoryPaymentStore()
+		seedCapturedPayment(t, store, "pay_1", 1000, "")
+		audit := NewMemoryAuditLog()
+		app := fiber.New()
+		router := &APIRouter{store: store, audit: audit}
+		router.SetupRoutes(app, Config{MaxRefundsPerPayment: 2, AdminToken: "admin-secret"})
+		return app, audit
+	}
+
+	t.Run("Refunds Up To Limit Succeed And One Beyond Is Rejected", func(t *testing.T) {
+		app, _ := newApp()
+		for i := 0; i < 2; i++ {
+			resp, _ := postRefund(t, app, "pay_1", `{"amount":100}`)
+			assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/payments/pay_1/refunds", strings.NewReader(`{"amount":100}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+		assert.Equal(t, ErrCodeRefundLimitReached, decodeErrorCode(t, resp))
+	})
+
+	t.Run("Admin Force Override", func(t *testing.T) {
+		app, audit := newApp()
+		postRefund(t, app, "pay_1", `{"amount":100}`)
+		postRefund(t, app, "pay_1", `{"amount":100}`)
+
+		req := httptest.NewRequest(http.MethodPost, "/payments/pay_1/refunds", strings.NewReader(`{"amount":100,"force":true,"reason":"duplicate charge"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(HeaderAdminToken, "admin-secret")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		entries, _ := audit.List(ctx)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "refund.limit_override", entries[0].Action)
+		assert.Equal(t, "2", entries[0].Details["max_refunds_per_payment"])
+	})
+
+	t.Run("Failed Refunds Do Not Count", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		seedCapturedPayment(t, store, "pay_1", 1000, "")
+		gateway := NewSandboxGateway("sandbox")
+		app := fiber.New()
+		(&APIRouter{store: store, gateway: gateway}).SetupRoutes(app, Config{MaxRefundsPerPayment: 1})
+
+		gateway.FailNext(ErrGatewayUnavailable)
+		resp, _ := postRefund(t, app, "pay_1", `{"amount":100}`)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		resp, _ = postRefund(t, app, "pay_1", `{"amount":100}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	})
+}
+
+func TestRefundEligibilityCurrencies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Cross Currency Operands Rejected", func(t *testing.T) {
+		err := checkRefundable(NewMoney(100, "USD"), NewMoney(1000, "THB"))
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+		assert.NotErrorIs(t, err, ErrRefundExceedsRefundable)
+	})
+
+	t.Run("Same Currency Compared", func(t *testing.T) {
+		assert.NoError(t, checkRefundable(NewMoney(1000, "THB"), NewMoney(1000, "THB")))
+		assert.ErrorIs(t, checkRefundable(NewMoney(1001, "THB"), NewMoney(1000, "THB")), ErrRefundExceedsRefundable)
+		assert.ErrorIs(t, checkRefundable(NewMoney(0, "THB"), NewMoney(1000, "THB")), ErrRefundExceedsRefundable)
+	})
+
+	t.Run("Pending Refund In Other Currency Fails Loudly", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		refunds := NewMemoryRefundStore()
+		payment := seedCapturedPayment(t, store, "pay_1", 1000, "")
+		assert.NoError(t, refunds.Save(ctx, Refund{ID: "ref_usd", PaymentID: "pay_1", Amount: 100, Currency: "USD", Status: RefundStatusPending}))
+		app := fiber.New()
+		router := &APIRouter{store: store, refunds: refunds}
+		router.SetupRoutes(app, Config{})
+
+		_, err := router.refundableAmount(ctx, payment)
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+		resp, _ := postRefund(t, app, "pay_1", `{"amount":100}`)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("Applying Refund In Other Currency Rejected", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		ledger := NewMemoryLedger()
+		payment := seedCapturedPayment(t, store, "pay_1", 1000, "")
+		router := &APIRouter{store: store, ledger: ledger}
+		router.ensureDependencies(Config{})
+
+		err := router.applyRefund(ctx, payment, Refund{ID: "ref_usd", PaymentID: "pay_1", Amount: 100, Currency: "USD"})
+		assert.ErrorIs(t, err, ErrCurrencyMismatch)
+		stored, _ := store.Get(ctx, "pay_1")
+		assert.Equal(t, int64(0), stored.AmountRefunded)
+		entries, _ := ledger.ListByPayment(ctx, "pay_1")
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Applying Refund Beyond Payment Amount Rejected", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		payment := seedCapturedPayment(t, store, "pay_1", 1000, "")
+		payment.AmountRefunded = 900
+		router := &APIRouter{store: store}
+		router.ensureDependencies(Config{})
+
+		err := router.applyRefund(ctx, payment, Refund{ID: "ref_1", PaymentID: "pay_1", Amount: 200, Currency: "THB"})
+		assert.ErrorIs(t, err, ErrRefundExceedsRefundable)
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	_ "time/tzdata" // embed the zone database so TIMEZONE works on minimal images
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// dateLayout is the format of date-only query parameters.
+const dateLayout = "2006-01-02"
+
+// Amount formats accepted by the ?amounts= query parameter of reports.
+const (
+	// AmountFormatMinor reports amounts as integer minor units only; it is the default.
+	AmountFormatMinor = "minor"
+	// AmountFormatMajor adds a decimal string in major units with the currency's number of decimal places.
+	AmountFormatMajor = "major"
+)
+
+// ReportAmount is a report total in minor units alongside its major-unit rendering.
+type ReportAmount struct {
+	Money
+	Major string `json:"major,omitempty"`
+}
+
+// settlementReportResponse is a SettlementReport as served, with totals in the requested amount format.
+type settlementReportResponse struct {
+	SettlementReport
+	Totals []ReportAmount `json:"totals"`
+}
+
+// SettlementReport summarizes payments captured during one business day.
+type SettlementReport struct {
+	Date     string    `json:"date"`
+	Timezone string    `json:"timezone"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Count    int       `json:"count"`
+	Totals   []Money   `json:"totals"`
+	// Settlements breaks the totals down by the date the funds are expected to arrive.
+	Settlements []ExpectedSettlement `json:"settlements"`
+}
+
+// ExpectedSettlement totals the day's captures expected to settle on one business date, per currency.
+type ExpectedSettlement struct {
+	Date   string  `json:"date"`
+	Totals []Money `json:"totals"`
+}
+
+// dayBounds interprets a date-only value as a whole day in loc and returns its [start, end) bounds in UTC.
+func dayBounds(date string, loc *time.Location) (time.Time, time.Time, error) {
+	day, err := time.ParseInLocation(dateLayout, date, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("date must be formatted as YYYY-MM-DD: %w", err)
+	}
+	return day.UTC(), day.AddDate(0, 0, 1).UTC(), nil
+}
+
+// BuildSettlementReport totals the live payments captured within the given business day in loc, per currency.
+// Test-mode payments are left out.
+func BuildSettlementReport(ctx context.Context, store PaymentStore, date string, loc *time.Location) (SettlementReport, error) {
+	from, to, err := dayBounds(date, loc)
+	if err != nil {
+		return SettlementReport{}, err
+	}
+	payments, err := store.List(ctx)
+	if err != nil {
+		return SettlementReport{}, err
+	}
+
+	report := SettlementReport{Date: date, Timezone: loc.String(), From: from, To: to, Totals: []Money{}, Settlements: []ExpectedSettlement{}}
+	totals := make(map[string]Money)
+	settlements := make(map[string]map[string]Money)
+	for _, p := range payments {
+		if p.TestMode || p.CapturedAt == nil || p.CapturedAt.Before(from) || !p.CapturedAt.Before(to) {
+			continue
+		}
+		total, ok := totals[p.Currency]
+		if !ok {
+			total = NewMoney(0, p.Currency)
+		}
+		if totals[p.Currency], err = total.Add(p.Money()); err != nil {
+			return SettlementReport{}, err
+		}
+		report.Count++
+
+		if p.EstimatedSettlementDate == "" {
+			continue
+		}
+		if settlements[p.EstimatedSettlementDate] == nil {
+			settlements[p.EstimatedSettlementDate] = make(map[string]Money)
+		}
+		byCurrency := settlements[p.EstimatedSettlementDate]
+		settled, ok := byCurrency[p.Currency]
+		if !ok {
+			settled = NewMoney(0, p.Currency)
+		}
+		if byCurrency[p.Currency], err = settled.Add(p.Money()); err != nil {
+			return SettlementReport{}, err
+		}
+	}
+
+	report.Totals = sortedTotals(totals)
+	for settlementDate, byCurrency := range settlements {
+		report.Settlements = append(report.Settlements, ExpectedSettlement{Date: settlementDate, Totals: sortedTotals(byCurrency)})
+	}
+	sort.Slice(report.Settlements, func(i, j int) bool { return report.Settlements[i].Date < report.Settlements[j].Date })
+	return report, nil
+}
+
+// sortedTotals returns the per-currency totals ordered by currency code.
+func sortedTotals(totals map[string]Money) []Money {
+	sorted := make([]Money, 0, len(totals))
+	for _, total := range totals {
+		sorted = append(sorted, total)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Currency < sorted[j].Currency })
+	return sorted
+}
+
+func (r *APIRouter) getSettlementReport(c *fiber.Ctx) error {
+	date := c.Query("date")
+	if date == "" {
+		return respondError(c, ErrCodeInvalidRequest, "date is required")
+	}
+
+	format := c.Query("amounts", AmountFormatMinor)
+	if format != AmountFormatMinor && format != AmountFormatMajor {
+		return respondError(c, ErrCodeInvalidRequest, "amounts must be minor or major")
+	}
+
+	report, err := BuildSettlementReport(c.UserContext(), r.store, date, r.config.Location())
+	if err != nil {
+		return respondError(c, ErrCodeInvalidRequest, err.Error())
+	}
+	if format == AmountFormatMinor {
+		return c.JSON(report)
+	}
+
+	// Totals were summed in minor units; formatting happens only here, so no float arithmetic is involved.
+	response := settlementReportResponse{SettlementReport: report, Totals: make([]ReportAmount, 0, len(report.Totals))}
+	for _, total := range report.Totals {
+		response.Totals = append(response.Totals, ReportAmount{Money: total, Major: total.Decimal()})
+	}
+	return c.JSON(response)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func capturedPayment(id string, amount int64, currency string, capturedAt time.Time) Payment {
+	return Payment{
+		ID: id, Amount: amount, Currency: currency, Status: PaymentStatusCaptured,
+		CreatedAt: capturedAt, CapturedAt: &capturedAt,
+	}
+}
+
+func TestSettlementReportTimezone(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryPaymentStore()
+	// 01:30 on the 14th in Bangkok, but still the 13th in UTC.
+	_ = store.Save(ctx, capturedPayment("pay_early", 1000, "THB", time.Date(2026, 10, 13, 18, 30, 0, 0, time.UTC)))
+	// 01:30 on the 15th in Bangkok, but still the 14th in UTC.
+	_ = store.Save(ctx, capturedPayment("pay_late", 2500, "THB", time.Date(2026, 10, 14, 18, 30, 0, 0, time.UTC)))
+
+	t.Run("Configured Timezone Day Boundaries", func(t *testing.T) {
+		app := fiber.New()
+		router := &APIRouter{store: store}
+		router.SetupRoutes(app, Config{Timezone: "Asia/Bangkok"})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/reports/settlement?date=2026-10-14", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var report SettlementReport
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		assert.Equal(t, "Asia/Bangkok", report.Timezone)
+		assert.Equal(t, time.Date(2026, 10, 13, 17, 0, 0, 0, time.UTC), report.From)
+		assert.Equal(t, 1, report.Count)
+		assert.Equal(t, []Money{NewMoney(1000, "THB")}, report.Totals)
+	})
+
+	t.Run("UTC Day Boundaries", func(t *testing.T) {
+		report, err := BuildSettlementReport(ctx, store, "2026-10-14", time.UTC)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Count)
+		assert.Equal(t, []Money{NewMoney(2500, "THB")}, report.Totals)
+	})
+
+	t.Run("Invalid Date", func(t *testing.T) {
+		app := fiber.New()
+		router := &APIRouter{store: store}
+		router.SetupRoutes(app, Config{})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/reports/settlement?date=14/10/2026", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestSettlementReportAmountFormat(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryPaymentStore()
+	capturedAt := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	_ = store.Save(ctx, capturedPayment("pay_thb_1", 10010, "THB", capturedAt))
+	_ = store.Save(ctx, capturedPayment("pay_thb_2", 20020, "THB", capturedAt))
+	_ = store.Save(ctx, capturedPayment("pay_jpy", 1500, "JPY", capturedAt))
+
+	app := fiber.New()
+	(&APIRouter{store: store}).SetupRoutes(app, Config{Timezone: "UTC"})
+	get := func(query string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/reports/settlement?date=2026-10-14"+query, nil))
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Major Units Per Currency", func(t *testing.T) {
+		resp := get("&amounts=major")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var report struct {
+			Count  int            `json:"count"`
+			Totals []ReportAmount `json:"totals"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		assert.Equal(t, 3, report.Count)
+		assert.Equal(t, []ReportAmount{
+			{Money: NewMoney(1500, "JPY"), Major: "1500"},
+			{Money: NewMoney(30030, "THB"), Major: "300.30"},
+		}, report.Totals)
+	})
+
+	t.Run("Minor Units By Default", func(t *testing.T) {
+		var report SettlementReport
+		assert.NoError(t, json.NewDecoder(get("").Body).Decode(&report))
+		assert.Equal(t, []Money{NewMoney(1500, "JPY"), NewMoney(30030, "THB")}, report.Totals)
+	})
+
+	t.Run("Unknown Format", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("&amounts=cents").StatusCode)
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLength bounds the inbound X-Request-ID values that are kept, since they end up in every log line.
+const maxRequestIDLength = 128
+
+type requestIDLocalsKey struct{}
+
+// NewRequestIDMiddleware gives every request a correlation ID: the caller's X-Request-ID when it is usable, or a
+// fresh UUID otherwise. The ID is echoed in the X-Request-ID response header and handlers read it through
+// RequestIDFromCtx.
+func NewRequestIDMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Locals(requestIDLocalsKey{}, requestID)
+		c.Set(fiber.HeaderXRequestID, requestID)
+		return c.Next()
+	}
+}
+
+// RequestIDFromCtx returns the request's correlation ID, or "" when the request ID middleware is off.
+func RequestIDFromCtx(c *fiber.Ctx) string {
+	requestID, _ := c.Locals(requestIDLocalsKey{}).(string)
+	return requestID
+}
+
+// validRequestID reports whether an inbound request ID is short and printable ASCII without spaces, so that it
+// cannot break up or bloat log lines.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
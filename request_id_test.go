@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	server := NewServer(Config{MiddlewareRequestID: true}, &APIRouter{})
+	server.app.Get("/request-id", func(c *fiber.Ctx) error {
+		return c.SendString(RequestIDFromCtx(c))
+	})
+	get := func(t *testing.T, requestID string) (*http.Response, string) {
+		req := httptest.NewRequest(http.MethodGet, "/request-id", nil)
+		if requestID != "" {
+			req.Header.Set(fiber.HeaderXRequestID, requestID)
+		}
+		resp, err := server.app.Test(req)
+		assert.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp, string(body)
+	}
+
+	t.Run("Inbound ID Preserved", func(t *testing.T) {
+		resp, handlerID := get(t, "checkout-7f3a")
+		assert.Equal(t, "checkout-7f3a", resp.Header.Get(fiber.HeaderXRequestID))
+		assert.Equal(t, "checkout-7f3a", handlerID)
+	})
+
+	t.Run("Fresh ID Generated When Missing", func(t *testing.T) {
+		resp, handlerID := get(t, "")
+		requestID := resp.Header.Get(fiber.HeaderXRequestID)
+		_, err := uuid.Parse(requestID)
+		assert.NoError(t, err, requestID)
+		assert.Equal(t, requestID, handlerID)
+
+		resp, _ = get(t, "")
+		assert.NotEqual(t, requestID, resp.Header.Get(fiber.HeaderXRequestID))
+	})
+
+	t.Run("Unusable Inbound ID Replaced", func(t *testing.T) {
+		for _, requestID := range []string{strings.Repeat("a", maxRequestIDLength+1), "two words"} {
+			resp, handlerID := get(t, requestID)
+			assert.NotEqual(t, requestID, resp.Header.Get(fiber.HeaderXRequestID))
+			_, err := uuid.Parse(handlerID)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("Empty When Disabled", func(t *testing.T) {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error { return c.SendString(RequestIDFromCtx(c)) })
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.NoError(t, err)
+		assert.Empty(t, resp.Header.Get(fiber.HeaderXRequestID))
+	})
+}
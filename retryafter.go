@@ -0,0 +1,28 @@
+package main
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+// RetryAfter computes the Retry-After header sent with 429 and 503 responses. A random jitter of up to Jitter is
+// added to Base so that clients shed at the same moment do not all retry at the same instant.
+type RetryAfter struct {
+	Base   time.Duration
+	Jitter time.Duration
+}
+
+// Seconds returns a Retry-After value in whole seconds within [Base, Base+Jitter], never less than one.
+func (r RetryAfter) Seconds() int {
+	delay := r.Base
+	if r.Jitter > 0 {
+		delay += rand.N(r.Jitter + time.Nanosecond)
+	}
+	return max(1, int(delay.Round(time.Second)/time.Second))
+}
+
+// Header returns Seconds formatted for the Retry-After header.
+func (r RetryAfter) Header() string {
+	return strconv.Itoa(r.Seconds())
+}
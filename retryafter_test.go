@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryAfter(t *testing.T) {
+	t.Run("Without Jitter", func(t *testing.T) {
+		retryAfter := RetryAfter{Base: 2 * time.Second}
+		assert.Equal(t, "2", retryAfter.Header())
+	})
+
+	t.Run("Never Below One Second", func(t *testing.T) {
+		assert.Equal(t, 1, RetryAfter{}.Seconds())
+	})
+
+	t.Run("Varies Within Bounds", func(t *testing.T) {
+		retryAfter := RetryAfter{Base: 2 * time.Second, Jitter: 5 * time.Second}
+		seen := make(map[int]bool)
+		for i := 0; i < 200; i++ {
+			seconds, err := strconv.Atoi(retryAfter.Header())
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, seconds, 2)
+			assert.LessOrEqual(t, seconds, 7)
+			seen[seconds] = true
+		}
+		assert.Greater(t, len(seen), 1)
+	})
+}
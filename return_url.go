@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReturnURLs are where a hosted flow, such as a QR payment page or a 3DS challenge, sends the customer back to
+// once the payment's outcome is known.
+type ReturnURLs struct {
+	Success string `json:"success,omitempty"`
+	Pending string `json:"pending,omitempty"`
+	Failure string `json:"failure,omitempty"`
+}
+
+// IsZero reports whether no return URL is set.
+func (u ReturnURLs) IsZero() bool {
+	return u == ReturnURLs{}
+}
+
+// forStatus returns the URL for a payment in status: success once authorized, captured or verified, pending
+// while the outcome is not known yet, and failure otherwise. It is empty when that URL was not given.
+func (u ReturnURLs) forStatus(status PaymentStatus) string {
+	switch status {
+	case PaymentStatusAuthorized, PaymentStatusCaptured, PaymentStatusVerified:
+		return u.Success
+	case PaymentStatusPending, PaymentStatusInReview:
+		return u.Pending
+	default:
+		return u.Failure
+	}
+}
+
+// validateReturnURLs checks each return URL like a notification_url, against RETURN_URL_ALLOWED_HOSTS, so that
+// payments cannot be used to redirect customers to arbitrary sites or internal addresses.
+func (r *APIRouter) validateReturnURLs(urls ReturnURLs) error {
+	allowed := parseNotificationHosts(r.config.ReturnURLAllowedHosts)
+	for _, returnURL := range []struct{ field, raw string }{
+		{"return_urls.success", urls.Success},
+		{"return_urls.pending", urls.Pending},
+		{"return_urls.failure", urls.Failure},
+	} {
+		if returnURL.raw == "" {
+			continue
+		}
+		if err := validateClientURL(returnURL.field, returnURL.raw, allowed, !r.config.IsProduction()); err != nil {
+			return err
+		}
+		if err := r.outboundPolicy().CheckHost(notificationHost(returnURL.raw)); err != nil {
+			return fmt.Errorf("%s must not point at an internal address", returnURL.field)
+		}
+	}
+	return nil
+}
+
+// returnToMerchant is where hosted flows send the customer back to: it redirects to the payment's return URL for
+// its current status.
+func (r *APIRouter) returnToMerchant(c *fiber.Ctx) error {
+	payment, err := r.loadVisiblePayment(c)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return respondError(c, ErrCodeNotFound, "payment not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	target := payment.ReturnURLs.forStatus(payment.Status)
+	if target == "" {
+		return respondError(c, ErrCodeNotFound, fmt.Sprintf("payment has no return URL for status %s", payment.Status))
+	}
+	return c.Redirect(target, fiber.StatusSeeOther)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RiskDecision is a risk scorer's verdict on a payment about to be authorized.
+type RiskDecision string
+
+const (
+	// RiskAllow lets the payment proceed to authorization.
+	RiskAllow RiskDecision = "allow"
+	// RiskReview holds the payment in the review queue until an operator approves or rejects it.
+	RiskReview RiskDecision = "review"
+)
+
+// RiskScorer decides whether a new payment may be authorized straight away.
+type RiskScorer interface {
+	Score(ctx context.Context, payment Payment) (RiskDecision, error)
+}
+
+// AmountRiskScorer sends payments of at least ReviewAmount minor units to review; 0 allows every payment.
+type AmountRiskScorer struct {
+	ReviewAmount int64
+}
+
+// Score implements RiskScorer.
+func (s AmountRiskScorer) Score(_ context.Context, payment Payment) (RiskDecision, error) {
+	if s.ReviewAmount > 0 && payment.Amount >= s.ReviewAmount {
+		return RiskReview, nil
+	}
+	return RiskAllow, nil
+}
+
+// riskDecision scores payment, holding it for review when the scorer fails so that an outage never lets
+// payments skip the check.
+func (r *APIRouter) riskDecision(ctx context.Context, payment Payment) RiskDecision {
+	decision, err := r.risk.Score(ctx, payment)
+	if err != nil {
+		log.Printf("Risk scoring failed for payment %s, holding it for review: %v", payment.ID, err)
+		return RiskReview
+	}
+	return decision
+}
+
+// holdForReview parks a new payment in the review queue instead of authorizing it.
+func (r *APIRouter) holdForReview(ctx context.Context, payment Payment) (Payment, error) {
+	before := payment
+	payment.Status = PaymentStatusInReview
+	payment.UpdatedAt = time.Now().UTC()
+	if err := r.updatePayment(ctx, before, &payment); err != nil {
+		return before, err
+	}
+	r.recordEvent(ctx, payment.ID, EventPaymentHeldForReview)
+	return payment, nil
+}
+
+// reviewDecisionRequest is the body accepted by POST /admin/reviews/:id/approve and /reject.
+type reviewDecisionRequest struct {
+	Reviewer string `json:"reviewer"`
+	Reason   string `json:"reason"`
+}
+
+// listReviews lists the payments held for review, oldest first.
+func (r *APIRouter) listReviews(c *fiber.Ctx) error {
+	page, err := r.parsePage(c)
+	if err != nil {
+		return respondError(c, ErrCodeInvalidRequest, err.Error())
+	}
+	payments, err := r.store.List(c.UserContext())
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to list payments")
+	}
+	responses := make([]PaymentResponse, 0)
+	for _, payment := range payments {
+		if payment.Status == PaymentStatusInReview {
+			responses = append(responses, newPaymentResponse(payment))
+		}
+	}
+	return respondPage(c, responses, page)
+}
+
+func (r *APIRouter) approveReview(c *fiber.Ctx) error {
+	return r.decideReview(c, true)
+}
+
+func (r *APIRouter) rejectReview(c *fiber.Ctx) error {
+	return r.decideReview(c, false)
+}
+
+// decideReview releases a held payment: approving it proceeds to authorization as if it had never been held,
+// rejecting it fails it. The reviewer and decision are recorded in the audit log before the payment moves on.
+func (r *APIRouter) decideReview(c *fiber.Ctx, approve bool) error {
+	var req reviewDecisionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	if req.Reviewer == "" {
+		return respondError(c, ErrCodeValidationFailed, "reviewer is required")
+	}
+
+	ctx := c.UserContext()
+	payment, err := r.store.Get(ctx, c.Params("id"))
+	if errors.Is(err, ErrPaymentNotFound) {
+		return respondError(c, ErrCodeNotFound, "payment not found")
+	}
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	if payment.Status != PaymentStatusInReview {
+		return respondError(c, ErrCodeInvalidState, "only payments in review can be approved or rejected")
+	}
+
+	decision := "reject"
+	if approve {
+		decision = "approve"
+	}
+	err = r.audit.Record(ctx, AuditEntry{
+		ID:           uuid.NewString(),
+		Actor:        req.Reviewer,
+		Action:       "review." + decision,
+		ResourceType: "payment",
+		ResourceID:   payment.ID,
+		Reason:       req.Reason,
+		Details:      map[string]string{"decision": decision, "amount": payment.Money().String()},
+		OccurredAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to record review decision")
+	}
+
+	if !approve {
+		before := payment
+		payment.Status = PaymentStatusFailed
+		payment.DeclineReason = "review_rejected"
+		payment.UpdatedAt = time.Now().UTC()
+		err := r.updatePayment(ctx, before, &payment)
+		if errors.Is(err, ErrPaymentVersionConflict) {
+			return respondError(c, ErrCodeConcurrentModification, "payment was changed by another request")
+		}
+		if err != nil {
+			return respondError(c, ErrCodeInternal, "failed to save payment")
+		}
+		r.recordEvent(ctx, payment.ID, EventPaymentFailed)
+		return c.JSON(newPaymentResponse(payment))
+	}
+
+	payment.Status = PaymentStatusPending
+	payment, err = r.proceedPayment(ctx, payment, payment.IdempotencyKey)
+	if errors.Is(err, ErrMethodUnavailable) {
+		return respondMethodUnavailable(c, "payment method "+payment.Method+" is temporarily unavailable", r.config.RetryAfterJitter)
+	}
+	var maintenance *MaintenanceError
+	if errors.As(err, &maintenance) {
+		return respondGatewayMaintenance(c, maintenance, r.config.RetryAfterJitter)
+	}
+	if err != nil {
+		return respondError(c, ErrCodeGatewayError, "payment gateway error")
+	}
+	return c.JSON(newPaymentResponse(payment))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func postReviewDecision(t *testing.T, app *fiber.App, paymentID, decision, body string) (*http.Response, PaymentResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/admin/reviews/"+paymentID+"/"+decision, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderAdminToken, "admin-secret")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var payment PaymentResponse
+	_ = json.NewDecoder(resp.Body).Decode(&payment)
+	return resp, payment
+}
+
+func TestReviewQueue(t *testing.T) {
+	ctx := context.Background()
+	newApp := func() (*fiber.App, *SandboxGateway, *MemoryAuditLog) {
+		sandbox := NewSandboxGateway("sandbox")
+		audit := NewMemoryAuditLog()
+		app := fiber.New()
+		(&APIRouter{store: NewMemoryPaymentStore(), gateway: sandbox, audit: audit}).
+			SetupRoutes(app, Config{RiskReviewAmount: 50000, AdminToken: "admin-secret"})
+		return app, sandbox, audit
+	}
+
+	t.Run("Payment Held For Review", func(t *testing.T) {
+		app, sandbox, _ := newApp()
+
+		resp, held := postPayment(t, app, `{"amount":75000,"currency":"THB"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, PaymentStatusInReview, held.Status)
+		assert.Empty(t, sandbox.ReceivedKeys(GatewayOpAuthorize))
+
+		_, allowed := postPayment(t, app, `{"amount":1000,"currency":"THB"}`, nil)
+		assert.Equal(t, PaymentStatusAuthorized, allowed.Status)
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/reviews", nil)
+		req.Header.Set(HeaderAdminToken, "admin-secret")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var page struct {
+			Data []PaymentResponse `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+		if assert.Len(t, page.Data, 1) {
+			assert.Equal(t, held.ID, page.Data[0].ID)
+		}
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/admin/reviews", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Approved Payment Is Authorized", func(t *testing.T) {
+		app, sandbox, audit := newApp()
+		_, held := postPayment(t, app, `{"amount":75000,"currency":"THB"}`, nil)
+
+		resp, payment := postReviewDecision(t, app, held.ID, "approve", `{"reviewer":"ops@example.com","reason":"known customer"}`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, PaymentStatusAuthorized, payment.Status)
+		assert.Equal(t, 1, sandbox.Processed(GatewayOpAuthorize))
+
+		entries, _ := audit.List(ctx)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "ops@example.com", entries[0].Actor)
+			assert.Equal(t, "review.approve", entries[0].Action)
+			assert.Equal(t, held.ID, entries[0].ResourceID)
+			assert.Equal(t, "approve", entries[0].Details["decision"])
+		}
+
+		resp, _ = postReviewDecision(t, app, held.ID, "approve", `{"reviewer":"ops@example.com"}`)
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Rejected Payment Fails", func(t *testing.T) {
+		app, sandbox, audit := newApp()
+		_, held := postPayment(t, app, `{"amount":75000,"currency":"THB"}`, nil)
+
+		resp, payment := postReviewDecision(t, app, held.ID, "reject", `{"reviewer":"ops@example.com","reason":"stolen card"}`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, PaymentStatusFailed, payment.Status)
+		assert.Equal(t, "review_rejected", payment.DeclineReason)
+		assert.Empty(t, sandbox.ReceivedKeys(GatewayOpAuthorize))
+
+		entries, _ := audit.List(ctx)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "review.reject", entries[0].Action)
+			assert.Equal(t, "stolen card", entries[0].Reason)
+		}
+	})
+
+	t.Run("Reviewer Required", func(t *testing.T) {
+		app, _, _ := newApp()
+		_, held := postPayment(t, app, `{"amount":75000,"currency":"THB"}`, nil)
+
+		resp, _ := postReviewDecision(t, app, held.ID, "approve", `{}`)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrSchemaOutdated is returned when the store's schema is older than the version this deployment expects.
+var ErrSchemaOutdated = errors.New("schema migrations are behind")
+
+// schemaPollInterval is how often the schema version is re-read while waiting for migrations.
+const schemaPollInterval = time.Second
+
+// WaitForSchemaVersion reads the applied schema version until it reaches expected or wait elapses, so that a
+// deployment rolled out ahead of its migrations does not serve traffic against a stale schema. A schema still
+// behind after the wait is logged, and only returned as an error when strict is set, like other startup checks.
+func WaitForSchemaVersion(ctx context.Context, version func(context.Context) (int, error), expected int, wait, poll time.Duration, strict bool) error {
+	deadline := time.Now().Add(wait)
+	for {
+		current, err := version(ctx)
+		if err == nil && current >= expected {
+			log.Printf("Startup schema check: schema at version %d", current)
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%w: at version %d, want %d", ErrSchemaOutdated, current, expected)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			log.Printf("Startup schema check failed: %v", err)
+			if strict {
+				return err
+			}
+			return nil
+		}
+		log.Printf("Startup schema check: %v; checking again", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(poll, remaining)):
+		}
+	}
+}
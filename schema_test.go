@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	versions := func(sequence ...int) func(context.Context) (int, error) {
+		return func(context.Context) (int, error) {
+			current := sequence[0]
+			if len(sequence) > 1 {
+				sequence = sequence[1:]
+			}
+			return current, nil
+		}
+	}
+
+	t.Run("Matching Version Proceeds", func(t *testing.T) {
+		err := WaitForSchemaVersion(ctx, versions(3), 3, 0, time.Millisecond, true)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Waits For Migrations To Finish", func(t *testing.T) {
+		err := WaitForSchemaVersion(ctx, versions(1, 2, 3), 3, time.Second, time.Millisecond, true)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Older Version Blocks Startup When Strict", func(t *testing.T) {
+		err := WaitForSchemaVersion(ctx, versions(2), 3, 10*time.Millisecond, time.Millisecond, true)
+		assert.ErrorIs(t, err, ErrSchemaOutdated)
+	})
+
+	t.Run("Older Version Only Logged When Not Strict", func(t *testing.T) {
+		err := WaitForSchemaVersion(ctx, versions(2), 3, 0, time.Millisecond, false)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrInvalidCredentials is returned when a gateway rejects the configured API credentials.
+var ErrInvalidCredentials = errors.New("invalid gateway credentials")
+
+// selfTestTimeout bounds each gateway credential check so a hanging gateway cannot stall startup.
+const selfTestTimeout = 5 * time.Second
+
+// CredentialVerifier is implemented by gateways that can verify their credentials with a harmless
+// authenticated call (e.g. fetching the account) that does not move money.
+type CredentialVerifier interface {
+	VerifyCredentials(ctx context.Context) error
+}
+
+// RunGatewaySelfTest verifies the credentials of each gateway that supports it, waiting throttle between
+// calls so restarts of many replicas do not burst the gateway. Failures are logged; they are only returned
+// as an error when strict is set, so the caller can abort startup.
+func RunGatewaySelfTest(ctx context.Context, gateways []PaymentGateway, throttle time.Duration, strict bool) error {
+	var failures []error
+	for i, gateway := range gateways {
+		verifier, ok := gatewayAs[CredentialVerifier](gateway)
+		if !ok {
+			log.Printf("Startup self-test: gateway %s does not support credential checks, skipping", gateway.Name())
+			continue
+		}
+		if i > 0 && throttle > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(throttle):
+			}
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+		err := verifier.VerifyCredentials(checkCtx)
+		cancel()
+		if err != nil {
+			log.Printf("Startup self-test: gateway %s credential check failed: %v", gateway.Name(), err)
+			failures = append(failures, fmt.Errorf("gateway %s: %w", gateway.Name(), err))
+			continue
+		}
+		log.Printf("Startup self-test: gateway %s credentials verified", gateway.Name())
+	}
+
+	if strict && len(failures) > 0 {
+		return errors.Join(failures...)
+	}
+	return nil
+}
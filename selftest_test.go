@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunGatewaySelfTest(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer func() { log.SetOutput(os.Stderr) }()
+
+	t.Run("Passing Self-Test", func(t *testing.T) {
+		buf.Reset()
+		gateway := NewSandboxGateway("sandbox")
+
+		err := RunGatewaySelfTest(context.Background(), []PaymentGateway{gateway}, 0, true)
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "gateway sandbox credentials verified")
+	})
+
+	t.Run("Failing Self-Test Aborts Under Strict Mode", func(t *testing.T) {
+		buf.Reset()
+		gateway := NewSandboxGateway("sandbox")
+		gateway.SetCredentialsError(ErrInvalidCredentials)
+
+		err := RunGatewaySelfTest(context.Background(), []PaymentGateway{gateway}, 0, true)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		assert.Contains(t, buf.String(), "gateway sandbox credential check failed")
+	})
+
+	t.Run("Failing Self-Test Only Logs Without Strict Mode", func(t *testing.T) {
+		buf.Reset()
+		gateway := NewSandboxGateway("sandbox")
+		gateway.SetCredentialsError(ErrInvalidCredentials)
+
+		err := RunGatewaySelfTest(context.Background(), []PaymentGateway{gateway}, 0, false)
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "credential check failed")
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSettlementDelayDays is the number of business days funds take to arrive when no delay is configured
+// for a payment's gateway or method.
+const defaultSettlementDelayDays = 2
+
+// thaiPublicHolidays are the Thai public holidays that fall on the same date every year, in the
+// SETTLEMENT_HOLIDAYS format. Holidays that follow the lunar calendar, such as Makha Bucha and Visakha Bucha,
+// and substitution days move each year and are added to SETTLEMENT_HOLIDAYS as full dates.
+const thaiPublicHolidays = "01-01,04-06,04-13,04-14,04-15,05-01,05-04,06-03,07-28,08-12,10-13,10-23,12-05,12-10,12-31"
+
+// Holiday date formats accepted in SETTLEMENT_HOLIDAYS.
+const (
+	holidayLayoutYearly = "01-02"
+	holidayLayoutDate   = dateLayout
+)
+
+// BusinessCalendar tells business days from weekends and holidays, for estimating when funds settle.
+type BusinessCalendar struct {
+	// yearly holds "MM-DD" holidays observed every year, dates holds "YYYY-MM-DD" holidays observed once.
+	yearly map[string]bool
+	dates  map[string]bool
+}
+
+// parseBusinessCalendar parses SETTLEMENT_HOLIDAYS, a comma-separated list of holidays given either as "MM-DD",
+// observed every year, or as "YYYY-MM-DD", observed once.
+func parseBusinessCalendar(spec string) (BusinessCalendar, error) {
+	calendar := BusinessCalendar{yearly: make(map[string]bool), dates: make(map[string]bool)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, err := time.Parse(holidayLayoutYearly, entry); err == nil {
+			calendar.yearly[entry] = true
+			continue
+		}
+		if _, err := time.Parse(holidayLayoutDate, entry); err == nil {
+			calendar.dates[entry] = true
+			continue
+		}
+		return BusinessCalendar{}, fmt.Errorf("invalid SETTLEMENT_HOLIDAYS entry %q: want MM-DD or YYYY-MM-DD", entry)
+	}
+	return calendar, nil
+}
+
+// IsBusinessDay reports whether day is neither a weekend nor a holiday, judged by its date in its own location.
+func (cal BusinessCalendar) IsBusinessDay(day time.Time) bool {
+	if weekday := day.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+		return false
+	}
+	return !cal.yearly[day.Format(holidayLayoutYearly)] && !cal.dates[day.Format(holidayLayoutDate)]
+}
+
+// AddBusinessDays returns the date n business days after day. With n of 0 it returns day itself when that is a
+// business day and the next business day otherwise.
+func (cal BusinessCalendar) AddBusinessDays(day time.Time, n int) time.Time {
+	for n == 0 && !cal.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	for n > 0 {
+		day = day.AddDate(0, 0, 1)
+		if cal.IsBusinessDay(day) {
+			n--
+		}
+	}
+	return day
+}
+
+// parseSettlementDelays parses SETTLEMENT_DELAYS, business-day settlement delays keyed by "gateway", "method"
+// or "gateway/method", such as "kbank=1,promptpay=0,kbank/card=2".
+func parseSettlementDelays(spec string) (map[string]int, error) {
+	delays := make(map[string]int)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, rawDays, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		days, err := strconv.Atoi(strings.TrimSpace(rawDays))
+		if !ok || key == "" || err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid SETTLEMENT_DELAYS entry %q: want key=non-negative integer", entry)
+		}
+		delays[key] = days
+	}
+	return delays, nil
+}
+
+// settlementDelayDays is how many business days a payment through gateway with method takes to settle. The most
+// specific configured delay wins: gateway and method together, then the method, then the gateway.
+func (r *APIRouter) settlementDelayDays(gateway, method string) int {
+	// Validate has already rejected malformed SETTLEMENT_DELAYS.
+	delays, _ := parseSettlementDelays(r.config.SettlementDelays)
+	for _, key := range []string{gateway + "/" + method, method, gateway} {
+		if days, ok := delays[key]; ok {
+			return days
+		}
+	}
+	if r.config.SettlementDelayDays > 0 {
+		return r.config.SettlementDelayDays
+	}
+	return defaultSettlementDelayDays
+}
+
+// estimateSettlementDate returns the business date, as YYYY-MM-DD in the business timezone, on which a captured
+// payment's funds are expected to arrive, or "" for a payment that is not captured.
+func (r *APIRouter) estimateSettlementDate(payment Payment) string {
+	if payment.CapturedAt == nil {
+		return ""
+	}
+	// Validate has already rejected malformed SETTLEMENT_HOLIDAYS.
+	calendar, _ := parseBusinessCalendar(r.config.SettlementHolidays)
+	loc := r.config.Location()
+	captured := payment.CapturedAt.In(loc)
+	day := time.Date(captured.Year(), captured.Month(), captured.Day(), 0, 0, 0, 0, loc)
+	delay := r.settlementDelayDays(r.gatewayFor(payment.TestMode).Name(), payment.Method)
+	return calendar.AddBusinessDays(day, delay).Format(dateLayout)
+}
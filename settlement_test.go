@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusinessCalendar(t *testing.T) {
+	calendar, err := parseBusinessCalendar(thaiPublicHolidays + ",2026-03-03")
+	assert.NoError(t, err)
+	day := func(date string) time.Time {
+		d, _ := time.Parse(dateLayout, date)
+		return d
+	}
+
+	t.Run("Skips Weekend", func(t *testing.T) {
+		assert.Equal(t, "2026-10-12", calendar.AddBusinessDays(day("2026-10-09"), 1).Format(dateLayout))
+		assert.Equal(t, "2026-10-12", calendar.AddBusinessDays(day("2026-10-10"), 0).Format(dateLayout))
+	})
+
+	t.Run("Skips Configured Holidays", func(t *testing.T) {
+		// King Bhumibol Memorial Day, observed every year on 13 October.
+		assert.Equal(t, "2026-10-14", calendar.AddBusinessDays(day("2026-10-12"), 1).Format(dateLayout))
+		// Songkran on Monday to Wednesday after a weekend.
+		assert.Equal(t, "2026-04-16", calendar.AddBusinessDays(day("2026-04-10"), 1).Format(dateLayout))
+		// A one-off date, such as a lunar holiday.
+		assert.Equal(t, "2026-03-04", calendar.AddBusinessDays(day("2026-03-02"), 1).Format(dateLayout))
+		assert.True(t, calendar.IsBusinessDay(day("2027-03-03")))
+	})
+
+	t.Run("Rejects Malformed Holidays", func(t *testing.T) {
+		for _, spec := range []string{"13-10", "2026-02-30", "songkran"} {
+			_, err := parseBusinessCalendar(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+}
+
+func TestEstimatedSettlementDate(t *testing.T) {
+	ctx := context.Background()
+	config := Config{Timezone: "Asia/Bangkok", SettlementHolidays: "10-13", SettlementDelays: "card=1,promptpay=0,sandbox/bank_transfer=3"}
+	router := &APIRouter{}
+	router.ensureDependencies(config)
+	// Friday 9 October, 20:00 in Bangkok.
+	capturedAt := time.Date(2026, 10, 9, 13, 0, 0, 0, time.UTC)
+	estimate := func(method string) string {
+		return router.estimateSettlementDate(Payment{Method: method, CapturedAt: &capturedAt})
+	}
+
+	t.Run("Delay Per Method And Gateway", func(t *testing.T) {
+		assert.Equal(t, "2026-10-12", estimate("card"))
+		assert.Equal(t, "2026-10-09", estimate(MethodPromptPay))
+		assert.Equal(t, "2026-10-15", estimate(MethodBankTransfer))
+		assert.Equal(t, "2026-10-14", estimate("wallet"), "default of two business days")
+		assert.Empty(t, router.estimateSettlementDate(Payment{Method: "card"}))
+	})
+
+	t.Run("Business Timezone Decides Capture Day", func(t *testing.T) {
+		// 02:00 on Saturday in Bangkok is still Friday in UTC.
+		late := time.Date(2026, 10, 9, 19, 0, 0, 0, time.UTC)
+		assert.Equal(t, "2026-10-12", router.estimateSettlementDate(Payment{Method: MethodPromptPay, CapturedAt: &late}))
+	})
+
+	t.Run("Returned On Capture And In Report", func(t *testing.T) {
+		store := NewMemoryPaymentStore()
+		app := fiber.New()
+		(&APIRouter{store: store}).SetupRoutes(app, config)
+		_, created := postPayment(t, app, `{"amount":1000,"currency":"THB","token":"tok_visa"}`, nil)
+
+		resp, captured := postCaptureRequest(t, app, created.ID)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.NotEmpty(t, captured.EstimatedSettlementDate)
+		stored, _ := store.Get(ctx, created.ID)
+		assert.Equal(t, router.estimateSettlementDate(stored), captured.EstimatedSettlementDate)
+
+		today := stored.CapturedAt.In(config.Location()).Format(dateLayout)
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/reports/settlement?date="+today, nil))
+		assert.NoError(t, err)
+		var report SettlementReport
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		if assert.Len(t, report.Settlements, 1) {
+			assert.Equal(t, captured.EstimatedSettlementDate, report.Settlements[0].Date)
+			assert.Equal(t, []Money{NewMoney(1000, "THB")}, report.Settlements[0].Totals)
+		}
+	})
+}
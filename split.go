@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Split is the share of a marketplace payment owed to one recipient, such as a seller. Amounts are fixed in
+// minor units when the payment is created; whatever the splits leave over stays with the platform.
+type Split struct {
+	Recipient string
+	Amount    int64
+}
+
+// splitInput is a split as sent on POST /payments: either an amount, which follows the payment's amount input
+// mode, or a percentage of the payment amount with at most two decimal places.
+type splitInput struct {
+	Recipient  string      `json:"recipient"`
+	Amount     AmountInput `json:"amount"`
+	Percentage float64     `json:"percentage"`
+}
+
+// SplitResponse is the JSON representation of a split returned by the API.
+type SplitResponse struct {
+	Recipient string `json:"recipient"`
+	Amount    int64  `json:"amount"`
+}
+
+func newSplitResponses(splits []Split) []SplitResponse {
+	if len(splits) == 0 {
+		return nil
+	}
+	responses := make([]SplitResponse, 0, len(splits))
+	for _, split := range splits {
+		responses = append(responses, SplitResponse{Recipient: split.Recipient, Amount: split.Amount})
+	}
+	return responses
+}
+
+// newSplits validates the splits of a create request and resolves percentages to amounts. Recipients must be
+// present and unique, each split needs a positive amount or a percentage but not both, percentages may total at
+// most 100% and all splits together may not exceed the payment amount less the platform fee, which would leave
+// the merchant owing the platform.
+//
+// Percentage splits are rounded with the largest remainder method: each gets its share rounded down, and the
+// minor units those roundings lose go one at a time to the splits with the largest discarded fractions, earlier
+// splits first on ties. The same request therefore always resolves to the same amounts, and percentages that
+// total 100% always add up to the full payment amount.
+func newSplits(inputs []splitInput, mode AmountInputMode, currency string, paymentAmount, fee int64) ([]Split, error) {
+	splits := make([]Split, len(inputs))
+	basisPoints := make([]int64, len(inputs))
+	seen := make(map[string]bool, len(inputs))
+	fixed := NewMoney(0, currency)
+	var totalBasisPoints int64
+	for i, input := range inputs {
+		if input.Recipient == "" {
+			return nil, errors.New("every split needs a recipient")
+		}
+		if seen[input.Recipient] {
+			return nil, fmt.Errorf("split recipient %q is listed twice", input.Recipient)
+		}
+		seen[input.Recipient] = true
+		splits[i].Recipient = input.Recipient
+
+		amount, err := input.Amount.MinorUnits(mode, currency)
+		if err != nil {
+			return nil, fmt.Errorf("split %q: %w", input.Recipient, err)
+		}
+		switch {
+		case amount != 0 && input.Percentage != 0:
+			return nil, fmt.Errorf("split %q must have an amount or a percentage, not both", input.Recipient)
+		case input.Percentage != 0:
+			points := math.Round(input.Percentage * 100)
+			if input.Percentage < 0 || points > 10000 || math.Abs(input.Percentage*100-points) > 1e-6 {
+				return nil, fmt.Errorf("split %q percentage must be between 0 and 100 with at most two decimal places", input.Recipient)
+			}
+			basisPoints[i] = int64(points)
+			totalBasisPoints += basisPoints[i]
+		case amount > 0:
+			splits[i].Amount = amount
+			if fixed, err = fixed.Add(NewMoney(amount, currency)); err != nil {
+				return nil, fmt.Errorf("splits total: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("split %q must have a positive amount or a percentage", input.Recipient)
+		}
+	}
+	if totalBasisPoints > 10000 {
+		return nil, fmt.Errorf("split percentages total %s%%, more than 100%%", formatBasisPoints(totalBasisPoints))
+	}
+
+	if paymentAmount < 0 {
+		return nil, fmt.Errorf("cannot split a negative payment amount %d", paymentAmount)
+	}
+	allocated := allocateBasisPoints(paymentAmount, basisPoints)
+	total := fixed
+	for i := range splits {
+		splits[i].Amount += allocated[i]
+		var err error
+		if total, err = total.Add(NewMoney(allocated[i], currency)); err != nil {
+			return nil, fmt.Errorf("splits total: %w", err)
+		}
+	}
+	if total.Amount > paymentAmount {
+		return nil, fmt.Errorf("splits total %d, more than the payment amount %d", total.Amount, paymentAmount)
+	}
+	if fee > 0 && total.Amount > paymentAmount-fee {
+		return nil, fmt.Errorf("splits total %d, more than the payment amount %d less the platform fee %d", total.Amount, paymentAmount, fee)
+	}
+	return splits, nil
+}
+
+// allocateBasisPoints divides the share of amount given by the sum of basisPoints between them with the largest
+// remainder method described on newSplits. Entries with zero basis points get nothing. amount may not be negative
+// and basisPoints may total at most 10000.
+func allocateBasisPoints(amount int64, basisPoints []int64) []int64 {
+	shares := make([]int64, len(basisPoints))
+	remainders := make([]int64, len(basisPoints))
+	var sum, totalBasisPoints int64
+	for i, points := range basisPoints {
+		shares[i], remainders[i] = mulBasisPoints(amount, points)
+		sum += shares[i]
+		totalBasisPoints += points
+	}
+
+	order := make([]int, 0, len(basisPoints))
+	for i, points := range basisPoints {
+		if points > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	// Each entry loses less than one minor unit to rounding, so at most one unit goes to each of them.
+	whole, _ := mulBasisPoints(amount, totalBasisPoints)
+	for _, i := range order[:min(whole-sum, int64(len(order)))] {
+		shares[i]++
+	}
+	return shares
+}
+
+// mulBasisPoints returns amount * basisPoints / 10000, rounded down, and the remainder of that division. It
+// splits amount at 10000 so that neither product can overflow for basis points up to 10000.
+func mulBasisPoints(amount, basisPoints int64) (share, remainder int64) {
+	high, low := amount/10000, amount%10000
+	return high*basisPoints + low*basisPoints/10000, low * basisPoints % 10000
+}
+
+// formatBasisPoints renders basis points as a percentage, such as 100.5 for 10050.
+func formatBasisPoints(points int64) string {
+	if points%100 == 0 {
+		return fmt.Sprintf("%d", points/100)
+	}
+	return fmt.Sprintf("%d.%02d", points/100, points%100)
+}
+
+// splitAccount is the ledger account holding what the platform owes a split recipient.
+func splitAccount(recipient string) LedgerAccount {
+	return AccountRecipientPayable + ":" + LedgerAccount(recipient)
+}
+
+// SplitTransaction builds the postings that move each split's amount from what we owe the merchant to what we
+// owe its recipient.
+func SplitTransaction(paymentID string, currency string, splits []Split) LedgerTransaction {
+	postings := make([]Posting, 0, 2*len(splits))
+	for _, split := range splits {
+		amount := NewMoney(split.Amount, currency)
+		postings = append(postings,
+			Posting{Account: AccountMerchantReceivable, Direction: Debit, Amount: amount},
+			Posting{Account: splitAccount(split.Recipient), Direction: Credit, Amount: amount},
+		)
+	}
+	return LedgerTransaction{ID: uuid.NewString(), PaymentID: paymentID, Kind: "split", Postings: postings, CreatedAt: time.Now().UTC()}
+}
+
+// SplitReversalTransaction builds the postings that take back from each recipient its share of a refund that
+// brought the payment's refunded total from refundedBefore to refundedAfter, returning it to what we owe the
+// merchant. Shares are computed on the running total, so partial refunds together reverse exactly the full
+// splits once the whole payment is refunded.
+func SplitReversalTransaction(paymentID string, currency string, splits []Split, paymentAmount, refundedBefore, refundedAfter int64) LedgerTransaction {
+	postings := make([]Posting, 0, 2*len(splits))
+	for _, split := range splits {
+		reversed := refundedShare(split.Amount, refundedAfter, paymentAmount) - refundedShare(split.Amount, refundedBefore, paymentAmount)
+		if reversed <= 0 {
+			continue
+		}
+		amount := NewMoney(reversed, currency)
+		postings = append(postings,
+			Posting{Account: splitAccount(split.Recipient), Direction: Debit, Amount: amount},
+			Posting{Account: AccountMerchantReceivable, Direction: Credit, Amount: amount},
+		)
+	}
+	return LedgerTransaction{ID: uuid.NewString(), PaymentID: paymentID, Kind: "split_reversal", Postings: postings, CreatedAt: time.Now().UTC()}
+}
+
+// refundedShare returns splitAmount * refunded / paymentAmount, rounded down. The product is taken at 128 bits
+// so that it cannot overflow; neither amount may be negative or exceed paymentAmount.
+func refundedShare(splitAmount, refunded, paymentAmount int64) int64 {
+	if paymentAmount <= 0 {
+		return 0
+	}
+	high, low := bits.Mul64(uint64(splitAmount), uint64(refunded))
+	share, _ := bits.Div64(high, low, uint64(paymentAmount))
+	return int64(share)
+}
+
+// PayoutStatus is the lifecycle state of a payout to a split recipient.
+type PayoutStatus string
+
+// PayoutPending is a payout that is owed but has not been paid out yet.
+const PayoutPending PayoutStatus = "pending"
+
+// Payout is what one split recipient is owed for a captured payment.
+type Payout struct {
+	ID        string       `json:"id"`
+	PaymentID string       `json:"payment_id"`
+	Recipient string       `json:"recipient"`
+	Amount    Money        `json:"amount"`
+	Status    PayoutStatus `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// PayoutStore persists payouts to split recipients.
+type PayoutStore interface {
+	Create(ctx context.Context, payout Payout) error
+	ListByPayment(ctx context.Context, paymentID string) ([]Payout, error)
+}
+
+// MemoryPayoutStore is a PayoutStore that keeps payouts in memory.
+type MemoryPayoutStore struct {
+	payouts memoryCollection[Payout]
+}
+
+// NewMemoryPayoutStore creates an empty MemoryPayoutStore.
+func NewMemoryPayoutStore() *MemoryPayoutStore {
+	return &MemoryPayoutStore{}
+}
+
+// Create implements PayoutStore.
+func (s *MemoryPayoutStore) Create(_ context.Context, payout Payout) error {
+	s.payouts.add(payout)
+	return nil
+}
+
+// ListByPayment implements PayoutStore, returning payouts in creation order.
+func (s *MemoryPayoutStore) ListByPayment(_ context.Context, paymentID string) ([]Payout, error) {
+	return s.payouts.filter(func(payout Payout) bool { return payout.PaymentID == paymentID }), nil
+}
+
+// postSplits books a captured payment's splits and records a pending payout for each recipient.
+func (r *APIRouter) postSplits(ctx context.Context, payment Payment) error {
+	if len(payment.Splits) == 0 {
+		return nil
+	}
+	tx := SplitTransaction(payment.ID, payment.Currency, payment.Splits)
+	if err := r.ledger.Post(ctx, tx); err != nil {
+		return err
+	}
+	for _, split := range payment.Splits {
+		payout := Payout{
+			ID:        uuid.NewString(),
+			PaymentID: payment.ID,
+			Recipient: split.Recipient,
+			Amount:    NewMoney(split.Amount, payment.Currency),
+			Status:    PayoutPending,
+			CreatedAt: tx.CreatedAt,
+		}
+		if err := r.payouts.Create(ctx, payout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *APIRouter) listPaymentPayouts(c *fiber.Ctx) error {
+	paymentID := c.Params("id")
+	if _, err := r.loadVisiblePayment(c); err != nil {
+		if errors.Is(err, ErrPaymentNotFound) {
+			return respondError(c, ErrCodeNotFound, "payment not found")
+		}
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+	payouts, err := r.payouts.ListByPayment(c.UserContext(), paymentID)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to list payouts")
+	}
+	if payouts == nil {
+		payouts = []Payout{}
+	}
+	return c.JSON(fiber.Map{"payouts": payouts})
+}
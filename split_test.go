@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentSplits(t *testing.T) {
+	ctx := context.Background()
+	newApp := func() (*fiber.App, *MemoryLedger, *MemoryPayoutStore) {
+		ledger := NewMemoryLedger()
+		payouts := NewMemoryPayoutStore()
+		app := fiber.New()
+		(&APIRouter{gateway: NewSandboxGateway("sandbox"), ledger: ledger, payouts: payouts}).
+			SetupRoutes(app, Config{PlatformFeeBasisPoints: 300})
+		return app, ledger, payouts
+	}
+
+	t.Run("Two Way Split Sums Correctly", func(t *testing.T) {
+		app, ledger, payouts := newApp()
+
+		resp, payment := postPayment(t, app, `{"amount":1001,"currency":"THB","splits":[`+
+			`{"recipient":"seller_a","percentage":60.5},{"recipient":"seller_b","percentage":36.5}]}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, []SplitResponse{{Recipient: "seller_a", Amount: 605}, {Recipient: "seller_b", Amount: 365}}, payment.Splits)
+		recorded, _ := payouts.ListByPayment(ctx, payment.ID)
+		assert.Empty(t, recorded, "nothing is owed before the capture")
+
+		resp, _ = postCaptureRequest(t, app, payment.ID)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		recorded, _ = payouts.ListByPayment(ctx, payment.ID)
+		if assert.Len(t, recorded, 2) {
+			assert.Equal(t, NewMoney(605, "THB"), recorded[0].Amount)
+			assert.Equal(t, NewMoney(365, "THB"), recorded[1].Amount)
+			assert.Equal(t, PayoutPending, recorded[1].Status)
+		}
+		balances, _ := ledger.Balances(ctx)
+		assert.Equal(t, int64(-605), balanceOf(balances, splitAccount("seller_a")))
+		assert.Equal(t, int64(-365), balanceOf(balances, splitAccount("seller_b")))
+		assert.Equal(t, int64(-1), balanceOf(balances, AccountMerchantReceivable), "the merchant keeps what the 30 fee and the splits leave")
+
+		req := httptest.NewRequest(http.MethodGet, "/payments/"+payment.ID+"/payouts", nil)
+		listResp, err := app.Test(req)
+		assert.NoError(t, err)
+		var listed struct {
+			Payouts []Payout `json:"payouts"`
+		}
+		assert.NoError(t, json.NewDecoder(listResp.Body).Decode(&listed))
+		assert.Len(t, listed.Payouts, 2)
+	})
+
+	t.Run("Refunds Reverse Splits Proportionally", func(t *testing.T) {
+		app, ledger, _ := newApp()
+		_, payment := postPayment(t, app, `{"amount":1001,"currency":"THB","splits":[`+
+			`{"recipient":"seller_a","percentage":60.5},{"recipient":"seller_b","percentage":36.5}]}`, nil)
+		resp, _ := postCaptureRequest(t, app, payment.ID)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, _ = postRefund(t, app, payment.ID, `{"amount":500}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		balances, _ := ledger.Balances(ctx)
+		assert.Equal(t, int64(-605+302), balanceOf(balances, splitAccount("seller_a")), "605 * 500 / 1001, rounded down")
+		assert.Equal(t, int64(-365+182), balanceOf(balances, splitAccount("seller_b")))
+		assert.Equal(t, int64(-1+500-484), balanceOf(balances, AccountMerchantReceivable))
+
+		resp, _ = postRefund(t, app, payment.ID, `{"amount":501}`)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		balances, _ = ledger.Balances(ctx)
+		assert.Zero(t, balanceOf(balances, splitAccount("seller_a")), "a full refund reverses the whole split")
+		assert.Zero(t, balanceOf(balances, splitAccount("seller_b")))
+		assert.Equal(t, int64(30), balanceOf(balances, AccountMerchantReceivable), "the merchant still owes the platform fee")
+		var total int64
+		for _, balance := range balances {
+			total += balance.Balance.Amount
+		}
+		assert.Zero(t, total, "the ledger stays balanced")
+	})
+
+	t.Run("Over 100 Percent Split Rejected", func(t *testing.T) {
+		app, _, _ := newApp()
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","splits":[`+
+			`{"recipient":"seller_a","percentage":70},{"recipient":"seller_b","percentage":30.5}]}`, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Invalid Splits Rejected", func(t *testing.T) {
+		app, _, _ := newApp()
+
+		for _, splits := range []string{
+			`[{"recipient":"seller_a","amount":600},{"recipient":"seller_b","amount":500}]`,
+			`[{"recipient":"seller_a","amount":600},{"recipient":"seller_b","percentage":50}]`,
+			`[{"recipient":"seller_a","amount":100},{"recipient":"seller_a","amount":100}]`,
+			`[{"amount":100}]`,
+			`[{"recipient":"seller_a"}]`,
+			`[{"recipient":"seller_a","amount":100,"percentage":10}]`,
+			`[{"recipient":"seller_a","percentage":12.345}]`,
+			`[{"recipient":"seller_a","percentage":-5}]`,
+		} {
+			resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","splits":`+splits+`}`, nil)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, splits)
+		}
+	})
+
+	t.Run("Platform Fee Leaves Room", func(t *testing.T) {
+		app, _, _ := newApp()
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","splits":[{"recipient":"seller_a","percentage":100}]}`, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "the 3% fee would leave merchant_receivable negative")
+
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","splits":[{"recipient":"seller_a","percentage":97}]}`, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, []SplitResponse{{Recipient: "seller_a", Amount: 970}}, payment.Splits)
+
+		assert.ErrorContains(t, Config{PlatformFeeBasisPoints: 10001}.Validate(), "PLATFORM_FEE_BPS")
+	})
+
+	t.Run("Large Amounts Do Not Overflow", func(t *testing.T) {
+		splits, err := newSplits([]splitInput{
+			{Recipient: "a", Percentage: 50},
+			{Recipient: "b", Percentage: 50},
+		}, AmountInputMinorUnits, "THB", math.MaxInt64, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []Split{{Recipient: "a", Amount: math.MaxInt64/2 + 1}, {Recipient: "b", Amount: math.MaxInt64 / 2}}, splits)
+
+		var inputs []splitInput
+		assert.NoError(t, json.Unmarshal([]byte(`[{"recipient":"a","amount":9223372036854775807},{"recipient":"b","amount":2}]`), &inputs))
+		_, err = newSplits(inputs, AmountInputMinorUnits, "THB", 1000, 0)
+		assert.ErrorIs(t, err, ErrAmountOverflow)
+
+		assert.Equal(t, NewMoney(276701161105643274, "THB"), PlatformFee(NewMoney(math.MaxInt64, "THB"), 300))
+	})
+
+	t.Run("Percentage Rounding Is Deterministic", func(t *testing.T) {
+		inputs := []splitInput{
+			{Recipient: "a", Percentage: 33.33},
+			{Recipient: "b", Percentage: 33.33},
+			{Recipient: "c", Percentage: 33.34},
+		}
+		for i := 0; i < 3; i++ {
+			splits, err := newSplits(inputs, AmountInputMinorUnits, "THB", 100, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []Split{{Recipient: "a", Amount: 33}, {Recipient: "b", Amount: 33}, {Recipient: "c", Amount: 34}}, splits)
+		}
+
+		// 99.99% of 200 is 199; the three shares round down to 198 and the first split gets the minor unit lost.
+		splits, err := newSplits([]splitInput{
+			{Recipient: "a", Percentage: 33.33},
+			{Recipient: "b", Percentage: 33.33},
+			{Recipient: "c", Percentage: 33.33},
+		}, AmountInputMinorUnits, "THB", 200, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []Split{{Recipient: "a", Amount: 67}, {Recipient: "b", Amount: 66}, {Recipient: "c", Amount: 66}}, splits)
+	})
+
+	t.Run("Test Payments Are Not Paid Out", func(t *testing.T) {
+		router := &APIRouter{}
+		router.ensureDependencies(Config{})
+		payment := Payment{ID: "pay_test", Amount: 1000, Currency: "THB", TestMode: true, Splits: []Split{{Recipient: "seller_a", Amount: 500}}}
+		assert.NoError(t, router.postCapture(ctx, payment))
+		recorded, _ := router.payouts.ListByPayment(ctx, payment.ID)
+		assert.Empty(t, recorded)
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedDestination is returned when an outbound webhook or callback would connect to an address the
+// outbound policy does not allow, such as a loopback, private or cloud metadata address.
+var ErrBlockedDestination = errors.New("outbound destination blocked")
+
+// internalPrefixes are address ranges next to loopback, private and link-local ones that must never be
+// reached from merchant-supplied URLs.
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// OutboundPolicy decides which addresses outbound webhooks and callbacks may connect to. Internal addresses
+// are blocked unless an Allow prefix covers them; Deny prefixes are blocked even when public.
+type OutboundPolicy struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// parseOutboundPolicy parses OUTBOUND_ALLOW_CIDRS and OUTBOUND_DENY_CIDRS, comma-separated CIDR lists.
+func parseOutboundPolicy(allow, deny string) (OutboundPolicy, error) {
+	var policy OutboundPolicy
+	var err error
+	if policy.Allow, err = parsePrefixes("OUTBOUND_ALLOW_CIDRS", allow); err != nil {
+		return OutboundPolicy{}, err
+	}
+	if policy.Deny, err = parsePrefixes("OUTBOUND_DENY_CIDRS", deny); err != nil {
+		return OutboundPolicy{}, err
+	}
+	return policy, nil
+}
+
+func parsePrefixes(name, spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: want a CIDR such as 10.0.0.0/8", name, entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// CheckAddr returns ErrBlockedDestination when the policy does not allow connecting to addr.
+func (p OutboundPolicy) CheckAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if containsAddr(p.Deny, addr) {
+		return fmt.Errorf("%w: %s is denied", ErrBlockedDestination, addr)
+	}
+	if isInternalAddr(addr) && !containsAddr(p.Allow, addr) {
+		return fmt.Errorf("%w: %s is an internal address", ErrBlockedDestination, addr)
+	}
+	return nil
+}
+
+// CheckHost rejects a URL host that is an IP literal the policy blocks. Host names pass; the addresses they
+// resolve to are checked when the connection is made.
+func (p OutboundPolicy) CheckHost(host string) error {
+	addr, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	if err != nil {
+		return nil
+	}
+	return p.CheckAddr(addr)
+}
+
+func isInternalAddr(addr netip.Addr) bool {
+	return !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || containsAddr(internalPrefixes, addr)
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewGuardedHTTPClient returns an HTTP client for merchant-supplied URLs. The policy is checked against the
+// address of every connection as it is dialed, after DNS resolution, so a host name that resolves to a public
+// address when checked and to an internal one when used (DNS rebinding) is still blocked. Redirects are
+// dialed through the same check, and proxies are not used since they would connect on our behalf.
+func NewGuardedHTTPClient(policy OutboundPolicy, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return fmt.Errorf("%w: unresolved address %q", ErrBlockedDestination, host)
+			}
+			return policy.CheckAddr(addr)
+		},
+	}
+	transport := &http.Transport{
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// outboundPolicy returns the configured outbound policy; Validate has already rejected malformed CIDRs.
+func (r *APIRouter) outboundPolicy() OutboundPolicy {
+	policy, _ := parseOutboundPolicy(r.config.OutboundAllowCIDRs, r.config.OutboundDenyCIDRs)
+	return policy
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboundPolicy(t *testing.T) {
+	t.Run("Public Address Allowed", func(t *testing.T) {
+		for _, addr := range []string{"93.184.216.34", "8.8.8.8", "2606:4700:4700::1111"} {
+			assert.NoError(t, OutboundPolicy{}.CheckAddr(netip.MustParseAddr(addr)), addr)
+		}
+	})
+
+	t.Run("Internal Addresses Blocked", func(t *testing.T) {
+		for _, addr := range []string{
+			"127.0.0.1", "10.0.0.1", "172.16.5.4", "192.168.1.1", "169.254.169.254", "100.64.0.1",
+			"0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "::ffff:169.254.169.254",
+		} {
+			assert.ErrorIs(t, OutboundPolicy{}.CheckAddr(netip.MustParseAddr(addr)), ErrBlockedDestination, addr)
+		}
+	})
+
+	t.Run("Allow And Deny Lists", func(t *testing.T) {
+		policy, err := parseOutboundPolicy("10.1.0.0/16", "93.184.216.0/24")
+		assert.NoError(t, err)
+		assert.NoError(t, policy.CheckAddr(netip.MustParseAddr("10.1.2.3")))
+		assert.ErrorIs(t, policy.CheckAddr(netip.MustParseAddr("10.2.0.1")), ErrBlockedDestination)
+		assert.ErrorIs(t, policy.CheckAddr(netip.MustParseAddr("93.184.216.34")), ErrBlockedDestination)
+
+		_, err = parseOutboundPolicy("not-a-cidr", "")
+		assert.Error(t, err)
+	})
+
+	t.Run("Host Names Checked At Dial Time", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+		parsed, _ := url.Parse(server.URL)
+		byName := "http://localhost:" + parsed.Port()
+
+		// The name passes the up-front check; the loopback address it resolves to is caught when dialed, so a
+		// record that changes between check and use cannot slip through.
+		assert.NoError(t, OutboundPolicy{}.CheckHost("localhost"))
+		_, err := NewGuardedHTTPClient(OutboundPolicy{}, time.Second).Get(byName)
+		assert.ErrorIs(t, err, ErrBlockedDestination)
+
+		_, err = NewGuardedHTTPClient(OutboundPolicy{}, time.Second).Get(server.URL)
+		assert.ErrorIs(t, err, ErrBlockedDestination)
+
+		allowed, _ := parseOutboundPolicy("127.0.0.0/8,::1/128", "")
+		resp, err := NewGuardedHTTPClient(allowed, time.Second).Get(byName)
+		if assert.NoError(t, err) {
+			_ = resp.Body.Close()
+			assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		}
+	})
+
+	t.Run("Merchant Webhook To Loopback Rejected", func(t *testing.T) {
+		server := newEchoChallengeServer(true)
+		defer server.Close()
+
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{AdminToken: "admin-secret"})
+		resp, _ := postWebhook(t, app, "m_1", server.URL)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Notification URL To Internal Address Rejected", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{store: NewMemoryPaymentStore(), gateway: NewSandboxGateway("sandbox")}).
+			SetupRoutes(app, Config{NotificationURLAllowedHosts: "169.254.169.254,localhost"})
+
+		resp, _ := postPayment(t, app, `{"amount":1000,"currency":"THB","notification_url":"http://169.254.169.254/latest"}`, nil)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("Notification Delivery To Loopback Fails", func(t *testing.T) {
+		receiver := &notificationReceiver{}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+		parsed, _ := url.Parse(server.URL)
+
+		deliveries := NewMemoryDeliveryLog()
+		router := &APIRouter{store: NewMemoryPaymentStore(), gateway: NewSandboxGateway("sandbox"), deliveries: deliveries}
+		app := fiber.New()
+		router.SetupRoutes(app, Config{NotificationURLAllowedHosts: "localhost"})
+
+		target := strings.Replace(server.URL, parsed.Hostname(), "localhost", 1)
+		resp, payment := postPayment(t, app, `{"amount":1000,"currency":"THB","notification_url":"`+target+`"}`, nil)
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+		_, err := router.relay.Flush(context.Background(), 0)
+		assert.NoError(t, err)
+		receiver.mu.Lock()
+		assert.Empty(t, receiver.payloads)
+		receiver.mu.Unlock()
+		recorded, _ := deliveries.ListByPayment(context.Background(), payment.ID)
+		if assert.NotEmpty(t, recorded) {
+			assert.False(t, recorded[0].Succeeded)
+		}
+	})
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrPaymentNotFound is returned by a PaymentStore when no payment exists for the requested ID.
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// ErrPaymentVersionConflict is returned by a PaymentStore when a payment was saved by someone else since the
+// caller read it.
+var ErrPaymentVersionConflict = errors.New("payment was modified concurrently")
+
+// PaymentStore persists payments.
+type PaymentStore interface {
+	// Save inserts or replaces the payment and stores it with its Version incremented. A non-zero Version must
+	// match the stored one, like an UPDATE ... WHERE version = ?, or Save returns ErrPaymentVersionConflict; a
+	// zero Version writes unconditionally.
+	Save(ctx context.Context, payment Payment) error
+	Get(ctx context.Context, id string) (Payment, error)
+	List(ctx context.Context) ([]Payment, error)
+	// GetByReferenceNumber returns the payment with the given reference number or ErrPaymentNotFound.
+	GetByReferenceNumber(ctx context.Context, reference string) (Payment, error)
+	// GetByIdempotencyKey returns the latest payment created in the given mode, by merchantID or by a key of no
+	// merchant when it is empty, with the client Idempotency-Key, or ErrPaymentNotFound. Clients choose their
+	// keys independently, so the same key may well have been sent by several merchants.
+	GetByIdempotencyKey(ctx context.Context, testMode bool, merchantID, key string) (Payment, error)
+	// Scan returns up to limit payments in insertion order, starting after the payment with ID after, or
+	// from the beginning when after is empty. Callers page through the whole table by passing the last ID
+	// of each batch, without ever holding more than one batch.
+	Scan(ctx context.Context, after string, limit int) ([]Payment, error)
+}
+
+// paymentRow is the at-rest representation of a payment; sensitive columns are kept encrypted.
+type paymentRow struct {
+	payment   Payment
+	metadata  EncryptedField
+	cardToken EncryptedField
+}
+
+// PaymentDatabase is implemented by a PaymentStore backed by a database, which startup connects to with
+// retries and whose migrations it waits for. MemoryPaymentStore has neither a connection nor migrations and does
+// not implement it.
+type PaymentDatabase interface {
+	// Ping reports whether the database is reachable.
+	Ping(ctx context.Context) error
+	// SchemaVersion returns the applied schema version, the highest entry in schema_migrations.
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
+// MemoryPaymentStore is a PaymentStore that keeps payments in memory, guarded by a sync.RWMutex.
+type MemoryPaymentStore struct {
+	mu        sync.RWMutex
+	rows      map[string]paymentRow
+	order     []string
+	position  map[string]int
+	byRef     map[string]string
+	byKey     map[string]string
+	encryptor *FieldEncryptor
+}
+
+// NewMemoryPaymentStore creates an empty MemoryPaymentStore that stores sensitive fields without encryption.
+func NewMemoryPaymentStore() *MemoryPaymentStore {
+	return &MemoryPaymentStore{
+		rows:     make(map[string]paymentRow),
+		position: make(map[string]int),
+		byRef:    make(map[string]string),
+		byKey:    make(map[string]string),
+	}
+}
+
+// SetEncryptor sets the FieldEncryptor used for the metadata and card token columns. Rows written with an
+// older key version remain readable as long as that version is still configured on the new encryptor.
+func (s *MemoryPaymentStore) SetEncryptor(encryptor *FieldEncryptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encryptor = encryptor
+}
+
+// Save inserts or replaces the payment, encrypting its sensitive fields. It returns ErrDuplicateReferenceNumber
+// when another payment already holds the payment's reference number and ErrPaymentVersionConflict when the
+// payment's Version is stale.
+func (s *MemoryPaymentStore) Save(_ context.Context, payment Payment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.rows[payment.ID]
+	switch {
+	case exists && payment.Version == 0:
+		payment.Version = existing.payment.Version + 1
+	case exists && payment.Version != existing.payment.Version:
+		return ErrPaymentVersionConflict
+	default:
+		payment.Version++
+	}
+	if payment.ReferenceNumber != "" {
+		if owner, taken := s.byRef[payment.ReferenceNumber]; taken && owner != payment.ID {
+			return ErrDuplicateReferenceNumber
+		}
+	}
+	row, err := s.encode(payment)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		s.position[payment.ID] = len(s.order)
+		s.order = append(s.order, payment.ID)
+	}
+	s.rows[payment.ID] = row
+	if payment.ReferenceNumber != "" {
+		s.byRef[payment.ReferenceNumber] = payment.ID
+	}
+	if payment.IdempotencyKey != "" {
+		s.byKey[paymentKeyIndex(payment.TestMode, payment.MerchantID, payment.IdempotencyKey)] = payment.ID
+	}
+	return nil
+}
+
+// Get returns the payment with the given ID or ErrPaymentNotFound.
+func (s *MemoryPaymentStore) Get(_ context.Context, id string) (Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	row, ok := s.rows[id]
+	if !ok {
+		return Payment{}, ErrPaymentNotFound
+	}
+	return s.decode(row)
+}
+
+// GetByReferenceNumber returns the payment with the given reference number or ErrPaymentNotFound.
+func (s *MemoryPaymentStore) GetByReferenceNumber(_ context.Context, reference string) (Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byRef[reference]
+	if !ok {
+		return Payment{}, ErrPaymentNotFound
+	}
+	return s.decode(s.rows[id])
+}
+
+// GetByIdempotencyKey returns the latest payment the mode and merchant created with the given Idempotency-Key
+// or ErrPaymentNotFound.
+func (s *MemoryPaymentStore) GetByIdempotencyKey(_ context.Context, testMode bool, merchantID, key string) (Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byKey[paymentKeyIndex(testMode, merchantID, key)]
+	if !ok {
+		return Payment{}, ErrPaymentNotFound
+	}
+	return s.decode(s.rows[id])
+}
+
+// paymentKeyIndex scopes a client Idempotency-Key to the mode and merchant that sent it, as the idempotency
+// middleware does, so that one merchant's key never shadows another's.
+func paymentKeyIndex(testMode bool, merchantID, key string) string {
+	mode := apiKeyModeLive
+	if testMode {
+		mode = apiKeyModeTest
+	}
+	return mode + " " + merchantID + " " + key
+}
+
+// List returns all payments in insertion order.
+func (s *MemoryPaymentStore) List(_ context.Context) ([]Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	payments := make([]Payment, 0, len(s.order))
+	for _, id := range s.order {
+		payment, err := s.decode(s.rows[id])
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+// Scan implements PaymentStore. An after ID that is not stored starts the scan from the beginning.
+func (s *MemoryPaymentStore) Scan(_ context.Context, after string, limit int) ([]Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	start := 0
+	if pos, ok := s.position[after]; ok {
+		start = pos + 1
+	}
+	end := min(start+limit, len(s.order))
+	payments := make([]Payment, 0, end-start)
+	for _, id := range s.order[start:end] {
+		payment, err := s.decode(s.rows[id])
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
+
+func (s *MemoryPaymentStore) encode(payment Payment) (paymentRow, error) {
+	metadata, err := json.Marshal(payment.Metadata)
+	if err != nil {
+		return paymentRow{}, err
+	}
+	encryptedMetadata, err := s.encryptor.Encrypt(metadata, []byte(payment.ID))
+	if err != nil {
+		return paymentRow{}, err
+	}
+	encryptedToken, err := s.encryptor.Encrypt([]byte(payment.CardToken), []byte(payment.ID))
+	if err != nil {
+		return paymentRow{}, err
+	}
+
+	payment.Metadata = nil
+	payment.CardToken = ""
+	return paymentRow{payment: payment, metadata: encryptedMetadata, cardToken: encryptedToken}, nil
+}
+
+func (s *MemoryPaymentStore) decode(row paymentRow) (Payment, error) {
+	payment := row.payment
+
+	metadata, err := s.encryptor.Decrypt(row.metadata, []byte(payment.ID))
+	if err != nil {
+		return Payment{}, err
+	}
+	if err := json.Unmarshal(metadata, &payment.Metadata); err != nil {
+		return Payment{}, err
+	}
+	token, err := s.encryptor.Decrypt(row.cardToken, []byte(payment.ID))
+	if err != nil {
+		return Payment{}, err
+	}
+	payment.CardToken = string(token)
+	return payment, nil
+}
+
+// memoryCollection is a concurrency-safe, append-ordered list shared by the in-memory stores of child records.
+type memoryCollection[T any] struct {
+	mu    sync.RWMutex
+	items []T
+}
+
+func (m *memoryCollection[T]) add(item T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = append(m.items, item)
+}
+
+// update replaces the first item matching match with fn(item), reporting whether one was found.
+func (m *memoryCollection[T]) update(match func(T) bool, fn func(T) T) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, item := range m.items {
+		if match(item) {
+			m.items[i] = fn(item)
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memoryCollection[T]) filter(match func(T) bool) []T {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []T
+	for _, item := range m.items {
+		if match(item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultPaymentCacheSize caps how many payments CachedPaymentStore keeps at once.
+const defaultPaymentCacheSize = 10000
+
+// maxPaymentCacheTTL bounds PAYMENT_CACHE_TTL, since writes made by other instances are only seen on expiry.
+const maxPaymentCacheTTL = time.Minute
+
+// cachedPayment is a cache entry: a payment read until expiresAt, or a read in flight identified by loading.
+type cachedPayment struct {
+	payment   Payment
+	expiresAt time.Time
+	loading   uint64
+}
+
+// CachedPaymentStore decorates a PaymentStore with a short-lived read-through cache for Get, so that hot
+// payments such as a QR being polled are not read from the database on every request. Every Save through the
+// store drops the payment's entry, so a status change is seen on the next read; a write made by another
+// instance is seen once the entry's TTL runs out.
+type CachedPaymentStore struct {
+	PaymentStore
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedPayment
+	// reads numbers the reads in flight. A save drops the payment's entry, read or not, so a read racing a
+	// save finds its marker gone and does not cache what it read before the save.
+	reads uint64
+	now   func() time.Time
+}
+
+// NewCachedPaymentStore wraps store with a Get cache whose entries live for ttl.
+func NewCachedPaymentStore(store PaymentStore, ttl time.Duration) *CachedPaymentStore {
+	return &CachedPaymentStore{
+		PaymentStore: store,
+		TTL:          ttl,
+		entries:      make(map[string]cachedPayment),
+		now:          time.Now,
+	}
+}
+
+// Get implements PaymentStore, answering from the cache while the entry is fresh.
+func (s *CachedPaymentStore) Get(ctx context.Context, id string) (Payment, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[id]
+	if ok && entry.loading == 0 && s.now().Before(entry.expiresAt) {
+		s.mu.Unlock()
+		return clonePayment(entry.payment), nil
+	}
+	var read uint64
+	if ok || len(s.entries) < defaultPaymentCacheSize {
+		s.reads++
+		read = s.reads
+		s.entries[id] = cachedPayment{loading: read}
+	}
+	s.mu.Unlock()
+
+	payment, err := s.PaymentStore.Get(ctx, id)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[id]; ok && read != 0 && entry.loading == read {
+		if err != nil {
+			delete(s.entries, id)
+		} else {
+			s.entries[id] = cachedPayment{payment: clonePayment(payment), expiresAt: s.now().Add(s.TTL)}
+		}
+	}
+	return payment, err
+}
+
+// clonePayment copies a payment's maps and slices, so that a caller changing what it read cannot change the
+// cached copy.
+func clonePayment(payment Payment) Payment {
+	payment.Metadata = maps.Clone(payment.Metadata)
+	payment.GatewayMetadata = maps.Clone(payment.GatewayMetadata)
+	payment.LineItems = slices.Clone(payment.LineItems)
+	payment.Splits = slices.Clone(payment.Splits)
+	return payment
+}
+
+// Save implements PaymentStore, dropping the payment's cache entry.
+func (s *CachedPaymentStore) Save(ctx context.Context, payment Payment) error {
+	err := s.PaymentStore.Save(ctx, payment)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, payment.ID)
+	return err
+}
+
+// newCachedPaymentStore puts a read cache in front of store when PAYMENT_CACHE_TTL is set, or returns store
+// unchanged.
+func newCachedPaymentStore(store PaymentStore, config Config) PaymentStore {
+	if config.PaymentCacheTTL <= 0 {
+		return store
+	}
+	return NewCachedPaymentStore(store, config.PaymentCacheTTL)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingPaymentStore counts the reads that reach the underlying store.
+type countingPaymentStore struct {
+	PaymentStore
+	mu    sync.Mutex
+	reads int
+}
+
+func (s *countingPaymentStore) Get(ctx context.Context, id string) (Payment, error) {
+	s.mu.Lock()
+	s.reads++
+	s.mu.Unlock()
+	return s.PaymentStore.Get(ctx, id)
+}
+
+func (s *countingPaymentStore) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+func TestCachedPaymentStore(t *testing.T) {
+	ctx := context.Background()
+	newStore := func() (*CachedPaymentStore, *countingPaymentStore, *time.Time) {
+		now := time.Now()
+		backing := &countingPaymentStore{PaymentStore: NewMemoryPaymentStore()}
+		cache := NewCachedPaymentStore(backing, 5*time.Second)
+		cache.now = func() time.Time { return now }
+		_ = cache.Save(ctx, Payment{ID: "pay_1", Status: PaymentStatusPending, Metadata: map[string]string{"order_id": "o_1"}})
+		return cache, backing, &now
+	}
+
+	t.Run("Hit Avoids Store", func(t *testing.T) {
+		cache, backing, _ := newStore()
+
+		for i := 0; i < 3; i++ {
+			payment, err := cache.Get(ctx, "pay_1")
+			assert.NoError(t, err)
+			assert.Equal(t, PaymentStatusPending, payment.Status)
+		}
+		assert.Equal(t, 1, backing.Reads())
+	})
+
+	t.Run("Save Invalidates", func(t *testing.T) {
+		cache, backing, _ := newStore()
+		_, _ = cache.Get(ctx, "pay_1")
+
+		assert.NoError(t, cache.Save(ctx, Payment{ID: "pay_1", Status: PaymentStatusCaptured}))
+		payment, _ := cache.Get(ctx, "pay_1")
+		assert.Equal(t, PaymentStatusCaptured, payment.Status)
+		assert.Equal(t, 2, backing.Reads())
+	})
+
+	t.Run("Expires After TTL", func(t *testing.T) {
+		cache, backing, now := newStore()
+		_, _ = cache.Get(ctx, "pay_1")
+
+		// A write made by another instance, straight to the shared store.
+		_ = backing.PaymentStore.Save(ctx, Payment{ID: "pay_1", Status: PaymentStatusFailed})
+		payment, _ := cache.Get(ctx, "pay_1")
+		assert.Equal(t, PaymentStatusPending, payment.Status)
+
+		*now = now.Add(5 * time.Second)
+		payment, _ = cache.Get(ctx, "pay_1")
+		assert.Equal(t, PaymentStatusFailed, payment.Status)
+		assert.Equal(t, 2, backing.Reads())
+	})
+
+	t.Run("Caller Changes Do Not Leak Into Cache", func(t *testing.T) {
+		cache, _, _ := newStore()
+
+		payment, _ := cache.Get(ctx, "pay_1")
+		payment.Metadata["order_id"] = "changed"
+		payment, _ = cache.Get(ctx, "pay_1")
+		assert.Equal(t, "o_1", payment.Metadata["order_id"])
+	})
+
+	t.Run("Not Found Is Not Cached", func(t *testing.T) {
+		cache, backing, _ := newStore()
+
+		_, err := cache.Get(ctx, "pay_missing")
+		assert.ErrorIs(t, err, ErrPaymentNotFound)
+		_, err = cache.Get(ctx, "pay_missing")
+		assert.ErrorIs(t, err, ErrPaymentNotFound)
+		assert.Equal(t, 2, backing.Reads())
+	})
+
+	t.Run("Concurrent Reads And Writes", func(t *testing.T) {
+		cache, _, _ := newStore()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, _ = cache.Get(ctx, "pay_1")
+			}()
+			go func() {
+				defer wg.Done()
+				_ = cache.Save(ctx, Payment{ID: "pay_1", Status: PaymentStatusAuthorized})
+			}()
+		}
+		wg.Wait()
+
+		payment, _ := cache.Get(ctx, "pay_1")
+		assert.Equal(t, PaymentStatusAuthorized, payment.Status)
+	})
+}
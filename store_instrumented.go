@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// dbQueryLatencyMetric is the histogram of repository query durations in seconds, labelled by query name.
+const dbQueryLatencyMetric = "payment_db_query_duration_seconds"
+
+// Query names reported by InstrumentedPaymentStore.
+const (
+	queryPaymentsSave = "payments.save"
+	queryPaymentsGet  = "payments.get"
+	queryPaymentsList = "payments.list"
+	queryPaymentsScan = "payments.scan"
+
+	queryPaymentsGetByReference = "payments.get_by_reference_number"
+	queryPaymentsGetByKey       = "payments.get_by_idempotency_key"
+)
+
+// InstrumentedPaymentStore decorates a PaymentStore, recording query latency and logging queries slower
+// than SlowThreshold. Only the query name and duration are logged, never the arguments, which may hold
+// card tokens or customer metadata. When Health is set, each query and whether it failed also count towards
+// the health score; a payment that is not found and a version conflict are not failures.
+type InstrumentedPaymentStore struct {
+	PaymentStore
+	Metrics       *MetricsRegistry
+	SlowThreshold time.Duration
+	Health        *DependencyHealth
+}
+
+// NewInstrumentedPaymentStore wraps store with latency metrics and a slow-query log; a zero slowThreshold
+// disables the log.
+func NewInstrumentedPaymentStore(store PaymentStore, metrics *MetricsRegistry, slowThreshold time.Duration) *InstrumentedPaymentStore {
+	return &InstrumentedPaymentStore{PaymentStore: store, Metrics: metrics, SlowThreshold: slowThreshold}
+}
+
+// Save implements PaymentStore.
+func (s *InstrumentedPaymentStore) Save(ctx context.Context, payment Payment) error {
+	started := time.Now()
+	err := s.PaymentStore.Save(ctx, payment)
+	s.observe(queryPaymentsSave, started, err)
+	return err
+}
+
+// Get implements PaymentStore.
+func (s *InstrumentedPaymentStore) Get(ctx context.Context, id string) (Payment, error) {
+	started := time.Now()
+	payment, err := s.PaymentStore.Get(ctx, id)
+	s.observe(queryPaymentsGet, started, err)
+	return payment, err
+}
+
+// List implements PaymentStore.
+func (s *InstrumentedPaymentStore) List(ctx context.Context) ([]Payment, error) {
+	started := time.Now()
+	payments, err := s.PaymentStore.List(ctx)
+	s.observe(queryPaymentsList, started, err)
+	return payments, err
+}
+
+// Scan implements PaymentStore.
+func (s *InstrumentedPaymentStore) Scan(ctx context.Context, after string, limit int) ([]Payment, error) {
+	started := time.Now()
+	payments, err := s.PaymentStore.Scan(ctx, after, limit)
+	s.observe(queryPaymentsScan, started, err)
+	return payments, err
+}
+
+// GetByReferenceNumber implements PaymentStore.
+func (s *InstrumentedPaymentStore) GetByReferenceNumber(ctx context.Context, reference string) (Payment, error) {
+	started := time.Now()
+	payment, err := s.PaymentStore.GetByReferenceNumber(ctx, reference)
+	s.observe(queryPaymentsGetByReference, started, err)
+	return payment, err
+}
+
+// GetByIdempotencyKey implements PaymentStore.
+func (s *InstrumentedPaymentStore) GetByIdempotencyKey(ctx context.Context, testMode bool, merchantID, key string) (Payment, error) {
+	started := time.Now()
+	payment, err := s.PaymentStore.GetByIdempotencyKey(ctx, testMode, merchantID, key)
+	s.observe(queryPaymentsGetByKey, started, err)
+	return payment, err
+}
+
+func (s *InstrumentedPaymentStore) observe(query string, started time.Time, err error) {
+	elapsed := time.Since(started)
+	s.Metrics.Observe(dbQueryLatencyMetric, Labels{"query": query}, elapsed.Seconds())
+	failed := err != nil && !errors.Is(err, ErrPaymentNotFound) && !errors.Is(err, ErrPaymentVersionConflict)
+	s.Health.Record("database", elapsed, failed)
+
+	if s.SlowThreshold > 0 && elapsed > s.SlowThreshold {
+		log.Printf("WARN slow query query=%s duration=%s threshold=%s",
+			query, elapsed.Round(time.Millisecond), s.SlowThreshold)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowPaymentStore delays every read to simulate a slow query.
+type slowPaymentStore struct {
+	PaymentStore
+	delay time.Duration
+}
+
+func (s *slowPaymentStore) Get(ctx context.Context, id string) (Payment, error) {
+	time.Sleep(s.delay)
+	return s.PaymentStore.Get(ctx, id)
+}
+
+func TestInstrumentedPaymentStore(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer func() { log.SetOutput(os.Stderr) }()
+	ctx := context.Background()
+
+	t.Run("Fast Query Records Latency Without Logging", func(t *testing.T) {
+		buf.Reset()
+		metrics := NewMetricsRegistry()
+		store := NewInstrumentedPaymentStore(NewMemoryPaymentStore(), metrics, time.Second)
+
+		assert.NoError(t, store.Save(ctx, Payment{ID: "pay_1", CardToken: "tok_secret"}))
+		_, err := store.Get(ctx, "pay_1")
+		assert.NoError(t, err)
+
+		assert.Equal(t, uint64(1), metrics.HistogramCount(dbQueryLatencyMetric, Labels{"query": queryPaymentsSave}))
+		assert.Equal(t, uint64(1), metrics.HistogramCount(dbQueryLatencyMetric, Labels{"query": queryPaymentsGet}))
+		assert.NotContains(t, buf.String(), "slow query")
+	})
+
+	t.Run("Slow Query Logs Name And Duration Only", func(t *testing.T) {
+		buf.Reset()
+		metrics := NewMetricsRegistry()
+		memory := NewMemoryPaymentStore()
+		assert.NoError(t, memory.Save(ctx, Payment{ID: "pay_secret_id", CardToken: "tok_secret"}))
+		store := NewInstrumentedPaymentStore(&slowPaymentStore{PaymentStore: memory, delay: 20 * time.Millisecond}, metrics, 5*time.Millisecond)
+
+		_, err := store.Get(ctx, "pay_secret_id")
+		assert.NoError(t, err)
+
+		assert.Contains(t, buf.String(), "WARN slow query query=payments.get")
+		assert.NotContains(t, buf.String(), "pay_secret_id")
+		assert.NotContains(t, buf.String(), "tok_secret")
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// StoreCreditEntry records store credit granted to (positive) or spent by (negative) a customer.
+type StoreCreditEntry struct {
+	ID         string
+	CustomerID string
+	PaymentID  string
+	RefundID   string
+	Amount     Money
+	CreatedAt  time.Time
+}
+
+// StoreCreditLedger records store credit movements per customer.
+type StoreCreditLedger interface {
+	Record(ctx context.Context, entry StoreCreditEntry) error
+	Balance(ctx context.Context, customerID, currency string) (Money, error)
+	ListByCustomer(ctx context.Context, customerID string) ([]StoreCreditEntry, error)
+}
+
+// MemoryStoreCreditLedger is a StoreCreditLedger that keeps entries in memory.
+type MemoryStoreCreditLedger struct {
+	entries memoryCollection[StoreCreditEntry]
+}
+
+// NewMemoryStoreCreditLedger creates an empty MemoryStoreCreditLedger.
+func NewMemoryStoreCreditLedger() *MemoryStoreCreditLedger {
+	return &MemoryStoreCreditLedger{}
+}
+
+// Record implements StoreCreditLedger.
+func (l *MemoryStoreCreditLedger) Record(_ context.Context, entry StoreCreditEntry) error {
+	l.entries.add(entry)
+	return nil
+}
+
+// Balance implements StoreCreditLedger, summing the customer's entries in the given currency.
+func (l *MemoryStoreCreditLedger) Balance(_ context.Context, customerID, currency string) (Money, error) {
+	balance := NewMoney(0, currency)
+	for _, entry := range l.entries.filter(func(e StoreCreditEntry) bool {
+		return e.CustomerID == customerID && e.Amount.Currency == balance.Currency
+	}) {
+		var err error
+		if balance, err = balance.Add(entry.Amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return balance, nil
+}
+
+// ListByCustomer implements StoreCreditLedger.
+func (l *MemoryStoreCreditLedger) ListByCustomer(_ context.Context, customerID string) ([]StoreCreditEntry, error) {
+	return l.entries.filter(func(e StoreCreditEntry) bool { return e.CustomerID == customerID }), nil
+}
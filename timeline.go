@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TimelineEntryType identifies the kind of record a timeline entry was built from.
+type TimelineEntryType string
+
+const (
+	// TimelineEvent is an entry built from a payment event.
+	TimelineEvent TimelineEntryType = "event"
+	// TimelineRefund is an entry built from a refund.
+	TimelineRefund TimelineEntryType = "refund"
+	// TimelineDispute is an entry built from a dispute.
+	TimelineDispute TimelineEntryType = "dispute"
+	// TimelineWebhookDelivery is an entry built from a webhook delivery attempt.
+	TimelineWebhookDelivery TimelineEntryType = "webhook_delivery"
+)
+
+// TimelineEntry is one item in a payment's chronological history. It deliberately carries only
+// support-safe fields: no card tokens, webhook URLs or response bodies.
+type TimelineEntry struct {
+	Type       TimelineEntryType `json:"type"`
+	ID         string            `json:"id"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	EventType  EventType         `json:"event_type,omitempty"`
+	Status     string            `json:"status,omitempty"`
+	Amount     *Money            `json:"amount,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	StatusCode int               `json:"status_code,omitempty"`
+}
+
+// buildPaymentTimeline merges events, refunds, disputes and webhook deliveries of a payment into one time-ordered list.
+func (r *APIRouter) buildPaymentTimeline(ctx context.Context, paymentID string) ([]TimelineEntry, error) {
+	var entries []TimelineEntry
+
+	events, err := r.events.ListByPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		entries = append(entries, TimelineEntry{Type: TimelineEvent, ID: e.ID, OccurredAt: e.OccurredAt, EventType: e.Type})
+	}
+
+	refunds, err := r.refunds.ListByPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rf := range refunds {
+		amount := rf.Money()
+		entries = append(entries, TimelineEntry{
+			Type: TimelineRefund, ID: rf.ID, OccurredAt: rf.CreatedAt, Status: string(rf.Status), Amount: &amount, Reason: rf.Reason,
+		})
+	}
+
+	disputes, err := r.disputes.ListByPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range disputes {
+		amount := NewMoney(d.Amount, d.Currency)
+		entries = append(entries, TimelineEntry{
+			Type: TimelineDispute, ID: d.ID, OccurredAt: d.OpenedAt, Status: string(d.Status), Amount: &amount, Reason: d.Reason,
+		})
+	}
+
+	deliveries, err := r.deliveries.ListByPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deliveries {
+		status := "failed"
+		if d.Succeeded {
+			status = "delivered"
+		}
+		entries = append(entries, TimelineEntry{
+			Type: TimelineWebhookDelivery, ID: d.ID, OccurredAt: d.AttemptedAt, EventType: d.EventType, Status: status, StatusCode: d.StatusCode,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].OccurredAt.Before(entries[j].OccurredAt) })
+	return entries, nil
+}
+
+func (r *APIRouter) getPaymentTimeline(c *fiber.Ctx) error {
+	paymentID := c.Params("id")
+	if _, err := r.loadVisiblePayment(c); err != nil {
+		if errors.Is(err, ErrPaymentNotFound) {
+			return respondError(c, ErrCodeNotFound, "payment not found")
+		}
+		return respondError(c, ErrCodeInternal, "failed to load payment")
+	}
+
+	page, err := r.parsePage(c)
+	if err != nil {
+		return respondError(c, ErrCodeInvalidRequest, err.Error())
+	}
+
+	entries, err := r.buildPaymentTimeline(c.UserContext(), paymentID)
+	if err != nil {
+		return respondError(c, ErrCodeInternal, "failed to build timeline")
+	}
+
+	return respondPage(c, entries, page)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentTimeline(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+	store := NewMemoryPaymentStore()
+	events := NewMemoryEventStore()
+	refunds := NewMemoryRefundStore()
+	disputes := NewMemoryDisputeStore()
+	deliveries := NewMemoryDeliveryLog()
+
+	_ = store.Save(ctx, Payment{ID: "pay_1", Amount: 1000, Currency: "THB", Status: PaymentStatusCaptured, CardToken: "tok_secret", CreatedAt: base})
+	_ = disputes.Save(ctx, Dispute{ID: "dp_1", PaymentID: "pay_1", Amount: 400, Currency: "THB", Status: DisputeStatusOpen, Reason: "fraudulent", OpenedAt: base.Add(3 * time.Hour)})
+	_ = refunds.Save(ctx, Refund{ID: "rf_1", PaymentID: "pay_1", Amount: 600, Currency: "THB", Status: RefundStatusSucceeded, CreatedAt: base.Add(2 * time.Hour)})
+	_ = events.Append(ctx, PaymentEvent{ID: "ev_1", PaymentID: "pay_1", Type: EventPaymentCaptured, OccurredAt: base.Add(time.Hour)})
+	_ = events.Append(ctx, PaymentEvent{ID: "ev_other", PaymentID: "pay_2", Type: EventPaymentCaptured, OccurredAt: base})
+	_ = deliveries.Record(ctx, WebhookDelivery{ID: "wd_1", PaymentID: "pay_1", EventType: EventPaymentCaptured, URL: "https://merchant.example/hook", StatusCode: 200, Succeeded: true, ResponseBody: "internal", AttemptedAt: base.Add(90 * time.Minute)})
+
+	app := fiber.New()
+	router := &APIRouter{store: store, events: events, refunds: refunds, disputes: disputes, deliveries: deliveries}
+	router.SetupRoutes(app, Config{})
+
+	t.Run("Ordered Timeline", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/payments/pay_1/timeline", nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result struct {
+			Data    []TimelineEntry `json:"data"`
+			Total   int             `json:"total"`
+			HasMore bool            `json:"has_more"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, 4, result.Total)
+		assert.False(t, result.HasMore)
+
+		ids := make([]string, 0, len(result.Data))
+		for _, entry := range result.Data {
+			ids = append(ids, entry.ID)
+		}
+		assert.Equal(t, []string{"ev_1", "wd_1", "rf_1", "dp_1"}, ids)
+		assert.Equal(t, TimelineRefund, result.Data[2].Type)
+		assert.Equal(t, int64(600), result.Data[2].Amount.Amount)
+		assert.Equal(t, TimelineDispute, result.Data[3].Type)
+	})
+
+	t.Run("Excludes Sensitive Fields", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/payments/pay_1/timeline", nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		var raw map[string]interface{}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&raw))
+		body, _ := json.Marshal(raw)
+		assert.NotContains(t, string(body), "tok_secret")
+		assert.NotContains(t, string(body), "merchant.example")
+		assert.NotContains(t, string(body), "internal")
+	})
+
+	t.Run("Paginated", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/payments/pay_1/timeline?limit=2&offset=2", nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		var result struct {
+			Data    []TimelineEntry `json:"data"`
+			HasMore bool            `json:"has_more"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Len(t, result.Data, 2)
+		assert.Equal(t, "rf_1", result.Data[0].ID)
+		assert.False(t, result.HasMore)
+	})
+
+	t.Run("Unknown Payment", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/payments/missing/timeline", nil)
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
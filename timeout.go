@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultMaxRequestTimeout is the absolute ceiling for the global and per-route request timeouts.
+const defaultMaxRequestTimeout = 5 * time.Minute
+
+// routeTimeout overrides the request timeout for one route. Path segments starting with ':' match any value.
+type routeTimeout struct {
+	method   string
+	segments []string
+	timeout  time.Duration
+}
+
+// parseRouteTimeouts parses a comma-separated list of "METHOD /path=duration" overrides, e.g.
+// "GET /reports/settlement=2m,POST /admin/reconciliation/import=5m".
+func parseRouteTimeouts(spec string) ([]routeTimeout, error) {
+	var routes []routeTimeout
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, rawTimeout, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("route timeout %q must look like \"METHOD /path=duration\"", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(rawTimeout))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("route timeout %q has an invalid duration", entry)
+		}
+		routes = append(routes, routeTimeout{
+			method:   strings.ToUpper(method),
+			segments: strings.Split(strings.Trim(path, "/"), "/"),
+			timeout:  timeout,
+		})
+	}
+	return routes, nil
+}
+
+// validateRequestTimeouts checks that the global timeout and every override parse and stay within max.
+func validateRequestTimeouts(global time.Duration, spec string, max time.Duration) error {
+	if global > max {
+		return fmt.Errorf("REQUEST_TIMEOUT %s exceeds MAX_REQUEST_TIMEOUT %s", global, max)
+	}
+	routes, err := parseRouteTimeouts(spec)
+	if err != nil {
+		return fmt.Errorf("invalid ROUTE_TIMEOUTS: %w", err)
+	}
+	for _, route := range routes {
+		if route.timeout > max {
+			return fmt.Errorf("ROUTE_TIMEOUTS %s /%s=%s exceeds MAX_REQUEST_TIMEOUT %s",
+				route.method, strings.Join(route.segments, "/"), route.timeout, max)
+		}
+	}
+	return nil
+}
+
+func (t routeTimeout) matches(method, path string) bool {
+	if t.method != method {
+		return false
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != len(t.segments) {
+		return false
+	}
+	for i, segment := range t.segments {
+		if !strings.HasPrefix(segment, ":") && segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestTimeout bounds each request with a deadline on its user context, using the first matching route
+// override or else the global timeout; 0 leaves a request unbounded. Handlers observe the deadline through
+// the context they pass to stores and gateways, which stop their calls once it passes. A handler that gave up
+// because of the deadline, returning an error or a server error, is answered with request_timeout. A response
+// the handler did finish is passed through even when it is late, since its side effects, such as a charged
+// payment, have already happened and a client told it timed out would retry them.
+func NewRequestTimeout(global time.Duration, routes []routeTimeout) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		timeout := global
+		for _, route := range routes {
+			if route.matches(c.Method(), c.Path()) {
+				timeout = route.timeout
+				break
+			}
+		}
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && (err != nil || c.Response().StatusCode() >= fiber.StatusInternalServerError) {
+			requestID := c.GetRespHeader(fiber.HeaderXRequestID)
+			c.Response().Reset()
+			if requestID != "" {
+				c.Set(fiber.HeaderXRequestID, requestID)
+			}
+			return respondError(c, ErrCodeRequestTimeout, "request exceeded its timeout of "+timeout.String())
+		}
+		return err
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// sleepHandler simulates work that takes d but gives up when the request context is done, like a store or
+// gateway call would.
+func sleepHandler(d time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		select {
+		case <-time.After(d):
+			return c.SendString("done")
+		case <-c.UserContext().Done():
+			return respondError(c, ErrCodeInternal, "aborted")
+		}
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	newApp := func(config Config) *fiber.App {
+		app := fiber.New()
+		useMiddlewares(app, config)
+		app.Get("/reports/settlement", sleepHandler(50*time.Millisecond))
+		app.Post("/payments", sleepHandler(50*time.Millisecond))
+		app.Get("/payments/:id/timeline", sleepHandler(50*time.Millisecond))
+		return app
+	}
+	config := Config{RequestTimeout: 10 * time.Millisecond, RouteTimeouts: "GET /reports/settlement=1s,GET /payments/:id/timeline=1s"}
+
+	t.Run("Override Lets Long Route Finish", func(t *testing.T) {
+		resp, err := newApp(config).Test(httptest.NewRequest(http.MethodGet, "/reports/settlement", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Override Matches Path Parameters", func(t *testing.T) {
+		resp, err := newApp(config).Test(httptest.NewRequest(http.MethodGet, "/payments/pay_1/timeline", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Normal Route Cut At Global Timeout", func(t *testing.T) {
+		resp, err := newApp(config).Test(httptest.NewRequest(http.MethodPost, "/payments", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+		assert.Equal(t, ErrCodeRequestTimeout, decodeErrorCode(t, resp))
+	})
+
+	t.Run("Timeout Response Keeps Request ID", func(t *testing.T) {
+		withRequestID := config
+		withRequestID.MiddlewareRequestID = true
+		resp, err := newApp(withRequestID).Test(httptest.NewRequest(http.MethodPost, "/payments", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get(fiber.HeaderXRequestID))
+	})
+
+	t.Run("Finished Response Passed Through After Deadline", func(t *testing.T) {
+		app := fiber.New()
+		useMiddlewares(app, Config{RequestTimeout: 10 * time.Millisecond})
+		app.Post("/payments", func(c *fiber.Ctx) error {
+			// Work that ignores the context, then reports the payment it already made.
+			time.Sleep(30 * time.Millisecond)
+			return c.Status(fiber.StatusCreated).SendString("charged")
+		})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/payments", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode, "the charge is reported, not hidden behind a timeout")
+	})
+
+	t.Run("Deadline Cancels Gateway Calls", func(t *testing.T) {
+		gateway := NewSandboxGateway("sandbox")
+		app := fiber.New()
+		useMiddlewares(app, Config{RequestTimeout: 10 * time.Millisecond})
+		app.Post("/payments", func(c *fiber.Ctx) error {
+			<-c.UserContext().Done()
+			if _, err := gateway.Authorize(c.UserContext(), AuthorizeRequest{PaymentID: "pay_1"}); err != nil {
+				return respondError(c, ErrCodeGatewayError, err.Error())
+			}
+			return c.SendStatus(fiber.StatusCreated)
+		})
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/payments", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+		assert.Zero(t, gateway.Processed(GatewayOpAuthorize), "the call is not sent once the deadline has passed")
+	})
+
+	t.Run("Deadline Reaches Handler Context", func(t *testing.T) {
+		app := fiber.New()
+		useMiddlewares(app, Config{RequestTimeout: time.Second})
+		var deadline time.Time
+		app.Get("/", func(c *fiber.Ctx) error {
+			deadline, _ = c.UserContext().Deadline()
+			return nil
+		})
+		_, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 500*time.Millisecond)
+	})
+}
+
+func TestValidateRequestTimeouts(t *testing.T) {
+	t.Run("Within Max", func(t *testing.T) {
+		assert.NoError(t, validateRequestTimeouts(30*time.Second, "GET /reports/settlement=2m", 5*time.Minute))
+	})
+
+	t.Run("Override Above Max", func(t *testing.T) {
+		err := validateRequestTimeouts(30*time.Second, "GET /reports/settlement=10m", 5*time.Minute)
+		assert.ErrorContains(t, err, "exceeds MAX_REQUEST_TIMEOUT")
+	})
+
+	t.Run("Global Above Max", func(t *testing.T) {
+		assert.Error(t, validateRequestTimeouts(10*time.Minute, "", 5*time.Minute))
+	})
+
+	t.Run("Malformed Override", func(t *testing.T) {
+		for _, spec := range []string{"/reports=1m", "GET /reports", "GET /reports=soon", "GET reports=1m", "GET /reports=-1s"} {
+			assert.Error(t, validateRequestTimeouts(0, spec, time.Minute), spec)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultTLSMinVersion is the oldest protocol version accepted when TLS_MIN_VERSION is not set.
+const defaultTLSMinVersion = "1.2"
+
+// tlsVersions are the protocol versions TLS_MIN_VERSION may name. TLS 1.0 and 1.1 are deliberately absent:
+// PCI DSS no longer accepts them.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsEnabled reports whether the service serves HTTPS.
+func (c Config) tlsEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSKeyFile != ""
+}
+
+// parseTLSMinVersion parses TLS_MIN_VERSION, "1.2" or "1.3"; empty means 1.2.
+func parseTLSMinVersion(spec string) (uint16, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = defaultTLSMinVersion
+	}
+	version, ok := tlsVersions[spec]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS_MIN_VERSION %q: want 1.2 or 1.3", spec)
+	}
+	return version, nil
+}
+
+// parseTLSCipherSuites parses TLS_CIPHER_SUITES, a comma-separated list of IANA cipher suite names such as
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". Only the suites Go considers secure are accepted; an empty list
+// uses Go's defaults. The list applies to TLS 1.2, since TLS 1.3 suites are not configurable and all secure.
+func parseTLSCipherSuites(spec string) ([]uint16, error) {
+	secure := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		secure[suite.Name] = suite.ID
+	}
+	insecure := make(map[string]bool)
+	for _, suite := range tls.InsecureCipherSuites() {
+		insecure[suite.Name] = true
+	}
+
+	var suites []uint16
+	for _, entry := range strings.Split(spec, ",") {
+		name := strings.TrimSpace(entry)
+		if name == "" {
+			continue
+		}
+		if insecure[name] {
+			return nil, fmt.Errorf("invalid TLS_CIPHER_SUITES entry %q: the cipher suite is insecure", name)
+		}
+		id, ok := secure[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS_CIPHER_SUITES entry %q: unknown cipher suite", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+// validateTLS checks the TLS settings: a certificate and key both or neither, and a valid minimum version and
+// cipher suite list.
+func (c Config) validateTLS() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if _, err := parseTLSMinVersion(c.TLSMinVersion); err != nil {
+		return err
+	}
+	_, err := parseTLSCipherSuites(c.TLSCipherSuites)
+	return err
+}
+
+// serverTLSConfig loads the certificate and builds the TLS configuration the listener serves with.
+func (c Config) serverTLSConfig() (*tls.Config, error) {
+	// Validate has already rejected a malformed TLS_MIN_VERSION and TLS_CIPHER_SUITES.
+	minVersion, _ := parseTLSMinVersion(c.TLSMinVersion)
+	suites, _ := parseTLSCipherSuites(c.TLSCipherSuites)
+	certificate, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   minVersion,
+		CipherSuites: suites,
+	}, nil
+}
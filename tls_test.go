@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelfSignedCertificate writes a certificate and key for 127.0.0.1 and returns their paths.
+func writeSelfSignedCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "payment-service"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCertificate(t)
+	serve := func(t *testing.T, config Config) string {
+		tlsConfig, err := config.serverTLSConfig()
+		assert.NoError(t, err)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		app := fiber.New(fiber.Config{DisableStartupMessage: true})
+		(&APIRouter{}).SetupRoutes(app, config)
+		go func() { _ = app.Listener(tls.NewListener(listener, tlsConfig)) }()
+		t.Cleanup(func() { _ = app.Shutdown() })
+		return "https://" + listener.Addr().String() + "/health"
+	}
+	get := func(url string, client *tls.Config) error {
+		client.InsecureSkipVerify = true
+		httpClient := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: client}}
+		resp, err := httpClient.Get(url)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	t.Run("Allowed Version Succeeds", func(t *testing.T) {
+		url := serve(t, Config{TLSCertFile: certFile, TLSKeyFile: keyFile})
+		assert.NoError(t, get(url, &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12}))
+		assert.NoError(t, get(url, &tls.Config{MinVersion: tls.VersionTLS13}))
+	})
+
+	t.Run("TLS 1.0 Handshake Fails", func(t *testing.T) {
+		url := serve(t, Config{TLSCertFile: certFile, TLSKeyFile: keyFile})
+		assert.Error(t, get(url, &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS10}))
+		assert.Error(t, get(url, &tls.Config{MinVersion: tls.VersionTLS11, MaxVersion: tls.VersionTLS11}))
+	})
+
+	t.Run("Minimum Version 1.3 Rejects 1.2", func(t *testing.T) {
+		url := serve(t, Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.3"})
+		assert.Error(t, get(url, &tls.Config{MaxVersion: tls.VersionTLS12}))
+		assert.NoError(t, get(url, &tls.Config{MinVersion: tls.VersionTLS13}))
+	})
+
+	t.Run("Cipher Suites Outside The List Fail", func(t *testing.T) {
+		url := serve(t, Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSCipherSuites: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"})
+		assert.Error(t, get(url, &tls.Config{
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		}))
+		assert.NoError(t, get(url, &tls.Config{
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		}))
+	})
+
+	t.Run("Config Validation", func(t *testing.T) {
+		assert.NoError(t, Config{TLSMinVersion: "1.3", TLSCipherSuites: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"}.Validate())
+		assert.ErrorContains(t, Config{TLSMinVersion: "1.0"}.Validate(), "TLS_MIN_VERSION")
+		assert.ErrorContains(t, Config{TLSMinVersion: "1.1"}.Validate(), "TLS_MIN_VERSION")
+		assert.ErrorContains(t, Config{TLSCipherSuites: "TLS_RSA_WITH_RC4_128_SHA"}.Validate(), "insecure")
+		assert.ErrorContains(t, Config{TLSCipherSuites: "TLS_MADE_UP"}.Validate(), "unknown cipher suite")
+		assert.ErrorContains(t, Config{TLSCertFile: certFile}.Validate(), "TLS_KEY_FILE")
+
+		_, err := Config{TLSCertFile: certFile, TLSKeyFile: filepath.Join(t.TempDir(), "missing.pem")}.serverTLSConfig()
+		assert.ErrorContains(t, err, "failed to load TLS certificate")
+	})
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// HeaderTraceParent is the W3C Trace Context header a request's trace ID is taken from when present.
+const HeaderTraceParent = "traceparent"
+
+// SpanEvent is a timestamped annotation on a span, such as a decision taken while serving the request.
+type SpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]string
+}
+
+// Span records the attributes and events of one request. All methods are safe on a nil *Span, so code can
+// annotate the current span without checking whether tracing is enabled.
+type Span struct {
+	mu         sync.Mutex
+	name       string
+	traceID    string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	events     []SpanEvent
+}
+
+type spanContextKey struct{}
+
+// StartSpan starts a span; an empty traceID gets a random one.
+func StartSpan(name, traceID string) *Span {
+	if traceID == "" {
+		id := uuid.New()
+		traceID = hex.EncodeToString(id[:])
+	}
+	return &Span{name: name, traceID: traceID, start: time.Now(), attributes: make(map[string]string)}
+}
+
+// ContextWithSpan returns a copy of ctx carrying span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, or nil when the request is not traced.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// TraceID returns the ID of the trace the span belongs to.
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.traceID
+}
+
+// SetAttribute sets a key/value attribute on the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// Attribute returns the value of an attribute set on the span.
+func (s *Span) Attribute(key string) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attributes[key]
+}
+
+// AddEvent records a named event with attributes on the span.
+func (s *Span) AddEvent(name string, attributes map[string]string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, SpanEvent{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+// Events returns the events recorded on the span, in order.
+func (s *Span) Events() []SpanEvent {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SpanEvent(nil), s.events...)
+}
+
+// End marks the span as finished.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.end = time.Now()
+}
+
+// String formats the span as a single log line.
+func (s *Span) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "trace_id=%s span=%q duration=%s", s.traceID, s.name, s.end.Sub(s.start))
+	writeSpanAttributes(&b, s.attributes)
+	for _, event := range s.events {
+		fmt.Fprintf(&b, " event=%s", event.Name)
+		writeSpanAttributes(&b, event.Attributes)
+	}
+	return b.String()
+}
+
+func writeSpanAttributes(b *strings.Builder, attributes map[string]string) {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(b, " %s=%q", key, attributes[key])
+	}
+}
+
+// traceIDFromHeader extracts the trace ID from a W3C traceparent header, returning "" when it is malformed.
+func traceIDFromHeader(traceParent string) string {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || strings.Trim(parts[1], "0") == "" {
+		return ""
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return ""
+	}
+	return strings.ToLower(parts[1])
+}
+
+// NewTracingMiddleware starts a span for every request, continuing the caller's trace when it sends a
+// traceparent header, and passes the finished span to export. Handlers and middleware further down annotate
+// it through SpanFromContext(c.UserContext()).
+func NewTracingMiddleware(export func(*Span)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		span := StartSpan(c.Method()+" "+c.Path(), traceIDFromHeader(c.Get(HeaderTraceParent)))
+		c.SetUserContext(ContextWithSpan(c.UserContext(), span))
+
+		err := c.Next()
+		span.SetAttribute("http.status_code", fmt.Sprint(c.Response().StatusCode()))
+		span.End()
+		export(span)
+		return err
+	}
+}
+
+// logSpan is the span exporter used until a tracing backend is configured.
+func logSpan(span *Span) {
+	log.Printf("TRACE %s", span)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	newApp := func() (*fiber.App, *[]*Span) {
+		var spans []*Span
+		app := fiber.New()
+		app.Use(NewTracingMiddleware(func(span *Span) { spans = append(spans, span) }))
+		app.Get("/ping", func(c *fiber.Ctx) error {
+			SpanFromContext(c.UserContext()).SetAttribute("handler", "ping")
+			return c.SendString("pong")
+		})
+		return app, &spans
+	}
+
+	t.Run("Continues Caller Trace", func(t *testing.T) {
+		app, spans := newApp()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		_, err := app.Test(req)
+		assert.NoError(t, err)
+
+		assert.Len(t, *spans, 1)
+		span := (*spans)[0]
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID())
+		assert.Equal(t, "ping", span.Attribute("handler"))
+		assert.Equal(t, "200", span.Attribute("http.status_code"))
+	})
+
+	t.Run("Starts New Trace For Malformed Header", func(t *testing.T) {
+		app, spans := newApp()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(HeaderTraceParent, "garbage")
+		_, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Len(t, (*spans)[0].TraceID(), 32)
+	})
+
+	t.Run("Nil Span Is Safe", func(t *testing.T) {
+		var span *Span
+		span.SetAttribute("k", "v")
+		span.AddEvent("e", nil)
+		assert.Empty(t, span.Attribute("k"))
+		assert.Empty(t, span.Events())
+	})
+}
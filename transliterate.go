@@ -0,0 +1,87 @@
+package main
+
+import "strings"
+
+// latinASCII maps accented Latin letters to their unaccented ASCII form.
+var latinASCII = map[rune]string{
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ä': "A", 'Å': "A", 'Æ': "AE", 'Ç': "C", 'È': "E", 'É': "E",
+	'Ê': "E", 'Ë': "E", 'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I", 'Ð': "D", 'Ñ': "N", 'Ò': "O", 'Ó': "O",
+	'Ô': "O", 'Õ': "O", 'Ö': "O", 'Ø': "O", 'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "U", 'Ý': "Y", 'Þ': "TH",
+	'ß': "ss", 'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae", 'ç': "c", 'è': "e",
+	'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ð': "d", 'ñ': "n", 'ò': "o",
+	'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y",
+	'þ': "th", 'ÿ': "y",
+}
+
+// thaiConsonants maps Thai consonants to their initial sound in the Royal Thai General System (RTGS).
+var thaiConsonants = map[rune]string{
+	'ก': "k", 'ข': "kh", 'ฃ': "kh", 'ค': "kh", 'ฅ': "kh", 'ฆ': "kh", 'ง': "ng", 'จ': "ch", 'ฉ': "ch",
+	'ช': "ch", 'ซ': "s", 'ฌ': "ch", 'ญ': "y", 'ฎ': "d", 'ฏ': "t", 'ฐ': "th", 'ฑ': "th", 'ฒ': "th", 'ณ': "n",
+	'ด': "d", 'ต': "t", 'ถ': "th", 'ท': "th", 'ธ': "th", 'น': "n", 'บ': "b", 'ป': "p", 'ผ': "ph", 'ฝ': "f",
+	'พ': "ph", 'ฟ': "f", 'ภ': "ph", 'ม': "m", 'ย': "y", 'ร': "r", 'ฤ': "rue", 'ล': "l", 'ฦ': "lue", 'ว': "w",
+	'ศ': "s", 'ษ': "s", 'ส': "s", 'ห': "h", 'ฬ': "l", 'อ': "o", 'ฮ': "h",
+}
+
+// thaiFinals maps Thai consonants that end a syllable to their RTGS final sound where it differs from the
+// initial one, e.g. the ร in อาหาร is read as n.
+var thaiFinals = map[rune]string{
+	'ข': "k", 'ค': "k", 'ฆ': "k", 'จ': "t", 'ช': "t", 'ซ': "t", 'ฎ': "t", 'ฏ': "t", 'ฐ': "t", 'ฑ': "t",
+	'ฒ': "t", 'ด': "t", 'ถ': "t", 'ท': "t", 'ธ': "t", 'ศ': "t", 'ษ': "t", 'ส': "t", 'บ': "p", 'ป': "p",
+	'พ': "p", 'ฟ': "p", 'ภ': "p", 'ญ': "n", 'ณ': "n", 'ร': "n", 'ล': "n", 'ฬ': "n",
+}
+
+// thaiVowels maps Thai vowel signs to RTGS; tone marks and other signs without a sound map to "".
+var thaiVowels = map[rune]string{
+	'ะ': "a", 'ั': "a", 'า': "a", 'ำ': "am", 'ิ': "i", 'ี': "i", 'ึ': "ue", 'ื': "ue", 'ุ': "u", 'ู': "u",
+	'ฺ': "", 'ๅ': "", 'ๆ': "", '็': "", '่': "", '้': "", '๊': "", '๋': "", '์': "", 'ํ': "", 'ฯ': "",
+}
+
+// thaiLeadingVowels are written before the consonant they follow in speech.
+var thaiLeadingVowels = map[rune]string{'เ': "e", 'แ': "ae", 'โ': "o", 'ใ': "ai", 'ไ': "ai"}
+
+// TransliterateASCII approximates text in ASCII: accented Latin letters lose their accents and Thai is
+// romanized letter by letter following RTGS, reading a consonant after a vowel and not followed by one as a
+// final. Other non-ASCII characters are dropped. It is meant for statement descriptors, not for
+// dictionary-accurate romanization.
+func TransliterateASCII(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	// leading holds a leading vowel until the consonant it is pronounced after has been written.
+	leading := ""
+	afterVowel := false
+	for i, ch := range runes {
+		vowelFollows := i+1 < len(runes) && thaiVowels[runes[i+1]] != ""
+		consonant, isConsonant := thaiConsonants[ch]
+		vowel, isVowel := thaiVowels[ch]
+		switch {
+		case isConsonant:
+			if final, ok := thaiFinals[ch]; ok && afterVowel && leading == "" && !vowelFollows {
+				consonant = final
+			}
+			// อ is silent when it only carries a written vowel.
+			if ch == 'อ' && (leading != "" || vowelFollows) {
+				consonant = ""
+			}
+			b.WriteString(consonant + leading)
+			afterVowel = leading != ""
+			leading = ""
+		case isVowel:
+			b.WriteString(vowel)
+			afterVowel = afterVowel || vowel != ""
+		default:
+			b.WriteString(leading)
+			leading, afterVowel = thaiLeadingVowels[ch], false
+			switch {
+			case leading != "":
+			case ch < 0x80:
+				b.WriteRune(ch)
+			case ch >= '๐' && ch <= '๙':
+				b.WriteRune('0' + (ch - '๐'))
+			default:
+				b.WriteString(latinASCII[ch])
+			}
+		}
+	}
+	b.WriteString(leading)
+	return b.String()
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhookEndpointStatus describes whether a merchant webhook endpoint may receive events.
+type WebhookEndpointStatus string
+
+const (
+	// WebhookEndpointPending marks an endpoint whose ownership has not been verified yet.
+	WebhookEndpointPending WebhookEndpointStatus = "pending"
+	// WebhookEndpointActive marks an endpoint that passed the challenge handshake.
+	WebhookEndpointActive WebhookEndpointStatus = "active"
+)
+
+// webhookChallengeTimeout bounds how long a merchant endpoint has to answer the challenge.
+const webhookChallengeTimeout = 5 * time.Second
+
+// ErrChallengeFailed is returned when a webhook endpoint does not echo the challenge token.
+var ErrChallengeFailed = errors.New("webhook challenge failed")
+
+// WebhookEndpoint represents a merchant-registered URL that receives event notifications.
+type WebhookEndpoint struct {
+	MerchantID string                `json:"merchant_id"`
+	URL        string                `json:"url"`
+	Status     WebhookEndpointStatus `json:"status"`
+	CreatedAt  time.Time             `json:"created_at"`
+	VerifiedAt *time.Time            `json:"verified_at,omitempty"`
+	// EventTypes are the event types the endpoint subscribed to; empty subscribes it to all of them.
+	EventTypes []EventType `json:"event_types,omitempty"`
+}
+
+// Subscribes reports whether the endpoint wants events of the given type.
+func (e WebhookEndpoint) Subscribes(eventType EventType) bool {
+	return len(e.EventTypes) == 0 || slices.Contains(e.EventTypes, eventType)
+}
+
+// parseEventTypes checks that every name is a known event type, dropping duplicates.
+func parseEventTypes(names []string) ([]EventType, error) {
+	var types []EventType
+	for _, name := range names {
+		eventType := EventType(name)
+		if !slices.Contains(knownEventTypes, eventType) {
+			return nil, fmt.Errorf("unknown event type %q", name)
+		}
+		if !slices.Contains(types, eventType) {
+			types = append(types, eventType)
+		}
+	}
+	return types, nil
+}
+
+// WebhookChallenger verifies that the owner of a URL controls it by sending a token and expecting it echoed back.
+type WebhookChallenger interface {
+	Challenge(ctx context.Context, endpointURL, token string) error
+}
+
+// HTTPWebhookChallenger performs the challenge handshake by POSTing the token to the endpoint over HTTP.
+type HTTPWebhookChallenger struct {
+	Client *http.Client
+}
+
+// Challenge sends {"type":"webhook.challenge","challenge":token} and accepts either a JSON body with the same
+// challenge field or the raw token as the response.
+func (h *HTTPWebhookChallenger) Challenge(ctx context.Context, endpointURL, token string) error {
+	payload, err := json.Marshal(map[string]string{"type": "webhook.challenge", "challenge": token})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: webhookChallengeTimeout}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrChallengeFailed, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: endpoint responded with status %d", ErrChallengeFailed, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrChallengeFailed, err)
+	}
+
+	var echoed struct {
+		Challenge string `json:"challenge"`
+	}
+	if json.Unmarshal(body, &echoed) == nil && echoed.Challenge == token {
+		return nil
+	}
+	if strings.TrimSpace(string(body)) == token {
+		return nil
+	}
+	return fmt.Errorf("%w: token not echoed", ErrChallengeFailed)
+}
+
+// WebhookRegistry stores merchant webhook endpoints and activates them once their challenge succeeds.
+type WebhookRegistry struct {
+	mu        sync.RWMutex
+	endpoints map[string]WebhookEndpoint
+	// replacements holds the new URL of a merchant with an active endpoint while that URL is being verified.
+	replacements map[string]WebhookEndpoint
+	challenger   WebhookChallenger
+	now          func() time.Time
+}
+
+// NewWebhookRegistry creates an empty WebhookRegistry that verifies endpoints with the given challenger.
+func NewWebhookRegistry(challenger WebhookChallenger) *WebhookRegistry {
+	return &WebhookRegistry{
+		endpoints:    make(map[string]WebhookEndpoint),
+		replacements: make(map[string]WebhookEndpoint),
+		challenger:   challenger,
+		now:          time.Now,
+	}
+}
+
+// Register stores the URL as a pending endpoint for the merchant, subscribed to eventTypes, and performs the
+// challenge handshake. Registering the same URL again keeps an already active endpoint, replacing its
+// subscriptions. A changed URL is re-verified while the active endpoint keeps receiving events, and only
+// replaces it once the challenge succeeds, so a failed verification never leaves the merchant without webhooks.
+func (w *WebhookRegistry) Register(ctx context.Context, merchantID, endpointURL string, eventTypes []EventType) (WebhookEndpoint, error) {
+	w.mu.Lock()
+	existing, ok := w.endpoints[merchantID]
+	replacing := ok && existing.Status == WebhookEndpointActive
+	if replacing && existing.URL == endpointURL {
+		existing.EventTypes = eventTypes
+		w.endpoints[merchantID] = existing
+		delete(w.replacements, merchantID)
+		w.mu.Unlock()
+		return existing, nil
+	}
+	endpoint := WebhookEndpoint{
+		MerchantID: merchantID,
+		URL:        endpointURL,
+		Status:     WebhookEndpointPending,
+		CreatedAt:  w.now().UTC(),
+		EventTypes: eventTypes,
+	}
+	candidates := w.endpoints
+	if replacing {
+		candidates = w.replacements
+	}
+	candidates[merchantID] = endpoint
+	w.mu.Unlock()
+
+	token, err := newChallengeToken()
+	if err == nil {
+		err = w.challenger.Challenge(ctx, endpointURL, token)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	current, ok := candidates[merchantID]
+	if !ok || current.URL != endpointURL {
+		if err != nil {
+			return endpoint, err
+		}
+		return endpoint, fmt.Errorf("%w: endpoint changed during verification", ErrChallengeFailed)
+	}
+	if err != nil {
+		// A replacement that failed verification is dropped; the merchant registers it again to retry.
+		delete(w.replacements, merchantID)
+		return endpoint, err
+	}
+	verifiedAt := w.now().UTC()
+	current.Status = WebhookEndpointActive
+	current.VerifiedAt = &verifiedAt
+	w.endpoints[merchantID] = current
+	delete(w.replacements, merchantID)
+	return current, nil
+}
+
+// Get returns the webhook endpoint registered for the merchant, if any.
+func (w *WebhookRegistry) Get(merchantID string) (WebhookEndpoint, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	endpoint, ok := w.endpoints[merchantID]
+	return endpoint, ok
+}
+
+func newChallengeToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// registerWebhookRequest is the body accepted by POST /merchants/:id/webhooks.
+type registerWebhookRequest struct {
+	URL string `json:"url"`
+	// EventTypes limits deliveries to these event types, such as "payment.captured"; omitted means all.
+	EventTypes []string `json:"event_types"`
+}
+
+// registerWebhook registers the merchant's webhook endpoint. Only the merchant's own keys or the admin token may
+// do so: the challenge proves control of the URL, not of the merchant whose events it would receive.
+func (r *APIRouter) registerWebhook(c *fiber.Ctx) error {
+	merchantID := c.Params("id")
+	if !r.canManageMerchant(c, merchantID) {
+		return respondError(c, ErrCodeForbidden, "webhooks can only be managed with one of the merchant's keys or the admin token")
+	}
+	var req registerWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return respondError(c, ErrCodeValidationFailed, "url must be an absolute http or https URL")
+	}
+
+	eventTypes, err := parseEventTypes(req.EventTypes)
+	if err != nil {
+		return respondError(c, ErrCodeValidationFailed, err.Error())
+	}
+
+	endpoint, err := r.webhooks.Register(c.UserContext(), merchantID, req.URL, eventTypes)
+	if errors.Is(err, ErrBlockedDestination) {
+		return respondError(c, ErrCodeValidationFailed, "url must not point at an internal address")
+	}
+	if err != nil {
+		if !errors.Is(err, ErrChallengeFailed) {
+			return respondError(c, ErrCodeInternal, "failed to register webhook endpoint")
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"endpoint":           endpoint,
+			"verification_error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"endpoint": endpoint,
+	})
+}
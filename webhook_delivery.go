@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// WebhookDelivery records one attempt to deliver an event to a merchant webhook endpoint.
+type WebhookDelivery struct {
+	ID           string
+	MerchantID   string
+	PaymentID    string
+	EventType    EventType
+	URL          string
+	StatusCode   int
+	Succeeded    bool
+	ResponseBody string
+	AttemptedAt  time.Time
+}
+
+// DeliveryLog records webhook delivery attempts.
+type DeliveryLog interface {
+	Record(ctx context.Context, delivery WebhookDelivery) error
+	ListByPayment(ctx context.Context, paymentID string) ([]WebhookDelivery, error)
+}
+
+// MemoryDeliveryLog is a DeliveryLog that keeps delivery attempts in memory.
+type MemoryDeliveryLog struct {
+	deliveries memoryCollection[WebhookDelivery]
+}
+
+// NewMemoryDeliveryLog creates an empty MemoryDeliveryLog.
+func NewMemoryDeliveryLog() *MemoryDeliveryLog {
+	return &MemoryDeliveryLog{}
+}
+
+// Record implements DeliveryLog.
+func (l *MemoryDeliveryLog) Record(_ context.Context, delivery WebhookDelivery) error {
+	l.deliveries.add(delivery)
+	return nil
+}
+
+// ListByPayment implements DeliveryLog.
+func (l *MemoryDeliveryLog) ListByPayment(_ context.Context, paymentID string) ([]WebhookDelivery, error) {
+	return l.deliveries.filter(func(d WebhookDelivery) bool { return d.PaymentID == paymentID }), nil
+}
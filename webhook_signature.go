@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HeaderWebhookSignature carries the signature of an outbound webhook, as "t=<unix seconds>,v1=<hex hmac>".
+const HeaderWebhookSignature = "X-Webhook-Signature"
+
+// defaultWebhookSignatureTolerance is how old a signed timestamp may be before verification rejects it.
+const defaultWebhookSignatureTolerance = 5 * time.Minute
+
+// maxClockSkewLeeway caps CLOCK_SKEW_LEEWAY so that accepting skewed clocks cannot widen the replay window
+// of signed webhooks without bound.
+const maxClockSkewLeeway = 2 * time.Minute
+
+// ErrInvalidWebhookSignature is returned when a webhook signature is malformed, stale or does not match.
+var ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+// SignWebhookPayload returns the signature header value for payload sent at timestamp. The HMAC-SHA256 covers
+// "<timestamp>.<payload>" so that a captured delivery cannot be replayed with a fresh timestamp.
+func SignWebhookPayload(secret string, payload []byte, timestamp time.Time) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, webhookHMAC(secret, ts, payload))
+}
+
+// VerifyWebhookSignature checks a signature header produced by SignWebhookPayload against payload, rejecting
+// timestamps more than tolerance away from now.
+func VerifyWebhookSignature(secret string, payload []byte, header string, tolerance time.Duration, now time.Time) error {
+	var ts, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signature = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || signature == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidWebhookSignature)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidWebhookSignature)
+	}
+	if !hmac.Equal([]byte(signature), []byte(webhookHMAC(secret, ts, payload))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidWebhookSignature)
+	}
+	return nil
+}
+
+func webhookHMAC(secret, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSignatureTolerance is how far a signed timestamp may be from our clock: the replay window plus the
+// configured leeway for clock skew between us and the sender.
+func (r *APIRouter) webhookSignatureTolerance() time.Duration {
+	return defaultWebhookSignatureTolerance + r.config.ClockSkewLeeway
+}
+
+// verifyGatewayWebhook checks the signature of an inbound gateway webhook when GATEWAY_WEBHOOK_SECRET is set.
+func (r *APIRouter) verifyGatewayWebhook(c *fiber.Ctx) error {
+	if r.config.GatewayWebhookSecret == "" {
+		return nil
+	}
+	return VerifyWebhookSignature(r.config.GatewayWebhookSecret, c.Body(), c.Get(HeaderWebhookSignature),
+		r.webhookSignatureTolerance(), time.Now())
+}
+
+// signWebhookSampleRequest is the body accepted by POST /sandbox/webhooks/sign.
+type signWebhookSampleRequest struct {
+	Payload json.RawMessage `json:"payload"`
+	// Secret is the integrator's sandbox signing secret; empty uses the configured WEBHOOK_SIGNING_SECRET.
+	Secret string `json:"secret"`
+}
+
+// signWebhookSample signs a sample payload exactly as a delivery would be, so integrators can test their
+// verification code. It is only routed outside production.
+func (r *APIRouter) signWebhookSample(c *fiber.Ctx) error {
+	var req signWebhookSampleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return respondError(c, ErrCodeInvalidRequest, "invalid request body")
+	}
+	if len(req.Payload) == 0 || !json.Valid(req.Payload) {
+		return respondError(c, ErrCodeValidationFailed, "payload must be a JSON value")
+	}
+	secret := req.Secret
+	if secret == "" {
+		secret = r.config.WebhookSigningSecret
+	}
+	if secret == "" {
+		return respondError(c, ErrCodeValidationFailed, "secret is required when no signing secret is configured")
+	}
+
+	signature := SignWebhookPayload(secret, req.Payload, time.Now())
+	return c.JSON(fiber.Map{
+		"payload": req.Payload,
+		"headers": fiber.Map{
+			fiber.HeaderContentType: fiber.MIMEApplicationJSON,
+			HeaderWebhookSignature:  signature,
+		},
+	})
+}
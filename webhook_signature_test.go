@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func postSignSample(t *testing.T, app *fiber.App, body string) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, "/sandbox/webhooks/sign", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestWebhookSignature(t *testing.T) {
+	payload := []byte(`{"type":"payment.captured","payment_id":"pay_1"}`)
+	now := time.Unix(1700000000, 0)
+
+	t.Run("Round Trip", func(t *testing.T) {
+		header := SignWebhookPayload("whsec_test", payload, now)
+		assert.NoError(t, VerifyWebhookSignature("whsec_test", payload, header, time.Minute, now))
+	})
+
+	t.Run("Rejects Tampered Payload And Wrong Secret", func(t *testing.T) {
+		header := SignWebhookPayload("whsec_test", payload, now)
+		err := VerifyWebhookSignature("whsec_test", []byte(`{"type":"payment.refunded"}`), header, time.Minute, now)
+		assert.True(t, errors.Is(err, ErrInvalidWebhookSignature))
+		err = VerifyWebhookSignature("whsec_other", payload, header, time.Minute, now)
+		assert.True(t, errors.Is(err, ErrInvalidWebhookSignature))
+	})
+
+	t.Run("Rejects Stale Timestamp", func(t *testing.T) {
+		header := SignWebhookPayload("whsec_test", payload, now.Add(-10*time.Minute))
+		err := VerifyWebhookSignature("whsec_test", payload, header, defaultWebhookSignatureTolerance, now)
+		assert.True(t, errors.Is(err, ErrInvalidWebhookSignature))
+	})
+
+	t.Run("Rejects Malformed Header", func(t *testing.T) {
+		err := VerifyWebhookSignature("whsec_test", payload, "v1=abc", time.Minute, now)
+		assert.True(t, errors.Is(err, ErrInvalidWebhookSignature))
+	})
+}
+
+func TestSignWebhookSample(t *testing.T) {
+	t.Run("Signature Verifies", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{Env: "development"})
+
+		resp := postSignSample(t, app, `{"payload":{"type":"payment.captured","amount":1000},"secret":"whsec_test"}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body struct {
+			Payload json.RawMessage   `json:"payload"`
+			Headers map[string]string `json:"headers"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		signature := body.Headers[HeaderWebhookSignature]
+		assert.NoError(t, VerifyWebhookSignature("whsec_test", body.Payload, signature, time.Minute, time.Now()))
+	})
+
+	t.Run("Falls Back To Configured Secret", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{WebhookSigningSecret: "whsec_config"})
+
+		resp := postSignSample(t, app, `{"payload":{"type":"payment.captured"}}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Requires Payload And Secret", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{})
+
+		assert.Equal(t, http.StatusUnprocessableEntity, postSignSample(t, app, `{"secret":"whsec_test"}`).StatusCode)
+		assert.Equal(t, http.StatusUnprocessableEntity, postSignSample(t, app, `{"payload":{}}`).StatusCode)
+	})
+
+	t.Run("Unavailable In Production", func(t *testing.T) {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{Env: "production", WebhookSigningSecret: "whsec_config"})
+
+		resp := postSignSample(t, app, `{"payload":{"type":"payment.captured"}}`)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestGatewayWebhookSignature(t *testing.T) {
+	body := `{"type":"refund.succeeded","gateway_reference":"unknown"}`
+	newApp := func() *fiber.App {
+		app := fiber.New()
+		(&APIRouter{}).SetupRoutes(app, Config{GatewayWebhookSecret: "whsec_gw", ClockSkewLeeway: 30 * time.Second})
+		return app
+	}
+	post := func(app *fiber.App, signature string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/gateways/sandbox", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if signature != "" {
+			req.Header.Set(HeaderWebhookSignature, signature)
+		}
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Missing Signature Rejected", func(t *testing.T) {
+		resp := post(newApp(), "")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, ErrCodeInvalidSignature, decodeErrorCode(t, resp))
+	})
+
+	t.Run("Skewed Timestamp Within Leeway Accepted", func(t *testing.T) {
+		signedAt := time.Now().Add(-defaultWebhookSignatureTolerance - 15*time.Second)
+		resp := post(newApp(), SignWebhookPayload("whsec_gw", []byte(body), signedAt))
+		// Past signature verification, the unknown refund is reported as not found.
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Skewed Timestamp Beyond Leeway Rejected", func(t *testing.T) {
+		signedAt := time.Now().Add(-defaultWebhookSignatureTolerance - 45*time.Second)
+		resp := post(newApp(), SignWebhookPayload("whsec_gw", []byte(body), signedAt))
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Sender Clock Ahead Within Leeway Accepted", func(t *testing.T) {
+		signedAt := time.Now().Add(defaultWebhookSignatureTolerance + 15*time.Second)
+		resp := post(newApp(), SignWebhookPayload("whsec_gw", []byte(body), signedAt))
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
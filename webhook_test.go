@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEchoChallengeServer(echo bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Challenge string `json:"challenge"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if !echo {
+			body.Challenge = "wrong-token"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func postWebhook(t *testing.T, app *fiber.App, merchantID, endpointURL string) (*http.Response, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodPost, "/merchants/"+merchantID+"/webhooks",
+		strings.NewReader(`{"url":"`+endpointURL+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderAdminToken, "admin-secret")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+
+	var result map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	return resp, result
+}
+
+func TestWebhookRegistration(t *testing.T) {
+	t.Run("Successful Handshake Activates Endpoint", func(t *testing.T) {
+		server := newEchoChallengeServer(true)
+		defer server.Close()
+
+		registry := NewWebhookRegistry(&HTTPWebhookChallenger{Client: server.Client()})
+		app := fiber.New()
+		router := &APIRouter{webhooks: registry}
+		router.SetupRoutes(app, Config{AdminToken: "admin-secret"})
+
+		resp, result := postWebhook(t, app, "m_1", server.URL)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "active", result["endpoint"].(map[string]interface{})["status"])
+
+		endpoint, ok := registry.Get("m_1")
+		assert.True(t, ok)
+		assert.Equal(t, WebhookEndpointActive, endpoint.Status)
+		assert.NotNil(t, endpoint.VerifiedAt)
+	})
+
+	t.Run("Failed Handshake Leaves Endpoint Pending", func(t *testing.T) {
+		server := newEchoChallengeServer(false)
+		defer server.Close()
+
+		registry := NewWebhookRegistry(&HTTPWebhookChallenger{Client: server.Client()})
+		app := fiber.New()
+		router := &APIRouter{webhooks: registry}
+		router.SetupRoutes(app, Config{AdminToken: "admin-secret"})
+
+		resp, result := postWebhook(t, app, "m_1", server.URL)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "pending", result["endpoint"].(map[string]interface{})["status"])
+		assert.NotEmpty(t, result["verification_error"])
+
+		endpoint, ok := registry.Get("m_1")
+		assert.True(t, ok)
+		assert.Equal(t, WebhookEndpointPending, endpoint.Status)
+	})
+
+	t.Run("URL Change Is Re-Verified", func(t *testing.T) {
+		good := newEchoChallengeServer(true)
+		defer good.Close()
+		bad := newEchoChallengeServer(false)
+		defer bad.Close()
+
+		registry := NewWebhookRegistry(&HTTPWebhookChallenger{})
+		app := fiber.New()
+		router := &APIRouter{webhooks: registry}
+		router.SetupRoutes(app, Config{AdminToken: "admin-secret"})
+
+		resp, _ := postWebhook(t, app, "m_1", good.URL)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		resp, result := postWebhook(t, app, "m_1", bad.URL)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "pending", result["endpoint"].(map[string]interface{})["status"])
+
+		endpoint, _ := registry.Get("m_1")
+		assert.Equal(t, good.URL, endpoint.URL, "the verified endpoint stays in place")
+		assert.Equal(t, WebhookEndpointActive, endpoint.Status)
+
+		replacement := newEchoChallengeServer(true)
+		defer replacement.Close()
+		resp, _ = postWebhook(t, app, "m_1", replacement.URL)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		endpoint, _ = registry.Get("m_1")
+		assert.Equal(t, replacement.URL, endpoint.URL, "a verified URL change takes over")
+		assert.Equal(t, WebhookEndpointActive, endpoint.Status)
+	})
+
+	t.Run("Only The Merchant Or Admin Registers", func(t *testing.T) {
+		server := newEchoChallengeServer(true)
+		defer server.Close()
+
+		registry := NewWebhookRegistry(&HTTPWebhookChallenger{Client: server.Client()})
+		merchantKeys := NewMemoryMerchantAPIKeyStore()
+		for merchantID, key := range map[string]string{"m_1": "sk_live_m1", "m_2": "sk_live_m2"} {
+			assert.NoError(t, merchantKeys.Create(context.Background(), MerchantAPIKey{
+				ID: "key_" + merchantID, MerchantID: merchantID, Hash: hashAPIKey(key), CreatedAt: time.Now(),
+			}))
+		}
+		app := fiber.New()
+		(&APIRouter{webhooks: registry, merchantKeys: merchantKeys}).SetupRoutes(app, Config{AdminToken: "admin-secret"})
+		register := func(key string) int {
+			req := httptest.NewRequest(http.MethodPost, "/merchants/m_1/webhooks", strings.NewReader(`{"url":"`+server.URL+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if key != "" {
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+key)
+			}
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, http.StatusForbidden, register("sk_live_m2"), "another merchant's key")
+		assert.Equal(t, http.StatusForbidden, register(""))
+		_, ok := registry.Get("m_1")
+		assert.False(t, ok)
+
+		assert.Equal(t, http.StatusCreated, register("sk_live_m1"))
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		app := fiber.New()
+		router := &APIRouter{}
+		router.SetupRoutes(app, Config{AdminToken: "admin-secret"})
+
+		resp, _ := postWebhook(t, app, "m_1", "ftp://example.com")
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+}
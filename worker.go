@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWorkerDrainTimeout is returned by Drain when the worker's current item did not finish in time and was
+// force-stopped by canceling its context.
+var ErrWorkerDrainTimeout = errors.New("worker drain timed out")
+
+// BackgroundWorker is a long-running job started alongside the HTTP server and drained on shutdown.
+type BackgroundWorker interface {
+	Name() string
+	Start()
+	// Remaining reports how many items of the current batch are still to be processed.
+	Remaining() int
+	// Drain stops the worker from picking up new items and waits up to timeout for the current one to
+	// finish, canceling it after that.
+	Drain(timeout time.Duration) error
+}
+
+// Worker periodically lists a batch of items and processes them one at a time. Once draining it finishes the
+// item in progress but starts no new ones.
+type Worker[T any] struct {
+	name     string
+	interval time.Duration
+	list     func(ctx context.Context) ([]T, error)
+	process  func(ctx context.Context, item T) error
+
+	remaining atomic.Int64
+	stopping  chan struct{}
+	cancel    context.CancelFunc
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewWorker creates a Worker that runs list, then process for each listed item, every interval once started.
+func NewWorker[T any](name string, interval time.Duration, list func(ctx context.Context) ([]T, error), process func(ctx context.Context, item T) error) *Worker[T] {
+	return &Worker[T]{name: name, interval: interval, list: list, process: process}
+}
+
+// Name implements BackgroundWorker.
+func (w *Worker[T]) Name() string {
+	return w.name
+}
+
+// Remaining implements BackgroundWorker.
+func (w *Worker[T]) Remaining() int {
+	return int(w.remaining.Load())
+}
+
+// Start runs the worker in the background.
+func (w *Worker[T]) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.stopping = make(chan struct{})
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stopping:
+				return
+			case <-ticker.C:
+				w.runBatch(ctx)
+			}
+		}
+	}()
+}
+
+func (w *Worker[T]) runBatch(ctx context.Context) {
+	items, err := w.list(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Worker %s failed to list work: %v", w.name, err)
+		}
+		return
+	}
+	w.remaining.Store(int64(len(items)))
+	defer w.remaining.Store(0)
+	for _, item := range items {
+		select {
+		case <-w.stopping:
+			return
+		default:
+		}
+		if err := w.process(ctx, item); err != nil && ctx.Err() == nil {
+			log.Printf("Worker %s failed to process item: %v", w.name, err)
+		}
+		w.remaining.Add(-1)
+	}
+}
+
+// Drain implements BackgroundWorker; it is safe to call more than once and on a worker never started.
+func (w *Worker[T]) Drain(timeout time.Duration) error {
+	var err error
+	w.once.Do(func() {
+		if w.done == nil {
+			return
+		}
+		close(w.stopping)
+		select {
+		case <-w.done:
+		case <-time.After(timeout):
+			err = fmt.Errorf("%w: %s after %s with %d items remaining", ErrWorkerDrainTimeout, w.name, timeout, w.Remaining())
+			w.cancel()
+			<-w.done
+		}
+		w.cancel()
+	})
+	return err
+}
+
+// DrainWorkers drains all workers concurrently, each with its own timeout, logging what each still has in
+// flight and how it stopped.
+func DrainWorkers(workers []BackgroundWorker, timeout time.Duration) {
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker BackgroundWorker) {
+			defer wg.Done()
+			log.Printf("Draining worker %s with %d items remaining", worker.Name(), worker.Remaining())
+			if err := worker.Drain(timeout); err != nil {
+				log.Printf("Force-stopped worker: %v", err)
+				return
+			}
+			log.Printf("Worker %s drained", worker.Name())
+		}(worker)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorker(t *testing.T) {
+	t.Run("Processes Batches Until Drained", func(t *testing.T) {
+		var processed int32
+		worker := NewWorker("test", 5*time.Millisecond,
+			func(ctx context.Context) ([]int, error) { return []int{1, 2}, nil },
+			func(ctx context.Context, item int) error {
+				atomic.AddInt32(&processed, 1)
+				return nil
+			})
+		worker.Start()
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&processed) >= 4 }, time.Second, time.Millisecond)
+
+		assert.NoError(t, worker.Drain(time.Second))
+		drained := atomic.LoadInt32(&processed)
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, drained, atomic.LoadInt32(&processed))
+	})
+
+	t.Run("Finishes Current Item And Skips The Rest", func(t *testing.T) {
+		started := make(chan struct{}, 3)
+		release := make(chan struct{})
+		var finished int32
+		worker := NewWorker("test", time.Millisecond,
+			func(ctx context.Context) ([]int, error) { return []int{1, 2, 3}, nil },
+			func(ctx context.Context, item int) error {
+				started <- struct{}{}
+				<-release
+				atomic.AddInt32(&finished, 1)
+				return nil
+			})
+		worker.Start()
+		<-started
+		assert.Equal(t, 3, worker.Remaining())
+
+		drained := make(chan error, 1)
+		go func() { drained <- worker.Drain(time.Second) }()
+		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-drained:
+			t.Fatal("Drain returned before the current item finished")
+		default:
+		}
+
+		close(release)
+		assert.NoError(t, <-drained)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&finished))
+		assert.Equal(t, 0, worker.Remaining())
+	})
+
+	t.Run("Over-Long Item Is Force-Stopped At Timeout", func(t *testing.T) {
+		started := make(chan struct{})
+		var canceled int32
+		worker := NewWorker("test", time.Millisecond,
+			func(ctx context.Context) ([]int, error) { return []int{1}, nil },
+			func(ctx context.Context, item int) error {
+				close(started)
+				<-ctx.Done()
+				atomic.StoreInt32(&canceled, 1)
+				return ctx.Err()
+			})
+		worker.Start()
+		<-started
+
+		begin := time.Now()
+		err := worker.Drain(20 * time.Millisecond)
+		assert.True(t, errors.Is(err, ErrWorkerDrainTimeout))
+		assert.Contains(t, err.Error(), "1 items remaining")
+		assert.Less(t, time.Since(begin), time.Second)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&canceled))
+		assert.NoError(t, worker.Drain(time.Second))
+	})
+
+	t.Run("Drain Without Start", func(t *testing.T) {
+		worker := NewWorker("test", time.Second,
+			func(ctx context.Context) ([]int, error) { return nil, nil },
+			func(ctx context.Context, item int) error { return nil })
+		assert.NoError(t, worker.Drain(time.Millisecond))
+	})
+}